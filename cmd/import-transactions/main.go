@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/ingest"
+	"loyalty-points-system-api/internal/ledger"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// import-transactions loads a merchant's end-of-day CSV or JSONL file. Running it
+// again on the same file resumes after the last committed chunk.
+func main() {
+	loader := config.RegisterFlags(flag.CommandLine)
+	file := flag.String("file", "", "CSV or JSONL file of transactions (required)")
+	formatFlag := flag.String("format", "", "csv or jsonl (defaults to the file extension)")
+	chunkSize := flag.Int("chunk-size", 0, "rows committed per database transaction (defaults to IMPORT_CHUNK_SIZE)")
+	reportPath := flag.String("report", "", "write per-row results as JSON lines to this file")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *formatFlag == "" {
+		*formatFlag = strings.TrimPrefix(filepath.Ext(*file), ".")
+	}
+	format, err := ingest.ParseFormat(*formatFlag)
+	if err != nil {
+		log.Fatalf("Invalid format: %v", err)
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	expiryRules, _ := ledger.ExpiryRulesFromConfig(cfg)
+	ledger.SetExpiryRules(expiryRules)
+	if *chunkSize <= 0 {
+		*chunkSize = cfg.ImportChunkSize
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open import file: %v", err)
+	}
+	defer f.Close()
+
+	db := config.ConnectDB(cfg)
+	defer db.Close()
+
+	report, err := ingest.Import(context.Background(), db, f, format, ingest.Options{
+		Source:    filepath.Base(*file),
+		CreatedBy: "cli",
+		ChunkSize: *chunkSize,
+	})
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		log.Printf("Run the same command again to resume.")
+		os.Exit(1)
+	}
+
+	if *reportPath != "" {
+		out, err := os.Create(*reportPath)
+		if err != nil {
+			log.Fatalf("Failed to create report file: %v", err)
+		}
+		defer out.Close()
+		enc := json.NewEncoder(out)
+		for _, res := range report.Rows {
+			if err := enc.Encode(res); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+		}
+	}
+
+	log.Printf("Import job %d finished (resumed: %t): %d rows, %d accepted, %d duplicate, %d rejected",
+		report.JobID, report.Resumed, report.Total, report.Accepted, report.Duplicates, report.Rejected)
+}
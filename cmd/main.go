@@ -1,78 +1,299 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/grpcserver"
 	"loyalty-points-system-api/internal/handlers"
+	"loyalty-points-system-api/internal/health"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/metrics"
+	"loyalty-points-system-api/internal/notify"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/routes"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/internal/tracing"
+	"loyalty-points-system-api/internal/utils"
 	"loyalty-points-system-api/pkg/middleware"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/robfig/cron/v3" // For scheduling the points expiration service
+	"google.golang.org/grpc"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.LoadConfig("dev")
+	// run returns instead of exiting so its cleanup always happens
+	if err := run(); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+func run() error {
+	// Load configuration: defaults, then config/env/<env>.env, then env vars, then flags
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := loader.Load()
+	if err != nil {
+		return err
+	}
+
+	// Log structured lines at the configured level; log.Printf output from
+	// libraries goes through the same handler
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	// Record spans for requests, database calls and jobs, and continue the
+	// callers' W3C trace context
+	flushTraces, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:      cfg.TraceExporter,
+		OTLPEndpoint:  cfg.OTLPEndpoint,
+		FilePath:      cfg.TraceFile,
+		SamplePercent: cfg.TraceSamplePercent,
+		Environment:   cfg.Env,
+		Instance:      cfg.InstanceID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Tokens and cursors are signed so clients cannot forge them
+	utils.ConfigureTokens([]byte(cfg.JWTSecret), cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	pagination.SetSigningKey([]byte(cfg.JWTSecret))
+
+	// Validate already parsed the expiry policies
+	expiryRules, _ := ledger.ExpiryRulesFromConfig(cfg)
+	ledger.SetExpiryRules(expiryRules)
+	service.SetTransferLimits(service.TransferLimitsFromConfig(cfg))
+	service.SetHouseholdSettings(service.HouseholdSettingsFromConfig(cfg))
+	service.SetHoldTTL(cfg.HoldTTL)
+	service.SetDefaultCurrency(cfg.DefaultCurrency)
 
 	// Connect to the database
 	db := config.ConnectDB(cfg)
-	defer db.Close()
 
-	// Set up the cron job for points expiration
-	c := cron.New()
-	_, err := c.AddFunc("@daily", func() {
-		handlers.ExpirePoints(db)
+	// Export pool statistics and business events on /metrics
+	metrics.RegisterDB(db, cfg.DBName)
+	ledger.SetHooks(ledger.Hooks{
+		PointsEarned:      metrics.PointsEarned,
+		PointsRedeemed:    metrics.PointsRedeemed,
+		PointsExpired:     metrics.PointsExpired,
+		PointsTransferred: metrics.PointsTransferred,
 	})
+	service.SetHooks(service.Hooks{LoginFailed: metrics.LoginFailed})
+
+	scheduler, err := scheduleJobs(db, cfg)
 	if err != nil {
-		log.Fatalf("Failed to schedule expiration job: %v", err)
+		db.Close()
+		return err
 	}
-	c.Start()
-	defer c.Stop()
 
-	// Set up routes
-	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
-		handlers.LoginHandler(w, r, db, cfg)
-	})
+	// /readyz reports these; /livez checks nothing
+	checker := health.New(cfg.ReadyCheckTimeout)
+	checker.Add("database", health.Database(db))
+	checker.Add("schema", health.Schema(db))
+	if cfg.ExpirationSchedule != jobs.Disabled {
+		checker.Add("expiration_job", health.JobFreshness(scheduler, "expire_points", cfg.ReadyExpirationMaxAge))
+	}
+	checker.Add("outbox", health.Outbox(db, cfg.ReadyOutboxMax))
 
-	http.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
-		handlers.RefreshTokenHandler(w, r, db, cfg)
-	})
+	// Set up routes; the table is shared with the OpenAPI spec tests
+	mux := http.NewServeMux()
+	routes.Register(mux, routes.Table(db, cfg, scheduler, checker), rateLimiter(db, cfg))
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.AppPort,
+		Handler:           mux,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
 
-	http.HandleFunc("/health", handlers.HealthCheckHandler)
+	// Serve the gRPC API on its own port, sharing the service layer with REST
+	grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to listen on gRPC port %s: %w", cfg.GRPCPort, err)
+	}
+	grpcServer := grpcserver.New(db)
 
-	http.HandleFunc("/create-user", func(w http.ResponseWriter, r *http.Request) {
-		handlers.CreateUserHandler(w, r, db)
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// Add Transaction API route with middleware
-	http.Handle("/add-transaction", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handlers.AddTransactionHandler(w, r, db)
-	})))
+	scheduler.Start()
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("Starting HTTP server", "port", cfg.AppPort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("server failed: %w", err)
+		}
+	}()
+	go func() {
+		slog.Info("Starting gRPC server", "port", cfg.GRPCPort)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			serveErr <- fmt.Errorf("gRPC server failed: %w", err)
+		}
+	}()
 
-	// Points Balance API route with middleware
-	http.Handle("/points-balance", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handlers.PointsBalanceHandler(w, r, db)
-	})))
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining in-flight work")
+	case runErr = <-serveErr:
+		slog.Error("Shutting down after a server failure", "err", runErr)
+	}
+	// A second signal kills the process without waiting for the drain
+	stop()
 
-	// Redeem Points API route with middleware
-	http.Handle("/redeem", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handlers.RedeemPointsHandler(w, r, db)
-	})))
+	// Fail /readyz while still serving, so load balancers stop routing here before
+	// the listeners close
+	checker.SetShuttingDown()
+	if runErr == nil && cfg.ShutdownDrainDelay > 0 {
+		slog.Info("Readiness failing, waiting before shutdown", "delay", cfg.ShutdownDrainDelay)
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
 
-	// Points History API route with middleware
-	http.Handle("/points-history", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handlers.PointsHistoryHandler(w, r, db)
-	})))
+	if err := shutdown(cfg.ShutdownTimeout, httpServer, grpcServer, scheduler, flushTraces, db); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
 
-	http.HandleFunc("/get-all-users", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetAllUsersHandler(w, r, db)
-	})
-	// Start the server
-	log.Printf("Starting server on port %s...", cfg.AppPort)
-	err = http.ListenAndServe(":"+cfg.AppPort, nil)
+// rateLimiter throttles clients per route, or returns nil when rate limiting is
+// off. The mysql backend shares each client's budget across replicas.
+func rateLimiter(db *sql.DB, cfg *config.Config) *middleware.RateLimiter {
+	if cfg.RateLimitBackend == "off" {
+		return nil
+	}
+	// Validate already parsed the options
+	opts, _ := middleware.RateLimitOptionsFromConfig(cfg)
+	var store middleware.RateStore = middleware.NewMemoryRateStore()
+	if cfg.RateLimitBackend == "mysql" {
+		store = middleware.NewMySQLRateStore(db)
+	}
+	return middleware.NewRateLimiter(store, opts)
+}
+
+// scheduleJobs registers the background jobs. The scheduler is not started yet.
+// Every replica schedules every job; the scheduler makes sure each run happens once.
+func scheduleJobs(db *sql.DB, cfg *config.Config) (*jobs.Scheduler, error) {
+	// Warn members about points that expire within the configured horizons
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := notify.LoadTemplates(cfg.NotifyTemplateDir)
 	if err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+		return nil, err
+	}
+	horizons, _ := cfg.WarningHorizons()
+	warner := &notify.ExpiryWarner{DB: db, Notifier: notifier, Templates: templates, Horizons: horizons}
+
+	scheduler := jobs.New(db, cfg.InstanceID)
+	scheduler.Observe(metrics.ObserveJob)
+	for _, job := range []jobs.Job{
+		{Name: "expire_points", Schedule: cfg.ExpirationSchedule, Run: func(ctx context.Context, dryRun bool) (int64, error) {
+			return handlers.ExpirePoints(ctx, db, dryRun)
+		}},
+		{Name: "expiry_warnings", Schedule: cfg.ExpiryWarningSchedule, Run: func(ctx context.Context, dryRun bool) (int64, error) {
+			report, err := warner.Run(ctx, dryRun)
+			logging.FromContext(ctx).Info("Expiry warnings sent",
+				"members", report.Members, "sent", report.Sent, "already_notified", report.Skipped, "failed", report.Failed)
+			return int64(report.Sent), err
+		}},
+		// Sign the audit chain head periodically so rewrites can be detected
+		{Name: "audit_checkpoint", Schedule: cfg.AuditCheckpointSchedule, Run: func(ctx context.Context, dryRun bool) (int64, error) {
+			return utils.CreateAuditCheckpoint(ctx, db, []byte(cfg.AuditSigningKey), dryRun)
+		}},
+		// Stored responses for Idempotency-Key retries are kept for IdempotencyKeyTTL
+		{Name: "idempotency_purge", Schedule: cfg.IdempotencyPurgeSchedule, Run: func(ctx context.Context, dryRun bool) (int64, error) {
+			return middleware.PurgeIdempotencyKeys(ctx, db, cfg.IdempotencyKeyTTL, dryRun)
+		}},
+		// Lapsed holds already stop reserving points; this records them as expired
+		{Name: "hold_expiry", Schedule: cfg.HoldExpirySchedule, Run: func(ctx context.Context, dryRun bool) (int64, error) {
+			return service.ExpireHolds(ctx, db, dryRun)
+		}},
+	} {
+		if err := scheduler.Add(job); err != nil {
+			return nil, err
+		}
+	}
+	// Shared rate limit buckets idle long enough to be full again are dropped
+	if cfg.RateLimitBackend == "mysql" {
+		opts, _ := middleware.RateLimitOptionsFromConfig(cfg)
+		err := scheduler.Add(jobs.Job{Name: "rate_limit_purge", Schedule: cfg.RateLimitPurgeSchedule, Run: func(ctx context.Context, dryRun bool) (int64, error) {
+			return middleware.PurgeRateLimitBuckets(ctx, db, opts.Policies.MaxFillTime(), dryRun)
+		}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scheduler, nil
+}
+
+// shutdown stops both servers after their in-flight requests finish, waits for
+// running cron jobs and pending audit writes, flushes buffered spans, then closes
+// the database pool. Steps still running at the deadline are abandoned and the
+// deadline error is returned.
+func shutdown(timeout time.Duration, httpServer *http.Server, grpcServer *grpc.Server, scheduler *jobs.Scheduler,
+	flushTraces func(context.Context) error, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("Shutdown: HTTP requests still running at the deadline", "err", err)
+		httpServer.Close()
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		slog.Warn("Shutdown: gRPC calls still running at the deadline")
+		grpcServer.Stop()
+	}
+
+	// Stop prevents new runs and its context is done once running jobs return
+	select {
+	case <-scheduler.Stop().Done():
+	case <-ctx.Done():
+		slog.Warn("Shutdown: cron jobs still running at the deadline")
+	}
+
+	if err := utils.FlushAuditLog(ctx); err != nil {
+		slog.Warn("Shutdown: audit writes still pending at the deadline", "err", err)
+	}
+	if err := flushTraces(ctx); err != nil {
+		slog.Warn("Shutdown: exporting spans", "err", err)
+	}
+
+	if err := db.Close(); err != nil {
+		slog.Error("Shutdown: closing database", "err", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("shutdown did not finish within %s: %w", timeout, err)
 	}
+	slog.Info("Shutdown complete")
+	return nil
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/utils"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// verify-audit walks the audit_log hash chain and reports the first broken link.
+func main() {
+	loader := config.RegisterFlags(flag.CommandLine)
+	export := flag.String("export-checkpoints", "", "write signed checkpoints as JSON lines to this file ('-' for stdout)")
+	checkpoint := flag.Bool("checkpoint", false, "sign the current chain head before verifying")
+	flag.Parse()
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	db := config.ConnectDB(cfg)
+	defer db.Close()
+
+	signingKey := []byte(cfg.AuditSigningKey)
+
+	if *checkpoint {
+		if _, err := utils.CreateAuditCheckpoint(context.Background(), db, signingKey, false); err != nil {
+			log.Fatalf("Failed to write audit checkpoint: %v", err)
+		}
+	}
+
+	result, err := utils.VerifyAuditChain(db, signingKey)
+	if err != nil {
+		log.Fatalf("Failed to verify audit chain: %v", err)
+	}
+
+	log.Printf("Verified %d chained audit entries (%d legacy entries without hashes), %d checkpoints",
+		result.Checked, result.Legacy, result.Checkpoints)
+
+	if *export != "" {
+		out := os.Stdout
+		if *export != "-" {
+			f, err := os.Create(*export)
+			if err != nil {
+				log.Fatalf("Failed to create export file: %v", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		n, err := utils.ExportAuditCheckpoints(db, out)
+		if err != nil {
+			log.Fatalf("Failed to export checkpoints: %v", err)
+		}
+		log.Printf("Exported %d checkpoints", n)
+	}
+
+	if result.Break != nil {
+		log.Printf("Audit chain BROKEN at audit %d: %s", result.Break.AuditID, result.Break.Reason)
+		os.Exit(1)
+	}
+	if result.CheckpointErr != "" {
+		log.Printf("Audit checkpoint verification FAILED: %s", result.CheckpointErr)
+		os.Exit(1)
+	}
+	log.Printf("Audit chain intact up to audit %d (%s)", result.LastAuditID, result.LastHash)
+}
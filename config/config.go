@@ -1,19 +1,40 @@
+// Package config loads the service configuration in layers: built-in defaults,
+// then config/env/<env>.env, then environment variables, then command-line flags.
+// The environment is chosen with --env or APP_ENV and defaults to dev.
 package config
 
 import (
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/tracing"
+	"loyalty-points-system-api/internal/validation"
+
 	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
 )
 
+// ScheduleOff is the schedule of a job that only runs when an admin triggers it.
+const ScheduleOff = "off"
+
 type Config struct {
+	Env                  string
 	AppPort              string
+	GRPCPort             string
 	DBHost               string
 	DBPort               string
 	DBUser               string
@@ -21,51 +42,507 @@ type Config struct {
 	DBName               string
 	JWTSecret            string
 	PointsExpirationDays int
+	AuditSigningKey      string
+	ImportChunkSize      int
+
+	// Expiry policy specs, see ledger.ParseExpiryPolicy
+	ExpiryPolicy       string
+	ExpiryRulePolicies string
+	ExpiryTierPolicies string
+
+	// Database pool and driver timeouts
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	DBDialTimeout     time.Duration
+	DBReadTimeout     time.Duration
+	DBWriteTimeout    time.Duration
+
+	// Token lifetimes
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// HTTP server timeouts and the overall deadline for graceful shutdown
+	ReadTimeout        time.Duration
+	ReadHeaderTimeout  time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	ShutdownTimeout    time.Duration
+	ShutdownDrainDelay time.Duration // /readyz fails this long before shutdown starts
+
+	// Readiness: per-check timeout, how stale the last successful expiration run
+	// may be, and how much undelivered work the outbox may hold
+	ReadyCheckTimeout     time.Duration
+	ReadyExpirationMaxAge time.Duration
+	ReadyOutboxMax        int
+
+	InstanceID string // names this replica in job_runs
+
+	LogLevel  string // debug, info, warn or error
+	LogFormat string // json or text
+
+	TraceExporter      string // none, otlp, stdout or file
+	OTLPEndpoint       string // OTLP gRPC receiver for the otlp exporter
+	TraceFile          string // file the file exporter appends to
+	TraceSamplePercent int    // share of new traces recorded, 0 to 100
+
+	// Cron schedules, in standard five-field syntax or descriptors such as @daily,
+	// or "off" for jobs that only run when an admin triggers them
+	ExpirationSchedule       string
+	AuditCheckpointSchedule  string
+	IdempotencyPurgeSchedule string
+	IdempotencyKeyTTL        time.Duration
+
+	// Pre-expiration warnings
+	ExpiryWarningSchedule string
+	ExpiryWarningHorizons string // comma-separated days, e.g. 30,7,1
+	NotifyChannel         string // log, file, smtp or webhook
+	NotifyFilePath        string
+	NotifySMTPAddr        string
+	NotifySMTPFrom        string
+	NotifySMTPDomain      string
+	NotifyWebhookURL      string
+	NotifyTemplateDir     string
+
+	// Points transfers between members
+	TransferDailyMax   int    // points a member may send per UTC day, 0 for no cap
+	TransferMinBalance int    // points the sender must keep
+	TransferTiers      string // comma-separated tiers allowed to transfer, empty for all
+
+	// Household pools
+	HouseholdInviteTTL  time.Duration
+	HouseholdMaxMembers int // head included
+
+	// Two-phase points holds
+	HoldTTL            time.Duration
+	HoldExpirySchedule string
+
+	// Points as payment
+	DefaultCurrency string // currency of purchases and quotes that do not name one
+
+	// Rate limiting
+	RateLimitBackend        string // off, memory or mysql
+	RateLimitPolicies       string // e.g. default=20/1s:40,POST /login=5/1m
+	RateLimitAPIKeys        string // client=sha256 hex of its key, comma-separated
+	RateLimitTrustedProxies string // CIDRs whose X-Forwarded-For is believed
+	RateLimitPurgeSchedule  string
 }
 
-func LoadConfig(env string) *Config {
-	err := godotenv.Load("config/env/" + env + ".env")
-	if err != nil {
-		log.Fatalf("Error loading %s environment file: %v", env, err)
+// setting is one configuration key. def is parsed like any other layer, so
+// defaults go through the same checks as user input.
+type setting struct {
+	key  string
+	def  string
+	help string
+	dest interface{} // *string, *int or *time.Duration
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"APP_PORT", "8080", "HTTP port", &c.AppPort},
+		{"GRPC_PORT", "9090", "gRPC port", &c.GRPCPort},
+		{"DB_HOST", "localhost", "MySQL host", &c.DBHost},
+		{"DB_PORT", "3306", "MySQL port", &c.DBPort},
+		{"DB_USER", "root", "MySQL user", &c.DBUser},
+		{"DB_PASSWORD", "", "MySQL password", &c.DBPassword},
+		{"DB_NAME", "loyalty_db", "MySQL database", &c.DBName},
+		{"DB_MAX_OPEN_CONNS", "10", "maximum open database connections", &c.DBMaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", "5", "maximum idle database connections", &c.DBMaxIdleConns},
+		{"DB_CONN_MAX_LIFETIME", "1m", "maximum lifetime of a database connection", &c.DBConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", "30s", "maximum idle time of a database connection", &c.DBConnMaxIdleTime},
+		{"DB_DIAL_TIMEOUT", "30s", "database connect timeout", &c.DBDialTimeout},
+		{"DB_READ_TIMEOUT", "30s", "database read timeout", &c.DBReadTimeout},
+		{"DB_WRITE_TIMEOUT", "30s", "database write timeout", &c.DBWriteTimeout},
+		{"JWT_SECRET", "", "key for signing tokens and pagination cursors", &c.JWTSecret},
+		{"ACCESS_TOKEN_TTL", "15m", "access token lifetime", &c.AccessTokenTTL},
+		{"REFRESH_TOKEN_TTL", "168h", "refresh token lifetime", &c.RefreshTokenTTL},
+		{"POINTS_EXPIRATION_DAYS", "365", "days before earned points expire", &c.PointsExpirationDays},
+		{"EXPIRY_POLICY", "fixed_days", "default expiry policy, e.g. fixed_days, rolling:180 or end_of_year", &c.ExpiryPolicy},
+		{"EXPIRY_POLICY_RULES", "", "expiry policies per earning rule, e.g. groceries=end_of_quarter", &c.ExpiryRulePolicies},
+		{"EXPIRY_POLICY_TIERS", "", "expiry policies per member tier, e.g. gold=rolling:365,vip=never", &c.ExpiryTierPolicies},
+		{"AUDIT_SIGNING_KEY", "", "key for audit checkpoints (defaults to JWT_SECRET)", &c.AuditSigningKey},
+		{"IMPORT_CHUNK_SIZE", "500", "rows committed per batch import transaction", &c.ImportChunkSize},
+		{"HTTP_READ_TIMEOUT", "30s", "HTTP read timeout", &c.ReadTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", "5s", "HTTP read header timeout", &c.ReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", "60s", "HTTP write timeout", &c.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "120s", "HTTP keep-alive idle timeout", &c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", "30s", "deadline for graceful shutdown", &c.ShutdownTimeout},
+		{"SHUTDOWN_DRAIN_DELAY", "0s", "how long /readyz fails before the servers stop accepting requests", &c.ShutdownDrainDelay},
+		{"READY_CHECK_TIMEOUT", "2s", "timeout of each readiness check", &c.ReadyCheckTimeout},
+		{"READY_EXPIRATION_MAX_AGE", "48h", "readiness fails when points expiration has not succeeded for this long", &c.ReadyExpirationMaxAge},
+		{"READY_OUTBOX_MAX", "1000", "readiness fails when more audit writes and notifications than this are pending", &c.ReadyOutboxMax},
+		{"INSTANCE_ID", "", "replica name recorded with job runs (defaults to host:pid)", &c.InstanceID},
+		{"LOG_LEVEL", "info", "minimum log level: debug, info, warn or error", &c.LogLevel},
+		{"LOG_FORMAT", "json", "log output format: json or text", &c.LogFormat},
+		{"TRACE_EXPORTER", "none", "trace exporter: none, otlp, stdout or file", &c.TraceExporter},
+		{"OTLP_ENDPOINT", "http://localhost:4317", "OTLP gRPC receiver for the otlp exporter; http:// disables TLS", &c.OTLPEndpoint},
+		{"TRACE_FILE", "traces.jsonl", "file the file exporter appends spans to", &c.TraceFile},
+		{"TRACE_SAMPLE_PERCENT", "100", "percentage of new traces recorded", &c.TraceSamplePercent},
+		{"EXPIRATION_SCHEDULE", "@daily", "cron schedule for points expiration", &c.ExpirationSchedule},
+		{"AUDIT_CHECKPOINT_SCHEDULE", "@hourly", "cron schedule for audit checkpoints", &c.AuditCheckpointSchedule},
+		{"IDEMPOTENCY_PURGE_SCHEDULE", "@hourly", "cron schedule for purging idempotency keys", &c.IdempotencyPurgeSchedule},
+		{"IDEMPOTENCY_KEY_TTL", "24h", "how long idempotent responses are kept", &c.IdempotencyKeyTTL},
+		{"EXPIRY_WARNING_SCHEDULE", "@daily", "cron schedule for pre-expiration warnings", &c.ExpiryWarningSchedule},
+		{"EXPIRY_WARNING_HORIZONS", "30,7,1", "days ahead to warn about expiring points", &c.ExpiryWarningHorizons},
+		{"NOTIFY_CHANNEL", "log", "notification channel: log, file, smtp or webhook", &c.NotifyChannel},
+		{"NOTIFY_FILE_PATH", "notifications.jsonl", "file the file channel appends to", &c.NotifyFilePath},
+		{"NOTIFY_SMTP_ADDR", "localhost:1025", "SMTP server for the smtp channel", &c.NotifySMTPAddr},
+		{"NOTIFY_SMTP_FROM", "loyalty@localhost", "sender address for the smtp channel", &c.NotifySMTPFrom},
+		{"NOTIFY_SMTP_DOMAIN", "localhost", "recipients are <username>@<domain>", &c.NotifySMTPDomain},
+		{"NOTIFY_WEBHOOK_URL", "", "URL the webhook channel posts to", &c.NotifyWebhookURL},
+		{"NOTIFY_TEMPLATE_DIR", "", "directory with expiry_warning_subject.tmpl and expiry_warning_body.tmpl", &c.NotifyTemplateDir},
+		{"TRANSFER_DAILY_MAX", "5000", "points a member may transfer per UTC day (0 for no cap)", &c.TransferDailyMax},
+		{"TRANSFER_MIN_BALANCE", "0", "points a member must keep after a transfer", &c.TransferMinBalance},
+		{"TRANSFER_TIERS", "", "member tiers allowed to send and receive transfers, comma-separated (empty for all)", &c.TransferTiers},
+		{"HOUSEHOLD_INVITE_TTL", "168h", "how long a household invitation can be accepted", &c.HouseholdInviteTTL},
+		{"HOUSEHOLD_MAX_MEMBERS", "6", "members per household, head included", &c.HouseholdMaxMembers},
+		{"HOLD_TTL", "15m", "how long an authorized points hold lasts", &c.HoldTTL},
+		{"HOLD_EXPIRY_SCHEDULE", "*/5 * * * *", "cron schedule for marking lapsed points holds expired", &c.HoldExpirySchedule},
+		{"DEFAULT_CURRENCY", "USD", "currency of purchases and points quotes that do not name one", &c.DefaultCurrency},
+		{"RATE_LIMIT_BACKEND", "memory", "rate limit buckets: off, memory (per replica) or mysql (shared)", &c.RateLimitBackend},
+		{"RATE_LIMIT_POLICIES", defaultRatePolicies, "rate limits per route, e.g. default=20/1s:40,POST /login=5/1m,/livez=off", &c.RateLimitPolicies},
+		{"RATE_LIMIT_API_KEYS", "", "registered API clients as name=<sha256 hex of the key>, comma-separated", &c.RateLimitAPIKeys},
+		{"RATE_LIMIT_TRUSTED_PROXIES", "", "CIDRs of proxies whose X-Forwarded-For is trusted", &c.RateLimitTrustedProxies},
+		{"RATE_LIMIT_PURGE_SCHEDULE", "@hourly", "cron schedule for purging idle rate limit buckets (mysql backend)", &c.RateLimitPurgeSchedule},
+	}
+}
+
+// defaultRatePolicies throttles credential and redemption endpoints hardest and
+// leaves probes and metrics unlimited.
+const defaultRatePolicies = "default=20/1s:40,POST /login=5/1m:10,POST /refresh=10/1m,POST /create-user=5/1m," +
+	"POST /redeem=30/1m,POST /orders=30/1m,POST /transfers=10/1m,GET /get-all-users=60/1m,/health=off,/livez=off,/readyz=off,/metrics=off"
+
+// flagName turns an env key into its flag, e.g. DB_MAX_OPEN_CONNS -> db-max-open-conns.
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// Loader reads the configuration layers. Its flags are registered on the
+// command's own flag set, so commands can mix them with their other flags.
+type Loader struct {
+	fs    *flag.FlagSet
+	env   *string
+	dir   *string
+	flags map[string]*string // env key -> flag value
+}
+
+// RegisterFlags adds --env, --config-dir and one flag per setting to fs. Call
+// Load after fs has been parsed.
+func RegisterFlags(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:    fs,
+		env:   fs.String("env", "", "environment to load: dev, test or prod (defaults to APP_ENV, then dev)"),
+		dir:   fs.String("config-dir", "config/env", "directory holding the <env>.env files"),
+		flags: map[string]*string{},
+	}
+	for _, s := range (&Config{}).settings() {
+		l.flags[s.key] = fs.String(flagName(s.key), "", fmt.Sprintf("%s (env %s, default %q)", s.help, s.key, s.def))
+	}
+	return l
+}
+
+// Load builds and validates the configuration.
+func (l *Loader) Load() (*Config, error) {
+	cfg := &Config{Env: l.envName()}
+	settings := cfg.settings()
+
+	values := map[string]string{}
+	sources := map[string]string{}
+	for _, s := range settings {
+		values[s.key], sources[s.key] = s.def, "default"
+	}
+
+	// The env file is optional so containers can be configured purely from env vars
+	path := filepath.Join(*l.dir, cfg.Env+".env")
+	fileValues, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("config: %s not found, using defaults and environment variables", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("config: reading %s: %w", path, err)
+	}
+	var unknown []string
+	for key, value := range fileValues {
+		if _, ok := values[key]; !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		values[key], sources[key] = value, path
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		log.Printf("config: ignoring unknown keys in %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.key); ok {
+			values[s.key], sources[s.key] = value, "environment"
+		}
+	}
+
+	l.fs.Visit(func(f *flag.Flag) {
+		for key, value := range l.flags {
+			if flagName(key) == f.Name {
+				values[key], sources[key] = *value, "flag --"+f.Name
+			}
+		}
+	})
+
+	var problems []string
+	for _, s := range settings {
+		if err := parseInto(s.dest, values[s.key]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s=%q (from %s): %v", s.key, values[s.key], sources[s.key], err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	// Audit checkpoints are signed with the JWT secret unless a dedicated key is set
+	if cfg.AuditSigningKey == "" {
+		cfg.AuditSigningKey = cfg.JWTSecret
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (l *Loader) envName() string {
+	if *l.env != "" {
+		return *l.env
+	}
+	if env := os.Getenv("APP_ENV"); env != "" {
+		return env
+	}
+	return "dev"
+}
+
+func parseInto(dest interface{}, value string) error {
+	switch d := dest.(type) {
+	case *string:
+		*d = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		*d = n
+	case *time.Duration:
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+		*d = v
+	}
+	return nil
+}
+
+// Check validates settings that another package turns into its own types.
+type Check func(*Config) error
+
+var (
+	checksMu sync.RWMutex
+	checks   []Check
+)
+
+// RegisterCheck adds a check that Validate runs after its own. Packages register
+// the checks for the settings they consume, so config does not import them.
+func RegisterCheck(fn Check) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks = append(checks, fn)
+}
+
+// ValidationError lists every invalid setting, so all of them can be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks ranges and cross-field rules.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	switch c.Env {
+	case "dev", "test", "prod":
+	default:
+		check(false, "APP_ENV must be dev, test or prod, got %q", c.Env)
+	}
+	check(validPort(c.AppPort), "APP_PORT must be a port number, got %q", c.AppPort)
+	check(validPort(c.GRPCPort), "GRPC_PORT must be a port number, got %q", c.GRPCPort)
+	check(c.AppPort != c.GRPCPort, "APP_PORT and GRPC_PORT must differ")
+	check(c.DBHost != "", "DB_HOST is required")
+	check(validPort(c.DBPort), "DB_PORT must be a port number, got %q", c.DBPort)
+	check(c.DBUser != "", "DB_USER is required")
+	check(c.DBName != "", "DB_NAME is required")
+	check(c.DBMaxOpenConns > 0, "DB_MAX_OPEN_CONNS must be positive")
+	check(c.DBMaxIdleConns >= 0 && c.DBMaxIdleConns <= c.DBMaxOpenConns,
+		"DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS (%d)", c.DBMaxOpenConns)
+
+	check(c.JWTSecret != "", "JWT_SECRET is required")
+	if c.Env == "prod" {
+		check(len(c.JWTSecret) >= 32, "JWT_SECRET must be at least 32 bytes in prod")
+		check(c.DBPassword != "", "DB_PASSWORD is required in prod")
+	}
+	check(c.AccessTokenTTL < c.RefreshTokenTTL, "ACCESS_TOKEN_TTL must be shorter than REFRESH_TOKEN_TTL")
+
+	check(c.PointsExpirationDays > 0, "POINTS_EXPIRATION_DAYS must be positive")
+	check(c.ImportChunkSize > 0, "IMPORT_CHUNK_SIZE must be positive")
+	check(validation.IsCurrency(c.DefaultCurrency), "DEFAULT_CURRENCY must be a three-letter currency code such as USD, got %q", c.DefaultCurrency)
+
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime}, {"DB_CONN_MAX_IDLE_TIME", c.DBConnMaxIdleTime},
+		{"DB_DIAL_TIMEOUT", c.DBDialTimeout}, {"DB_READ_TIMEOUT", c.DBReadTimeout}, {"DB_WRITE_TIMEOUT", c.DBWriteTimeout},
+		{"ACCESS_TOKEN_TTL", c.AccessTokenTTL}, {"REFRESH_TOKEN_TTL", c.RefreshTokenTTL},
+		{"HTTP_READ_TIMEOUT", c.ReadTimeout}, {"HTTP_READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.WriteTimeout}, {"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout}, {"IDEMPOTENCY_KEY_TTL", c.IdempotencyKeyTTL}, {"HOLD_TTL", c.HoldTTL},
+		{"READY_CHECK_TIMEOUT", c.ReadyCheckTimeout}, {"READY_EXPIRATION_MAX_AGE", c.ReadyExpirationMaxAge},
+	}
+	for _, d := range durations {
+		check(d.value > 0, "%s must be positive", d.key)
+	}
+	check(c.ShutdownDrainDelay >= 0, "SHUTDOWN_DRAIN_DELAY must not be negative")
+	check(c.ReadyOutboxMax > 0, "READY_OUTBOX_MAX must be positive")
+
+	schedules := []struct{ key, value string }{
+		{"EXPIRATION_SCHEDULE", c.ExpirationSchedule},
+		{"AUDIT_CHECKPOINT_SCHEDULE", c.AuditCheckpointSchedule},
+		{"IDEMPOTENCY_PURGE_SCHEDULE", c.IdempotencyPurgeSchedule},
+		{"EXPIRY_WARNING_SCHEDULE", c.ExpiryWarningSchedule},
+		{"RATE_LIMIT_PURGE_SCHEDULE", c.RateLimitPurgeSchedule},
+		{"HOLD_EXPIRY_SCHEDULE", c.HoldExpirySchedule},
+	}
+	for _, sched := range schedules {
+		if sched.value == ScheduleOff {
+			continue
+		}
+		_, err := cron.ParseStandard(sched.value)
+		check(err == nil, "%s is not a valid cron schedule: %v", sched.key, err)
 	}
 
-	expirationDays, _ := strconv.Atoi(os.Getenv("POINTS_EXPIRATION_DAYS"))
+	_, err := logging.ParseLevel(c.LogLevel)
+	check(err == nil, "LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	check(c.LogFormat == "json" || c.LogFormat == "text", "LOG_FORMAT must be json or text, got %q", c.LogFormat)
 
-	return &Config{
-		AppPort:              os.Getenv("APP_PORT"),
-		DBHost:               os.Getenv("DB_HOST"),
-		DBPort:               os.Getenv("DB_PORT"),
-		DBUser:               os.Getenv("DB_USER"),
-		DBPassword:           os.Getenv("DB_PASSWORD"),
-		DBName:               os.Getenv("DB_NAME"),
-		JWTSecret:            os.Getenv("JWT_SECRET"),
-		PointsExpirationDays: expirationDays,
+	switch c.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		u, err := url.Parse(c.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"OTLP_ENDPOINT must be an http(s) URL for the otlp exporter, got %q", c.OTLPEndpoint)
+	case tracing.ExporterFile:
+		check(c.TraceFile != "", "TRACE_FILE is required for the file exporter")
+	default:
+		check(false, "TRACE_EXPORTER must be none, otlp, stdout or file, got %q", c.TraceExporter)
 	}
+	check(c.TraceSamplePercent >= 0 && c.TraceSamplePercent <= 100, "TRACE_SAMPLE_PERCENT must be between 0 and 100")
+
+	_, err = c.WarningHorizons()
+	check(err == nil, "%v", err)
+	switch c.NotifyChannel {
+	case "log":
+	case "file":
+		check(c.NotifyFilePath != "", "NOTIFY_FILE_PATH is required for the file channel")
+	case "smtp":
+		check(c.NotifySMTPAddr != "" && c.NotifySMTPFrom != "" && c.NotifySMTPDomain != "",
+			"NOTIFY_SMTP_ADDR, NOTIFY_SMTP_FROM and NOTIFY_SMTP_DOMAIN are required for the smtp channel")
+	case "webhook":
+		u, err := url.Parse(c.NotifyWebhookURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"NOTIFY_WEBHOOK_URL must be an http(s) URL for the webhook channel, got %q", c.NotifyWebhookURL)
+	default:
+		check(false, "NOTIFY_CHANNEL must be log, file, smtp or webhook, got %q", c.NotifyChannel)
+	}
+
+	check(c.TransferDailyMax >= 0, "TRANSFER_DAILY_MAX must not be negative")
+	check(c.TransferMinBalance >= 0, "TRANSFER_MIN_BALANCE must not be negative")
+	check(c.HouseholdInviteTTL > 0, "HOUSEHOLD_INVITE_TTL must be positive")
+	check(c.HouseholdMaxMembers >= 2, "HOUSEHOLD_MAX_MEMBERS must be at least 2")
+
+	switch c.RateLimitBackend {
+	case "off", "memory", "mysql":
+	default:
+		check(false, "RATE_LIMIT_BACKEND must be off, memory or mysql, got %q", c.RateLimitBackend)
+	}
+
+	checksMu.RLock()
+	for _, fn := range checks {
+		if err := fn(c); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	checksMu.RUnlock()
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// WarningHorizons parses EXPIRY_WARNING_HORIZONS. An empty list disables warnings.
+func (c *Config) WarningHorizons() ([]int, error) {
+	var horizons []int
+	seen := map[int]bool{}
+	for _, item := range strings.Split(c.ExpiryWarningHorizons, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		days, err := strconv.Atoi(item)
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("EXPIRY_WARNING_HORIZONS must be positive day counts, got %q", item)
+		}
+		if !seen[days] {
+			seen[days] = true
+			horizons = append(horizons, days)
+		}
+	}
+	return horizons, nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
 }
 
 func ConnectDB(cfg *Config) *sql.DB {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&timeout=30s&readTimeout=30s&writeTimeout=30s",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&timeout=%s&readTimeout=%s&writeTimeout=%s",
 		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName,
+		cfg.DBDialTimeout, cfg.DBReadTimeout, cfg.DBWriteTimeout,
 	)
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := tracing.OpenDB("mysql", dsn)
 	if err != nil {
 		log.Fatalf("Could not connect to the database: %v", err)
 	}
 
-	db.SetMaxOpenConns(10) // Reduced for better stability
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(1 * time.Minute)
-	db.SetConnMaxIdleTime(30 * time.Second)
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
 
 	// Verify connection
 	for i := 0; i < 3; i++ {
 		if err := db.Ping(); err != nil {
-			log.Printf("Failed to ping database (attempt %d/3): %v", i+1, err)
+			slog.Warn("Failed to ping database", "attempt", i+1, "of", 3, "err", err)
 			time.Sleep(time.Second * time.Duration(i+1))
 			continue
 		}
-		log.Println("Connected to the MySQL database successfully.")
+		slog.Info("Connected to the MySQL database", "host", cfg.DBHost, "database", cfg.DBName)
 		return db
 	}
 
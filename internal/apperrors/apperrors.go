@@ -0,0 +1,204 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"loyalty-points-system-api/internal/validation"
+)
+
+// Code is a stable, machine-readable error code returned to clients.
+type Code string
+
+const (
+	CodeBadRequest         Code = "REQUEST_INVALID"
+	CodeInvalidBody        Code = "REQUEST_INVALID_BODY"
+	CodeInvalidParameter   Code = "REQUEST_INVALID_PARAMETER"
+	CodeMissingParameter   Code = "REQUEST_MISSING_PARAMETER"
+	CodeValidationFailed   Code = "VALIDATION_FAILED"
+	CodeMethodNotAllowed   Code = "METHOD_NOT_ALLOWED"
+	CodeRouteNotFound      Code = "ROUTE_NOT_FOUND"
+	CodePaginationInvalid  Code = "PAGINATION_INVALID"
+	CodeFormatUnsupported  Code = "FORMAT_UNSUPPORTED"
+	CodeDatasetUnknown     Code = "EXPORT_DATASET_UNKNOWN"
+	CodeUnauthorized       Code = "AUTH_UNAUTHORIZED"
+	CodeTokenMissing       Code = "AUTH_TOKEN_MISSING"
+	CodeTokenInvalid       Code = "AUTH_TOKEN_INVALID"
+	CodeInvalidCredentials Code = "AUTH_INVALID_CREDENTIALS"
+	CodeForbidden          Code = "ACCESS_FORBIDDEN"
+	CodeUserNotFound       Code = "USER_NOT_FOUND"
+	CodeUserExists         Code = "USER_ALREADY_EXISTS"
+	CodePointsInsufficient Code = "POINTS_INSUFFICIENT"
+	CodeTransferLimit      Code = "TRANSFER_LIMIT_EXCEEDED"
+	CodeTransferNotAllowed Code = "TRANSFER_NOT_ALLOWED"
+	CodeHouseholdNotFound  Code = "HOUSEHOLD_NOT_FOUND"
+	CodeHouseholdMember    Code = "HOUSEHOLD_MEMBERSHIP_EXISTS"
+	CodeHouseholdNoMember  Code = "HOUSEHOLD_MEMBER_NOT_FOUND"
+	CodeHouseholdFull      Code = "HOUSEHOLD_FULL"
+	CodeHouseholdRole      Code = "HOUSEHOLD_PERMISSION_DENIED"
+	CodeInvitationNotFound Code = "HOUSEHOLD_INVITATION_NOT_FOUND"
+	CodeInvitationClosed   Code = "HOUSEHOLD_INVITATION_CLOSED"
+	CodeRewardNotFound     Code = "REWARD_NOT_FOUND"
+	CodeRewardExists       Code = "REWARD_SKU_DUPLICATE"
+	CodeRewardUnavailable  Code = "REWARD_UNAVAILABLE"
+	CodeRewardOutOfStock   Code = "REWARD_OUT_OF_STOCK"
+	CodeRewardTier         Code = "REWARD_TIER_RESTRICTED"
+	CodeOrderNotFound      Code = "ORDER_NOT_FOUND"
+	CodeOrderState         Code = "ORDER_STATE_INVALID"
+	CodeHoldNotFound       Code = "HOLD_NOT_FOUND"
+	CodeHoldState          Code = "HOLD_STATE_INVALID"
+	CodeHoldExpired        Code = "HOLD_EXPIRED"
+	CodeHoldExceeded       Code = "HOLD_CAPTURE_EXCEEDS_AMOUNT"
+	CodeRateNotFound       Code = "CONVERSION_RATE_NOT_FOUND"
+	CodeRateExists         Code = "CONVERSION_RATE_DUPLICATE"
+	CodePaymentExceeded    Code = "POINTS_PAYMENT_EXCEEDS_AMOUNT"
+	CodeCategoryInvalid    Code = "TRANSACTION_CATEGORY_INVALID"
+	CodeTransactionExists  Code = "TRANSACTION_DUPLICATE"
+	CodeImportInterrupted  Code = "IMPORT_INTERRUPTED"
+	CodeIdempotencyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyPending Code = "IDEMPOTENCY_REQUEST_IN_PROGRESS"
+	CodeJobUnknown         Code = "JOB_UNKNOWN"
+	CodeJobRunning         Code = "JOB_ALREADY_RUNNING"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeInternal           Code = "INTERNAL_ERROR"
+)
+
+type entry struct {
+	Status int
+	Title  string
+}
+
+// catalog is the single place error codes are mapped to HTTP statuses and titles.
+var catalog = map[Code]entry{
+	CodeBadRequest:         {http.StatusBadRequest, "Bad Request"},
+	CodeInvalidBody:        {http.StatusBadRequest, "Invalid Request Body"},
+	CodeInvalidParameter:   {http.StatusBadRequest, "Invalid Parameter"},
+	CodeMissingParameter:   {http.StatusBadRequest, "Missing Parameter"},
+	CodeValidationFailed:   {http.StatusUnprocessableEntity, "Validation Failed"},
+	CodeMethodNotAllowed:   {http.StatusMethodNotAllowed, "Method Not Allowed"},
+	CodeRouteNotFound:      {http.StatusNotFound, "Not Found"},
+	CodePaginationInvalid:  {http.StatusBadRequest, "Invalid Pagination Parameter"},
+	CodeFormatUnsupported:  {http.StatusBadRequest, "Invalid Format"},
+	CodeDatasetUnknown:     {http.StatusNotFound, "Unknown Dataset"},
+	CodeUnauthorized:       {http.StatusUnauthorized, "Unauthorized"},
+	CodeTokenMissing:       {http.StatusUnauthorized, "Unauthorized"},
+	CodeTokenInvalid:       {http.StatusUnauthorized, "Unauthorized"},
+	CodeInvalidCredentials: {http.StatusUnauthorized, "Unauthorized"},
+	CodeForbidden:          {http.StatusForbidden, "Forbidden"},
+	CodeUserNotFound:       {http.StatusNotFound, "User Not Found"},
+	CodeUserExists:         {http.StatusConflict, "Conflict"},
+	CodePointsInsufficient: {http.StatusBadRequest, "Insufficient Points"},
+	CodeTransferLimit:      {http.StatusUnprocessableEntity, "Transfer Limit Exceeded"},
+	CodeTransferNotAllowed: {http.StatusForbidden, "Transfer Not Allowed"},
+	CodeHouseholdNotFound:  {http.StatusNotFound, "Household Not Found"},
+	CodeHouseholdMember:    {http.StatusConflict, "Conflict"},
+	CodeHouseholdNoMember:  {http.StatusNotFound, "Household Member Not Found"},
+	CodeHouseholdFull:      {http.StatusConflict, "Conflict"},
+	CodeHouseholdRole:      {http.StatusForbidden, "Household Permission Denied"},
+	CodeInvitationNotFound: {http.StatusNotFound, "Invitation Not Found"},
+	CodeInvitationClosed:   {http.StatusConflict, "Conflict"},
+	CodeRewardNotFound:     {http.StatusNotFound, "Reward Not Found"},
+	CodeRewardExists:       {http.StatusConflict, "Conflict"},
+	CodeRewardUnavailable:  {http.StatusConflict, "Reward Unavailable"},
+	CodeRewardOutOfStock:   {http.StatusConflict, "Out of Stock"},
+	CodeRewardTier:         {http.StatusForbidden, "Reward Not Available for Tier"},
+	CodeOrderNotFound:      {http.StatusNotFound, "Order Not Found"},
+	CodeOrderState:         {http.StatusConflict, "Conflict"},
+	CodeHoldNotFound:       {http.StatusNotFound, "Hold Not Found"},
+	CodeHoldState:          {http.StatusConflict, "Conflict"},
+	CodeHoldExpired:        {http.StatusConflict, "Hold Expired"},
+	CodeHoldExceeded:       {http.StatusUnprocessableEntity, "Capture Exceeds Hold"},
+	CodeRateNotFound:       {http.StatusUnprocessableEntity, "No Conversion Rate"},
+	CodeRateExists:         {http.StatusConflict, "Conflict"},
+	CodePaymentExceeded:    {http.StatusUnprocessableEntity, "Points Exceed Amount"},
+	CodeCategoryInvalid:    {http.StatusBadRequest, "Invalid Category"},
+	CodeTransactionExists:  {http.StatusConflict, "Conflict"},
+	CodeImportInterrupted:  {http.StatusInternalServerError, "Import Error"},
+	CodeIdempotencyReused:  {http.StatusUnprocessableEntity, "Idempotency Key Reused"},
+	CodeIdempotencyPending: {http.StatusConflict, "Conflict"},
+	CodeJobUnknown:         {http.StatusNotFound, "Unknown Job"},
+	CodeJobRunning:         {http.StatusConflict, "Conflict"},
+	CodeRateLimited:        {http.StatusTooManyRequests, "Too Many Requests"},
+	CodeInternal:           {http.StatusInternalServerError, "Internal Server Error"},
+}
+
+// Codes returns every code in the catalog, sorted.
+func Codes() []Code {
+	codes := make([]Code, 0, len(catalog))
+	for code := range catalog {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Error is a domain error with a stable code. It can wrap the underlying cause.
+type Error struct {
+	Code    Code
+	Details string
+	Fields  []validation.FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Details + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Details
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status for the error's code.
+func (e *Error) Status() int {
+	return HTTPStatus(e.Code)
+}
+
+// Title returns the short human-readable summary for the error's code.
+func (e *Error) Title() string {
+	if c, ok := catalog[e.Code]; ok {
+		return c.Title
+	}
+	return catalog[CodeInternal].Title
+}
+
+// New returns a domain error for code with a client-facing detail message.
+func New(code Code, details string) *Error {
+	return &Error{Code: code, Details: details}
+}
+
+// Wrap returns a domain error for code that keeps err as its cause.
+func Wrap(err error, code Code, details string) *Error {
+	return &Error{Code: code, Details: details, Err: err}
+}
+
+// Validation returns a VALIDATION_FAILED error listing every invalid field.
+func Validation(errs validation.Errors) *Error {
+	return &Error{Code: CodeValidationFailed, Details: "One or more fields are invalid", Fields: errs, Err: errs}
+}
+
+// HTTPStatus maps a code to its HTTP status; unknown codes are internal errors.
+func HTTPStatus(code Code) int {
+	if c, ok := catalog[code]; ok {
+		return c.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// From returns the domain error in err's chain, or an INTERNAL_ERROR wrapping err.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Wrap(err, CodeInternal, "An unexpected error occurred")
+}
+
+// Is reports whether err carries the given code.
+func Is(err error, code Code) bool {
+	var appErr *Error
+	return errors.As(err, &appErr) && appErr.Code == code
+}
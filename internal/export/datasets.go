@@ -0,0 +1,162 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kind tells the writers how to encode a column value.
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindInt
+	KindFloat
+	KindTime
+)
+
+// Column is one exported column.
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// Dataset describes an exportable table.
+type Dataset struct {
+	Name       string
+	Table      string
+	Columns    []Column
+	TimeColumn string // column the date range filter applies to
+}
+
+// Datasets are the tables finance can extract for reconciliation.
+var Datasets = map[string]Dataset{
+	"transactions": {
+		Name:  "transactions",
+		Table: "transactions",
+		Columns: []Column{
+			{"id", KindInt}, {"transaction_id", KindString}, {"user_id", KindInt},
+			{"transaction_amount", KindFloat}, {"category", KindString},
+			{"transaction_date", KindTime}, {"product_code", KindString}, {"points", KindInt},
+		},
+		TimeColumn: "transaction_date",
+	},
+	"points": {
+		Name:  "points",
+		Table: "points",
+		Columns: []Column{
+			{"id", KindInt}, {"user_id", KindInt}, {"transaction_id", KindString},
+			{"points", KindInt}, {"transaction_type", KindString},
+			{"transaction_date", KindTime}, {"valid_until", KindTime}, {"reason", KindString},
+		},
+		TimeColumn: "transaction_date",
+	},
+	"audit_log": {
+		Name:  "audit_log",
+		Table: "audit_log",
+		Columns: []Column{
+			{"id", KindInt}, {"user_id", KindInt}, {"action", KindString},
+			{"details", KindString}, {"created_at", KindTime},
+			{"prev_hash", KindString}, {"row_hash", KindString}, {"request_id", KindString},
+		},
+		TimeColumn: "created_at",
+	},
+}
+
+// Filter narrows an export. Zero values disable a filter; To is exclusive.
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	UserID int
+}
+
+// dateLayouts are accepted for from/to filters.
+var dateLayouts = []string{"2006-01-02", time.RFC3339}
+
+// ParseDate parses a from/to filter value. An empty string returns the zero time.
+func ParseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", s)
+}
+
+// MonthRange returns the [first day, first day of next month) range for "YYYY-MM".
+func MonthRange(month string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q (expected YYYY-MM)", month)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+func (d Dataset) query(f Filter) (string, []interface{}) {
+	names := make([]string, len(d.Columns))
+	for i, c := range d.Columns {
+		names[i] = c.Name
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 1", strings.Join(names, ", "), d.Table)
+	var args []interface{}
+	if !f.From.IsZero() {
+		query += fmt.Sprintf(" AND %s >= ?", d.TimeColumn)
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		query += fmt.Sprintf(" AND %s < ?", d.TimeColumn)
+		args = append(args, f.To)
+	}
+	if f.UserID > 0 {
+		query += " AND user_id = ?"
+		args = append(args, f.UserID)
+	}
+	return query + " ORDER BY id", args
+}
+
+// Run streams every matching row of dataset into w and returns the row count.
+// Rows are read one at a time, so memory use does not grow with the export size.
+func Run(ctx context.Context, db *sql.DB, dataset Dataset, f Filter, w RowWriter) (int, error) {
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return 0, errors.New("from must be before to")
+	}
+
+	query, args := dataset.query(f)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if err := w.WriteHeader(dataset.Columns); err != nil {
+		return 0, err
+	}
+
+	values := make([]sql.NullString, len(dataset.Columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		if err := w.WriteRow(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, w.Close()
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an export output encoding.
+type Format string
+
+const (
+	FormatCSV      Format = "csv"
+	FormatJSONL    Format = "jsonl"
+	FormatColumnar Format = "columnar"
+)
+
+// ColumnarRowGroupSize is the number of rows buffered per columnar row group.
+const ColumnarRowGroupSize = 1000
+
+// ParseFormat accepts "csv", "jsonl"/"ndjson" or "columnar".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "csv":
+		return FormatCSV, nil
+	case "jsonl", "ndjson":
+		return FormatJSONL, nil
+	case "columnar":
+		return FormatColumnar, nil
+	}
+	return "", fmt.Errorf("unsupported format %q (expected csv, jsonl or columnar)", s)
+}
+
+// ContentType returns the MIME type served for f.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONL, FormatColumnar:
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// Extension returns the file extension for f.
+func (f Format) Extension() string {
+	switch f {
+	case FormatJSONL:
+		return "jsonl"
+	case FormatColumnar:
+		return "col.jsonl"
+	}
+	return "csv"
+}
+
+// RowWriter encodes exported rows. Close flushes anything still buffered.
+type RowWriter interface {
+	WriteHeader(columns []Column) error
+	WriteRow(values []sql.NullString) error
+	Close() error
+}
+
+// NewWriter returns the RowWriter for format f.
+func NewWriter(f Format, w io.Writer) RowWriter {
+	switch f {
+	case FormatJSONL:
+		return &jsonlWriter{w: bufio.NewWriter(w)}
+	case FormatColumnar:
+		return &columnarWriter{w: bufio.NewWriter(w)}
+	}
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) WriteHeader(columns []Column) error {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	c.record = make([]string, len(columns))
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) WriteRow(values []sql.NullString) error {
+	for i, v := range values {
+		c.record[i] = v.String
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	w       *bufio.Writer
+	columns []Column
+}
+
+func (j *jsonlWriter) WriteHeader(columns []Column) error {
+	j.columns = columns
+	return nil
+}
+
+// WriteRow builds the object by hand so column order is preserved.
+func (j *jsonlWriter) WriteRow(values []sql.NullString) error {
+	j.w.WriteByte('{')
+	for i, col := range j.columns {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		j.w.WriteString(jsonString(col.Name))
+		j.w.WriteByte(':')
+		if err := writeJSONValue(j.w, col.Kind, values[i]); err != nil {
+			return err
+		}
+	}
+	j.w.WriteString("}\n")
+	return nil
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.w.Flush()
+}
+
+// columnarWriter emits a header line describing the schema, then one JSON line per
+// row group holding each column's values as an array. Only one row group is held in
+// memory at a time.
+type columnarWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	groups  int
+	data    [][]sql.NullString
+}
+
+func (c *columnarWriter) WriteHeader(columns []Column) error {
+	c.columns = columns
+	c.data = make([][]sql.NullString, len(columns))
+	for i := range c.data {
+		c.data[i] = make([]sql.NullString, 0, ColumnarRowGroupSize)
+	}
+
+	type schemaColumn struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	schema := struct {
+		Format       string         `json:"format"`
+		Version      int            `json:"version"`
+		RowGroupSize int            `json:"row_group_size"`
+		Columns      []schemaColumn `json:"columns"`
+	}{Format: "loyalty-columnar", Version: 1, RowGroupSize: ColumnarRowGroupSize}
+	for _, col := range columns {
+		schema.Columns = append(schema.Columns, schemaColumn{col.Name, kindName(col.Kind)})
+	}
+	return json.NewEncoder(c.w).Encode(schema)
+}
+
+func (c *columnarWriter) WriteRow(values []sql.NullString) error {
+	for i, v := range values {
+		c.data[i] = append(c.data[i], v)
+	}
+	if len(c.data[0]) == ColumnarRowGroupSize {
+		return c.flushGroup()
+	}
+	return nil
+}
+
+func (c *columnarWriter) flushGroup() error {
+	rows := len(c.data[0])
+	if rows == 0 {
+		return nil
+	}
+	fmt.Fprintf(c.w, `{"row_group":%d,"rows":%d,"columns":{`, c.groups, rows)
+	for i, col := range c.columns {
+		if i > 0 {
+			c.w.WriteByte(',')
+		}
+		c.w.WriteString(jsonString(col.Name))
+		c.w.WriteString(":[")
+		for j, v := range c.data[i] {
+			if j > 0 {
+				c.w.WriteByte(',')
+			}
+			if err := writeJSONValue(c.w, col.Kind, v); err != nil {
+				return err
+			}
+		}
+		c.w.WriteByte(']')
+		c.data[i] = c.data[i][:0]
+	}
+	c.w.WriteString("}}\n")
+	c.groups++
+	return nil
+}
+
+func (c *columnarWriter) Close() error {
+	if c.data != nil {
+		if err := c.flushGroup(); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+func kindName(k Kind) string {
+	switch k {
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindTime:
+		return "timestamp"
+	}
+	return "string"
+}
+
+func writeJSONValue(w *bufio.Writer, kind Kind, v sql.NullString) error {
+	if !v.Valid {
+		_, err := w.WriteString("null")
+		return err
+	}
+	switch kind {
+	case KindInt, KindFloat:
+		// Numbers come straight from MySQL and are already valid JSON
+		_, err := w.WriteString(v.String)
+		return err
+	}
+	_, err := w.WriteString(jsonString(v.String))
+	return err
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
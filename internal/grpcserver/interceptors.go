@@ -0,0 +1,154 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/tracing"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/middleware"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// ErrorDomain is the ErrorInfo domain attached to every error status.
+const ErrorDomain = "loyalty-points-system-api"
+
+// RequestIDMetadata is the metadata key of the request ID, the gRPC counterpart of
+// the X-Request-ID header.
+const RequestIDMetadata = "x-request-id"
+
+// publicMethods do not need an access token, like /create-user, /login and /refresh.
+var publicMethods = map[string]bool{
+	"/loyalty.v1.UserService/CreateUser":   true,
+	"/loyalty.v1.UserService/Login":        true,
+	"/loyalty.v1.UserService/RefreshToken": true,
+}
+
+// RequestIDInterceptor takes the caller's x-request-id metadata, or generates one,
+// sends it back as a response header and puts it in the call context. It must run
+// outermost so every log line and error of the call carries the ID.
+func RequestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if values := md.Get(RequestIDMetadata); len(values) > 0 {
+		id = values[0]
+	}
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, id))
+	ctx = tracing.Correlate(ctx, id)
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logging.FromContext(ctx).Info("Call completed",
+		"method", info.FullMethod, "code", status.Code(err).String(), "duration_ms", time.Since(start).Milliseconds())
+	return resp, err
+}
+
+// AuthInterceptor validates the "authorization: Bearer <token>" metadata with the
+// same JWT rules as middleware.AuthMiddleware and stores the username under
+// middleware.UserIDKey.
+func AuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, apperrors.New(apperrors.CodeTokenMissing, "Missing authorization metadata")
+	}
+
+	tokenParts := strings.Split(values[0], " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		return nil, apperrors.New(apperrors.CodeTokenInvalid, "Invalid authorization metadata format")
+	}
+
+	claims, err := utils.ValidateToken(tokenParts[1])
+	if err != nil {
+		return nil, apperrors.New(apperrors.CodeTokenInvalid, "Invalid or expired token")
+	}
+	if claims.Username == "" {
+		return nil, apperrors.New(apperrors.CodeTokenInvalid, "Invalid token payload")
+	}
+
+	return handler(context.WithValue(ctx, middleware.UserIDKey, claims.Username), req)
+}
+
+// ErrorInterceptor converts apperrors errors into gRPC statuses. It must run
+// outside AuthInterceptor so authentication failures are converted too.
+func ErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+	return nil, toStatus(ctx, info.FullMethod, err)
+}
+
+// toStatus maps err through the apperrors catalog. The stable code is sent as an
+// ErrorInfo reason, the request ID as RequestInfo and field errors as a BadRequest
+// detail.
+func toStatus(ctx context.Context, method string, err error) error {
+	appErr := apperrors.From(err)
+	if appErr.Code == apperrors.CodeInternal || errors.Unwrap(appErr) != nil {
+		logging.FromContext(ctx).Error("gRPC call failed", "method", method, "err", err)
+	}
+
+	st := status.New(grpcCode(appErr.Status()), appErr.Details)
+	details := []protoiface.MessageV1{&errdetails.ErrorInfo{Reason: string(appErr.Code), Domain: ErrorDomain}}
+	if id := logging.RequestID(ctx); id != "" {
+		details = append(details, &errdetails.RequestInfo{RequestId: id})
+	}
+	if len(appErr.Fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range appErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Msg,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// grpcCode maps an HTTP status from the apperrors catalog to a gRPC code.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
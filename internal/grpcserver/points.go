@@ -0,0 +1,64 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	pb "loyalty-points-system-api/pkg/pb/loyaltyv1"
+)
+
+type pointsServer struct {
+	pb.UnimplementedPointsServiceServer
+	db *sql.DB
+}
+
+func (s *pointsServer) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
+	if _, err := callerUsername(ctx); err != nil {
+		return nil, err
+	}
+	userID, err := positiveUserID(req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	page, err := parsePage(req.GetPage(), service.LedgerPageOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, nextCursor, err := service.PointsBalance(ctx, s.db, userID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.GetBalanceResponse{Balance: int32(balance.Balance), NextCursor: nextCursor}
+	for _, entry := range balance.History {
+		resp.History = append(resp.History, &pb.BalanceHistoryEntry{
+			TransactionDate: entry.TransactionDate,
+			Points:          int32(entry.Points),
+			Reason:          entry.Reason,
+		})
+	}
+	return resp, nil
+}
+
+func (s *pointsServer) RedeemPoints(ctx context.Context, req *pb.RedeemPointsRequest) (*pb.RedeemPointsResponse, error) {
+	username, err := callerUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := service.RedeemPoints(ctx, s.db, username, models.RedeemRequest{
+		UserID: int(req.GetUserId()),
+		Points: int(req.GetPoints()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RedeemPointsResponse{
+		RemainingPoints: int32(resp.RemainingPoints),
+		PointsRedeemed:  int32(resp.PointsRedeemed),
+		RedemptionId:    resp.RedemptionID,
+	}, nil
+}
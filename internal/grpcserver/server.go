@@ -0,0 +1,70 @@
+// Package grpcserver exposes the user, points and transaction operations over
+// gRPC. It calls the same internal/service functions as the REST handlers and
+// accepts the same JWT access tokens.
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strconv"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/pkg/middleware"
+	pb "loyalty-points-system-api/pkg/pb/loyaltyv1"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+)
+
+// New returns a gRPC server with every loyalty service registered. Calls are
+// traced, continuing the caller's W3C trace context from the metadata.
+func New(db *sql.DB, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(RequestIDInterceptor, ErrorInterceptor, AuthInterceptor),
+	}, opts...)
+	srv := grpc.NewServer(opts...)
+	pb.RegisterUserServiceServer(srv, &userServer{db: db})
+	pb.RegisterPointsServiceServer(srv, &pointsServer{db: db})
+	pb.RegisterTransactionServiceServer(srv, &transactionServer{db: db})
+	return srv
+}
+
+// parsePage validates a PageRequest with the same rules as the REST limit, sort
+// and cursor query parameters.
+func parsePage(p *pb.PageRequest, opts pagination.Options) (pagination.Params, error) {
+	q := url.Values{}
+	if p.GetLimit() != 0 {
+		q.Set("limit", strconv.Itoa(int(p.GetLimit())))
+	}
+	if p.GetSort() != "" {
+		q.Set("sort", p.GetSort())
+	}
+	if p.GetCursor() != "" {
+		q.Set("cursor", p.GetCursor())
+	}
+	page, err := pagination.Parse(q, opts)
+	if err != nil {
+		return page, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error())
+	}
+	return page, nil
+}
+
+// callerUsername returns the username AuthInterceptor stored in ctx.
+func callerUsername(ctx context.Context) (string, error) {
+	username, ok := ctx.Value(middleware.UserIDKey).(string)
+	if !ok {
+		return "", apperrors.New(apperrors.CodeTokenInvalid, "Failed to extract user information from token")
+	}
+	return username, nil
+}
+
+// positiveUserID checks a user_id field, which proto3 defaults to zero when unset.
+func positiveUserID(id int64) (int, error) {
+	if id < 1 {
+		return 0, apperrors.New(apperrors.CodeMissingParameter, "user_id is required")
+	}
+	return int(id), nil
+}
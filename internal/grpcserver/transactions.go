@@ -0,0 +1,72 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	pb "loyalty-points-system-api/pkg/pb/loyaltyv1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type transactionServer struct {
+	pb.UnimplementedTransactionServiceServer
+	db *sql.DB
+}
+
+func (s *transactionServer) AddTransaction(ctx context.Context, req *pb.AddTransactionRequest) (*pb.AddTransactionResponse, error) {
+	username, err := callerUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := service.AddTransaction(ctx, s.db, username, models.AddTransactionRequest{
+		TransactionID:     req.GetTransactionId(),
+		UserID:            int(req.GetUserId()),
+		TransactionAmount: req.GetTransactionAmount(),
+		Category:          req.GetCategory(),
+		TransactionDate:   req.GetTransactionDate(),
+		ProductCode:       req.GetProductCode(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddTransactionResponse{Points: int32(resp.Points)}, nil
+}
+
+func (s *transactionServer) ListTransactions(ctx context.Context, req *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	username, err := callerUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := positiveUserID(req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	page, err := parsePage(req.GetPage(), service.LedgerPageOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	records, nextCursor, err := service.ListTransactions(ctx, s.db, username, userID, req.GetCategory(), page)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListTransactionsResponse{NextCursor: nextCursor}
+	for _, record := range records {
+		resp.Transactions = append(resp.Transactions, &pb.Transaction{
+			Id:                int64(record.ID),
+			TransactionId:     record.TransactionID,
+			UserId:            int64(record.UserID),
+			TransactionAmount: record.TransactionAmount,
+			Category:          record.Category,
+			TransactionDate:   timestamppb.New(record.TransactionDate),
+			ProductCode:       record.ProductCode,
+			Points:            int32(record.Points),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,45 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	pb "loyalty-points-system-api/pkg/pb/loyaltyv1"
+)
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	db *sql.DB
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	resp, err := service.CreateUser(ctx, s.db, models.CreateUserRequest{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateUserResponse{UserId: resp.UserID}, nil
+}
+
+func (s *userServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	tokens, err := service.Login(ctx, s.db, models.LoginRequest{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LoginResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *userServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	resp, err := service.RefreshToken(ctx, models.RefreshRequest{RefreshToken: req.GetRefreshToken()})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RefreshTokenResponse{AccessToken: resp.AccessToken}, nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"database/sql"
+	"loyalty-points-system-api/internal/apperrors"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/pkg/middleware"
+	"net/http"
+)
+
+// tokenUsername returns the username AuthMiddleware stored in the request context.
+// It writes the error response and returns false when it is missing.
+func tokenUsername(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Failed to extract user information from token"))
+	}
+	return username, ok
+}
+
+// authorizeUserAccess checks that the caller owns userID or is an admin. It writes
+// the error response and returns false when access is denied.
+func authorizeUserAccess(w http.ResponseWriter, r *http.Request, db *sql.DB, userID int) bool {
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return false
+	}
+	if err := service.AuthorizeUserAccess(r.Context(), db, username, userID); err != nil {
+		response.WriteError(w, r, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"database/sql"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+// auditPageOptions are the sort orders offered for the audit log.
+var auditPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"id":         {Column: "id", Kind: pagination.KindInt},
+		"created_at": {Column: "created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "id",
+	DefaultDesc: true,
+}
+
+// AuditLogHandler returns one page of audit_log entries, optionally filtered by
+// user_id, action and request_id, e.g. GET /audit-log?user_id=1&action=Login.
+func AuditLogHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("AuditLogHandler: Starting to process audit log request")
+
+	page, err := pagination.Parse(r.URL.Query(), auditPageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
+	}
+
+	query := `
+		SELECT id, user_id, action, details, created_at, prev_hash, row_hash, request_id
+		FROM audit_log
+		WHERE 1 = 1`
+	var args []interface{}
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "user_id must be an integer"))
+			return
+		}
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	if action := r.URL.Query().Get("action"); action != "" {
+		query += " AND action = ?"
+		args = append(args, action)
+	}
+	if requestID := r.URL.Query().Get("request_id"); requestID != "" {
+		query += " AND request_id = ?"
+		args = append(args, requestID)
+	}
+
+	query, args = page.Apply(query, args)
+	rows, err := db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching audit log", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to fetch audit log"))
+		return
+	}
+	defer rows.Close()
+
+	entries := []utils.AuditEntry{}
+	fetched := 0
+	for rows.Next() {
+		var entry utils.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Action, &entry.Details,
+			&entry.CreatedAt, &entry.PrevHash, &entry.RowHash, &entry.RequestID); err != nil {
+			logging.FromContext(r.Context()).Error("Error scanning audit row", "err", err)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to process audit log"))
+			return
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.FromContext(r.Context()).Error("Error iterating over audit rows", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to process audit log"))
+		return
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := entries[len(entries)-1]
+		var value interface{} = last.ID
+		if page.Sort == "created_at" {
+			value = last.CreatedAt
+		}
+		nextCursor = page.Next(value, int64(last.ID))
+	}
+	response.WritePageResponse(w, entries, nextCursor, "Audit log retrieved successfully")
+}
@@ -4,41 +4,31 @@ import (
 	"database/sql"
 	"encoding/json"
 	"loyalty-points-system-api/config"
-	utils "loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/models"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
 	"net/http"
 )
 
 func RefreshTokenHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, cfg *config.Config) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only POST method is allowed"))
 		return
 	}
 
-	var req struct {
-		RefreshToken string `json:"refresh_token"`
-	}
+	var req models.RefreshRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	claims, err := utils.ValidateToken(req.RefreshToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidBody, "Failed to decode JSON body"))
 		return
 	}
 
-	// Generate a new access token
-	accessToken, err := utils.GenerateAccessToken(claims.Username)
+	resp, err := service.RefreshToken(r.Context(), req)
 	if err != nil {
-		http.Error(w, "Error generating access token", http.StatusInternalServerError)
+		response.WriteError(w, r, err)
 		return
 	}
 
 	// Respond with the new access token
-	response := map[string]string{
-		"access_token": accessToken,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	response.WriteSuccessResponse(w, resp, "Token refreshed successfully")
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ingest"
+	"loyalty-points-system-api/internal/logging"
+	response "loyalty-points-system-api/internal/reponse"
+	utils "loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/middleware"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxBatchBodyBytes caps the size of an uploaded batch file.
+const maxBatchBodyBytes = 64 << 20
+
+// BatchTransactionsHandler imports a CSV or JSONL file of AddTransactionRequest rows
+// and responds with a per-row report. Re-posting the same file resumes an
+// interrupted import instead of starting over.
+func BatchTransactionsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, cfg *config.Config) {
+	logging.FromContext(r.Context()).Debug("BatchTransactionsHandler: Starting to process batch import request")
+
+	if r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only POST method is allowed"))
+		return
+	}
+
+	tokenUsername, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Failed to extract user information from token"))
+		return
+	}
+
+	// Format comes from the query string, falling back to the content type
+	formatParam := r.URL.Query().Get("format")
+	if formatParam == "" {
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			formatParam = "csv"
+		} else {
+			formatParam = "jsonl"
+		}
+	}
+	format, err := ingest.ParseFormat(formatParam)
+	if err != nil {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeFormatUnsupported, err.Error()))
+		return
+	}
+
+	chunkSize := cfg.ImportChunkSize
+	if chunkStr := r.URL.Query().Get("chunk_size"); chunkStr != "" {
+		chunkSize, err = strconv.Atoi(chunkStr)
+		if err != nil || chunkSize < 1 || chunkSize > 10000 {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "chunk_size must be between 1 and 10000"))
+			return
+		}
+	}
+
+	// Spool the body to disk so the file can be checksummed and then streamed
+	spool, err := os.CreateTemp("", "batch-import-*")
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating spool file", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to buffer upload"))
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if _, err := io.Copy(spool, http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)); err != nil {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidBody, "Failed to read upload (maximum 64 MB)"))
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		logging.FromContext(r.Context()).Error("Error rewinding spool file", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to buffer upload"))
+		return
+	}
+
+	// The import keeps going if the client disconnects; it is traced with the request
+	report, err := ingest.Import(context.WithoutCancel(r.Context()), db, spool, format, ingest.Options{
+		Source:    r.URL.Query().Get("source"),
+		CreatedBy: tokenUsername,
+		ChunkSize: chunkSize,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error importing batch", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeImportInterrupted, "Import interrupted; re-send the same file to resume"))
+		return
+	}
+
+	var callerID int
+	if err := db.QueryRowContext(r.Context(), "SELECT id FROM users WHERE username = ?", tokenUsername).Scan(&callerID); err != nil {
+		logging.FromContext(r.Context()).Error("Error fetching importing user", "err", err)
+	}
+	utils.LogAction(r.Context(), db, callerID, "Batch Import",
+		fmt.Sprintf("Import job %d: %d rows, %d accepted, %d duplicate, %d rejected",
+			report.JobID, report.Total, report.Accepted, report.Duplicates, report.Rejected))
+
+	response.WriteSuccessResponse(w, report, "Batch import processed")
+}
@@ -1,76 +1,166 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
 	"loyalty-points-system-api/internal/utils"
 )
 
-// ExpirePoints runs periodically to mark points as expired
-func ExpirePoints(db *sql.DB) {
-	log.Println("Starting points expiration job...")
+// ExpirePoints marks earned points past their valid_until as expired and returns
+// the number of lots expired. Only the part of a lot that was not spent or
+// transferred expires; fully consumed lots are skipped. Expired points are taken
+// off the balance that held them, the member's or the household's, and pool lots
+// are logged against the household. A dry run only counts them.
+func ExpirePoints(ctx context.Context, db *sql.DB, dryRun bool) (int64, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("Starting points expiration job")
 
 	// Start a transaction
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Println("Failed to start transaction:", err)
-		return
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Members are locked before pools and pools before their lots, the order
+	// purchases, redemptions and transfers take them in
+	const expiredWhere = "valid_until < NOW() AND transaction_type = 'Earned' AND points > consumed"
+	heads := map[int]int{}
+	if !dryRun {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id FROM users
+			WHERE id IN (SELECT user_id FROM points WHERE household_id IS NULL AND `+expiredWhere+`)
+			ORDER BY id FOR UPDATE`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lock users: %w", err)
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to lock users: %w", err)
+		}
+
+		rows, err = tx.QueryContext(ctx, `
+			SELECT id, head_user_id FROM households
+			WHERE id IN (SELECT household_id FROM points WHERE household_id IS NOT NULL AND `+expiredWhere+`)
+			ORDER BY id FOR UPDATE`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lock households: %w", err)
+		}
+		for rows.Next() {
+			var id, headID int
+			if err := rows.Scan(&id, &headID); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to scan household row: %w", err)
+			}
+			heads[id] = headID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to lock households: %w", err)
+		}
 	}
 
 	// Find points that have expired
-	rows, err := tx.Query(`
-		SELECT id, user_id, points FROM points
-		WHERE valid_until < NOW() AND transaction_type = 'Earned'
-	`)
+	query := "SELECT id, user_id, household_id, points - consumed FROM points WHERE " + expiredWhere
+	if !dryRun {
+		query += " FOR UPDATE"
+	}
+	rows, err := tx.QueryContext(ctx, query)
 	if err != nil {
-		log.Println("Failed to query expired points:", err)
-		tx.Rollback()
-		return
+		return 0, fmt.Errorf("failed to query expired points: %w", err)
 	}
-	defer rows.Close()
-
-	// Process each expired point entry
+	type expiredLot struct {
+		id, userID, points int
+		householdID        sql.NullInt64
+	}
+	var lots []expiredLot
 	for rows.Next() {
-		var id, userID, expiredPoints int
-		if err := rows.Scan(&id, &userID, &expiredPoints); err != nil {
-			log.Println("Failed to scan expired points row:", err)
-			tx.Rollback()
-			return
+		var lot expiredLot
+		if err := rows.Scan(&lot.id, &lot.userID, &lot.householdID, &lot.points); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired points row: %w", err)
 		}
+		lots = append(lots, lot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read expired points: %w", err)
+	}
+
+	if dryRun {
+		logger.Info("Points expiration dry run", "lots", len(lots))
+		return int64(len(lots)), nil
+	}
 
+	// Process each expired point entry
+	for _, lot := range lots {
 		// Log the expired points in the points table
-		_, err := tx.Exec(`
+		_, err := tx.ExecContext(ctx, `
 			UPDATE points SET transaction_type = 'Expired', reason = 'Expired'
 			WHERE id = ?
-		`, id)
+		`, lot.id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to mark points as expired: %w", err)
+		}
+
+		if lot.householdID.Valid {
+			_, err = tx.ExecContext(ctx, "UPDATE households SET loyalty_points = GREATEST(loyalty_points - ?, 0) WHERE id = ?",
+				lot.points, lot.householdID.Int64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to deduct expired household points: %w", err)
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO expired_points_log (household_id, expired_points)
+				VALUES (?, ?)
+			`, lot.householdID.Int64, lot.points)
+			if err != nil {
+				return 0, fmt.Errorf("failed to log expired points: %w", err)
+			}
+			logger.Debug("Expired household points", "household_id", lot.householdID.Int64, "points", lot.points)
+			continue
+		}
+
+		// Lots earned before spends consumed them can outlast the balance, hence
+		// the floor at zero here and for pools
+		_, err = tx.ExecContext(ctx, "UPDATE users SET loyalty_points = GREATEST(loyalty_points - ?, 0) WHERE id = ?",
+			lot.points, lot.userID)
 		if err != nil {
-			log.Println("Failed to mark points as expired:", err)
-			tx.Rollback()
-			return
+			return 0, fmt.Errorf("failed to deduct expired points: %w", err)
 		}
 
 		// Log the expired points in a separate table
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			INSERT INTO expired_points_log (user_id, expired_points)
 			VALUES (?, ?)
-		`, userID, expiredPoints)
+		`, lot.userID, lot.points)
 		if err != nil {
-			log.Println("Failed to log expired points:", err)
-			tx.Rollback()
-			return
+			return 0, fmt.Errorf("failed to log expired points: %w", err)
 		}
-
-		// Deduct expired points from user's balance (optional if using sum queries)
-		log.Printf("User %d: Expired %d points", userID, expiredPoints)
-		// Log expired points in audit log
-		utils.LogAction(db, userID, "Expire Points", fmt.Sprintf("Expired %d points", expiredPoints))
+		logger.Debug("Expired points", "user_id", lot.userID, "points", lot.points)
 	}
 
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
-		log.Println("Failed to commit transaction:", err)
-		return
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Log expired points in audit log once they are committed. Pool expiries are
+	// recorded under the household's head.
+	for _, lot := range lots {
+		ledger.ReportExpired(lot.points)
+		if lot.householdID.Valid {
+			householdID := int(lot.householdID.Int64)
+			utils.LogAction(ctx, db, heads[householdID], "Expire Household Points",
+				fmt.Sprintf("Expired %d points from household %d", lot.points, householdID))
+			continue
+		}
+		utils.LogAction(ctx, db, lot.userID, "Expire Points", fmt.Sprintf("Expired %d points", lot.points))
 	}
-	log.Println("Points expiration job completed successfully.")
+	logger.Info("Points expiration job completed", "lots", len(lots))
+	return int64(len(lots)), nil
 }
@@ -1,12 +1,39 @@
 package handlers
 
 import (
-	"encoding/json"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/health"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	response "loyalty-points-system-api/internal/reponse"
 	"net/http"
 )
 
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	response := map[string]string{"status": "UP"}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	response.WriteSuccessResponse(w, models.HealthResponse{Status: "UP"}, "Service is healthy")
+}
+
+// LivezHandler reports that the process is serving requests. It checks no
+// dependencies, so a database outage does not get the process restarted.
+func LivezHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteJSON(w, http.StatusOK, models.HealthResponse{Status: health.StatusUp})
+}
+
+// ReadyzHandler reports each readiness check, with 503 when any of them fails or
+// the server is shutting down.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	report := checker.Ready(r.Context())
+	code := http.StatusOK
+	if report.Status != health.StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	if report.Status != health.StatusUp && !report.ShuttingDown {
+		logging.FromContext(r.Context()).Warn("Readiness check failed", "components", report.Components)
+	}
+	response.WriteJSON(w, code, report)
+}
+
+// NotFoundHandler answers every path without a route in the standard error envelope.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "No route for "+r.URL.Path))
 }
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AuthorizeHoldHandler handles POST /holds, reserving points of the caller's
+// balance until the hold is captured or released.
+func AuthorizeHoldHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("AuthorizeHoldHandler: Starting to process hold request")
+
+	if r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only POST method is allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AuthorizeHoldRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	resp, err := service.AuthorizeHold(r.Context(), db, username, req)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, resp, "Hold authorized successfully")
+}
+
+// HoldHandler serves GET /holds/{id} and POST /holds/{id}/capture and
+// /holds/{id}/release. The capture body is optional; without it the whole hold
+// is captured.
+func HoldHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("HoldHandler: Starting to process hold request")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "holds" {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /holds/{id}"))
+		return
+	}
+	holdID, err := strconv.Atoi(parts[1])
+	if err != nil || holdID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected a numeric hold id"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		hold, err := service.GetHold(ctx, db, username, holdID)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, hold, "Hold retrieved successfully")
+
+	case len(parts) == 3 && parts[2] == "capture" && r.Method == http.MethodPost:
+		var req models.CaptureHoldRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			logging.FromContext(ctx).Error("Error decoding request body", "err", err)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidBody, "Failed to decode JSON body"))
+			return
+		}
+		resp, err := service.CaptureHold(ctx, db, username, holdID, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, resp, "Hold captured successfully")
+
+	case len(parts) == 3 && parts[2] == "release" && r.Method == http.MethodPost:
+		resp, err := service.ReleaseHold(ctx, db, username, holdID)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, resp, "Hold released successfully")
+
+	default:
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "No hold endpoint matches "+r.Method+" "+r.URL.Path))
+	}
+}
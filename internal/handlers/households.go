@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CreateHouseholdHandler handles POST /households, creating a household headed by
+// the caller.
+func CreateHouseholdHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("CreateHouseholdHandler: Starting to process create household request")
+
+	if r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only POST method is allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CreateHouseholdRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	household, err := service.CreateHousehold(r.Context(), db, username, req)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, household, "Household created successfully")
+}
+
+// HouseholdHandler serves the /households/ subtree: GET /households/{id} and
+// /households/{id}/history, POST /households/{id}/invitations and
+// /households/{id}/redeem, and PATCH or DELETE /households/{id}/members/{user_id}.
+func HouseholdHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("HouseholdHandler: Starting to process household request")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "households" {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /households/{id}"))
+		return
+	}
+	householdID, err := strconv.Atoi(parts[1])
+	if err != nil || householdID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected a numeric household id"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		household, err := service.GetHousehold(ctx, db, username, householdID)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, household, "Household retrieved successfully")
+
+	case len(parts) == 3 && parts[2] == "history" && r.Method == http.MethodGet:
+		page, err := pagination.Parse(r.URL.Query(), service.HouseholdHistoryPageOptions)
+		if err != nil {
+			response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+			return
+		}
+		history, nextCursor, err := service.HouseholdHistory(ctx, db, username, householdID, page)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WritePageResponse(w, history, nextCursor, "Household history retrieved successfully")
+
+	case len(parts) == 3 && parts[2] == "invitations" && r.Method == http.MethodPost:
+		var req models.InviteMemberRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		invitation, err := service.InviteHouseholdMember(ctx, db, username, householdID, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, invitation, "Invitation sent successfully")
+
+	case len(parts) == 3 && parts[2] == "redeem" && r.Method == http.MethodPost:
+		var req models.HouseholdRedeemRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		resp, err := service.RedeemHouseholdPoints(ctx, db, username, householdID, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, resp, "Household points redeemed successfully")
+
+	case len(parts) == 4 && parts[2] == "members" && (r.Method == http.MethodPatch || r.Method == http.MethodDelete):
+		userID, err := strconv.Atoi(parts[3])
+		if err != nil || userID < 1 {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /households/{id}/members/{user_id}"))
+			return
+		}
+		var household *models.HouseholdResponse
+		message := "Member role updated successfully"
+		if r.Method == http.MethodPatch {
+			var req models.UpdateMemberRoleRequest
+			if !decodeBody(w, r, &req) {
+				return
+			}
+			household, err = service.UpdateHouseholdMemberRole(ctx, db, username, householdID, userID, req)
+		} else {
+			household, err = service.RemoveHouseholdMember(ctx, db, username, householdID, userID)
+			message = "Member removed successfully"
+		}
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, household, message)
+
+	default:
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "No household endpoint matches "+r.Method+" "+r.URL.Path))
+	}
+}
+
+// ListHouseholdInvitationsHandler handles GET /household-invitations, listing the
+// caller's pending invitations.
+func ListHouseholdInvitationsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("ListHouseholdInvitationsHandler: Starting to process invitations request")
+
+	if r.Method != http.MethodGet {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET method is allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+	invitations, err := service.ListHouseholdInvitations(r.Context(), db, username)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, invitations, "Invitations retrieved successfully")
+}
+
+// HouseholdInvitationHandler serves POST /household-invitations/{id}/accept and
+// POST /household-invitations/{id}/decline.
+func HouseholdInvitationHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("HouseholdInvitationHandler: Starting to process invitation response")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "household-invitations" || (parts[2] != "accept" && parts[2] != "decline") {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound,
+			"Expected /household-invitations/{id}/accept or /household-invitations/{id}/decline"))
+		return
+	}
+	invitationID, err := strconv.Atoi(parts[1])
+	if err != nil || invitationID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected a numeric invitation id"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only POST method is allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	if parts[2] == "accept" {
+		household, err := service.AcceptHouseholdInvitation(r.Context(), db, username, invitationID)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, household, "Invitation accepted successfully")
+		return
+	}
+	invitation, err := service.DeclineHouseholdInvitation(r.Context(), db, username, invitationID)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, invitation, "Invitation declined successfully")
+}
+
+// decodeBody decodes the JSON request body into v, writing the error response
+// when it cannot.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		logging.FromContext(r.Context()).Error("Error decoding request body", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidBody, "Failed to decode JSON body"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ListJobsHandler handles GET /jobs. It lists the background jobs with their
+// schedules and latest runs.
+func ListJobsHandler(w http.ResponseWriter, r *http.Request, scheduler *jobs.Scheduler) {
+	logging.FromContext(r.Context()).Debug("ListJobsHandler: Starting to process jobs list request")
+
+	if r.Method != http.MethodGet {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET method is allowed"))
+		return
+	}
+
+	infos, err := scheduler.Jobs(r.Context())
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, infos, "Jobs retrieved successfully")
+}
+
+// JobHandler serves the /jobs/ subtree: GET /jobs/{name}/runs lists a job's run
+// history and POST /jobs/{name}/run runs it now, e.g. POST /jobs/expire_points/run?dry_run=true.
+func JobHandler(w http.ResponseWriter, r *http.Request, scheduler *jobs.Scheduler) {
+	logging.FromContext(r.Context()).Debug("JobHandler: Starting to process job request")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "jobs" || parts[1] == "" {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /jobs/{name}/runs or /jobs/{name}/run"))
+		return
+	}
+	name := parts[1]
+
+	switch {
+	case parts[2] == "runs" && r.Method == http.MethodGet:
+		page, err := pagination.Parse(r.URL.Query(), jobs.RunPageOptions)
+		if err != nil {
+			response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+			return
+		}
+		runs, nextCursor, err := scheduler.Runs(r.Context(), name, page)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WritePageResponse(w, runs, nextCursor, "Job runs retrieved successfully")
+
+	case parts[2] == "run" && r.Method == http.MethodPost:
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "dry_run must be true or false"))
+				return
+			}
+		}
+		username, ok := tokenUsername(w, r)
+		if !ok {
+			return
+		}
+		run, err := scheduler.Trigger(r.Context(), name, username, dryRun)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		message := "Job run succeeded"
+		if run.Status == jobs.StatusFailed {
+			message = "Job run failed"
+		}
+		response.WriteSuccessResponse(w, run, message)
+
+	case parts[2] == "runs" || parts[2] == "run":
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Use GET /jobs/{name}/runs or POST /jobs/{name}/run"))
+
+	default:
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /jobs/{name}/runs or /jobs/{name}/run"))
+	}
+}
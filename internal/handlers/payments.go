@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"net/http"
+	"strconv"
+)
+
+// ConversionRatesHandler serves /conversion-rates: GET lists the rates, filtered
+// by ?currency= and ?tier=, and POST adds one (admin only).
+func ConversionRatesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("ConversionRatesHandler: Starting to process conversion rates request")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET and POST methods are allowed"))
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		username, ok := tokenUsername(w, r)
+		if !ok {
+			return
+		}
+		var req models.ConversionRateRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		rate, err := service.CreateConversionRate(r.Context(), db, username, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, rate, "Conversion rate created successfully")
+		return
+	}
+
+	query := r.URL.Query()
+	page, err := pagination.Parse(query, service.RatePageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
+	}
+	rates, nextCursor, err := service.ListConversionRates(r.Context(), db, query.Get("currency"), query.Get("tier"), page)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WritePageResponse(w, rates, nextCursor, "Conversion rates retrieved successfully")
+}
+
+// PointsQuoteHandler serves GET /points-quote?user_id=&amount=&currency=, pricing
+// an amount in points for the member.
+func PointsQuoteHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("PointsQuoteHandler: Starting to process points quote request")
+
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	for _, name := range []string{"user_id", "amount"} {
+		if query.Get(name) == "" {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeMissingParameter, name+" is required"))
+			return
+		}
+	}
+	req := models.PointsQuoteRequest{Currency: query.Get("currency")}
+	var err error
+	if req.UserID, err = strconv.Atoi(query.Get("user_id")); err != nil || req.UserID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "user_id must be a positive integer"))
+		return
+	}
+	if req.Amount, err = strconv.ParseFloat(query.Get("amount"), 64); err != nil {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "amount must be a number"))
+		return
+	}
+
+	quote, err := service.QuotePoints(r.Context(), db, username, req)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, quote, "Points quote calculated successfully")
+}
@@ -2,108 +2,222 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/json"
-	"log"
+	"fmt"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
 	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
 	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/utils"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// PointsHistoryHandler handles retrieving a user's points history
+// historyPageOptions are the sort orders offered for the unified points history.
+// Entry keys combine the source row id with the entry type, so they are unique
+// across the points and transactions tables.
+var historyPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"occurred_at": {Column: "occurred_at", Kind: pagination.KindTime},
+		"points":      {Column: "points", Kind: pagination.KindInt},
+	},
+	DefaultSort: "occurred_at",
+	DefaultDesc: true,
+	IDColumn:    "entry_key",
+}
+
+// historyTypes maps the accepted type filter values to entry types.
+var historyTypes = map[string]string{
+	"earned":       models.HistoryEarned,
+	"redeemed":     models.HistoryRedeemed,
+	"expired":      models.HistoryExpired,
+	"adjusted":     models.HistoryAdjusted,
+	"transfer_out": models.HistoryTransferOut,
+	"transfer_in":  models.HistoryTransferIn,
+}
+
+// PointsHistoryHandler handles GET /users/{id}/points/history. It merges earned and
+// expired lots from the points table, redemptions and adjustments from the
+// transactions table and transfers from point_transfers into one paginated
+// timeline.
 func PointsHistoryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	log.Println("PointsHistoryHandler: Starting to process points history request.")
-
-	var req models.PointsHistoryRequest
-	if r.Body == nil {
-		log.Println("PointsHistoryHandler: Request body is empty.")
-		response.WriteErrorResponse(w, http.StatusBadRequest, response.APIError{
-			Code:    "400",
-			Msg:     "Empty Request Body",
-			Details: "Request body cannot be empty",
-		})
+	logging.FromContext(r.Context()).Debug("PointsHistoryHandler: Starting to process points history request")
+
+	if r.Method != http.MethodGet {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET method is allowed"))
 		return
 	}
 
-	// Decode request body
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		response.WriteErrorResponse(w, http.StatusBadRequest, response.APIError{
-			Code:    "400",
-			Msg:     "Invalid Request Payload",
-			Details: "Failed to decode JSON body",
-		})
+	userID, ok := pointsHistoryUserID(r.URL.Path)
+	if !ok {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /users/{id}/points/history"))
 		return
 	}
 
-	log.Printf("PointsHistoryHandler: Received request for user_id: %d, start_date: %s, end_date: %s, transaction_type: %s",
-		req.UserID, req.StartDate, req.EndDate, req.TransactionType)
-
-	// Base query
-	query := `
-		SELECT id, user_id, points, transaction_type, transaction_date, reason
-		FROM points
-		WHERE user_id = ?
-	`
-	args := []interface{}{req.UserID}
+	req, err := parsePointsHistoryQuery(userID, r.URL.Query())
+	if err != nil {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, err.Error()))
+		return
+	}
 
-	// Add filters for date range
-	if req.StartDate != "" && req.EndDate != "" {
-		query += " AND transaction_date BETWEEN ? AND ?"
-		args = append(args, req.StartDate, req.EndDate)
-		log.Printf("PointsHistoryHandler: Filtering points history between %s and %s", req.StartDate, req.EndDate)
+	page, err := pagination.Parse(r.URL.Query(), historyPageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
 	}
 
-	// Add filter for transaction type
-	if req.TransactionType != "" {
-		query += " AND transaction_type = ?"
-		args = append(args, req.TransactionType)
-		log.Printf("PointsHistoryHandler: Filtering points history by transaction_type: %s", req.TransactionType)
+	if !authorizeUserAccess(w, r, db, req.UserID) {
+		return
 	}
 
-	// Execute query
-	rows, err := db.Query(query, args...)
+	query, args := pointsHistoryQuery(req)
+	query, args = page.Apply(query, args)
+
+	rows, err := db.QueryContext(r.Context(), query, args...)
 	if err != nil {
-		log.Printf("Error executing query to fetch points history: %v", err)
-		response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-			Code:    "500",
-			Msg:     "Internal Server Error",
-			Details: "Failed to fetch points history",
-		})
+		logging.FromContext(r.Context()).Error("Error executing query to fetch points history", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to fetch points history"))
 		return
 	}
 	defer rows.Close()
 
 	// Parse results
-	var history []models.PointsHistoryResponse
+	history := []models.PointsHistoryEntry{}
+	fetched := 0
 	for rows.Next() {
-		var record models.PointsHistoryResponse
-		if err := rows.Scan(
-			&record.ID, &record.UserID, &record.Points, &record.TransactionType,
-			&record.TransactionDate, &record.Reason,
-		); err != nil {
-			log.Printf("Error scanning points history row: %v", err)
-			response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-				Code:    "500",
-				Msg:     "Internal Server Error",
-				Details: "Failed to process points history",
-			})
+		var entry models.PointsHistoryEntry
+		if err := rows.Scan(&entry.EntryID, &entry.Type, &entry.Points,
+			&entry.OccurredAt, &entry.Reference, &entry.Reason); err != nil {
+			logging.FromContext(r.Context()).Error("Error scanning points history row", "err", err)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to process points history"))
 			return
 		}
-		history = append(history, record)
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		entry.OccurredAt = entry.OccurredAt.In(req.Location)
+		history = append(history, entry)
 	}
 
 	// Check for errors during row iteration
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-			Code:    "500",
-			Msg:     "Internal Server Error",
-			Details: "Failed to process points history",
-		})
+		logging.FromContext(r.Context()).Error("Error iterating over rows", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to process points history"))
 		return
 	}
 
-	// Respond with the history
-	log.Printf("PointsHistoryHandler: Successfully retrieved %d records for user_id: %d", len(history), req.UserID)
-	response.WriteSuccessResponse(w, history, "Points history retrieved successfully")
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := history[len(history)-1]
+		var value interface{} = last.OccurredAt
+		if page.Sort == "points" {
+			value = last.Points
+		}
+		nextCursor = page.Next(value, last.EntryID)
+	}
+
+	logging.FromContext(r.Context()).Debug("PointsHistoryHandler: Retrieved points history", "user_id", req.UserID, "records", len(history))
+	response.WritePageResponse(w, history, nextCursor, "Points history retrieved successfully")
+}
+
+// pointsHistoryUserID extracts {id} from /users/{id}/points/history.
+func pointsHistoryUserID(path string) (int, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 4 || parts[0] != "users" || parts[2] != "points" || parts[3] != "history" {
+		return 0, false
+	}
+	userID, err := strconv.Atoi(parts[1])
+	if err != nil || userID < 1 {
+		return 0, false
+	}
+	return userID, true
+}
+
+// parsePointsHistoryQuery validates the from, to, type and tz query parameters.
+func parsePointsHistoryQuery(userID int, q url.Values) (models.PointsHistoryRequest, error) {
+	req := models.PointsHistoryRequest{UserID: userID, Location: time.UTC}
+
+	if tz := q.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return req, fmt.Errorf("unknown time zone %q", tz)
+		}
+		req.Location = loc
+	}
+
+	var err error
+	if from := q.Get("from"); from != "" {
+		if req.From, err = utils.ParseRangeDate(from, req.Location, false); err != nil {
+			return req, err
+		}
+	}
+	if to := q.Get("to"); to != "" {
+		if req.To, err = utils.ParseRangeDate(to, req.Location, true); err != nil {
+			return req, err
+		}
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To) {
+		return req, fmt.Errorf("from must be before to")
+	}
+
+	if types := q.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			entryType, ok := historyTypes[strings.ToLower(strings.TrimSpace(t))]
+			if !ok {
+				return req, fmt.Errorf("unknown type %q (expected earned, redeemed, expired, adjusted, transfer_out or transfer_in)", t)
+			}
+			req.Types = append(req.Types, entryType)
+		}
+	}
+	return req, nil
+}
+
+// pointsHistoryQuery builds the unified history as a derived table. An expired lot
+// appears twice: once when it was earned and once, negated, when what was left
+// of it expired. Lots received by transfer appear as the transfer, and lots given
+// back by an order refund as the adjustment, not as earned.
+// Points earned into or redeemed from a household pool belong to the pool's
+// history instead.
+// A member is never on both sides of a transfer, so id * 4 + 3 stays unique.
+func pointsHistoryQuery(req models.PointsHistoryRequest) (string, []interface{}) {
+	query := `
+		SELECT entry_key, entry_type, points, occurred_at, reference, reason FROM (
+			SELECT id * 4 AS entry_key, 'Earned' AS entry_type, points, transaction_date AS occurred_at,
+				transaction_id AS reference, COALESCE(reason, '') AS reason
+			FROM points WHERE user_id = ? AND transaction_type IN ('Earned', 'Expired') AND transfer_id IS NULL AND order_id IS NULL AND household_id IS NULL
+			UNION ALL
+			SELECT id * 4 + 1, 'Expired', -(points - consumed), valid_until, transaction_id, 'Points expired'
+			FROM points WHERE user_id = ? AND transaction_type = 'Expired' AND household_id IS NULL
+			UNION ALL
+			SELECT id * 4 + 2, CASE WHEN category = 'redemption' THEN 'Redeemed' ELSE 'Adjusted' END,
+				points, transaction_date, transaction_id, category
+			FROM transactions WHERE user_id = ? AND category IN ('redemption', 'adjustment') AND household_id IS NULL
+			UNION ALL
+			SELECT id * 4 + 3, CASE WHEN sender_id = ? THEN 'TransferOut' ELSE 'TransferIn' END,
+				CASE WHEN sender_id = ? THEN -points ELSE points END, created_at, reference,
+				CASE WHEN sender_id = ? THEN CONCAT('Transfer to user ', recipient_id) ELSE CONCAT('Transfer from user ', sender_id) END
+			FROM point_transfers WHERE sender_id = ? OR recipient_id = ?
+		) history
+		WHERE 1 = 1`
+	args := []interface{}{req.UserID, req.UserID, req.UserID, req.UserID, req.UserID, req.UserID, req.UserID, req.UserID}
+
+	if !req.From.IsZero() {
+		query += " AND occurred_at >= ?"
+		args = append(args, req.From.UTC())
+	}
+	if !req.To.IsZero() {
+		query += " AND occurred_at < ?"
+		args = append(args, req.To.UTC())
+	}
+	if len(req.Types) > 0 {
+		query += " AND entry_type IN (" + strings.TrimSuffix(strings.Repeat("?,", len(req.Types)), ",") + ")"
+		for _, t := range req.Types {
+			args = append(args, t)
+		}
+	}
+	return query, args
 }
@@ -2,105 +2,44 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
-	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/pagination"
 	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
 	"net/http"
-
 	"strconv"
 )
 
 // PointsBalanceHandler returns the user's current points balance and history
 func PointsBalanceHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	log.Println("PointsBalanceHandler: Starting to process points balance request.")
-
-	// Parse query parameters for pagination
-	userID := r.URL.Query().Get("user_id")
-	pageStr := r.URL.Query().Get("page")
-	pageSizeStr := r.URL.Query().Get("page_size")
+	logging.FromContext(r.Context()).Debug("PointsBalanceHandler: Starting to process points balance request")
 
-	if userID == "" {
-		log.Println("PointsBalanceHandler: user_id is missing in the query parameters.")
-		response.WriteErrorResponse(w, http.StatusBadRequest, response.APIError{
-			Code:    "400",
-			Msg:     "Missing Parameter",
-			Details: "user_id is required",
-		})
+	// Parse query parameters
+	rawUserID := r.URL.Query().Get("user_id")
+	if rawUserID == "" {
+		logging.FromContext(r.Context()).Debug("PointsBalanceHandler: user_id is missing in the query parameters")
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMissingParameter, "user_id is required"))
 		return
 	}
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = 10
-	}
-
-	offset := (page - 1) * pageSize
-
-	// Get the user's current points balance
-	var balance int
-	err = db.QueryRow("SELECT loyalty_points FROM users WHERE id = ?", userID).Scan(&balance)
-	if err != nil {
-		log.Printf("Error retrieving points balance for user %s: %v", userID, err)
-		response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-			Code:    "500",
-			Msg:     "Internal Server Error",
-			Details: "Could not retrieve points balance",
-		})
+	userID, err := strconv.Atoi(rawUserID)
+	if err != nil || userID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "user_id must be a positive integer"))
 		return
 	}
 
-	// Get the user's points history
-	history := []models.PointsHistory{}
-	query := `SELECT transaction_date, points, category FROM transactions WHERE user_id = ? ORDER BY transaction_date DESC LIMIT ? OFFSET ?`
-	rows, err := db.Query(query, userID, pageSize, offset)
+	page, err := pagination.Parse(r.URL.Query(), service.LedgerPageOptions)
 	if err != nil {
-		log.Printf("Error retrieving points history for user %s: %v", userID, err)
-		response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-			Code:    "500",
-			Msg:     "Internal Server Error",
-			Details: "Could not retrieve points history",
-		})
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
 		return
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var record models.PointsHistory
-		var category string
-		if err := rows.Scan(&record.TransactionDate, &record.Points, &category); err != nil {
-			log.Printf("Error scanning points history row for user %s: %v", userID, err)
-			response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-				Code:    "500",
-				Msg:     "Internal Server Error",
-				Details: "Error scanning points history",
-			})
-			return
-		}
-		record.Reason = "Transaction - " + category
-		history = append(history, record)
-	}
 
-	// Check for errors during row iteration
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows for user %s: %v", userID, err)
-		response.WriteErrorResponse(w, http.StatusInternalServerError, response.APIError{
-			Code:    "500",
-			Msg:     "Internal Server Error",
-			Details: "Failed to process points history",
-		})
+	balance, nextCursor, err := service.PointsBalance(r.Context(), db, userID, page)
+	if err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	// Respond with balance and history
-	responses := models.PointsBalanceResponse{
-		Balance: balance,
-		History: history,
-	}
-	log.Printf("PointsBalanceHandler: Successfully retrieved points balance and history for user %s.", userID)
-	response.WriteSuccessResponse(w, responses, "Points balance and history retrieved successfully")
+	logging.FromContext(r.Context()).Debug("PointsBalanceHandler: Retrieved points balance and history", "user_id", userID)
+	response.WritePageResponse(w, balance, nextCursor, "Points balance and history retrieved successfully")
 }
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"database/sql"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RewardsHandler serves /rewards: GET lists the catalog, with ?all=true showing
+// admins inactive and out-of-window rewards too, and POST adds a reward.
+func RewardsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("RewardsHandler: Starting to process rewards request")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET and POST methods are allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.RewardRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		reward, err := service.CreateReward(r.Context(), db, username, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, reward, "Reward created successfully")
+		return
+	}
+
+	query := r.URL.Query()
+	all := false
+	if s := query.Get("all"); s != "" {
+		var err error
+		if all, err = strconv.ParseBool(s); err != nil {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "all must be true or false"))
+			return
+		}
+	}
+	page, err := pagination.Parse(query, service.RewardPageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
+	}
+	rewards, nextCursor, err := service.ListRewards(r.Context(), db, username, all, page)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WritePageResponse(w, rewards, nextCursor, "Rewards retrieved successfully")
+}
+
+// RewardHandler serves GET and PUT /rewards/{id}.
+func RewardHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("RewardHandler: Starting to process reward request")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "rewards" {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /rewards/{id}"))
+		return
+	}
+	rewardID, err := strconv.Atoi(parts[1])
+	if err != nil || rewardID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected a numeric reward id"))
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET and PUT methods are allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req models.RewardRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		reward, err := service.UpdateReward(r.Context(), db, username, rewardID, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, reward, "Reward updated successfully")
+		return
+	}
+	reward, err := service.GetReward(r.Context(), db, rewardID)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, reward, "Reward retrieved successfully")
+}
+
+// OrdersHandler serves /orders: POST orders a reward with the caller's points and
+// GET lists orders, filtered by ?user_id= and ?status=. Without user_id every
+// member's orders are listed, which is admin only.
+func OrdersHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("OrdersHandler: Starting to process orders request")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMethodNotAllowed, "Only GET and POST methods are allowed"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.OrderRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		resp, err := service.PlaceOrder(r.Context(), db, username, req)
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		response.WriteSuccessResponse(w, resp, "Order placed successfully")
+		return
+	}
+
+	query := r.URL.Query()
+	userID := 0
+	if s := query.Get("user_id"); s != "" {
+		var err error
+		if userID, err = strconv.Atoi(s); err != nil || userID < 1 {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "user_id must be a positive integer"))
+			return
+		}
+	}
+	status := query.Get("status")
+	switch status {
+	case "", models.OrderPending, models.OrderFulfilled, models.OrderCancelled, models.OrderRefunded:
+	default:
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter,
+			"status must be one of pending, fulfilled, cancelled or refunded"))
+		return
+	}
+	page, err := pagination.Parse(query, service.OrderPageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
+	}
+	orders, nextCursor, err := service.ListOrders(r.Context(), db, username, userID, status, page)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WritePageResponse(w, orders, nextCursor, "Orders retrieved successfully")
+}
+
+// OrderHandler serves GET /orders/{id} and POST /orders/{id}/cancel,
+// /orders/{id}/fulfill and /orders/{id}/refund.
+func OrderHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("OrderHandler: Starting to process order request")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "orders" {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected /orders/{id}"))
+		return
+	}
+	orderID, err := strconv.Atoi(parts[1])
+	if err != nil || orderID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "Expected a numeric order id"))
+		return
+	}
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	var order *models.Order
+	var message string
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		order, err = service.GetOrder(ctx, db, username, orderID)
+		message = "Order retrieved successfully"
+	case len(parts) == 3 && parts[2] == "cancel" && r.Method == http.MethodPost:
+		order, err = service.CancelOrder(ctx, db, username, orderID)
+		message = "Order cancelled successfully"
+	case len(parts) == 3 && parts[2] == "fulfill" && r.Method == http.MethodPost:
+		order, err = service.FulfillOrder(ctx, db, username, orderID)
+		message = "Order fulfilled successfully"
+	case len(parts) == 3 && parts[2] == "refund" && r.Method == http.MethodPost:
+		order, err = service.RefundOrder(ctx, db, username, orderID)
+		message = "Order refunded successfully"
+	default:
+		response.WriteError(w, r, apperrors.New(apperrors.CodeRouteNotFound, "No order endpoint matches "+r.Method+" "+r.URL.Path))
+		return
+	}
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, order, message)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"database/sql"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"net/http"
+	"strconv"
+)
+
+// ListTransactionsHandler returns one page of a user's transactions, optionally
+// filtered by category, e.g. GET /transactions?user_id=1&category=groceries&limit=50.
+func ListTransactionsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("ListTransactionsHandler: Starting to process transactions list request")
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID < 1 {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeMissingParameter, "user_id is required"))
+		return
+	}
+
+	page, err := pagination.Parse(r.URL.Query(), service.LedgerPageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
+	}
+
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	transactions, nextCursor, err := service.ListTransactions(r.Context(), db, username, userID, r.URL.Query().Get("category"), page)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WritePageResponse(w, transactions, nextCursor, "Transactions retrieved successfully")
+}
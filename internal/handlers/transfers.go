@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/service"
+	"net/http"
+)
+
+// TransferPointsHandler handles POST /transfers, moving points from the caller's
+// account to another member's.
+func TransferPointsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	logging.FromContext(r.Context()).Debug("TransferPointsHandler: Starting to process transfer request")
+
+	username, ok := tokenUsername(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.FromContext(r.Context()).Error("Error decoding request body", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidBody, "Failed to decode JSON body"))
+		return
+	}
+
+	resp, err := service.TransferPoints(r.Context(), db, username, req)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.WriteSuccessResponse(w, resp, "Points transferred successfully")
+}
@@ -2,55 +2,77 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/json"
-	"log"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	response "loyalty-points-system-api/internal/reponse"
 
 	"net/http"
 )
 
-type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
+// userPageOptions are the sort orders offered for the users list.
+var userPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"id":       {Column: "id", Kind: pagination.KindInt},
+		"username": {Column: "username", Kind: pagination.KindString},
+	},
+	DefaultSort: "id",
 }
 
 func GetAllUsersHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	log.Println("GetAllUsersHandler: Fetching all users.")
+	logging.FromContext(r.Context()).Debug("GetAllUsersHandler: Fetching users")
+
+	page, err := pagination.Parse(r.URL.Query(), userPageOptions)
+	if err != nil {
+		response.WriteError(w, r, apperrors.Wrap(err, apperrors.CodePaginationInvalid, err.Error()))
+		return
+	}
 
 	// Query to fetch id and username only
-	rows, err := db.Query("SELECT id, username FROM users")
+	query, args := page.Apply("SELECT id, username FROM users WHERE 1 = 1", nil)
+	rows, err := db.QueryContext(r.Context(), query, args...)
 	if err != nil {
-		log.Printf("Error querying users: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error querying users", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to fetch users"))
 		return
 	}
 	defer rows.Close()
 
 	// Slice to hold users
-	var users []User
+	users := []models.UserSummary{}
+	fetched := 0
 
-	// Iterate through rows and scan into User struct
+	// Iterate through rows and scan into UserSummary struct
 	for rows.Next() {
-		var user User
+		var user models.UserSummary
 		if err := rows.Scan(&user.ID, &user.Username); err != nil {
-			log.Printf("Error scanning user row: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			logging.FromContext(r.Context()).Error("Error scanning user row", "err", err)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to process users"))
 			return
 		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
 		users = append(users, user)
 	}
 
 	// Check for errors after iteration
 	if err := rows.Err(); err != nil {
-		log.Printf("Error after iterating rows: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error after iterating rows", "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to process users"))
 		return
 	}
 
-	// Encode users to JSON and send response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(users); err != nil {
-		log.Printf("Error encoding users to JSON: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := users[len(users)-1]
+		var value interface{} = last.ID
+		if page.Sort == "username" {
+			value = last.Username
+		}
+		nextCursor = page.Next(value, int64(last.ID))
 	}
+	response.WritePageResponse(w, users, nextCursor, "Users retrieved successfully")
 }
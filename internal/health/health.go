@@ -0,0 +1,171 @@
+// Package health answers the liveness and readiness probes. Readiness runs a set
+// of named checks, each under its own timeout, and fails while the server is
+// shutting down so load balancers stop sending it traffic.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/utils"
+)
+
+// Component and overall statuses.
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+// SchemaVersion is the number of the latest file in migrations/. The database is
+// not ready until schema_migrations has reached it.
+const SchemaVersion = 22
+
+// Check reports on one component. It returns a short detail for the report, and
+// an error when the component is not ready.
+type Check func(ctx context.Context) (string, error)
+
+type namedCheck struct {
+	name string
+	run  Check
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// New returns a checker giving each check timeout to finish.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check under name. It must be called before the server starts.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, run: check})
+}
+
+// SetShuttingDown makes every later readiness report fail.
+func (c *Checker) SetShuttingDown() {
+	c.draining.Store(true)
+}
+
+// Ready runs the checks concurrently and reports on each in the order they were
+// added. The checks are skipped once shutdown has begun.
+func (c *Checker) Ready(ctx context.Context) models.ReadinessResponse {
+	if c.draining.Load() {
+		return models.ReadinessResponse{Status: StatusDown, ShuttingDown: true, Components: []models.ComponentHealth{}}
+	}
+
+	report := models.ReadinessResponse{Status: StatusUp, Components: make([]models.ComponentHealth, len(c.checks))}
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			report.Components[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		if component.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check namedCheck) models.ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check.run(ctx)
+	component := models.ComponentHealth{
+		Name:      check.name,
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:    detail,
+	}
+	if err != nil {
+		component.Status = StatusDown
+		component.Detail = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			component.Detail = fmt.Sprintf("no answer within %s", c.timeout)
+		}
+	}
+	return component
+}
+
+// Database checks that a connection to MySQL can be made.
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) (string, error) {
+		if err := db.PingContext(ctx); err != nil {
+			return "", fmt.Errorf("ping failed: %w", err)
+		}
+		return "", nil
+	}
+}
+
+// Schema checks that the migrations up to SchemaVersion have been applied. A newer
+// schema is accepted, since migrations are applied before the code that needs them.
+func Schema(db *sql.DB) Check {
+	return func(ctx context.Context) (string, error) {
+		var version sql.NullInt64
+		if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+			return "", fmt.Errorf("could not read schema_migrations: %w", err)
+		}
+		if !version.Valid || version.Int64 < SchemaVersion {
+			return "", fmt.Errorf("schema version %d is behind %d", version.Int64, SchemaVersion)
+		}
+		return fmt.Sprintf("version %d", version.Int64), nil
+	}
+}
+
+// JobFreshness checks that job has succeeded on some instance within maxAge.
+// Until it has, the time the check was created stands in for the last success, so
+// a fresh deployment is ready before the job's first slot.
+func JobFreshness(scheduler *jobs.Scheduler, job string, maxAge time.Duration) Check {
+	since := time.Now()
+	return func(ctx context.Context) (string, error) {
+		run, err := scheduler.LastSucceeded(ctx, job)
+		if err != nil {
+			return "", fmt.Errorf("could not read job runs: %w", err)
+		}
+		last, detail := since, "no successful run yet"
+		if run != nil && run.FinishedAt != nil {
+			last, detail = *run.FinishedAt, "last succeeded at "+run.FinishedAt.UTC().Format(time.RFC3339)
+		}
+		if age := time.Since(last); age > maxAge {
+			return "", fmt.Errorf("%s, more than %s ago", detail, maxAge)
+		}
+		return detail, nil
+	}
+}
+
+// Outbox checks that work waiting to be delivered, namely audit entries not yet
+// written by this instance and notifications not yet sent, has not piled up
+// beyond max.
+func Outbox(db *sql.DB, max int) Check {
+	return func(ctx context.Context) (string, error) {
+		var notifications int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE status = 'pending'").Scan(&notifications); err != nil {
+			return "", fmt.Errorf("could not count pending notifications: %w", err)
+		}
+		audit := utils.PendingAuditWrites()
+		detail := fmt.Sprintf("%d pending audit writes, %d pending notifications", audit, notifications)
+		if audit+notifications > max {
+			return "", fmt.Errorf("%s, more than %d", detail, max)
+		}
+		return detail, nil
+	}
+}
@@ -0,0 +1,374 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Row outcomes reported for every imported row.
+const (
+	StatusAccepted  = "accepted"
+	StatusDuplicate = "duplicate"
+	StatusRejected  = "rejected"
+)
+
+// DefaultChunkSize is used when Options.ChunkSize is not set.
+const DefaultChunkSize = 500
+
+// RowResult is the outcome of a single imported row.
+type RowResult struct {
+	Row           int    `json:"row"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Status        string `json:"status"`
+	Points        int    `json:"points,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// Report is the result of an import job, including rows committed by earlier
+// interrupted runs of the same file.
+type Report struct {
+	JobID      int64       `json:"job_id"`
+	Resumed    bool        `json:"resumed"`
+	Total      int         `json:"total"`
+	Accepted   int         `json:"accepted"`
+	Duplicates int         `json:"duplicates"`
+	Rejected   int         `json:"rejected"`
+	Rows       []RowResult `json:"rows"`
+}
+
+func (rep *Report) add(res RowResult) {
+	rep.Rows = append(rep.Rows, res)
+	rep.Total++
+	switch res.Status {
+	case StatusAccepted:
+		rep.Accepted++
+	case StatusDuplicate:
+		rep.Duplicates++
+	case StatusRejected:
+		rep.Rejected++
+	}
+}
+
+// Options controls an import run.
+type Options struct {
+	Source    string // file name shown in import_jobs
+	CreatedBy string // username, or "cli" for command-line imports
+	ChunkSize int    // rows committed per database transaction
+}
+
+// Import validates and records every row of src. Each chunk of rows, their per-row
+// results and the job's progress are committed together, so re-importing the same
+// file after an interruption continues after the last committed chunk.
+func Import(ctx context.Context, db *sql.DB, src io.ReadSeeker, format Format, opts Options) (*Report, error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+
+	checksum, err := fileChecksum(src)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID, status, rowsDone, err := findOrCreateJob(ctx, db, checksum, format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{JobID: jobID, Resumed: rowsDone > 0 || status == "completed"}
+	if err := loadResults(ctx, db, jobID, report); err != nil {
+		return nil, err
+	}
+	if status == "completed" {
+		return report, nil
+	}
+	if rowsDone > 0 {
+		logging.FromContext(ctx).Info("Import job resuming", "import_job", jobID, "after_row", rowsDone)
+	}
+
+	chunk := make([]Record, 0, opts.ChunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		results, err := commitChunk(ctx, db, jobID, chunk)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			report.add(res)
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	err = ReadRecords(src, format, func(rec Record) error {
+		if rec.Row <= rowsDone {
+			return nil
+		}
+		chunk = append(chunk, rec)
+		if len(chunk) == opts.ChunkSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return report, fmt.Errorf("import job %d stopped after %d rows: %w", jobID, report.Total, err)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE import_jobs SET status = 'completed' WHERE id = ?", jobID); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func fileChecksum(src io.ReadSeeker) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		return "", fmt.Errorf("failed to read import file: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func findOrCreateJob(ctx context.Context, db *sql.DB, checksum string, format Format, opts Options) (int64, string, int, error) {
+	var jobID int64
+	var status string
+	var rowsDone int
+	err := db.QueryRowContext(ctx, "SELECT id, status, rows_done FROM import_jobs WHERE checksum = ?", checksum).
+		Scan(&jobID, &status, &rowsDone)
+	if err == nil {
+		return jobID, status, rowsDone, nil
+	} else if err != sql.ErrNoRows {
+		return 0, "", 0, err
+	}
+
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO import_jobs (checksum, source, format, created_by)
+		VALUES (?, ?, ?, ?)`, checksum, opts.Source, string(format), opts.CreatedBy)
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("failed to create import job: %w", err)
+	}
+	jobID, err = result.LastInsertId()
+	return jobID, "in_progress", 0, err
+}
+
+func loadResults(ctx context.Context, db *sql.DB, jobID int64, report *Report) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT row_num, transaction_id, status, points, reason
+		FROM import_results WHERE job_id = ? ORDER BY row_num`, jobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res RowResult
+		if err := rows.Scan(&res.Row, &res.TransactionID, &res.Status, &res.Points, &res.Reason); err != nil {
+			return err
+		}
+		report.add(res)
+	}
+	return rows.Err()
+}
+
+// commitChunk records the valid rows of chunk in one transaction. Each row runs
+// under a savepoint so a failing row is rejected without losing the rest.
+func commitChunk(ctx context.Context, db *sql.DB, jobID int64, chunk []Record) ([]RowResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "import chunk", trace.WithAttributes(
+		attribute.Int64("import.job_id", jobID), attribute.Int("import.rows", len(chunk))))
+	defer span.End()
+
+	results := make([]RowResult, len(chunk))
+	seen := map[string]bool{}
+	var txnIDs []string
+	userIDs := map[int]bool{}
+
+	for i := range chunk {
+		rec := &chunk[i]
+		// An over-long transaction_id is rejected below, but its result row must
+		// still fit import_results
+		results[i] = RowResult{Row: rec.Row, TransactionID: truncate(rec.Req.TransactionID, 255)}
+		if rec.Err == nil {
+			rec.Err = PrepareRecord(&rec.Req)
+		}
+		switch {
+		case rec.Err != nil:
+			results[i].Status = StatusRejected
+			results[i].Reason = rec.Err.Error()
+		case seen[rec.Req.TransactionID]:
+			results[i].Status = StatusDuplicate
+			results[i].Reason = "transaction_id repeated in file"
+		default:
+			seen[rec.Req.TransactionID] = true
+			txnIDs = append(txnIDs, rec.Req.TransactionID)
+			userIDs[rec.Req.UserID] = true
+		}
+	}
+
+	existing, err := existingTransactionIDs(ctx, db, txnIDs)
+	if err != nil {
+		return nil, err
+	}
+	knownUsers, err := existingUserIDs(ctx, db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i, rec := range chunk {
+		res := &results[i]
+		if res.Status != "" {
+			continue
+		}
+		if existing[rec.Req.TransactionID] {
+			res.Status, res.Reason = StatusDuplicate, "transaction_id already recorded"
+			continue
+		}
+		if !knownUsers[rec.Req.UserID] {
+			res.Status, res.Reason = StatusRejected, "user_id does not exist"
+			continue
+		}
+
+		points, _ := ledger.CalculatePoints(rec.Req.Category, rec.Req.TransactionAmount)
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+		if err := ledger.RecordEarn(ctx, tx, rec.Req, points); err != nil {
+			if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); rbErr != nil {
+				return nil, rbErr
+			}
+			if ledger.IsDuplicate(err) {
+				res.Status, res.Reason = StatusDuplicate, "transaction_id already recorded"
+			} else {
+				logging.FromContext(ctx).Error("Import row failed", "import_job", jobID, "row", rec.Row, "err", err)
+				res.Status, res.Reason = StatusRejected, "could not record transaction"
+			}
+			continue
+		}
+		res.Status, res.Points = StatusAccepted, points
+	}
+
+	var accepted, duplicates, rejected int
+	for _, res := range results {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO import_results (job_id, row_num, transaction_id, status, points, reason)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			jobID, res.Row, res.TransactionID, res.Status, res.Points, truncate(res.Reason, 255))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record import result: %w", err)
+		}
+		switch res.Status {
+		case StatusAccepted:
+			accepted++
+		case StatusDuplicate:
+			duplicates++
+		default:
+			rejected++
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE import_jobs
+		SET rows_done = ?, accepted = accepted + ?, duplicates = duplicates + ?, rejected = rejected + ?
+		WHERE id = ?`,
+		chunk[len(chunk)-1].Row, accepted, duplicates, rejected, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update import job progress: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for i, res := range results {
+		if res.Status == StatusAccepted {
+			ledger.ReportEarned(chunk[i].Req.Category, res.Points)
+		}
+	}
+	return results, nil
+}
+
+func existingTransactionIDs(ctx context.Context, db *sql.DB, ids []string) (map[string]bool, error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return existingKeys(ctx, db, "SELECT transaction_id FROM transactions WHERE transaction_id IN (%s)", args,
+		func(rows *sql.Rows, found map[string]bool) error {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			found[id] = true
+			return nil
+		})
+}
+
+func existingUserIDs(ctx context.Context, db *sql.DB, ids map[int]bool) (map[int]bool, error) {
+	args := make([]interface{}, 0, len(ids))
+	for id := range ids {
+		args = append(args, id)
+	}
+	found := map[int]bool{}
+	_, err := existingKeys(ctx, db, "SELECT id FROM users WHERE id IN (%s)", args,
+		func(rows *sql.Rows, _ map[string]bool) error {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			found[id] = true
+			return nil
+		})
+	return found, err
+}
+
+// existingKeys runs an IN (...) lookup for args and hands each row to scan.
+func existingKeys(ctx context.Context, db *sql.DB, query string, args []interface{}, scan func(*sql.Rows, map[string]bool) error) (map[string]bool, error) {
+	found := map[string]bool{}
+	if len(args) == 0 {
+		return found, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows, found); err != nil {
+			return nil, err
+		}
+	}
+	return found, rows.Err()
+}
+
+// truncate cuts s to at most n characters, which is how VARCHAR columns count.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/validation"
+)
+
+// Format is the encoding of an import file.
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat accepts "csv", "jsonl" or "ndjson".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return FormatCSV, nil
+	case "jsonl", "ndjson":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("unsupported format %q (expected csv or jsonl)", s)
+}
+
+// csvColumns are the CSV header names, matching the AddTransactionRequest JSON fields.
+var csvColumns = []string{"transaction_id", "user_id", "transaction_amount", "category", "transaction_date", "product_code"}
+
+// Record is one row of an import file. Err is set when the row could not be decoded.
+type Record struct {
+	Row int
+	Req models.AddTransactionRequest
+	Err error
+}
+
+// ReadRecords decodes r and calls fn for every row, numbering data rows from 1.
+func ReadRecords(r io.Reader, format Format, fn func(Record) error) error {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, fn)
+	case FormatJSONL:
+		return readJSONL(r, fn)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+func readCSV(r io.Reader, fn func(Record) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range csvColumns[:5] {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("CSV header is missing column %q", name)
+		}
+	}
+
+	field := func(fields []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		rec := Record{Row: row}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rec.Err = fmt.Errorf("malformed CSV row: %v", parseErr.Err)
+		} else if err != nil {
+			return err
+		} else {
+			rec.Req = models.AddTransactionRequest{
+				TransactionID:   field(fields, "transaction_id"),
+				Category:        field(fields, "category"),
+				TransactionDate: field(fields, "transaction_date"),
+				ProductCode:     field(fields, "product_code"),
+			}
+			if rec.Req.UserID, err = strconv.Atoi(field(fields, "user_id")); err != nil {
+				rec.Err = errors.New("user_id must be an integer")
+			} else if rec.Req.TransactionAmount, err = strconv.ParseFloat(field(fields, "transaction_amount"), 64); err != nil {
+				rec.Err = errors.New("transaction_amount must be a number")
+			}
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSONL(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+		rec := Record{Row: row}
+		if err := json.Unmarshal(line, &rec.Req); err != nil {
+			rec.Err = fmt.Errorf("malformed JSON row: %v", err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// PrepareRecord validates a decoded row before any database work is done and
+// normalises transaction_date to the MySQL DATETIME layout.
+func PrepareRecord(req *models.AddTransactionRequest) error {
+	if errs := validation.Validate(req); len(errs) > 0 {
+		return errs
+	}
+	// Imports record purchases already settled elsewhere; they cannot spend points
+	if req.PointsPayment > 0 {
+		return errors.New("points_payment is not supported in imports")
+	}
+	date, err := validation.NormalizeDateTime(req.TransactionDate)
+	if err != nil {
+		return err
+	}
+	req.TransactionDate = date
+	return nil
+}
@@ -0,0 +1,400 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/tracing"
+
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Disabled is the schedule of a job that only runs when triggered by an admin.
+const Disabled = config.ScheduleOff
+
+// Run sources recorded in job_runs.
+const (
+	SourceSchedule = "schedule"
+	SourceManual   = "manual"
+)
+
+// Run statuses recorded in job_runs.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Func does one run of a job and returns how many rows it changed. With dryRun it
+// must not change anything and returns how many rows it would have changed.
+type Func func(ctx context.Context, dryRun bool) (int64, error)
+
+// Job is a named background task.
+type Job struct {
+	Name     string
+	Schedule string // cron schedule, or Disabled
+	Run      Func
+}
+
+// Info describes a registered job for the admin API.
+type Info struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	LastRun  *RunRecord `json:"last_run,omitempty"`
+}
+
+// RunRecord is one row of job_runs.
+type RunRecord struct {
+	ID           int64      `json:"id"`
+	Job          string     `json:"job"`
+	Source       string     `json:"source"`
+	DryRun       bool       `json:"dry_run"`
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	Instance     string     `json:"instance"`
+	TriggeredBy  string     `json:"triggered_by,omitempty"`
+	Status       string     `json:"status"`
+	AffectedRows int64      `json:"affected_rows"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// RunPageOptions are the sort orders offered for run history.
+var RunPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"id": {Column: "id", Kind: pagination.KindInt},
+	},
+	DefaultSort: "id",
+	DefaultDesc: true,
+}
+
+type entry struct {
+	Job
+	id cron.EntryID
+}
+
+// Scheduler runs jobs on their schedules so that every replica can run one
+// without a job running twice. A MySQL named lock keeps runs of the same job from
+// overlapping, and job_runs is keyed by job and scheduled time, so a slot another
+// replica has already run is skipped.
+type Scheduler struct {
+	db       *sql.DB
+	instance string
+	cron     *cron.Cron
+	jobs     map[string]*entry
+	observe  func(job, status string, elapsed time.Duration)
+}
+
+// New returns a scheduler recording runs in db. instance identifies this replica
+// in job_runs; the host name and process id are used when it is empty.
+func New(db *sql.DB, instance string) *Scheduler {
+	if instance == "" {
+		host, _ := os.Hostname()
+		instance = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+	return &Scheduler{db: db, instance: instance, cron: cron.New(), jobs: map[string]*entry{}}
+}
+
+// Add registers job. It must be called before Start.
+func (s *Scheduler) Add(job Job) error {
+	if _, dup := s.jobs[job.Name]; dup {
+		return fmt.Errorf("job %q is registered twice", job.Name)
+	}
+	e := &entry{Job: job}
+	if job.Schedule != Disabled {
+		id, err := s.cron.AddFunc(job.Schedule, func() {
+			// cron sets Prev to the slot being run before it serves the snapshot
+			s.RunScheduled(e.Name, s.cron.Entry(e.id).Prev)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to schedule job %q: %w", job.Name, err)
+		}
+		e.id = id
+	}
+	s.jobs[job.Name] = e
+	return nil
+}
+
+// Observe sets a function that is told how long each run took, e.g. to export
+// metrics. Dry runs are not reported. It must be called before Start.
+func (s *Scheduler) Observe(fn func(job, status string, elapsed time.Duration)) {
+	s.observe = fn
+}
+
+// Start starts running jobs on their schedules.
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs. The returned context is done once running
+// scheduled jobs have returned.
+func (s *Scheduler) Stop() context.Context {
+	return s.cron.Stop()
+}
+
+// RunScheduled runs name as the scheduled run for slot, unless the job is running
+// elsewhere or the slot has already been run. Failures are recorded, not returned.
+func (s *Scheduler) RunScheduled(name string, slot time.Time) {
+	e, ok := s.jobs[name]
+	if !ok {
+		slog.Error("Jobs: no such job", "job", name)
+		return
+	}
+	ctx := context.Background()
+	slot = slot.UTC().Truncate(time.Second)
+
+	logger := slog.With("job", name)
+	release, locked, err := s.lock(ctx, name)
+	if err != nil {
+		logger.Error("Jobs: could not lock job", "err", err)
+		return
+	}
+	if !locked {
+		logger.Info("Jobs: already running on another instance, skipping")
+		return
+	}
+	defer release()
+
+	run, claimed, err := s.begin(ctx, name, SourceSchedule, false, &slot, "")
+	if err != nil {
+		logger.Error("Jobs: could not record job run", "err", err)
+		return
+	}
+	if !claimed {
+		logger.Info("Jobs: slot already ran on another instance, skipping", "scheduled_for", slot)
+		return
+	}
+	run = s.execute(ctx, e, run)
+	if run.Status == StatusFailed {
+		logger.Error("Jobs: run failed", "run_id", run.ID, "err", run.Error)
+		return
+	}
+	logger.Info("Jobs: run succeeded", "run_id", run.ID, "affected_rows", run.AffectedRows)
+}
+
+// Trigger runs name now on behalf of an admin and returns the recorded run. A
+// dry run reports what the job would change without taking the job's lock.
+func (s *Scheduler) Trigger(ctx context.Context, name, username string, dryRun bool) (*RunRecord, error) {
+	e, ok := s.jobs[name]
+	if !ok {
+		return nil, apperrors.New(apperrors.CodeJobUnknown, fmt.Sprintf("No job named %q", name))
+	}
+
+	if !dryRun {
+		release, locked, err := s.lock(ctx, name)
+		if err != nil {
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to lock job")
+		}
+		if !locked {
+			return nil, apperrors.New(apperrors.CodeJobRunning, fmt.Sprintf("Job %q is already running", name))
+		}
+		defer release()
+	}
+
+	run, _, err := s.begin(ctx, name, SourceManual, dryRun, nil, username)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record job run")
+	}
+	// The run is not cancelled with the request, so a client disconnect does not cut
+	// it short, but it keeps the request ID and trace for its logs and audit entries
+	run = s.execute(context.WithoutCancel(ctx), e, run)
+	return &run, nil
+}
+
+// Jobs lists the registered jobs by name with their next scheduled time on this
+// instance and their latest recorded run on any instance.
+func (s *Scheduler) Jobs(ctx context.Context) ([]Info, error) {
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]Info, 0, len(names))
+	for _, name := range names {
+		e := s.jobs[name]
+		info := Info{Name: name, Schedule: e.Schedule}
+		if e.Schedule != Disabled {
+			if next := s.cron.Entry(e.id).Next; !next.IsZero() {
+				next = next.UTC()
+				info.NextRun = &next
+			}
+		}
+		rows, err := s.db.QueryContext(ctx, selectRuns+" WHERE job_name = ? ORDER BY id DESC LIMIT 1", name)
+		if err != nil {
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch job runs")
+		}
+		runs, err := scanRuns(rows, 1)
+		if err != nil {
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process job runs")
+		}
+		if len(runs) > 0 {
+			info.LastRun = &runs[0]
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// LastSucceeded returns name's latest successful run on any instance, or nil if
+// it has never succeeded. Dry runs are not counted.
+func (s *Scheduler) LastSucceeded(ctx context.Context, name string) (*RunRecord, error) {
+	rows, err := s.db.QueryContext(ctx,
+		selectRuns+" WHERE job_name = ? AND status = ? AND dry_run = FALSE ORDER BY id DESC LIMIT 1", name, StatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := scanRuns(rows, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// Runs returns one page of name's run history and the cursor of the next page.
+func (s *Scheduler) Runs(ctx context.Context, name string, page pagination.Params) ([]RunRecord, string, error) {
+	if _, ok := s.jobs[name]; !ok {
+		return nil, "", apperrors.New(apperrors.CodeJobUnknown, fmt.Sprintf("No job named %q", name))
+	}
+	query, args := page.Apply(selectRuns+" WHERE job_name = ?", []interface{}{name})
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch job runs")
+	}
+	runs, err := scanRuns(rows, page.Limit+1)
+	if err != nil {
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process job runs")
+	}
+
+	nextCursor := ""
+	if page.HasMore(len(runs)) {
+		runs = runs[:page.Limit]
+		last := runs[len(runs)-1]
+		nextCursor = page.Next(last.ID, last.ID)
+	}
+	return runs, nextCursor, nil
+}
+
+// lock takes the job's MySQL named lock without waiting. The lock belongs to one
+// connection, which is held until release is called.
+func (s *Scheduler) lock(ctx context.Context, name string) (release func(), locked bool, err error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get a connection for the job lock: %w", err)
+	}
+	lockName := "loyalty_job:" + name
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lockName).Scan(&got); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("could not take the job lock: %w", err)
+	}
+	if got.Int64 != 1 {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", lockName); err != nil {
+			slog.Error("Jobs: could not release lock", "job", name, "err", err)
+		}
+		conn.Close()
+	}, true, nil
+}
+
+// begin records a running run. Scheduled runs are unique per job and slot, so it
+// returns false when the slot already has a run.
+func (s *Scheduler) begin(ctx context.Context, name, source string, dryRun bool, slot *time.Time, username string) (RunRecord, bool, error) {
+	run := RunRecord{
+		Job: name, Source: source, DryRun: dryRun, ScheduledFor: slot, Instance: s.instance,
+		TriggeredBy: username, Status: StatusRunning, StartedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT IGNORE INTO job_runs (job_name, source, dry_run, scheduled_for, instance, triggered_by, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		name, source, dryRun, slot, s.instance, sql.NullString{String: username, Valid: username != ""},
+		StatusRunning, run.StartedAt)
+	if err != nil {
+		return run, false, fmt.Errorf("could not record job run: %w", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return run, false, nil
+	}
+	run.ID, err = result.LastInsertId()
+	if err != nil {
+		return run, false, fmt.Errorf("could not record job run: %w", err)
+	}
+	return run, true, nil
+}
+
+// execute runs the job in a span of its own, a child of the request span for
+// manual runs, and records how it ended.
+func (s *Scheduler) execute(ctx context.Context, e *entry, run RunRecord) RunRecord {
+	ctx, span := tracing.Tracer().Start(ctx, "job "+e.Name, trace.WithAttributes(
+		attribute.String("job.name", e.Name), attribute.Int64("job.run_id", run.ID),
+		attribute.String("job.source", run.Source), attribute.Bool("job.dry_run", run.DryRun)))
+	defer span.End()
+	ctx = tracing.WithTraceID(ctx)
+	logger := logging.FromContext(ctx).With("job", e.Name, "run_id", run.ID)
+	ctx = logging.WithLogger(ctx, logger)
+
+	start := time.Now()
+	affected, err := e.Run(ctx, run.DryRun)
+	elapsed := time.Since(start)
+	finished := time.Now().UTC().Truncate(time.Second)
+	run.AffectedRows, run.FinishedAt, run.Status = affected, &finished, StatusSucceeded
+	if err != nil {
+		run.Status, run.Error = StatusFailed, err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, run.Error)
+	}
+	span.SetAttributes(attribute.Int64("job.affected_rows", affected))
+	if s.observe != nil && !run.DryRun {
+		s.observe(e.Name, run.Status, elapsed)
+	}
+
+	_, dbErr := s.db.ExecContext(ctx,
+		"UPDATE job_runs SET status = ?, affected_rows = ?, error = ?, finished_at = ? WHERE id = ?",
+		run.Status, run.AffectedRows, sql.NullString{String: run.Error, Valid: err != nil}, finished, run.ID)
+	if dbErr != nil {
+		logger.Error("Jobs: could not record the end of the run", "err", dbErr)
+	}
+	return run
+}
+
+const selectRuns = `
+	SELECT id, job_name, source, dry_run, scheduled_for, instance, COALESCE(triggered_by, ''),
+		status, affected_rows, COALESCE(error, ''), started_at, finished_at
+	FROM job_runs`
+
+func scanRuns(rows *sql.Rows, max int) ([]RunRecord, error) {
+	defer rows.Close()
+	runs := []RunRecord{}
+	for rows.Next() && len(runs) < max {
+		var run RunRecord
+		var scheduledFor, finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Job, &run.Source, &run.DryRun, &scheduledFor, &run.Instance,
+			&run.TriggeredBy, &run.Status, &run.AffectedRows, &run.Error, &run.StartedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		if scheduledFor.Valid {
+			run.ScheduledFor = &scheduledFor.Time
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
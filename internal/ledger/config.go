@@ -0,0 +1,38 @@
+package ledger
+
+import (
+	"fmt"
+
+	"loyalty-points-system-api/config"
+)
+
+func init() {
+	// Expiry policies are reported with the rest of an invalid configuration
+	config.RegisterCheck(func(c *config.Config) error {
+		if c.PointsExpirationDays <= 0 {
+			return nil // config reports it
+		}
+		_, err := ExpiryRulesFromConfig(c)
+		return err
+	})
+}
+
+// ExpiryRulesFromConfig builds the expiry rules. fixed_days and rolling policies
+// without a day count use POINTS_EXPIRATION_DAYS.
+func ExpiryRulesFromConfig(cfg *config.Config) (ExpiryRules, error) {
+	var rules ExpiryRules
+	var err error
+	if rules.Default, err = ParseExpiryPolicy(cfg.ExpiryPolicy, cfg.PointsExpirationDays); err != nil {
+		return rules, fmt.Errorf("EXPIRY_POLICY: %w", err)
+	}
+	if rules.Categories, err = ParseExpiryAssignments(cfg.ExpiryRulePolicies, cfg.PointsExpirationDays); err != nil {
+		return rules, fmt.Errorf("EXPIRY_POLICY_RULES: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return rules, fmt.Errorf("EXPIRY_POLICY_RULES: %w", err)
+	}
+	if rules.Tiers, err = ParseExpiryAssignments(cfg.ExpiryTierPolicies, cfg.PointsExpirationDays); err != nil {
+		return rules, fmt.Errorf("EXPIRY_POLICY_TIERS: %w", err)
+	}
+	return rules, nil
+}
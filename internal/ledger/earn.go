@@ -0,0 +1,165 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// CategoryMultipliers maps a purchase category to the points earned per unit spent.
+var CategoryMultipliers = map[string]float64{
+	"electronics": 1.0,
+	"groceries":   2.0,
+	"clothing":    1.5,
+}
+
+func init() {
+	// `validate:"category"` accepts only categories with an earning rule
+	msg := "must be one of " + strings.Join(Categories(), ", ")
+
+	validation.RegisterRule("category", func(v reflect.Value, _ string) (string, bool) {
+		_, ok := CategoryMultipliers[v.String()]
+		return msg, ok
+	})
+}
+
+// Categories returns the categories with an earning rule, sorted.
+func Categories() []string {
+	categories := make([]string, 0, len(CategoryMultipliers))
+	for category := range CategoryMultipliers {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// CalculatePoints applies the earning rule for a category. It returns false when the
+// category has no earning rule.
+func CalculatePoints(category string, amount float64) (int, bool) {
+	multiplier, ok := CategoryMultipliers[category]
+	if !ok {
+		return 0, false
+	}
+	return int(amount * multiplier), true
+}
+
+// RecordEarn writes the transaction, its earned points lot and the new balance
+// inside tx. A household member's points go to the household's pool instead of
+// their own balance.
+func RecordEarn(ctx context.Context, tx *sql.Tx, req models.AddTransactionRequest, pointsEarned int) error {
+	householdID, err := HouseholdOf(ctx, tx, req.UserID)
+	if err != nil {
+		return err
+	}
+	var pool interface{} // NULL for points earned into the member's own balance
+	if householdID != 0 {
+		pool = householdID
+	}
+
+	// Record the transaction
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			transaction_id, user_id, transaction_amount, 
+			category, transaction_date, product_code, points, household_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.TransactionID, req.UserID, req.TransactionAmount,
+		req.Category, req.TransactionDate, req.ProductCode, pointsEarned, pool,
+	)
+	if IsDuplicate(err) {
+		return apperrors.Wrap(err, apperrors.CodeTransactionExists, "Transaction ID has already been recorded")
+	} else if err != nil {
+		return fmt.Errorf("could not record transaction: %w", err)
+	}
+
+	// Expiry counts from the transaction date, not from when it was recorded
+	earnedAt, err := validation.ParseDateTime(req.TransactionDate)
+	if err != nil {
+		return fmt.Errorf("could not parse transaction date: %w", err)
+	}
+	policy, err := expiryFor(ctx, tx, req.UserID, req.Category)
+	if err != nil {
+		return err
+	}
+	var validUntil interface{} // NULL for lots that never expire
+	if until, expires := policy.ValidUntil(earnedAt); expires {
+		validUntil = until
+	}
+
+	// Add points record
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO points (
+			user_id, transaction_id, points, 
+			transaction_type, transaction_date, valid_until, reason,
+			expiry_policy, expiry_days, household_id
+		) VALUES (?, ?, ?, 'Earned', ?, ?, ?, ?, ?, ?)`,
+		req.UserID, req.TransactionID, pointsEarned,
+		req.TransactionDate, validUntil, "Purchase",
+		string(policy.Kind), policy.Days, pool,
+	)
+	if err != nil {
+		return fmt.Errorf("could not record points: %w", err)
+	}
+
+	// Balances are locked before lots, member then household, as the expiry job
+	// and redemptions lock them
+	if householdID != 0 {
+		// The member's own lots are extended below, so their row is locked too
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", req.UserID).Scan(new(int)); err != nil {
+			return fmt.Errorf("could not lock user: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE households SET loyalty_points = loyalty_points + ? WHERE id = ?", pointsEarned, householdID)
+		if err != nil {
+			return fmt.Errorf("could not update household points: %w", err)
+		}
+	} else {
+		// Update user's total loyalty points
+		_, err = tx.ExecContext(ctx, `
+			UPDATE users 
+			SET loyalty_points = loyalty_points + ? 
+			WHERE id = ?`,
+			pointsEarned, req.UserID,
+		)
+		if err != nil {
+			return fmt.Errorf("could not update user points: %w", err)
+		}
+	}
+
+	// Earning is member activity, which keeps rolling lots alive
+	if err := ExtendRollingExpiry(ctx, tx, req.UserID, earnedAt); err != nil {
+		return err
+	}
+	if householdID != 0 {
+		return ExtendHouseholdRollingExpiry(ctx, tx, householdID, earnedAt)
+	}
+	return nil
+}
+
+// HouseholdOf returns the household userID belongs to, or 0 when they are not in
+// one.
+func HouseholdOf(ctx context.Context, tx *sql.Tx, userID int) (int, error) {
+	var householdID int
+	err := tx.QueryRowContext(ctx, "SELECT household_id FROM household_members WHERE user_id = ?", userID).Scan(&householdID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("could not look up household: %w", err)
+	}
+	return householdID, nil
+}
+
+// IsDuplicate reports whether err is a MySQL duplicate key error, e.g. a
+// transaction_id that was already recorded.
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
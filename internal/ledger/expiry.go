@@ -0,0 +1,217 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryKind is how an earned points lot expires.
+type ExpiryKind string
+
+const (
+	// ExpiryFixedDays expires a lot Days after it was earned.
+	ExpiryFixedDays ExpiryKind = "fixed_days"
+	// ExpiryEndOfQuarter expires a lot when the calendar quarter it was earned in ends.
+	ExpiryEndOfQuarter ExpiryKind = "end_of_quarter"
+	// ExpiryEndOfYear expires a lot when the calendar year it was earned in ends.
+	ExpiryEndOfYear ExpiryKind = "end_of_year"
+	// ExpiryRolling expires a lot Days after the member's last earn or redemption.
+	ExpiryRolling ExpiryKind = "rolling"
+	// ExpiryNever keeps a lot forever, e.g. for promotional campaigns.
+	ExpiryNever ExpiryKind = "never"
+)
+
+// ExpiryPolicy decides a lot's valid_until. Days is used by fixed_days and rolling.
+type ExpiryPolicy struct {
+	Kind ExpiryKind
+	Days int
+}
+
+// ValidUntil returns when points earned at earnedAt expire, or false when they
+// never do. Calendar boundaries are in UTC.
+func (p ExpiryPolicy) ValidUntil(earnedAt time.Time) (time.Time, bool) {
+	earnedAt = earnedAt.UTC()
+	switch p.Kind {
+	case ExpiryEndOfQuarter:
+		quarterStart := time.Month((int(earnedAt.Month())-1)/3*3 + 1)
+		return time.Date(earnedAt.Year(), quarterStart+3, 1, 0, 0, 0, 0, time.UTC), true
+	case ExpiryEndOfYear:
+		return time.Date(earnedAt.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC), true
+	case ExpiryNever:
+		return time.Time{}, false
+	default:
+		return earnedAt.AddDate(0, 0, p.Days), true
+	}
+}
+
+func (p ExpiryPolicy) String() string {
+	if p.Kind == ExpiryFixedDays || p.Kind == ExpiryRolling {
+		return fmt.Sprintf("%s:%d", p.Kind, p.Days)
+	}
+	return string(p.Kind)
+}
+
+// ParseExpiryPolicy reads "kind" or "kind:days", e.g. "rolling:180" or
+// "end_of_quarter". fixed_days and rolling without days use defaultDays.
+func ParseExpiryPolicy(spec string, defaultDays int) (ExpiryPolicy, error) {
+	kind, daysStr, hasDays := strings.Cut(strings.TrimSpace(spec), ":")
+	p := ExpiryPolicy{Kind: ExpiryKind(kind)}
+	switch p.Kind {
+	case ExpiryFixedDays, ExpiryRolling:
+		p.Days = defaultDays
+		if hasDays {
+			days, err := strconv.Atoi(daysStr)
+			if err != nil {
+				return p, fmt.Errorf("expiry policy %q: days must be an integer", spec)
+			}
+			p.Days = days
+		}
+		if p.Days < 1 {
+			return p, fmt.Errorf("expiry policy %q: days must be positive", spec)
+		}
+	case ExpiryEndOfQuarter, ExpiryEndOfYear, ExpiryNever:
+		if hasDays {
+			return p, fmt.Errorf("expiry policy %q: %s does not take days", spec, p.Kind)
+		}
+	default:
+		return p, fmt.Errorf("expiry policy %q: kind must be one of fixed_days, end_of_quarter, end_of_year, rolling, never", spec)
+	}
+	return p, nil
+}
+
+// ParseExpiryAssignments reads a comma-separated list of name=policy pairs, e.g.
+// "groceries=end_of_quarter,electronics=fixed_days:730".
+func ParseExpiryAssignments(list string, defaultDays int) (map[string]ExpiryPolicy, error) {
+	policies := map[string]ExpiryPolicy{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q must be name=policy", item)
+		}
+		if _, dup := policies[name]; dup {
+			return nil, fmt.Errorf("%q is assigned more than once", name)
+		}
+		p, err := ParseExpiryPolicy(spec, defaultDays)
+		if err != nil {
+			return nil, err
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// ExpiryRules selects the policy for an earned lot. An earning rule's policy wins
+// over the member's tier policy, so campaign rules such as never apply to everyone.
+type ExpiryRules struct {
+	Default    ExpiryPolicy
+	Categories map[string]ExpiryPolicy // by earning rule, i.e. purchase category
+	Tiers      map[string]ExpiryPolicy // by users.tier
+}
+
+// For returns the policy for points earned in category by a member of tier.
+func (r ExpiryRules) For(category, tier string) ExpiryPolicy {
+	if p, ok := r.Categories[category]; ok {
+		return p
+	}
+	if p, ok := r.Tiers[tier]; ok {
+		return p
+	}
+	return r.Default
+}
+
+// Validate checks that every category policy names an earning rule.
+func (r ExpiryRules) Validate() error {
+	var unknown []string
+	for category := range r.Categories {
+		if _, ok := CategoryMultipliers[category]; !ok {
+			unknown = append(unknown, category)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("no earning rule for %s; categories are %s",
+			strings.Join(unknown, ", "), strings.Join(Categories(), ", "))
+	}
+	return nil
+}
+
+func (r ExpiryRules) hasRolling() bool {
+	if r.Default.Kind == ExpiryRolling {
+		return true
+	}
+	for _, policies := range []map[string]ExpiryPolicy{r.Categories, r.Tiers} {
+		for _, p := range policies {
+			if p.Kind == ExpiryRolling {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expiryRules are the active rules. The default matches POINTS_EXPIRATION_DAYS=365.
+var expiryRules = ExpiryRules{Default: ExpiryPolicy{Kind: ExpiryFixedDays, Days: 365}}
+
+// SetExpiryRules replaces the active expiry rules. It must be called before any
+// points are recorded.
+func SetExpiryRules(r ExpiryRules) {
+	expiryRules = r
+}
+
+// ExtendRollingExpiry resets the expiry of userID's unexpired rolling lots to
+// their own number of days after activityAt, or after now when activityAt is
+// later. Lots never move to an earlier date, and a lot that has lapsed stays
+// lapsed even if the expiry job has not processed it yet, whatever activityAt.
+// Lots earned into a household pool are extended by ExtendHouseholdRollingExpiry.
+func ExtendRollingExpiry(ctx context.Context, tx *sql.Tx, userID int, activityAt time.Time) error {
+	return extendRolling(ctx, tx, "user_id = ? AND household_id IS NULL", userID, activityAt)
+}
+
+// ExtendHouseholdRollingExpiry is ExtendRollingExpiry for the lots in householdID's
+// pool, which any member's activity keeps alive.
+func ExtendHouseholdRollingExpiry(ctx context.Context, tx *sql.Tx, householdID int, activityAt time.Time) error {
+	return extendRolling(ctx, tx, "household_id = ?", householdID, activityAt)
+}
+
+func extendRolling(ctx context.Context, tx *sql.Tx, owner string, id int, activityAt time.Time) error {
+	if !expiryRules.hasRolling() {
+		return nil
+	}
+	// activityAt comes from the client; it must not extend lots past what activity
+	// now would, and lapsed lots are judged by the server's clock
+	if now := time.Now(); activityAt.After(now) {
+		activityAt = now
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE points
+		SET valid_until = GREATEST(valid_until, DATE_ADD(?, INTERVAL expiry_days DAY))
+		WHERE `+owner+` AND transaction_type = 'Earned' AND expiry_policy = ? AND valid_until > NOW()`,
+		activityAt.UTC(), id, string(ExpiryRolling),
+	)
+	if err != nil {
+		return fmt.Errorf("could not extend rolling expiry: %w", err)
+	}
+	return nil
+}
+
+// expiryFor resolves the policy for a new lot, looking up the member's tier only
+// when tier policies are configured.
+func expiryFor(ctx context.Context, tx *sql.Tx, userID int, category string) (ExpiryPolicy, error) {
+	tier := ""
+	if len(expiryRules.Tiers) > 0 {
+		if err := tx.QueryRowContext(ctx, "SELECT tier FROM users WHERE id = ?", userID).Scan(&tier); err != nil {
+			return ExpiryPolicy{}, fmt.Errorf("could not look up member tier: %w", err)
+		}
+	}
+	return expiryRules.For(category, tier), nil
+}
@@ -0,0 +1,46 @@
+package ledger
+
+// Hooks observe committed ledger changes, e.g. to export metrics. Callers report a
+// change only once its transaction has committed. Nil fields are skipped.
+type Hooks struct {
+	PointsEarned      func(category string, points int)
+	PointsRedeemed    func(points int)
+	PointsExpired     func(points int)
+	PointsTransferred func(points int)
+}
+
+var hooks Hooks
+
+// SetHooks replaces the ledger hooks. It must be called before any points are
+// recorded.
+func SetHooks(h Hooks) {
+	hooks = h
+}
+
+// ReportEarned tells the hooks about a committed purchase in category.
+func ReportEarned(category string, points int) {
+	if hooks.PointsEarned != nil {
+		hooks.PointsEarned(category, points)
+	}
+}
+
+// ReportRedeemed tells the hooks about a committed redemption.
+func ReportRedeemed(points int) {
+	if hooks.PointsRedeemed != nil {
+		hooks.PointsRedeemed(points)
+	}
+}
+
+// ReportTransferred tells the hooks about a committed transfer between members.
+func ReportTransferred(points int) {
+	if hooks.PointsTransferred != nil {
+		hooks.PointsTransferred(points)
+	}
+}
+
+// ReportExpired tells the hooks about committed expired points.
+func ReportExpired(points int) {
+	if hooks.PointsExpired != nil {
+		hooks.PointsExpired(points)
+	}
+}
@@ -0,0 +1,96 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Every spend takes its points from the owner's unexpired earned lots, oldest
+// first, by raising points.consumed. A lot keeps the amount it was earned with,
+// and only what is left of it can expire or be warned about.
+
+// LotSlice is the part of an earned lot taken by a spend, with the lot's expiry.
+// ValidUntil is nil for points that never expire.
+type LotSlice struct {
+	Points     int
+	ValidUntil *time.Time
+	Policy     ExpiryPolicy
+}
+
+// columns returns the slice's valid_until and expiry_policy as stored, NULL for
+// points that never expire and for slices without a policy.
+func (s LotSlice) columns() (validUntil, kind interface{}) {
+	if s.ValidUntil != nil {
+		validUntil = s.ValidUntil.UTC()
+	}
+	if s.Policy.Kind != "" {
+		kind = string(s.Policy.Kind)
+	}
+	return validUntil, kind
+}
+
+// DebitLotsFIFO takes up to points from userID's own unexpired earned lots, oldest
+// first, and returns the slices taken. The lots are locked until tx ends. The
+// slices add up to less than points when part of the balance is not backed by
+// lots, e.g. after adjustments or refunds.
+func DebitLotsFIFO(ctx context.Context, tx *sql.Tx, userID, points int, now time.Time) ([]LotSlice, error) {
+	return debitLots(ctx, tx, "user_id = ? AND household_id IS NULL", userID, points, now)
+}
+
+// DebitHouseholdLotsFIFO is DebitLotsFIFO for the lots in householdID's pool.
+func DebitHouseholdLotsFIFO(ctx context.Context, tx *sql.Tx, householdID, points int, now time.Time) ([]LotSlice, error) {
+	return debitLots(ctx, tx, "household_id = ?", householdID, points, now)
+}
+
+func debitLots(ctx context.Context, tx *sql.Tx, owner string, id, points int, now time.Time) ([]LotSlice, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, points - consumed, valid_until, COALESCE(expiry_policy, ''), expiry_days
+		FROM points
+		WHERE `+owner+` AND transaction_type = 'Earned' AND points > consumed
+			AND (valid_until IS NULL OR valid_until > ?)
+		ORDER BY transaction_date, id
+		FOR UPDATE`, id, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("could not read points lots: %w", err)
+	}
+	type lot struct {
+		id int64
+		LotSlice
+	}
+	var lots []lot
+	for rows.Next() {
+		var l lot
+		var validUntil sql.NullTime
+		var kind string
+		if err := rows.Scan(&l.id, &l.Points, &validUntil, &kind, &l.Policy.Days); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan points lot: %w", err)
+		}
+		if validUntil.Valid {
+			l.ValidUntil = &validUntil.Time
+		}
+		l.Policy.Kind = ExpiryKind(kind)
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read points lots: %w", err)
+	}
+
+	var slices []LotSlice
+	for _, l := range lots {
+		if points == 0 {
+			break
+		}
+		take := min(l.Points, points)
+		if _, err := tx.ExecContext(ctx, "UPDATE points SET consumed = consumed + ? WHERE id = ?", take, l.id); err != nil {
+			return nil, fmt.Errorf("could not debit points lot: %w", err)
+		}
+		l.Points = take
+		slices = append(slices, l.LotSlice)
+		points -= take
+	}
+	return slices, nil
+}
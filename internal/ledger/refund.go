@@ -0,0 +1,74 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SaveOrderLots records the slices reward order orderID spent, so a refund can
+// give them back.
+func SaveOrderLots(ctx context.Context, tx *sql.Tx, orderID int64, slices []LotSlice) error {
+	for _, s := range slices {
+		validUntil, kind := s.columns()
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO reward_order_lots (order_id, points, valid_until, expiry_policy, expiry_days)
+			VALUES (?, ?, ?, ?, ?)`,
+			orderID, s.Points, validUntil, kind, s.Policy.Days,
+		)
+		if err != nil {
+			return fmt.Errorf("could not record order lots: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreditRefund gives userID back the lots reward order orderID spent, as new lots
+// recorded under reference and dated at. Each keeps the expiry of the lot it was
+// taken from, so a refund neither rescues points from expiring nor shortens
+// their life; points whose date has passed expire on the next run. Points of the
+// order that no lot backed come back without one.
+func CreditRefund(ctx context.Context, tx *sql.Tx, userID, orderID int, reference string, at time.Time) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT points, valid_until, COALESCE(expiry_policy, ''), expiry_days
+		FROM reward_order_lots WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		return fmt.Errorf("could not read order lots: %w", err)
+	}
+	var lots []LotSlice
+	for rows.Next() {
+		var lot LotSlice
+		var validUntil sql.NullTime
+		var kind string
+		if err := rows.Scan(&lot.Points, &validUntil, &kind, &lot.Policy.Days); err != nil {
+			rows.Close()
+			return fmt.Errorf("could not scan order lot: %w", err)
+		}
+		if validUntil.Valid {
+			lot.ValidUntil = &validUntil.Time
+		}
+		lot.Policy.Kind = ExpiryKind(kind)
+		lots = append(lots, lot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not read order lots: %w", err)
+	}
+
+	for _, lot := range lots {
+		validUntil, kind := lot.columns()
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO points (
+				user_id, transaction_id, points,
+				transaction_type, transaction_date, valid_until, reason,
+				expiry_policy, expiry_days, order_id
+			) VALUES (?, ?, ?, 'Earned', ?, ?, 'Refund', ?, ?, ?)`,
+			userID, reference, lot.Points, at.UTC(), validUntil, kind, lot.Policy.Days, orderID,
+		)
+		if err != nil {
+			return fmt.Errorf("could not record refunded points: %w", err)
+		}
+	}
+	return nil
+}
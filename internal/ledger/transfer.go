@@ -0,0 +1,50 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreditTransfer records points received by userID through transfer transferID
+// as new lots dated at. With preserveExpiry each debited slice keeps its expiry;
+// otherwise, and for points not backed by a slice, the recipient's own expiry
+// policy applies from at.
+func CreditTransfer(ctx context.Context, tx *sql.Tx, userID int, transferID int64, reference string,
+	points int, slices []LotSlice, preserveExpiry bool, at time.Time) error {
+	var lots []LotSlice
+	if preserveExpiry {
+		for _, s := range slices {
+			lots = append(lots, s)
+			points -= s.Points
+		}
+	}
+	if points > 0 {
+		policy, err := expiryFor(ctx, tx, userID, "")
+		if err != nil {
+			return err
+		}
+		lot := LotSlice{Points: points, Policy: policy}
+		if until, expires := policy.ValidUntil(at); expires {
+			lot.ValidUntil = &until
+		}
+		lots = append(lots, lot)
+	}
+
+	for _, lot := range lots {
+		validUntil, kind := lot.columns()
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO points (
+				user_id, transaction_id, points,
+				transaction_type, transaction_date, valid_until, reason,
+				expiry_policy, expiry_days, transfer_id
+			) VALUES (?, ?, ?, 'Earned', ?, ?, 'Transfer', ?, ?, ?)`,
+			userID, reference, lot.Points, at.UTC(), validUntil, kind, lot.Policy.Days, transferID,
+		)
+		if err != nil {
+			return fmt.Errorf("could not record transferred points: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of secret attributes.
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are never logged. Keys are matched
+// case-insensitively, and a key ending in one of them counts too, e.g. db_password.
+var secretKeys = []string{"password", "token", "secret", "authorization", "signing_key", "api_key"}
+
+// requestIDPattern bounds client-supplied request IDs so they are safe to log and
+// store.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// jwtPattern finds JWTs inside free-form values such as error messages.
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// ParseLevel reads debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("log level must be debug, info, warn or error, got %q", s)
+	}
+	return level, nil
+}
+
+// New returns a logger writing to w at level in format json or text. Secret
+// attributes and tokens inside values are redacted.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("log format must be json or text, got %q", format)
+	}
+}
+
+// redact hides secret attributes and JWTs embedded in string values.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, secret := range secretKeys {
+		if strings.HasSuffix(key, secret) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s := a.Value.String(); strings.Contains(s, "eyJ") {
+			return slog.String(a.Key, jwtPattern.ReplaceAllString(s, Redacted))
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok && strings.Contains(err.Error(), "eyJ") {
+			return slog.String(a.Key, jwtPattern.ReplaceAllString(err.Error(), Redacted))
+		}
+	}
+	return a
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+)
+
+// ValidRequestID reports whether a client-supplied request ID can be used as is:
+// 1 to 128 letters, digits, dots, underscores, colons or hyphens.
+func ValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
+// NewRequestID returns a random 32-character hex ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context carrying id and a logger that adds it to every
+// line as request_id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, id)
+	return context.WithValue(ctx, loggerKey, FromContext(ctx).With("request_id", id))
+}
+
+// WithLogger returns a context whose logger is logger, e.g. one carrying a job name.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// RequestID returns the request ID in ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// FromContext returns the request's logger, or the default logger outside a
+// request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
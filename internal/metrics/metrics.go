@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric, e.g. loyalty_http_requests_total.
+const namespace = "loyalty"
+
+// registry holds this service's metrics. It is separate from the global default
+// registry so libraries cannot add metrics to /metrics behind our back.
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	pointsEarned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "points_earned_total",
+		Help: "Points earned, by purchase category.",
+	}, []string{"category"})
+
+	pointsRedeemed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "points_redeemed_total",
+		Help: "Points redeemed.",
+	})
+
+	pointsExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "points_expired_total",
+		Help: "Points expired by the expiration job.",
+	})
+
+	pointsTransferred = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "points_transferred_total",
+		Help: "Points transferred between members.",
+	})
+
+	transactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "transactions_total",
+		Help: "Purchase transactions recorded, by category.",
+	}, []string{"category"})
+
+	failedLogins = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "failed_logins_total",
+		Help: "Login attempts rejected for bad credentials.",
+	})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "job_duration_seconds",
+		Help:    "Background job run time by job and status. Dry runs are not counted.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"job", "status"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		pointsEarned, pointsRedeemed, pointsExpired, pointsTransferred, transactions, failedLogins,
+		jobDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports db's connection pool statistics from sql.DB.Stats as go_sql_*
+// metrics labelled db_name, e.g. go_sql_in_use_connections. It must be called
+// once per pool.
+func RegisterDB(db *sql.DB, name string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveHTTPRequest records one served request. route is the API path template,
+// never the raw URL, so label values stay bounded.
+func ObserveHTTPRequest(method, route string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// PointsEarned records a committed purchase and the points it earned.
+func PointsEarned(category string, points int) {
+	transactions.WithLabelValues(category).Inc()
+	pointsEarned.WithLabelValues(category).Add(float64(points))
+}
+
+// PointsRedeemed records a committed redemption.
+func PointsRedeemed(points int) {
+	pointsRedeemed.Add(float64(points))
+}
+
+// PointsExpired records points expired by the expiration job.
+func PointsExpired(points int) {
+	pointsExpired.Add(float64(points))
+}
+
+// PointsTransferred records a committed transfer between members.
+func PointsTransferred(points int) {
+	pointsTransferred.Add(float64(points))
+}
+
+// LoginFailed records a login rejected for bad credentials.
+func LoginFailed() {
+	failedLogins.Inc()
+}
+
+// ObserveJob records how long a background job run took.
+func ObserveJob(job, status string, elapsed time.Duration) {
+	jobDuration.WithLabelValues(job, status).Observe(elapsed.Seconds())
+}
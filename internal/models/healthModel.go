@@ -0,0 +1,21 @@
+package models
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// ReadinessResponse is the body of GET /readyz. Status is UP only when every
+// component is UP and the server is not shutting down.
+type ReadinessResponse struct {
+	Status       string            `json:"status"`
+	ShuttingDown bool              `json:"shutting_down,omitempty"`
+	Components   []ComponentHealth `json:"components"`
+}
+
+// ComponentHealth is the outcome of one readiness check.
+type ComponentHealth struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Detail    string  `json:"detail,omitempty"`
+}
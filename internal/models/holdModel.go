@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Hold statuses. An authorized hold is captured, released or expires; the other
+// statuses are final.
+const (
+	HoldAuthorized = "authorized"
+	HoldCaptured   = "captured"
+	HoldReleased   = "released"
+	HoldExpired    = "expired"
+)
+
+// AuthorizeHoldRequest reserves points until the hold is captured or released.
+type AuthorizeHoldRequest struct {
+	UserID int `json:"user_id" validate:"gt=0"`
+	Points int `json:"points" validate:"gt=0"`
+}
+
+// CaptureHoldRequest redeems a hold. Points may be less than the held amount, in
+// which case the rest is released; zero captures the whole hold.
+type CaptureHoldRequest struct {
+	Points int `json:"points,omitempty" validate:"omitempty,gt=0"`
+}
+
+type Hold struct {
+	ID             int        `json:"id"`
+	Reference      string     `json:"reference"` // redemption transaction_id once captured
+	UserID         int        `json:"user_id"`
+	Points         int        `json:"points"`
+	CapturedPoints int        `json:"captured_points,omitempty"`
+	Status         string     `json:"status"` // authorized, captured, released or expired
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+}
+
+// HoldResponse is a hold with the member's balances after the change.
+type HoldResponse struct {
+	Hold            Hold `json:"hold"`
+	Balance         int  `json:"balance"`
+	AvailablePoints int  `json:"available_points"`
+	PendingPoints   int  `json:"pending_points"`
+}
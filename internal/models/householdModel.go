@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Household member roles. The head manages the household and, like a redeemer,
+// may redeem the pool's points; members only earn into it.
+const (
+	HouseholdRoleHead     = "head"
+	HouseholdRoleRedeemer = "redeemer"
+	HouseholdRoleMember   = "member"
+)
+
+// Household invitation statuses.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// CreateHouseholdRequest creates a household headed by the caller.
+type CreateHouseholdRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// HouseholdResponse is a household with its pooled balance and members.
+type HouseholdResponse struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	HeadUserID int               `json:"head_user_id"`
+	Balance    int               `json:"balance"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Members    []HouseholdMember `json:"members"`
+}
+
+type HouseholdMember struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"` // head, redeemer or member
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// InviteMemberRequest invites a member who is not yet in a household.
+type InviteMemberRequest struct {
+	UserID int    `json:"user_id" validate:"gt=0"`
+	Role   string `json:"role" validate:"required,oneof=redeemer member"`
+}
+
+type HouseholdInvitation struct {
+	ID            int       `json:"id"`
+	HouseholdID   int       `json:"household_id"`
+	HouseholdName string    `json:"household_name"`
+	InviteeUserID int       `json:"invitee_user_id"`
+	Role          string    `json:"role"`
+	Status        string    `json:"status"` // pending, accepted, declined or revoked
+	InvitedBy     int       `json:"invited_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// UpdateMemberRoleRequest changes a member's role. The head's role cannot change.
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=redeemer member"`
+}
+
+// HouseholdRedeemRequest spends points from the household pool.
+type HouseholdRedeemRequest struct {
+	Points int `json:"points" validate:"gt=0"`
+}
+
+type HouseholdRedeemResponse struct {
+	HouseholdID     int    `json:"household_id"`
+	RemainingPoints int    `json:"remaining_points"`
+	PointsRedeemed  int    `json:"points_redeemed"`
+	RedemptionID    string `json:"redemption_id"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ConversionRateRequest sets what one point is worth in a currency. The rate
+// applies from EffectiveFrom until a later rate for the same currency and tier.
+type ConversionRateRequest struct {
+	Currency      string  `json:"currency" validate:"currency"`
+	Tier          string  `json:"tier,omitempty" validate:"max=32"`                       // empty for every tier
+	PointValue    float64 `json:"point_value" validate:"min=0.000001,max=1000"`           // the column keeps six decimals
+	EffectiveFrom string  `json:"effective_from,omitempty" validate:"omitempty,datetime"` // now when empty
+}
+
+type ConversionRate struct {
+	ID            int       `json:"id"`
+	Currency      string    `json:"currency"`
+	Tier          string    `json:"tier"`        // empty for every tier
+	PointValue    float64   `json:"point_value"` // currency units one point pays for
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PointsQuoteRequest holds the query parameters of GET /points-quote.
+type PointsQuoteRequest struct {
+	UserID   int     `json:"user_id" validate:"gt=0"`
+	Amount   float64 `json:"amount" validate:"gt=0,max=99999999.99"`
+	Currency string  `json:"currency" validate:"omitempty,currency"` // DEFAULT_CURRENCY when empty
+}
+
+// PointsQuote prices an amount in points for one member at the rate in effect
+// now.
+type PointsQuote struct {
+	UserID          int            `json:"user_id"`
+	Currency        string         `json:"currency"`
+	Amount          float64        `json:"amount"`
+	Rate            ConversionRate `json:"rate"`
+	PointsRequired  int            `json:"points_required"` // points that pay the whole amount
+	AvailablePoints int            `json:"available_points"`
+	MaxPoints       int            `json:"max_points"` // most points the member can put towards the amount
+	MaxPointsValue  float64        `json:"max_points_value"`
+	CashAmount      float64        `json:"cash_amount"` // left to pay in cash after MaxPoints
+}
+
+// PointsPayment is the part of a purchase paid with points.
+type PointsPayment struct {
+	Reference   string  `json:"reference"` // transaction_id of the redemption
+	Currency    string  `json:"currency"`
+	RateID      int     `json:"rate_id"`
+	PointValue  float64 `json:"point_value"`
+	Points      int     `json:"points"`
+	PointsValue float64 `json:"points_value"`
+	CashAmount  float64 `json:"cash_amount"` // the part that earns points
+}
@@ -2,31 +2,71 @@ package models
 
 import "time"
 
+// Entry types in the unified points history
+const (
+	HistoryEarned      = "Earned"
+	HistoryRedeemed    = "Redeemed"
+	HistoryExpired     = "Expired"
+	HistoryAdjusted    = "Adjusted"
+	HistoryTransferOut = "TransferOut"
+	HistoryTransferIn  = "TransferIn"
+)
+
+// PointsHistoryRequest holds the validated query parameters of
+// GET /users/{id}/points/history.
 type PointsHistoryRequest struct {
-	UserID          int    `json:"user_id"`
-	StartDate       string `json:"start_date"`       // Format: YYYY-MM-DD
-	EndDate         string `json:"end_date"`         // Format: YYYY-MM-DD
-	TransactionType string `json:"transaction_type"` // Earned, Redeemed, Expired
+	UserID   int
+	From     time.Time      // inclusive, zero when not set
+	To       time.Time      // exclusive, zero when not set
+	Types    []string       // subset of the History* types, empty for all
+	Location *time.Location // time zone for date-only filters and the response
 }
 
-type PointsHistoryResponse struct {
-	ID              int       `json:"id"`
-	UserID          int       `json:"user_id"`
-	Points          int       `json:"points"`
-	TransactionType string    `json:"transaction_type"`
-	TransactionDate time.Time `json:"transaction_date"`
-	Reason          string    `json:"reason,omitempty"` // Optional: only for expired transactions
+type PointsHistoryEntry struct {
+	EntryID    int64     `json:"entry_id"`
+	Type       string    `json:"type"` // Earned, Redeemed, Expired, Adjusted, TransferOut, TransferIn
+	Points     int       `json:"points"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Reference  string    `json:"reference"` // transaction_id the entry belongs to
+	Reason     string    `json:"reason,omitempty"`
 }
 
 type RedeemRequest struct {
-	UserID int `json:"user_id"`
-	Points int `json:"points"`
+	UserID int `json:"user_id" validate:"gt=0"`
+	Points int `json:"points" validate:"gt=0"`
 }
 
 type RedeemResponse struct {
-	Success         bool   `json:"success"`
-	Message         string `json:"message"`
 	RemainingPoints int    `json:"remaining_points"`
+	PointsRedeemed  int    `json:"points_redeemed"`
+	RedemptionID    string `json:"redemption_id"`
+}
+
+// TransferRequest moves points from the caller's account to another member's.
+// With PreserveExpiry the points keep the expiry of the lots they came from;
+// otherwise they expire under the recipient's policy as if earned now.
+type TransferRequest struct {
+	FromUserID     int    `json:"from_user_id" validate:"gt=0"`
+	ToUserID       int    `json:"to_user_id" validate:"gt=0"`
+	Points         int    `json:"points" validate:"gt=0"`
+	PreserveExpiry bool   `json:"preserve_expiry"`
+	Note           string `json:"note,omitempty" validate:"max=255"`
+}
+
+type TransferResponse struct {
+	TransferID        string        `json:"transfer_id"`
+	FromUserID        int           `json:"from_user_id"`
+	ToUserID          int           `json:"to_user_id"`
+	PointsTransferred int           `json:"points_transferred"`
+	RemainingPoints   int           `json:"remaining_points"`
+	PreserveExpiry    bool          `json:"preserve_expiry"`
+	Lots              []TransferLot `json:"lots"` // sender's lots debited, oldest first
+}
+
+// TransferLot is the part of one of the sender's lots that a transfer moved.
+type TransferLot struct {
+	Points     int        `json:"points"`
+	ValidUntil *time.Time `json:"valid_until"` // null for points that never expire
 }
 
 type PointsHistory struct {
@@ -35,7 +75,11 @@ type PointsHistory struct {
 	Reason          string `json:"reason"`
 }
 
+// PointsBalanceResponse reports the total balance, of which PendingPoints are
+// reserved by authorized holds and AvailablePoints can be spent.
 type PointsBalanceResponse struct {
-	Balance int             `json:"balance"`
-	History []PointsHistory `json:"history"`
+	Balance         int             `json:"balance"`
+	AvailablePoints int             `json:"available_points"`
+	PendingPoints   int             `json:"pending_points"`
+	History         []PointsHistory `json:"history"`
 }
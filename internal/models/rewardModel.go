@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Order statuses. A pending order can be fulfilled or cancelled; a fulfilled
+// order can be refunded. Cancelling and refunding give the points back.
+const (
+	OrderPending   = "pending"
+	OrderFulfilled = "fulfilled"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+// RewardRequest creates or replaces a catalog item.
+type RewardRequest struct {
+	SKU            string   `json:"sku" validate:"required,max=64"`
+	Name           string   `json:"name" validate:"required,max=255"`
+	Description    string   `json:"description,omitempty" validate:"max=1000"`
+	PointsCost     int      `json:"points_cost" validate:"gt=0,max=10000000"`
+	Stock          *int     `json:"stock"`                                                   // null for unlimited stock
+	AvailableFrom  string   `json:"available_from,omitempty" validate:"omitempty,datetime"`  // orderable from, inclusive
+	AvailableUntil string   `json:"available_until,omitempty" validate:"omitempty,datetime"` // orderable until, exclusive
+	Tiers          []string `json:"tiers,omitempty"`                                         // tiers that may order it, empty for all
+	Active         bool     `json:"active"`
+}
+
+type Reward struct {
+	ID             int        `json:"id"`
+	SKU            string     `json:"sku"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	PointsCost     int        `json:"points_cost"`
+	Stock          *int       `json:"stock"` // null for unlimited stock
+	AvailableFrom  *time.Time `json:"available_from"`
+	AvailableUntil *time.Time `json:"available_until"`
+	Tiers          []string   `json:"tiers"` // empty for every tier
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// OrderRequest orders quantity of a reward with the points of the caller's own
+// account.
+type OrderRequest struct {
+	UserID   int `json:"user_id" validate:"gt=0"`
+	RewardID int `json:"reward_id" validate:"gt=0"`
+	Quantity int `json:"quantity" validate:"gt=0,max=100"` // with points_cost's max, keeps an order's points inside INT
+}
+
+type Order struct {
+	ID          int        `json:"id"`
+	Reference   string     `json:"reference"` // transaction_id of the redemption
+	UserID      int        `json:"user_id"`
+	RewardID    int        `json:"reward_id"`
+	RewardSKU   string     `json:"reward_sku"`
+	RewardName  string     `json:"reward_name"`
+	Quantity    int        `json:"quantity"`
+	Points      int        `json:"points"`
+	Status      string     `json:"status"` // pending, fulfilled, cancelled or refunded
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FulfilledAt *time.Time `json:"fulfilled_at"`
+	ClosedAt    *time.Time `json:"closed_at"` // when it was cancelled or refunded
+}
+
+type OrderResponse struct {
+	Order           Order `json:"order"`
+	RemainingPoints int   `json:"remaining_points"`
+}
@@ -1,15 +1,33 @@
 package models
 
+import "time"
+
+// AddTransactionRequest records a purchase. With PointsPayment the member pays
+// part of TransactionAmount with points and earns only on the cash remainder.
 type AddTransactionRequest struct {
-	TransactionID     string  `json:"transaction_id"`
-	UserID            int     `json:"user_id"`
-	TransactionAmount float64 `json:"transaction_amount"`
-	Category          string  `json:"category"`
-	TransactionDate   string  `json:"transaction_date"`
-	ProductCode       string  `json:"product_code"`
+	TransactionID     string  `json:"transaction_id" validate:"required,max=255"`
+	UserID            int     `json:"user_id" validate:"gt=0"`
+	TransactionAmount float64 `json:"transaction_amount" validate:"gt=0,max=99999999.99"`
+	Category          string  `json:"category" validate:"required,category"`
+	TransactionDate   string  `json:"transaction_date" validate:"required,datetime,notfuture"`
+	ProductCode       string  `json:"product_code" validate:"max=255"`
+	Currency          string  `json:"currency,omitempty" validate:"omitempty,currency"` // DEFAULT_CURRENCY when empty
+	PointsPayment     int     `json:"points_payment,omitempty" validate:"min=0"`        // points put towards the amount
 }
 
 type AddTransactionResponse struct {
-	Message string `json:"message"`
-	Points  int    `json:"points"`
+	Message string         `json:"message"`
+	Points  int            `json:"points"`
+	Payment *PointsPayment `json:"payment,omitempty"` // set when points paid part of the purchase
+}
+
+type TransactionRecord struct {
+	ID                int       `json:"id"`
+	TransactionID     string    `json:"transaction_id"`
+	UserID            int       `json:"user_id"`
+	TransactionAmount float64   `json:"transaction_amount"`
+	Category          string    `json:"category"`
+	TransactionDate   time.Time `json:"transaction_date"`
+	ProductCode       string    `json:"product_code"`
+	Points            int       `json:"points"`
 }
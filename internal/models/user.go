@@ -5,21 +5,36 @@ type User struct {
 	Username     string `json:"username"`
 	PasswordHash string `json:"-"`
 	RefreshToken string `json:"-"`
+	Role         string `json:"role"`
 }
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+type RefreshResponse struct {
+	AccessToken string `json:"access_token"`
+}
 type CreateUserRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,max=255"`
+	Password string `json:"password" validate:"min=6,maxbytes=72"` // bcrypt rejects passwords over 72 bytes
 }
 
 type CreateUserResponse struct {
-	Message string `json:"message"`
+	UserID int64 `json:"user_id"`
+}
+
+// UserSummary is the public view of a user in user lists.
+type UserSummary struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
 }
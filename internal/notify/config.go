@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"fmt"
+
+	"loyalty-points-system-api/config"
+)
+
+// FromConfig returns the notifier selected by NOTIFY_CHANNEL.
+func FromConfig(cfg *config.Config) (Notifier, error) {
+	switch cfg.NotifyChannel {
+	case "log":
+		return LogNotifier{}, nil
+	case "file":
+		return &FileNotifier{Path: cfg.NotifyFilePath}, nil
+	case "smtp":
+		return &SMTPNotifier{Addr: cfg.NotifySMTPAddr, From: cfg.NotifySMTPFrom, Domain: cfg.NotifySMTPDomain}, nil
+	case "webhook":
+		return &WebhookNotifier{URL: cfg.NotifyWebhookURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification channel %q", cfg.NotifyChannel)
+	}
+}
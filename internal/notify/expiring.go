@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"loyalty-points-system-api/internal/logging"
+)
+
+// KindExpiryWarning is the notification kind for points about to expire.
+const KindExpiryWarning = "points_expiring"
+
+// ExpiryWarner warns members whose points expire within one of the horizons. It
+// runs on a schedule next to ExpirePoints.
+type ExpiryWarner struct {
+	DB        *sql.DB
+	Notifier  Notifier
+	Templates Templates
+	Horizons  []int            // days ahead, e.g. 30, 7, 1
+	Now       func() time.Time // defaults to time.Now
+}
+
+// WarningReport summarizes one run.
+type WarningReport struct {
+	Members int // members with points expiring within the longest horizon
+	Sent    int // or, on a dry run, would be sent
+	Skipped int // already notified for this horizon
+	Failed  int
+}
+
+type expiringLot struct {
+	points     int
+	validUntil time.Time
+}
+
+type expiringMember struct {
+	userID   int
+	username string
+	balance  int
+	lots     []expiringLot // ordered by validUntil
+}
+
+// Run sends at most one notification per member: for the shortest horizon that
+// contains their earliest expiring lot. Each member, horizon and expiry date is
+// notified once; failed sends are retried on the next run. A dry run sends and
+// records nothing.
+func (w *ExpiryWarner) Run(ctx context.Context, dryRun bool) (WarningReport, error) {
+	var report WarningReport
+	if len(w.Horizons) == 0 {
+		return report, nil
+	}
+	horizons := append([]int(nil), w.Horizons...)
+	sort.Ints(horizons)
+
+	now := time.Now()
+	if w.Now != nil {
+		now = w.Now()
+	}
+	now = now.UTC()
+
+	members, err := w.expiringMembers(ctx, now, now.AddDate(0, 0, horizons[len(horizons)-1]))
+	if err != nil {
+		return report, err
+	}
+	report.Members = len(members)
+
+	for _, m := range members {
+		earliest := m.lots[0].validUntil
+		horizon := horizons[len(horizons)-1]
+		for _, h := range horizons {
+			if !earliest.After(now.AddDate(0, 0, h)) {
+				horizon = h
+				break
+			}
+		}
+
+		// Lots do not track redemptions, so never promise more than the balance
+		points := 0
+		for _, lot := range m.lots {
+			if !lot.validUntil.After(now.AddDate(0, 0, horizon)) {
+				points += lot.points
+			}
+		}
+		if points > m.balance {
+			points = m.balance
+		}
+		if points <= 0 {
+			continue
+		}
+
+		n := Notification{
+			UserID: m.userID, Username: m.username, Kind: KindExpiryWarning,
+			HorizonDays: horizon, Points: points, ExpiresAt: earliest,
+		}
+		n.Subject, n.Body, err = w.Templates.Render(ExpiryWarningData{
+			Username: m.username, Points: points, ExpiresAt: earliest, HorizonDays: horizon,
+		})
+		if err != nil {
+			return report, err
+		}
+
+		dedupKey := fmt.Sprintf("%s:%d:%d:%s", KindExpiryWarning, m.userID, horizon, earliest.Format("2006-01-02"))
+		if dryRun {
+			notified, err := w.notified(ctx, dedupKey)
+			if err != nil {
+				return report, err
+			}
+			if notified {
+				report.Skipped++
+			} else {
+				report.Sent++
+			}
+			continue
+		}
+		claimed, err := w.claim(ctx, dedupKey, n)
+		if err != nil {
+			return report, err
+		}
+		if !claimed {
+			report.Skipped++
+			continue
+		}
+
+		sendErr := w.Notifier.Send(ctx, n)
+		if err := w.record(ctx, dedupKey, sendErr); err != nil {
+			return report, err
+		}
+		if sendErr != nil {
+			logging.FromContext(ctx).Warn("ExpiryWarner: notification failed", "user_id", m.userID, "channel", w.Notifier.Channel(), "err", sendErr)
+			report.Failed++
+			continue
+		}
+		report.Sent++
+	}
+	return report, nil
+}
+
+// expiringMembers returns members with unexpired earned lots of their own, not
+// of a household pool, in (from, to].
+func (w *ExpiryWarner) expiringMembers(ctx context.Context, from, to time.Time) ([]*expiringMember, error) {
+	rows, err := w.DB.QueryContext(ctx, `
+		SELECT p.user_id, u.username, u.loyalty_points, p.points - p.consumed, p.valid_until
+		FROM points p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.transaction_type = 'Earned' AND p.points > p.consumed AND p.household_id IS NULL
+			AND p.valid_until > ? AND p.valid_until <= ?
+		ORDER BY p.user_id, p.valid_until`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("querying expiring points: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*expiringMember
+	for rows.Next() {
+		var userID, balance int
+		var username string
+		var lot expiringLot
+		if err := rows.Scan(&userID, &username, &balance, &lot.points, &lot.validUntil); err != nil {
+			return nil, fmt.Errorf("scanning expiring points: %w", err)
+		}
+		if len(members) == 0 || members[len(members)-1].userID != userID {
+			members = append(members, &expiringMember{userID: userID, username: username, balance: balance})
+		}
+		m := members[len(members)-1]
+		m.lots = append(m.lots, lot)
+	}
+	return members, rows.Err()
+}
+
+// claim records n as pending under dedupKey. It returns false when the key was
+// already sent or is being sent.
+func (w *ExpiryWarner) claim(ctx context.Context, dedupKey string, n Notification) (bool, error) {
+	channel := w.Notifier.Channel()
+	result, err := w.DB.ExecContext(ctx, `
+		INSERT IGNORE INTO notifications (
+			dedup_key, user_id, kind, channel, horizon_days, points, expires_at, subject, body, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending')`,
+		dedupKey, n.UserID, n.Kind, channel, n.HorizonDays, n.Points, n.ExpiresAt, n.Subject, n.Body)
+	if err != nil {
+		return false, fmt.Errorf("recording notification: %w", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted > 0 {
+		return true, nil
+	}
+
+	// Retry an earlier failed attempt with the current content
+	result, err = w.DB.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = 'pending', channel = ?, points = ?, subject = ?, body = ?, attempts = attempts + 1
+		WHERE dedup_key = ? AND status = 'failed'`,
+		channel, n.Points, n.Subject, n.Body, dedupKey)
+	if err != nil {
+		return false, fmt.Errorf("retrying notification: %w", err)
+	}
+	retried, _ := result.RowsAffected()
+	return retried > 0, nil
+}
+
+// notified reports whether dedupKey was sent or is being sent, i.e. whether claim
+// would skip it.
+func (w *ExpiryWarner) notified(ctx context.Context, dedupKey string) (bool, error) {
+	var count int
+	err := w.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE dedup_key = ? AND status <> 'failed'", dedupKey).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("checking notification: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (w *ExpiryWarner) record(ctx context.Context, dedupKey string, sendErr error) error {
+	status, errMsg, sentAt := "sent", sql.NullString{}, sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if sendErr != nil {
+		status, errMsg, sentAt = "failed", sql.NullString{String: sendErr.Error(), Valid: true}, sql.NullTime{}
+	}
+	_, err := w.DB.ExecContext(ctx,
+		"UPDATE notifications SET status = ?, error = ?, sent_at = ? WHERE dedup_key = ?",
+		status, errMsg, sentAt, dedupKey)
+	if err != nil {
+		return fmt.Errorf("recording notification result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// ExpiryWarningData is what the expiry warning templates can use.
+type ExpiryWarningData struct {
+	Username    string
+	Points      int
+	ExpiresAt   time.Time // earliest expiry among the lots
+	HorizonDays int
+}
+
+const (
+	defaultSubject = `{{.Points}} loyalty points expire within {{.HorizonDays}} {{if eq .HorizonDays 1}}day{{else}}days{{end}}`
+	defaultBody    = `Hi {{.Username}},
+
+{{.Points}} of your loyalty points will expire soon, the first of them on {{.ExpiresAt.Format "January 2, 2006"}}.
+Redeem them before then to keep their value.
+`
+)
+
+// Templates render a notification's subject and body.
+type Templates struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// DefaultTemplates returns the built-in expiry warning templates.
+func DefaultTemplates() Templates {
+	return Templates{
+		Subject: template.Must(template.New("subject").Parse(defaultSubject)),
+		Body:    template.Must(template.New("body").Parse(defaultBody)),
+	}
+}
+
+// LoadTemplates reads expiry_warning_subject.tmpl and expiry_warning_body.tmpl
+// from dir. An empty dir returns the defaults.
+func LoadTemplates(dir string) (Templates, error) {
+	if dir == "" {
+		return DefaultTemplates(), nil
+	}
+	subject, err := template.ParseFiles(filepath.Join(dir, "expiry_warning_subject.tmpl"))
+	if err != nil {
+		return Templates{}, fmt.Errorf("loading subject template: %w", err)
+	}
+	body, err := template.ParseFiles(filepath.Join(dir, "expiry_warning_body.tmpl"))
+	if err != nil {
+		return Templates{}, fmt.Errorf("loading body template: %w", err)
+	}
+	return Templates{Subject: subject, Body: body}, nil
+}
+
+// Render returns the subject and body for data. Newlines in the subject are
+// dropped so it stays a single header line.
+func (t Templates) Render(data interface{}) (subject, body string, err error) {
+	var buf bytes.Buffer
+	if err := t.Subject.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("rendering subject: %w", err)
+	}
+	subject = string(bytes.ReplaceAll(bytes.TrimSpace(buf.Bytes()), []byte("\n"), []byte(" ")))
+
+	buf.Reset()
+	if err := t.Body.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("rendering body: %w", err)
+	}
+	return subject, buf.String(), nil
+}
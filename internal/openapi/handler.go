@@ -0,0 +1,29 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"loyalty-points-system-api/internal/logging"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// SpecHandler serves the OpenAPI document at GET /openapi.json.
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(Spec()); err != nil {
+		logging.FromContext(r.Context()).Error("SpecHandler: Error encoding OpenAPI document", "err", err)
+	}
+}
+
+// DocsHandler serves a self-contained reference page that renders /openapi.json.
+// It loads nothing from third-party hosts.
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
@@ -0,0 +1,438 @@
+package openapi
+
+import (
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ingest"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/utils"
+)
+
+type access int
+
+const (
+	accessPublic access = iota
+	accessUser
+	accessAdmin
+)
+
+// operation declares one documented route. Request and response schemas come
+// from the zero values in body and data, so they follow the models package.
+type operation struct {
+	method, path, id, summary, tag string
+	access                         access
+	params                         []Parameter
+	body                           interface{} // JSON request model
+	upload                         []string    // raw request body content types
+	data                           interface{} // data field of the success envelope
+	raw                            []string    // non-envelope response content types
+	probe                          bool        // data is the whole body, not wrapped in the envelope
+	unavailable                    string      // with probe, describes the 503 sent with the same body
+	rawDescription                 string
+	unthrottled                    bool // not rate limited under the default policies
+	errors                         []apperrors.Code
+}
+
+func queryParam(name, description string, required bool, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
+
+// idempotencyKey lets clients retry a POST without applying it twice.
+var idempotencyKey = Parameter{
+	Name: "Idempotency-Key", In: "header",
+	Description: "Client-chosen key; a retry with the same key and body returns the original response.",
+	Schema:      &Schema{Type: "string", MaxLength: intPtr(255)},
+}
+
+// jobName is the path parameter of the job endpoints.
+var jobName = Parameter{
+	Name: "name", In: "path", Required: true,
+	Schema: &Schema{Type: "string", Enum: []string{"audit_checkpoint", "expire_points", "expiry_warnings", "hold_expiry", "idempotency_purge", "rate_limit_purge"}},
+}
+
+// holdID is the path parameter of the hold endpoints.
+var holdID = Parameter{Name: "id", In: "path", Required: true, Schema: integerSchema()}
+
+// householdID, memberID and invitationID are path parameters of the household
+// endpoints.
+var (
+	householdID  = Parameter{Name: "id", In: "path", Required: true, Schema: integerSchema()}
+	memberID     = Parameter{Name: "user_id", In: "path", Required: true, Schema: integerSchema()}
+	invitationID = Parameter{Name: "id", In: "path", Required: true, Schema: integerSchema()}
+)
+
+// rewardID and orderID are path parameters of the rewards endpoints.
+var (
+	rewardID = Parameter{Name: "id", In: "path", Required: true, Schema: integerSchema()}
+	orderID  = Parameter{Name: "id", In: "path", Required: true, Schema: integerSchema()}
+)
+
+func intPtr(n int) *int { return &n }
+
+func stringSchema() *Schema  { return &Schema{Type: "string"} }
+func integerSchema() *Schema { return &Schema{Type: "integer"} }
+
+// pageParams are the keyset pagination parameters shared by list endpoints.
+func pageParams(sorts ...string) []Parameter {
+	return []Parameter{
+		queryParam("limit", "Page size, 1 to 100 (default 20). page_size is accepted as an alias.", false, integerSchema()),
+		queryParam("sort", "Sort field, prefixed with - for descending order.", false, &Schema{Type: "string", Enum: sortEnum(sorts)}),
+		queryParam("cursor", "next_cursor from the previous page.", false, stringSchema()),
+	}
+}
+
+func sortEnum(sorts []string) []string {
+	enum := make([]string, 0, len(sorts)*2)
+	for _, s := range sorts {
+		enum = append(enum, s, "-"+s)
+	}
+	return enum
+}
+
+// operations is the spec's source of truth; every route in routes.Table must
+// appear here.
+var operations = []operation{
+	{
+		method: "POST", path: "/login", id: "login", summary: "Exchange credentials for an access and refresh token", tag: "Auth",
+		body: models.LoginRequest{}, data: models.TokenResponse{},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeInvalidCredentials, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/refresh", id: "refreshToken", summary: "Issue a new access token from a refresh token", tag: "Auth",
+		body: models.RefreshRequest{}, data: models.RefreshResponse{},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeTokenInvalid, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/health", id: "health", summary: "Report service health; kept for old clients, probes should use /livez and /readyz", tag: "System",
+		data: models.HealthResponse{}, unthrottled: true,
+	},
+	{
+		method: "GET", path: "/livez", id: "livez", summary: "Liveness probe; answers while the process serves requests", tag: "System",
+		data: models.HealthResponse{}, probe: true, unthrottled: true,
+	},
+	{
+		method: "GET", path: "/readyz", id: "readyz", summary: "Readiness probe; checks the database, schema version, expiration job and outbox", tag: "System",
+		data: models.ReadinessResponse{}, probe: true, unavailable: "A check failed, or the server is shutting down", unthrottled: true,
+	},
+	{
+		method: "GET", path: "/metrics", id: "getMetrics", summary: "Prometheus metrics", tag: "System",
+		raw: []string{"text/plain"}, rawDescription: "Metrics in the Prometheus text exposition format", unthrottled: true,
+	},
+	{
+		method: "POST", path: "/create-user", id: "createUser", summary: "Register a user", tag: "Users",
+		body: models.CreateUserRequest{}, data: models.CreateUserResponse{},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeUserExists, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/get-all-users", id: "listUsers", summary: "List users", tag: "Users",
+		access: accessAdmin, params: pageParams("id", "username"), data: []models.UserSummary{},
+		errors: []apperrors.Code{apperrors.CodePaginationInvalid, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/add-transaction", id: "addTransaction", summary: "Record a purchase, optionally paid in part with points, and earn points", tag: "Points",
+		access: accessUser, body: models.AddTransactionRequest{}, data: models.AddTransactionResponse{},
+		params: []Parameter{idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeCategoryInvalid,
+			apperrors.CodeUserNotFound, apperrors.CodeForbidden, apperrors.CodeTransactionExists,
+			apperrors.CodePointsInsufficient, apperrors.CodeRateNotFound, apperrors.CodePaymentExceeded,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/points-balance", id: "getPointsBalance", summary: "Get a user's balance and earning history", tag: "Points",
+		access: accessUser, data: models.PointsBalanceResponse{},
+		params: append([]Parameter{queryParam("user_id", "User to report on.", true, integerSchema())},
+			pageParams("transaction_date", "points")...),
+		errors: []apperrors.Code{apperrors.CodeMissingParameter, apperrors.CodeInvalidParameter, apperrors.CodePaginationInvalid, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/redeem", id: "redeemPoints", summary: "Redeem points from the caller's balance", tag: "Points",
+		access: accessUser, body: models.RedeemRequest{}, data: models.RedeemResponse{},
+		params: []Parameter{idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeUserNotFound,
+			apperrors.CodeForbidden, apperrors.CodePointsInsufficient,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/transfers", id: "transferPoints", summary: "Transfer points from the caller's balance to another member", tag: "Points",
+		access: accessUser, body: models.TransferRequest{}, data: models.TransferResponse{},
+		params: []Parameter{idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeUserNotFound,
+			apperrors.CodeForbidden, apperrors.CodePointsInsufficient, apperrors.CodeTransferLimit, apperrors.CodeTransferNotAllowed,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/holds", id: "authorizeHold", summary: "Reserve points of the caller's balance until captured or released", tag: "Points",
+		access: accessUser, body: models.AuthorizeHoldRequest{}, data: models.HoldResponse{},
+		params: []Parameter{idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeUserNotFound,
+			apperrors.CodeForbidden, apperrors.CodePointsInsufficient,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/holds/{id}", id: "getHold", summary: "Get a points hold", tag: "Points",
+		access: accessUser, data: models.Hold{},
+		params: []Parameter{holdID},
+		errors: []apperrors.Code{apperrors.CodeHoldNotFound, apperrors.CodeForbidden, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/holds/{id}/capture", id: "captureHold", summary: "Redeem a hold, or part of it, releasing the rest", tag: "Points",
+		access: accessUser, body: models.CaptureHoldRequest{}, data: models.HoldResponse{},
+		params: []Parameter{holdID, idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeHoldNotFound,
+			apperrors.CodeHoldState, apperrors.CodeHoldExpired, apperrors.CodeHoldExceeded, apperrors.CodePointsInsufficient,
+			apperrors.CodeRouteNotFound, apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending,
+			apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/holds/{id}/release", id: "releaseHold", summary: "Release a hold, making its points available again", tag: "Points",
+		access: accessUser, data: models.HoldResponse{},
+		params: []Parameter{holdID, idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeHoldNotFound, apperrors.CodeHoldState, apperrors.CodeHoldExpired, apperrors.CodeRouteNotFound,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/points-quote", id: "getPointsQuote", summary: "Price an amount in points for a member at the rate in effect now", tag: "Points",
+		access: accessUser, data: models.PointsQuote{},
+		params: []Parameter{
+			queryParam("user_id", "Member whose tier and available points are used.", true, integerSchema()),
+			queryParam("amount", "Amount to pay.", true, &Schema{Type: "number"}),
+			queryParam("currency", "Three-letter currency code; DEFAULT_CURRENCY when omitted.", false, stringSchema()),
+		},
+		errors: []apperrors.Code{apperrors.CodeMissingParameter, apperrors.CodeInvalidParameter, apperrors.CodeValidationFailed,
+			apperrors.CodeUserNotFound, apperrors.CodeForbidden, apperrors.CodeRateNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/conversion-rates", id: "listConversionRates", summary: "List point-to-currency conversion rates", tag: "Points",
+		access: accessUser, data: []models.ConversionRate{},
+		params: append([]Parameter{
+			queryParam("currency", "Only rates for this currency.", false, stringSchema()),
+			queryParam("tier", "Only rates set for this tier.", false, stringSchema()),
+		}, pageParams("effective_from", "id")...),
+		errors: []apperrors.Code{apperrors.CodePaginationInvalid, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/conversion-rates", id: "createConversionRate", summary: "Add a conversion rate taking effect at effective_from", tag: "Points",
+		access: accessAdmin, body: models.ConversionRateRequest{}, data: models.ConversionRate{},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeRateExists, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/households", id: "createHousehold", summary: "Create a household pool headed by the caller", tag: "Households",
+		access: accessUser, body: models.CreateHouseholdRequest{}, data: models.HouseholdResponse{},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeHouseholdMember,
+			apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/households/{id}", id: "getHousehold", summary: "Get a household's pooled balance and members", tag: "Households",
+		access: accessUser, data: models.HouseholdResponse{},
+		params: []Parameter{householdID},
+		errors: []apperrors.Code{apperrors.CodeHouseholdNotFound, apperrors.CodeForbidden, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/households/{id}/history", id: "getHouseholdHistory", summary: "Get a household's pool history", tag: "Households",
+		access: accessUser, data: []models.PointsHistoryEntry{},
+		params: append([]Parameter{householdID}, pageParams("occurred_at", "points")...),
+		errors: []apperrors.Code{apperrors.CodeHouseholdNotFound, apperrors.CodeForbidden, apperrors.CodePaginationInvalid,
+			apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/households/{id}/invitations", id: "inviteHouseholdMember", summary: "Invite a member to the household (head only)", tag: "Households",
+		access: accessUser, body: models.InviteMemberRequest{}, data: models.HouseholdInvitation{},
+		params: []Parameter{householdID},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeHouseholdNotFound,
+			apperrors.CodeHouseholdRole, apperrors.CodeUserNotFound, apperrors.CodeHouseholdMember, apperrors.CodeHouseholdFull,
+			apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/households/{id}/redeem", id: "redeemHouseholdPoints", summary: "Redeem points from the household pool (head and redeemers)", tag: "Households",
+		access: accessUser, body: models.HouseholdRedeemRequest{}, data: models.HouseholdRedeemResponse{},
+		params: []Parameter{householdID, idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeHouseholdNotFound,
+			apperrors.CodeForbidden, apperrors.CodeHouseholdRole, apperrors.CodePointsInsufficient, apperrors.CodeRouteNotFound,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "PATCH", path: "/households/{id}/members/{user_id}", id: "updateHouseholdMemberRole", summary: "Change a member's role (head only)", tag: "Households",
+		access: accessUser, body: models.UpdateMemberRoleRequest{}, data: models.HouseholdResponse{},
+		params: []Parameter{householdID, memberID},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeHouseholdNotFound,
+			apperrors.CodeHouseholdRole, apperrors.CodeHouseholdNoMember, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "DELETE", path: "/households/{id}/members/{user_id}", id: "removeHouseholdMember", summary: "Remove a member, or leave the household", tag: "Households",
+		access: accessUser, data: models.HouseholdResponse{},
+		params: []Parameter{householdID, memberID},
+		errors: []apperrors.Code{apperrors.CodeHouseholdNotFound, apperrors.CodeHouseholdRole, apperrors.CodeHouseholdNoMember,
+			apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/household-invitations", id: "listHouseholdInvitations", summary: "List the caller's pending household invitations", tag: "Households",
+		access: accessUser, data: []models.HouseholdInvitation{},
+		errors: []apperrors.Code{apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/household-invitations/{id}/accept", id: "acceptHouseholdInvitation", summary: "Accept a household invitation", tag: "Households",
+		access: accessUser, data: models.HouseholdResponse{},
+		params: []Parameter{invitationID},
+		errors: []apperrors.Code{apperrors.CodeInvitationNotFound, apperrors.CodeInvitationClosed, apperrors.CodeHouseholdMember,
+			apperrors.CodeHouseholdFull, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/household-invitations/{id}/decline", id: "declineHouseholdInvitation", summary: "Decline a household invitation", tag: "Households",
+		access: accessUser, data: models.HouseholdInvitation{},
+		params: []Parameter{invitationID},
+		errors: []apperrors.Code{apperrors.CodeInvitationNotFound, apperrors.CodeInvitationClosed, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/rewards", id: "listRewards", summary: "List the rewards that can be ordered now", tag: "Rewards",
+		access: accessUser, data: []models.Reward{},
+		params: append([]Parameter{
+			queryParam("all", "With true, also list inactive rewards and those outside their availability window (admin only).", false, &Schema{Type: "boolean"}),
+		}, pageParams("id", "points_cost")...),
+		errors: []apperrors.Code{apperrors.CodeInvalidParameter, apperrors.CodePaginationInvalid, apperrors.CodeForbidden, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/rewards", id: "createReward", summary: "Add a reward to the catalog", tag: "Rewards",
+		access: accessAdmin, body: models.RewardRequest{}, data: models.Reward{},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeRewardExists, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/rewards/{id}", id: "getReward", summary: "Get a reward", tag: "Rewards",
+		access: accessUser, data: models.Reward{},
+		params: []Parameter{rewardID},
+		errors: []apperrors.Code{apperrors.CodeRewardNotFound, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "PUT", path: "/rewards/{id}", id: "updateReward", summary: "Replace a reward", tag: "Rewards",
+		access: accessAdmin, body: models.RewardRequest{}, data: models.Reward{},
+		params: []Parameter{rewardID},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeRewardNotFound,
+			apperrors.CodeRewardExists, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/orders", id: "placeOrder", summary: "Order a reward with the caller's points", tag: "Rewards",
+		access: accessUser, body: models.OrderRequest{}, data: models.OrderResponse{},
+		params: []Parameter{idempotencyKey},
+		errors: []apperrors.Code{apperrors.CodeInvalidBody, apperrors.CodeValidationFailed, apperrors.CodeUserNotFound,
+			apperrors.CodeForbidden, apperrors.CodeRewardNotFound, apperrors.CodeRewardUnavailable, apperrors.CodeRewardTier,
+			apperrors.CodeRewardOutOfStock, apperrors.CodePointsInsufficient,
+			apperrors.CodeInvalidParameter, apperrors.CodeIdempotencyReused, apperrors.CodeIdempotencyPending, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/orders", id: "listOrders", summary: "List reward orders", tag: "Rewards",
+		access: accessUser, data: []models.Order{},
+		params: append([]Parameter{
+			queryParam("user_id", "Only this user's orders; without it every order is listed (admin only).", false, integerSchema()),
+			queryParam("status", "Only orders in this status.", false,
+				&Schema{Type: "string", Enum: []string{models.OrderPending, models.OrderFulfilled, models.OrderCancelled, models.OrderRefunded}}),
+		}, pageParams("created_at")...),
+		errors: []apperrors.Code{apperrors.CodeInvalidParameter, apperrors.CodePaginationInvalid, apperrors.CodeForbidden, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/orders/{id}", id: "getOrder", summary: "Get a reward order", tag: "Rewards",
+		access: accessUser, data: models.Order{},
+		params: []Parameter{orderID},
+		errors: []apperrors.Code{apperrors.CodeOrderNotFound, apperrors.CodeForbidden, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/orders/{id}/cancel", id: "cancelOrder", summary: "Cancel a pending order, restoring its points and stock", tag: "Rewards",
+		access: accessUser, data: models.Order{},
+		params: []Parameter{orderID},
+		errors: []apperrors.Code{apperrors.CodeOrderNotFound, apperrors.CodeOrderState, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/orders/{id}/fulfill", id: "fulfillOrder", summary: "Mark a pending order as fulfilled", tag: "Rewards",
+		access: accessAdmin, data: models.Order{},
+		params: []Parameter{orderID},
+		errors: []apperrors.Code{apperrors.CodeOrderNotFound, apperrors.CodeOrderState, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/orders/{id}/refund", id: "refundOrder", summary: "Refund a fulfilled order, restoring its points", tag: "Rewards",
+		access: accessAdmin, data: models.Order{},
+		params: []Parameter{orderID},
+		errors: []apperrors.Code{apperrors.CodeOrderNotFound, apperrors.CodeOrderState, apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/users/{id}/points/history", id: "getPointsHistory", summary: "Get a user's unified points history", tag: "Points",
+		access: accessUser, data: []models.PointsHistoryEntry{},
+		params: append([]Parameter{
+			{Name: "id", In: "path", Required: true, Schema: integerSchema()},
+			queryParam("from", "Start date (inclusive), YYYY-MM-DD or RFC 3339.", false, stringSchema()),
+			queryParam("to", "End date (inclusive), YYYY-MM-DD, or exclusive RFC 3339 timestamp.", false, stringSchema()),
+			queryParam("tz", "IANA time zone for date-only filters and returned timestamps (default UTC).", false, stringSchema()),
+			queryParam("type", "Comma-separated subset of earned, redeemed, expired, adjusted, transfer_out, transfer_in.", false, stringSchema()),
+		}, pageParams("occurred_at", "points")...),
+		errors: []apperrors.Code{apperrors.CodeInvalidParameter, apperrors.CodePaginationInvalid, apperrors.CodeForbidden,
+			apperrors.CodeRouteNotFound, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/transactions", id: "listTransactions", summary: "List a user's transactions", tag: "Points",
+		access: accessUser, data: []models.TransactionRecord{},
+		params: append([]Parameter{
+			queryParam("user_id", "User whose transactions to list.", true, integerSchema()),
+			queryParam("category", "Only transactions in this category.", false, stringSchema()),
+		}, pageParams("transaction_date", "points")...),
+		errors: []apperrors.Code{apperrors.CodeMissingParameter, apperrors.CodePaginationInvalid, apperrors.CodeForbidden, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/transactions/batch", id: "importTransactions", summary: "Import an end-of-day transaction file", tag: "Back office",
+		access: accessAdmin, upload: []string{"text/csv", "application/x-ndjson"}, data: ingest.Report{},
+		params: []Parameter{
+			queryParam("format", "csv or jsonl; defaults from Content-Type.", false, &Schema{Type: "string", Enum: []string{"csv", "jsonl"}}),
+			queryParam("chunk_size", "Rows committed per database transaction, 1 to 10000.", false, integerSchema()),
+			queryParam("source", "Free-form label stored with the import job.", false, stringSchema()),
+		},
+		errors: []apperrors.Code{apperrors.CodeFormatUnsupported, apperrors.CodeInvalidParameter, apperrors.CodeInvalidBody,
+			apperrors.CodeImportInterrupted, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/export/{dataset}", id: "exportDataset", summary: "Stream a ledger table", tag: "Back office",
+		access: accessAdmin, raw: []string{"text/csv", "application/x-ndjson"}, rawDescription: "The dataset as CSV, JSONL or columnar JSON lines",
+		params: []Parameter{
+			{Name: "dataset", In: "path", Required: true, Schema: &Schema{Type: "string", Enum: []string{"transactions", "points", "audit_log"}}},
+			queryParam("format", "Output format (default csv).", false, &Schema{Type: "string", Enum: []string{"csv", "jsonl", "columnar"}}),
+			queryParam("month", "Calendar month, YYYY-MM; alternative to from/to.", false, stringSchema()),
+			queryParam("from", "Start (inclusive), YYYY-MM-DD or RFC 3339.", false, stringSchema()),
+			queryParam("to", "End (exclusive), YYYY-MM-DD or RFC 3339.", false, stringSchema()),
+			queryParam("user_id", "Only rows for this user.", false, integerSchema()),
+		},
+		errors: []apperrors.Code{apperrors.CodeDatasetUnknown, apperrors.CodeFormatUnsupported, apperrors.CodeInvalidParameter, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/audit-log", id: "listAuditLog", summary: "List audit log entries", tag: "Back office",
+		access: accessAdmin, data: []utils.AuditEntry{},
+		params: append([]Parameter{
+			queryParam("user_id", "Only entries for this user.", false, integerSchema()),
+			queryParam("action", "Only entries with this action.", false, stringSchema()),
+			queryParam("request_id", "Only entries written while serving this X-Request-ID.", false, stringSchema()),
+		}, pageParams("id", "created_at")...),
+		errors: []apperrors.Code{apperrors.CodeInvalidParameter, apperrors.CodePaginationInvalid, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/jobs", id: "listJobs", summary: "List background jobs with their schedules and latest runs", tag: "Back office",
+		access: accessAdmin, data: []jobs.Info{},
+		errors: []apperrors.Code{apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/jobs/{name}/runs", id: "listJobRuns", summary: "List a job's run history", tag: "Back office",
+		access: accessAdmin, data: []jobs.RunRecord{},
+		params: append([]Parameter{jobName}, pageParams("id")...),
+		errors: []apperrors.Code{apperrors.CodeJobUnknown, apperrors.CodePaginationInvalid, apperrors.CodeInternal},
+	},
+	{
+		method: "POST", path: "/jobs/{name}/run", id: "runJob", summary: "Run a job now, or preview it with dry_run", tag: "Back office",
+		access: accessAdmin, data: jobs.RunRecord{},
+		params: []Parameter{
+			jobName,
+			queryParam("dry_run", "Report what the job would change without changing anything.", false, &Schema{Type: "boolean"}),
+		},
+		errors: []apperrors.Code{apperrors.CodeJobUnknown, apperrors.CodeJobRunning, apperrors.CodeInvalidParameter, apperrors.CodeInternal},
+	},
+	{
+		method: "GET", path: "/openapi.json", id: "getOpenAPI", summary: "This OpenAPI document", tag: "System",
+		raw: []string{"application/json"}, rawDescription: "OpenAPI 3.0 document",
+	},
+	{
+		method: "GET", path: "/docs", id: "getDocs", summary: "Browsable API reference", tag: "System",
+		raw: []string{"text/html"}, rawDescription: "HTML page rendering /openapi.json",
+	},
+}
@@ -0,0 +1,166 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"loyalty-points-system-api/internal/ledger"
+)
+
+// Schema is the subset of the OpenAPI 3.0 Schema Object the API needs.
+type Schema struct {
+	Ref              string             `json:"$ref,omitempty"`
+	Type             string             `json:"type,omitempty"`
+	Format           string             `json:"format,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	AllOf            []*Schema          `json:"allOf,omitempty"`
+	Enum             []string           `json:"enum,omitempty"`
+	Minimum          *float64           `json:"minimum,omitempty"`
+	Maximum          *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum bool               `json:"exclusiveMinimum,omitempty"`
+	MinLength        *int               `json:"minLength,omitempty"`
+	MaxLength        *int               `json:"maxLength,omitempty"`
+	Nullable         bool               `json:"nullable,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// registry builds component schemas from Go types, keyed by type name.
+type registry struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func newRegistry() *registry {
+	return &registry{schemas: map[string]*Schema{}, types: map[string]reflect.Type{}}
+}
+
+// ref returns the schema for a value of type t. Named structs are added to the
+// components once and referenced by $ref.
+func (reg *registry) ref(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := t.Name()
+		if existing, ok := reg.types[name]; ok {
+			if existing != t {
+				panic(fmt.Sprintf("openapi: schema name %q used by %s and %s", name, existing, t))
+			}
+		} else {
+			reg.types[name] = t
+			reg.schemas[name] = reg.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: reg.ref(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	}
+	// interface{} fields such as the envelope's data can hold anything
+	return &Schema{}
+}
+
+// object describes a struct from its json tags, and its constraints from the
+// `validate` tags used by the validation package.
+func (reg *registry) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := reg.ref(field.Type)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if applyRules(prop, tag) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyRules copies validation rules onto a property schema. It reports whether
+// the rules reject the zero value, i.e. whether the field must be sent.
+func applyRules(s *Schema, tag string) bool {
+	required, optional := false, false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, _ := strconv.ParseFloat(param, 64)
+		switch name {
+		case "omitempty":
+			// The remaining rules only apply when a value is sent
+			optional = true
+		case "required", "datetime", "category", "currency":
+			required = true
+		case "min", "max", "gt":
+			if name != "max" && (n > 0 || (name == "gt" && n == 0)) {
+				required = true
+			}
+			setBound(s, name, n)
+		}
+
+		switch name {
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "datetime":
+			s.Description = "YYYY-MM-DD, YYYY-MM-DD HH:MM:SS or RFC 3339; times without an offset are UTC"
+		case "category":
+			s.Enum = ledger.Categories()
+		case "currency":
+			s.Description = "Three-letter currency code, e.g. USD"
+		}
+	}
+	return required && !optional
+}
+
+// setBound applies min, max or gt as a length limit on strings and a value limit
+// on numbers.
+func setBound(s *Schema, rule string, n float64) {
+	if s.Type == "string" {
+		length := int(n)
+		if rule == "max" {
+			s.MaxLength = &length
+		} else {
+			s.MinLength = &length
+		}
+		return
+	}
+	switch rule {
+	case "max":
+		s.Maximum = &n
+	case "gt":
+		s.Minimum, s.ExclusiveMinimum = &n, true
+	default:
+		s.Minimum = &n
+	}
+}
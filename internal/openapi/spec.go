@@ -0,0 +1,223 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"loyalty-points-system-api/internal/apperrors"
+	response "loyalty-points-system-api/internal/reponse"
+)
+
+// Version is the API version reported in the spec.
+const Version = "1.0.0"
+
+// Document is an OpenAPI 3.0 document.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+var (
+	specOnce sync.Once
+	spec     *Document
+)
+
+// Spec returns the API document, built once from the operations table.
+func Spec() *Document {
+	specOnce.Do(func() {
+		spec = build(operations)
+	})
+	return spec
+}
+
+// Has reports whether the spec documents method on path.
+func (d *Document) Has(method, path string) bool {
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// Operations lists every documented operation as "METHOD path", sorted.
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func build(ops []operation) *Document {
+	reg := newRegistry()
+	envelope := reg.ref(reflect.TypeOf(response.SuccessResponse{}))
+	errorEnvelope := reg.ref(reflect.TypeOf(response.ErrorResponse{}))
+	problem := reg.ref(reflect.TypeOf(response.ProblemDetails{}))
+	reg.ref(reflect.TypeOf(response.WarningResponse{}))
+
+	// Every error carries a code from the apperrors catalog
+	codes := apperrors.Codes()
+	codeEnum := make([]string, len(codes))
+	for i, code := range codes {
+		codeEnum[i] = string(code)
+	}
+	reg.schemas["APIError"].Properties["code"].Enum = codeEnum
+	reg.schemas["ProblemDetails"].Properties["code"].Enum = codeEnum
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Loyalty Points System API",
+			Version:     Version,
+			Description: "Earn, redeem and audit loyalty points. Errors use the ErrorResponse envelope, or RFC 7807 problem+json when requested with Accept: application/problem+json.",
+		},
+		Paths: map[string]map[string]Operation{},
+		Components: Components{
+			Schemas: reg.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, op := range ops {
+		o := Operation{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Tags:        []string{op.tag},
+			Parameters:  op.params,
+			Responses:   map[string]Response{},
+		}
+		if op.body != nil {
+			o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"application/json": {Schema: reg.ref(reflect.TypeOf(op.body))},
+			}}
+		}
+		if op.upload != nil {
+			o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+			for _, contentType := range op.upload {
+				o.RequestBody.Content[contentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+			}
+		}
+
+		switch {
+		case op.probe:
+			body := map[string]MediaType{"application/json": {Schema: reg.ref(reflect.TypeOf(op.data))}}
+			o.Responses["200"] = Response{Description: "Success", Content: body}
+			if op.unavailable != "" {
+				o.Responses["503"] = Response{Description: op.unavailable, Content: body}
+			}
+		case op.raw != nil:
+			o.Responses["200"] = Response{Description: op.rawDescription, Content: map[string]MediaType{}}
+			for _, contentType := range op.raw {
+				o.Responses["200"].Content[contentType] = MediaType{Schema: &Schema{Type: "string"}}
+			}
+		default:
+			data := &Schema{Type: "object", Properties: map[string]*Schema{"data": reg.ref(reflect.TypeOf(op.data))}}
+			o.Responses["200"] = Response{Description: "Success", Content: map[string]MediaType{
+				"application/json": {Schema: &Schema{AllOf: []*Schema{envelope, data}}},
+			}}
+		}
+
+		errs := op.errors
+		if !op.unthrottled {
+			errs = append(errs, apperrors.CodeRateLimited)
+		}
+		if op.access >= accessUser {
+			o.Security = []map[string][]string{{"bearerAuth": {}}}
+			errs = append(errs, apperrors.CodeTokenMissing, apperrors.CodeTokenInvalid)
+		}
+		if op.access == accessAdmin {
+			errs = append(errs, apperrors.CodeForbidden)
+		}
+		for status, desc := range errorResponses(errs) {
+			o.Responses[strconv.Itoa(status)] = Response{Description: desc, Content: map[string]MediaType{
+				"application/json":         {Schema: errorEnvelope},
+				"application/problem+json": {Schema: problem},
+			}}
+		}
+
+		if doc.Paths[op.path] == nil {
+			doc.Paths[op.path] = map[string]Operation{}
+		}
+		doc.Paths[op.path][strings.ToLower(op.method)] = o
+	}
+	return doc
+}
+
+// errorResponses groups codes by HTTP status and describes each status by the
+// codes it can carry.
+func errorResponses(codes []apperrors.Code) map[int]string {
+	byStatus := map[int][]string{}
+	seen := map[apperrors.Code]bool{}
+	for _, code := range codes {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		status := apperrors.HTTPStatus(code)
+		byStatus[status] = append(byStatus[status], string(code))
+	}
+
+	descs := map[int]string{}
+	for status, statusCodes := range byStatus {
+		descs[status] = fmt.Sprintf("%s: %s", http.StatusText(status), strings.Join(statusCodes, ", "))
+	}
+	return descs
+}
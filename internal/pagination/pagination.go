@@ -0,0 +1,218 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size limits shared by every list endpoint.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed, tampered with or
+// were issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// signingKey signs cursors so clients cannot forge keyset positions.
+var signingKey = []byte("your_secret_key")
+
+// SetSigningKey sets the HMAC key used for cursors.
+func SetSigningKey(key []byte) {
+	signingKey = key
+}
+
+// Kind is the type of a sort column, used to round-trip cursor values.
+type Kind int
+
+const (
+	KindInt Kind = iota
+	KindString
+	KindTime
+)
+
+// SortField is a sortable column. Ties are always broken by the table's id column.
+type SortField struct {
+	Column string
+	Kind   Kind
+}
+
+// Options describes what a list endpoint allows.
+type Options struct {
+	Sorts       map[string]SortField // sort parameter name -> column
+	DefaultSort string               // name in Sorts
+	DefaultDesc bool
+	IDColumn    string // unique tiebreaker, "id" when empty
+}
+
+// Params is a validated page request.
+type Params struct {
+	Limit  int
+	Sort   string
+	Desc   bool
+	field  SortField
+	idCol  string
+	cursor *cursor
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int64  `json:"i"`
+}
+
+// Parse reads limit, sort and cursor from query parameters. sort is a field name,
+// optionally prefixed with "-" for descending order; page_size is accepted as an
+// alias for limit.
+func Parse(q url.Values, opts Options) (Params, error) {
+	p := Params{Limit: DefaultLimit, Sort: opts.DefaultSort, Desc: opts.DefaultDesc, idCol: opts.IDColumn}
+	if p.idCol == "" {
+		p.idCol = "id"
+	}
+
+	limitStr := q.Get("limit")
+	if limitStr == "" {
+		limitStr = q.Get("page_size")
+	}
+	if limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return p, errors.New("limit must be a positive integer")
+		}
+		if limit > MaxLimit {
+			return p, fmt.Errorf("limit must not exceed %d", MaxLimit)
+		}
+		p.Limit = limit
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		p.Desc = strings.HasPrefix(sort, "-")
+		p.Sort = strings.TrimPrefix(sort, "-")
+	}
+	field, ok := opts.Sorts[p.Sort]
+	if !ok {
+		return p, fmt.Errorf("unsupported sort %q", p.Sort)
+	}
+	p.field = field
+
+	if token := q.Get("cursor"); token != "" {
+		c, err := decode(token)
+		if err != nil {
+			return p, err
+		}
+		// A cursor only makes sense for the ordering that produced it
+		if c.Sort != p.Sort || c.Desc != p.Desc {
+			return p, ErrInvalidCursor
+		}
+		p.cursor = c
+	}
+	return p, nil
+}
+
+// Where returns the keyset condition (without a leading AND) and its arguments,
+// or an empty string on the first page.
+func (p Params) Where() (string, []interface{}) {
+	if p.cursor == nil {
+		return "", nil
+	}
+	value, err := p.field.parse(p.cursor.Value)
+	if err != nil {
+		// decode already verified the signature, so this only happens on a kind change
+		return "1 = 0", nil
+	}
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	cond := fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", p.field.Column, op, p.field.Column, p.idCol, op)
+	return cond, []interface{}{value, value, p.cursor.ID}
+}
+
+// OrderLimit returns the ORDER BY and LIMIT clause. One extra row is fetched so
+// Next can tell whether another page exists.
+func (p Params) OrderLimit() string {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT %d", p.field.Column, dir, p.idCol, dir, p.Limit+1)
+}
+
+// Apply appends the keyset condition, ordering and limit to a query that already
+// has a WHERE clause.
+func (p Params) Apply(query string, args []interface{}) (string, []interface{}) {
+	if cond, condArgs := p.Where(); cond != "" {
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	return query + p.OrderLimit(), args
+}
+
+// HasMore reports whether more rows than the page size were fetched.
+func (p Params) HasMore(fetched int) bool {
+	return fetched > p.Limit
+}
+
+// Next returns the cursor for the page after the row with sort value and id.
+func (p Params) Next(value interface{}, id int64) string {
+	return encode(cursor{Sort: p.Sort, Desc: p.Desc, Value: p.field.format(value), ID: id})
+}
+
+func (f SortField) format(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (f SortField) parse(value string) (interface{}, error) {
+	switch f.Kind {
+	case KindInt:
+		return strconv.ParseInt(value, 10, 64)
+	case KindTime:
+		return time.Parse(time.RFC3339Nano, value)
+	}
+	return value, nil
+}
+
+func encode(c cursor) string {
+	payload, _ := json.Marshal(c)
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + sign(body)
+}
+
+func decode(token string) (*cursor, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(sign(parts[0]))) {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -3,13 +3,19 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/validation"
 )
 
 // SuccessResponse represents a standardized success response structure.
 type SuccessResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data"`
-	Message string      `json:"message"`
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data"`
+	Message    string      `json:"message"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
 // ErrorResponse represents a standardized error response structure.
@@ -18,10 +24,28 @@ type ErrorResponse struct {
 	Error   APIError `json:"error"`
 }
 
+// APIError carries a stable machine-readable code (see the apperrors catalog)
+// alongside the HTTP status and human-readable messages.
 type APIError struct {
-	Code    string `json:"code"`
-	Msg     string `json:"msg"`
-	Details string `json:"details"`
+	Code      string                  `json:"code"`
+	Status    int                     `json:"status"`
+	Msg       string                  `json:"msg"`
+	Details   string                  `json:"details"`
+	Fields    []validation.FieldError `json:"fields,omitempty"`
+	RequestID string                  `json:"request_id,omitempty"`
+}
+
+// ProblemDetails is the RFC 7807 body sent when the client accepts
+// application/problem+json.
+type ProblemDetails struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail"`
+	Instance  string                  `json:"instance,omitempty"`
+	Code      string                  `json:"code"`
+	Fields    []validation.FieldError `json:"fields,omitempty"`
+	RequestID string                  `json:"request_id,omitempty"`
 }
 
 // WarningResponse represents a standardized warning response structure.
@@ -49,6 +73,29 @@ func WriteSuccessResponse(w http.ResponseWriter, data interface{}, message strin
 	json.NewEncoder(w).Encode(response)
 }
 
+// WritePageResponse writes a success response for one page of a list. nextCursor
+// is empty on the last page.
+func WritePageResponse(w http.ResponseWriter, data interface{}, nextCursor string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	response := SuccessResponse{
+		Success:    true,
+		Data:       data,
+		Message:    message,
+		NextCursor: nextCursor,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// WriteJSON writes body as it is, without the envelope, for endpoints read by
+// machines that only understand their own format, such as health probes.
+func WriteJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
 // WriteErrorResponse writes an error response to the client.
 func WriteErrorResponse(w http.ResponseWriter, code int, apiErr APIError) {
 	w.Header().Set("Content-Type", "application/json")
@@ -60,6 +107,44 @@ func WriteErrorResponse(w http.ResponseWriter, code int, apiErr APIError) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// WriteError maps err through the apperrors catalog and writes it in the standard
+// envelope, or as problem+json when the client asks for it. Errors without a
+// domain code are reported as INTERNAL_ERROR. The request ID is included so
+// clients can quote it in support requests.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := apperrors.From(err)
+	status := appErr.Status()
+	requestID := ""
+	if r != nil {
+		requestID = logging.RequestID(r.Context())
+	}
+
+	if r != nil && strings.Contains(r.Header.Get("Accept"), "application/problem+json") {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ProblemDetails{
+			Type:      "urn:loyalty-points:error:" + strings.ToLower(string(appErr.Code)),
+			Title:     appErr.Title(),
+			Status:    status,
+			Detail:    appErr.Details,
+			Instance:  r.URL.Path,
+			Code:      string(appErr.Code),
+			Fields:    appErr.Fields,
+			RequestID: requestID,
+		})
+		return
+	}
+
+	WriteErrorResponse(w, status, APIError{
+		Code:      string(appErr.Code),
+		Status:    status,
+		Msg:       appErr.Title(),
+		Details:   appErr.Details,
+		Fields:    appErr.Fields,
+		RequestID: requestID,
+	})
+}
+
 // WriteWarningResponse writes a warning response to the client.
 func WriteWarningResponse(w http.ResponseWriter, data interface{}, apiWarn APIWarning) {
 	w.Header().Set("Content-Type", "application/json")
@@ -71,32 +156,3 @@ func WriteWarningResponse(w http.ResponseWriter, data interface{}, apiWarn APIWa
 	}
 	json.NewEncoder(w).Encode(response)
 }
-
-// // CreateUserHandler handles user creation and logs the action
-// func CreateUserHandler(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-// 	if r.Method != http.MethodPost {
-// 		WriteErrorResponse(w, http.StatusMethodNotAllowed, APIError{
-// 			Code:    "405",
-// 			Msg:     "Method Not Allowed",
-
-// // Example Usage
-// // func ExampleHandler(w http.ResponseWriter, r *http.Request) {
-// 	// On success:
-// 	WriteSuccessResponse(w, []string{"item1", "item2"}, "Operation successful.")
-//
-// 	// On error:
-// 	apiErr := APIError{
-// 		Code:    "400",
-// 		Msg:     "Bad Request",
-// 		Details: "Invalid input data",
-// 	}
-// 	WriteErrorResponse(w, http.StatusBadRequest, apiErr)
-//
-// 	// On warning:
-// 	apiWarn := APIWarning{
-// 		Code:    "1001",
-// 		Msg:     "Partial Data",
-// 		Details: "Some data could not be retrieved.",
-// 	}
-// 	WriteWarningResponse(w, []string{"item1"}, apiWarn)
-// }
@@ -0,0 +1,210 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/handlers"
+	"loyalty-points-system-api/internal/health"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/metrics"
+	"loyalty-points-system-api/internal/openapi"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// Route is one API operation. Path is the OpenAPI path template, e.g.
+// /users/{id}/points/history; Pattern is the ServeMux pattern that serves it.
+// Several routes may share a subtree pattern such as "/users/".
+type Route struct {
+	Method  string
+	Path    string
+	Pattern string
+	Handler http.Handler
+}
+
+// Table returns every route the API serves. The OpenAPI spec is checked against
+// this table in tests, so a route added here without a spec entry fails the build.
+func Table(db *sql.DB, cfg *config.Config, scheduler *jobs.Scheduler, checker *health.Checker) []Route {
+	auth := middleware.AuthMiddleware
+	// Retried POSTs with the same Idempotency-Key get the first response back
+	idempotent := func(next http.Handler) http.Handler {
+		return middleware.AuthMiddleware(middleware.Idempotency(db, next))
+	}
+	admin := func(next http.Handler) http.Handler {
+		return middleware.AuthMiddleware(middleware.RequireRole(db, middleware.RoleAdmin, next))
+	}
+
+	household := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HouseholdHandler(w, r, db)
+	})
+	invitation := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HouseholdInvitationHandler(w, r, db)
+	})
+	rewards := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.RewardsHandler(w, r, db)
+	})
+	reward := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.RewardHandler(w, r, db)
+	})
+	orders := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.OrdersHandler(w, r, db)
+	})
+	order := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.OrderHandler(w, r, db)
+	})
+	hold := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.HoldHandler(w, r, db)
+	})
+	rates := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.ConversionRatesHandler(w, r, db)
+	})
+
+	return []Route{
+		{http.MethodPost, "/login", "/login", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.LoginHandler(w, r, db, cfg)
+		})},
+		{http.MethodPost, "/refresh", "/refresh", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.RefreshTokenHandler(w, r, db, cfg)
+		})},
+		{http.MethodGet, "/health", "/health", http.HandlerFunc(handlers.HealthCheckHandler)},
+		{http.MethodGet, "/livez", "/livez", http.HandlerFunc(handlers.LivezHandler)},
+		{http.MethodGet, "/readyz", "/readyz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.ReadyzHandler(w, r, checker)
+		})},
+		{http.MethodGet, "/metrics", "/metrics", metrics.Handler()},
+		{http.MethodPost, "/create-user", "/create-user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.CreateUserHandler(w, r, db)
+		})},
+		{http.MethodGet, "/get-all-users", "/get-all-users", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.GetAllUsersHandler(w, r, db)
+		}))},
+
+		// Ledger routes require a valid access token
+		{http.MethodPost, "/add-transaction", "/add-transaction", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.AddTransactionHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/points-balance", "/points-balance", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.PointsBalanceHandler(w, r, db)
+		}))},
+		{http.MethodPost, "/redeem", "/redeem", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.RedeemPointsHandler(w, r, db)
+		}))},
+		{http.MethodPost, "/transfers", "/transfers", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.TransferPointsHandler(w, r, db)
+		}))},
+
+		// Two-phase holds. POS terminals retry captures, so the whole subtree
+		// accepts Idempotency-Key
+		{http.MethodPost, "/holds", "/holds", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.AuthorizeHoldHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/holds/{id}", "/holds/", idempotent(hold)},
+		{http.MethodPost, "/holds/{id}/capture", "/holds/", idempotent(hold)},
+		{http.MethodPost, "/holds/{id}/release", "/holds/", idempotent(hold)},
+
+		// Points as payment. Adding a rate shares the pattern with listing them,
+		// so the service checks the admin role
+		{http.MethodGet, "/points-quote", "/points-quote", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.PointsQuoteHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/conversion-rates", "/conversion-rates", auth(rates)},
+		{http.MethodPost, "/conversion-rates", "/conversion-rates", auth(rates)},
+
+		// Household pools. The subtree shares one handler, so all of it accepts
+		// Idempotency-Key; it matters for the redeem POST
+		{http.MethodPost, "/households", "/households", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.CreateHouseholdHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/households/{id}", "/households/", idempotent(household)},
+		{http.MethodGet, "/households/{id}/history", "/households/", idempotent(household)},
+		{http.MethodPost, "/households/{id}/invitations", "/households/", idempotent(household)},
+		{http.MethodPost, "/households/{id}/redeem", "/households/", idempotent(household)},
+		{http.MethodPatch, "/households/{id}/members/{user_id}", "/households/", idempotent(household)},
+		{http.MethodDelete, "/households/{id}/members/{user_id}", "/households/", idempotent(household)},
+		{http.MethodGet, "/household-invitations", "/household-invitations", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListHouseholdInvitationsHandler(w, r, db)
+		}))},
+		{http.MethodPost, "/household-invitations/{id}/accept", "/household-invitations/", auth(invitation)},
+		{http.MethodPost, "/household-invitations/{id}/decline", "/household-invitations/", auth(invitation)},
+
+		// Rewards catalog and orders. Writes to the catalog and order fulfillment
+		// share patterns with member routes, so the service checks the admin role
+		{http.MethodGet, "/rewards", "/rewards", auth(rewards)},
+		{http.MethodPost, "/rewards", "/rewards", auth(rewards)},
+		{http.MethodGet, "/rewards/{id}", "/rewards/", auth(reward)},
+		{http.MethodPut, "/rewards/{id}", "/rewards/", auth(reward)},
+		{http.MethodPost, "/orders", "/orders", idempotent(orders)},
+		{http.MethodGet, "/orders", "/orders", idempotent(orders)},
+		{http.MethodGet, "/orders/{id}", "/orders/", auth(order)},
+		{http.MethodPost, "/orders/{id}/cancel", "/orders/", auth(order)},
+		{http.MethodPost, "/orders/{id}/fulfill", "/orders/", auth(order)},
+		{http.MethodPost, "/orders/{id}/refund", "/orders/", auth(order)},
+
+		{http.MethodGet, "/users/{id}/points/history", "/users/", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.PointsHistoryHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/transactions", "/transactions", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListTransactionsHandler(w, r, db)
+		}))},
+
+		// Back-office routes, admin only
+		{http.MethodPost, "/transactions/batch", "/transactions/batch", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.BatchTransactionsHandler(w, r, db, cfg)
+		}))},
+		{http.MethodGet, "/export/{dataset}", "/export/", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.ExportHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/audit-log", "/audit-log", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.AuditLogHandler(w, r, db)
+		}))},
+		{http.MethodGet, "/jobs", "/jobs", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListJobsHandler(w, r, scheduler)
+		}))},
+		{http.MethodGet, "/jobs/{name}/runs", "/jobs/", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.JobHandler(w, r, scheduler)
+		}))},
+		{http.MethodPost, "/jobs/{name}/run", "/jobs/", admin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.JobHandler(w, r, scheduler)
+		}))},
+
+		// API documentation
+		{http.MethodGet, "/openapi.json", "/openapi.json", http.HandlerFunc(openapi.SpecHandler)},
+		{http.MethodGet, "/docs", "/docs", http.HandlerFunc(openapi.DocsHandler)},
+	}
+}
+
+// Register adds routes to mux, once per pattern, and sends everything else to
+// the ROUTE_NOT_FOUND handler. Every request is traced, gets an X-Request-ID, is
+// counted per route in /metrics and is throttled by limiter, which may be nil.
+func Register(mux *http.ServeMux, routes []Route, limiter *middleware.RateLimiter) {
+	// Routes sharing a pattern are told apart by method for the metrics label and
+	// rate limit policy
+	paths := map[string]map[string]string{}
+	for _, route := range routes {
+		if paths[route.Pattern] == nil {
+			paths[route.Pattern] = map[string]string{}
+		}
+		paths[route.Pattern][route.Method] = route.Path
+	}
+
+	registered := map[string]bool{}
+	for _, route := range routes {
+		if registered[route.Pattern] {
+			continue
+		}
+		registered[route.Pattern] = true
+		byMethod := paths[route.Pattern]
+		label := func(r *http.Request) string {
+			if path, ok := byMethod[r.Method]; ok {
+				return path
+			}
+			return "unmatched"
+		}
+		mux.Handle(route.Pattern, middleware.Tracing(label,
+			middleware.RequestID(middleware.Metrics(label, limiter.Limit(label, route.Handler)))))
+	}
+	unmatched := func(*http.Request) string { return "unmatched" }
+	mux.Handle("/", middleware.Tracing(unmatched,
+		middleware.RequestID(middleware.Metrics(unmatched, http.HandlerFunc(handlers.NotFoundHandler)))))
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"strings"
+
+	"loyalty-points-system-api/config"
+)
+
+// TransferLimitsFromConfig returns the limits on points transfers.
+func TransferLimitsFromConfig(cfg *config.Config) TransferLimits {
+	limits := TransferLimits{DailyMax: cfg.TransferDailyMax, MinBalance: cfg.TransferMinBalance}
+	for _, tier := range strings.Split(cfg.TransferTiers, ",") {
+		if tier = strings.TrimSpace(tier); tier != "" {
+			limits.Tiers = append(limits.Tiers, tier)
+		}
+	}
+	return limits
+}
+
+// HouseholdSettingsFromConfig returns the household pool settings.
+func HouseholdSettingsFromConfig(cfg *config.Config) HouseholdSettings {
+	return HouseholdSettings{InviteTTL: cfg.HouseholdInviteTTL, MaxMembers: cfg.HouseholdMaxMembers}
+}
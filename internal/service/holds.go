@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// holdTTL is how long an authorized hold lasts. The default matches the config
+// default.
+var holdTTL = 15 * time.Minute
+
+// SetHoldTTL replaces how long authorized holds last. It must be called before
+// serving requests.
+func SetHoldTTL(ttl time.Duration) {
+	holdTTL = ttl
+}
+
+const holdColumns = "id, reference, user_id, points, captured_points, status, created_at, expires_at, closed_at"
+
+// scanHold reads a hold. An authorized hold past its expiry is reported as
+// expired even before the hold_expiry job has marked it.
+func scanHold(row rowScanner) (models.Hold, error) {
+	var h models.Hold
+	var captured sql.NullInt64
+	var closedAt sql.NullTime
+	err := row.Scan(&h.ID, &h.Reference, &h.UserID, &h.Points, &captured, &h.Status, &h.CreatedAt, &h.ExpiresAt, &closedAt)
+	if err != nil {
+		return h, err
+	}
+	h.CapturedPoints = int(captured.Int64)
+	if closedAt.Valid {
+		h.ClosedAt = &closedAt.Time
+	}
+	if h.Status == models.HoldAuthorized && !time.Now().Before(h.ExpiresAt) {
+		h.Status = models.HoldExpired
+		h.ClosedAt = &h.ExpiresAt
+	}
+	return h, nil
+}
+
+// pendingHolds returns the points userID has reserved in authorized holds that
+// have not expired at now.
+func pendingHolds(ctx context.Context, q queryRower, userID int, now time.Time) (int, error) {
+	var pending int
+	err := q.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(points), 0) FROM points_holds WHERE user_id = ? AND status = 'authorized' AND expires_at > ?",
+		userID, now).Scan(&pending)
+	if err != nil {
+		return 0, fmt.Errorf("could not sum pending holds: %w", err)
+	}
+	return pending, nil
+}
+
+// AuthorizeHold reserves points of username's own account. The points stay in
+// the balance but cannot be spent until the hold is captured, released or
+// expires.
+func AuthorizeHold(ctx context.Context, db *sql.DB, username string, req models.AuthorizeHoldRequest) (*models.HoldResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if err := requireOwner(ctx, db, username, req.UserID, "You can only hold your own points"); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", req.UserID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// The member's row lock serializes holds and spending, so the pending sum
+	// cannot go stale
+	var balance int
+	err = tx.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE", req.UserID).Scan(&balance)
+	if err != nil {
+		logger.Error("Error fetching user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	now := time.Now().UTC()
+	pending, err := pendingHolds(ctx, tx, req.UserID, now)
+	if err != nil {
+		logger.Error("Error fetching pending holds", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	if req.Points > balance-pending {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "User does not have enough available points for the hold")
+	}
+
+	hold := models.Hold{
+		Reference: newHoldReference(), UserID: req.UserID, Points: req.Points,
+		Status: models.HoldAuthorized, CreatedAt: now, ExpiresAt: now.Add(holdTTL),
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO points_holds (reference, user_id, points, status, created_at, expires_at)
+		VALUES (?, ?, ?, 'authorized', ?, ?)`,
+		hold.Reference, hold.UserID, hold.Points, hold.CreatedAt, hold.ExpiresAt)
+	if err != nil {
+		logger.Error("Error recording hold", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record hold")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("Error reading hold id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record hold")
+	}
+	hold.ID = int(id)
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+
+	utils.LogAction(ctx, db, req.UserID, "Authorize Hold",
+		fmt.Sprintf("Held %d points until %s. Hold ID: %s", hold.Points, hold.ExpiresAt.Format(time.RFC3339), hold.Reference))
+
+	return holdResponse(hold, balance, pending+hold.Points), nil
+}
+
+// GetHold returns one hold. The caller must own it or be an admin.
+func GetHold(ctx context.Context, db *sql.DB, username string, holdID int) (*models.Hold, error) {
+	hold, err := scanHold(db.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM points_holds WHERE id = ?", holdID))
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeHoldNotFound, "Hold does not exist")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching hold", "hold_id", holdID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch hold")
+	}
+	if err := AuthorizeUserAccess(ctx, db, username, hold.UserID); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// CaptureHold redeems an authorized hold, or part of it, releasing the rest.
+// The owner or an admin may capture.
+func CaptureHold(ctx context.Context, db *sql.DB, username string, holdID int, req models.CaptureHoldRequest) (*models.HoldResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("hold_id", holdID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	hold, balance, err := lockHold(ctx, db, tx, username, holdID)
+	if err != nil {
+		return nil, err
+	}
+	points := req.Points
+	if points == 0 {
+		points = hold.Points
+	}
+	if points > hold.Points {
+		return nil, apperrors.New(apperrors.CodeHoldExceeded,
+			fmt.Sprintf("Cannot capture %d points from hold %s of %d points", points, hold.Reference, hold.Points))
+	}
+	// Lots may have expired since the hold was authorized
+	if points > balance {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "User no longer has enough points to capture the hold")
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			transaction_id, user_id, transaction_amount, category, transaction_date, product_code, points
+		) VALUES (?, ?, ?, 'redemption', ?, 'REDEMPTION', ?)`,
+		hold.Reference, hold.UserID, 0, now, -points)
+	if err != nil {
+		logger.Error("Error creating redemption transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create redemption transaction")
+	}
+	// Spent points come out of the oldest lots first
+	if _, err := ledger.DebitLotsFIFO(ctx, tx, hold.UserID, points, now); err != nil {
+		logger.Error("Error debiting points lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to debit points")
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?", points, hold.UserID); err != nil {
+		logger.Error("Error updating user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+	}
+	// Capturing is member activity, which keeps rolling lots alive
+	if err := ledger.ExtendRollingExpiry(ctx, tx, hold.UserID, now); err != nil {
+		logger.Error("Error extending rolling expiry", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update points expiry")
+	}
+
+	hold.Status, hold.CapturedPoints, hold.ClosedAt = models.HoldCaptured, points, &now
+	pending, err := closeHold(ctx, tx, hold, now)
+	if err != nil {
+		logger.Error("Error closing hold", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update hold")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	ledger.ReportRedeemed(points)
+
+	utils.LogAction(ctx, db, hold.UserID, "Capture Hold",
+		fmt.Sprintf("Captured %d of %d held points. Hold ID: %s", points, hold.Points, hold.Reference))
+
+	return holdResponse(hold, balance-points, pending), nil
+}
+
+// ReleaseHold returns an authorized hold's points to the available balance. The
+// owner or an admin may release.
+func ReleaseHold(ctx context.Context, db *sql.DB, username string, holdID int) (*models.HoldResponse, error) {
+	logger := logging.FromContext(ctx).With("hold_id", holdID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	hold, balance, err := lockHold(ctx, db, tx, username, holdID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	hold.Status, hold.ClosedAt = models.HoldReleased, &now
+	pending, err := closeHold(ctx, tx, hold, now)
+	if err != nil {
+		logger.Error("Error closing hold", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update hold")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+
+	utils.LogAction(ctx, db, hold.UserID, "Release Hold",
+		fmt.Sprintf("Released %d held points. Hold ID: %s", hold.Points, hold.Reference))
+
+	return holdResponse(hold, balance, pending), nil
+}
+
+// ExpireHolds marks authorized holds past their expiry as expired and returns how
+// many were marked. Expired holds already stop counting as pending; this only
+// records it. A dry run only counts them.
+func ExpireHolds(ctx context.Context, db *sql.DB, dryRun bool) (int64, error) {
+	now := time.Now().UTC()
+	if dryRun {
+		var count int64
+		err := db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM points_holds WHERE status = 'authorized' AND expires_at <= ?", now).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("counting expired holds: %w", err)
+		}
+		return count, nil
+	}
+	result, err := db.ExecContext(ctx,
+		"UPDATE points_holds SET status = 'expired', closed_at = expires_at WHERE status = 'authorized' AND expires_at <= ?", now)
+	if err != nil {
+		return 0, fmt.Errorf("expiring holds: %w", err)
+	}
+	expired, _ := result.RowsAffected()
+	logging.FromContext(ctx).Info("Expired points holds", "expired", expired)
+	return expired, nil
+}
+
+// lockHold locks the member who owns holdID, then the hold, and returns the hold
+// with the member's balance. It fails unless the hold is still authorized, and
+// hides holds of other members from callers who are not admins.
+func lockHold(ctx context.Context, db *sql.DB, tx *sql.Tx, username string, holdID int) (models.Hold, int, error) {
+	var hold models.Hold
+	callerID, callerRole, err := caller(ctx, db, username)
+	if err != nil {
+		return hold, 0, err
+	}
+	logger := logging.FromContext(ctx).With("hold_id", holdID)
+
+	// The member is locked before the hold, as authorizations do
+	var userID int
+	err = db.QueryRowContext(ctx, "SELECT user_id FROM points_holds WHERE id = ?", holdID).Scan(&userID)
+	if err == sql.ErrNoRows || (err == nil && callerRole != middleware.RoleAdmin && userID != callerID) {
+		return hold, 0, apperrors.New(apperrors.CodeHoldNotFound, "Hold does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching hold", "err", err)
+		return hold, 0, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch hold")
+	}
+	var balance int
+	err = tx.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE", userID).Scan(&balance)
+	if err != nil {
+		logger.Error("Error fetching user points", "err", err)
+		return hold, 0, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	hold, err = scanHold(tx.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM points_holds WHERE id = ? FOR UPDATE", holdID))
+	if err != nil {
+		logger.Error("Error locking hold", "err", err)
+		return hold, 0, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch hold")
+	}
+
+	switch hold.Status {
+	case models.HoldAuthorized:
+		return hold, balance, nil
+	case models.HoldExpired:
+		return hold, 0, apperrors.New(apperrors.CodeHoldExpired,
+			fmt.Sprintf("Hold %s expired at %s", hold.Reference, hold.ExpiresAt.Format(time.RFC3339)))
+	default:
+		return hold, 0, apperrors.New(apperrors.CodeHoldState, fmt.Sprintf("Hold %s is already %s", hold.Reference, hold.Status))
+	}
+}
+
+// closeHold stores hold's final status and returns the points its member still
+// has pending in other holds.
+func closeHold(ctx context.Context, tx *sql.Tx, hold models.Hold, now time.Time) (int, error) {
+	var captured interface{}
+	if hold.Status == models.HoldCaptured {
+		captured = hold.CapturedPoints
+	}
+	_, err := tx.ExecContext(ctx, "UPDATE points_holds SET status = ?, captured_points = ?, closed_at = ? WHERE id = ?",
+		hold.Status, captured, now, hold.ID)
+	if err != nil {
+		return 0, err
+	}
+	return pendingHolds(ctx, tx, hold.UserID, now)
+}
+
+func holdResponse(hold models.Hold, balance, pending int) *models.HoldResponse {
+	return &models.HoldResponse{
+		Hold:            hold,
+		Balance:         balance,
+		AvailablePoints: max(balance-pending, 0),
+		PendingPoints:   pending,
+	}
+}
+
+// newHoldReference returns a random hold ID such as HOLD_9f86d081884c7d65.
+func newHoldReference() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "HOLD_" + hex.EncodeToString(b)
+}
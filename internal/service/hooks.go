@@ -0,0 +1,21 @@
+package service
+
+// Hooks observe service events that are not ledger changes, e.g. to export
+// metrics. Ledger changes are reported through ledger.SetHooks. Nil fields are
+// skipped.
+type Hooks struct {
+	LoginFailed func()
+}
+
+var hooks Hooks
+
+// SetHooks replaces the service hooks. It must be called before serving requests.
+func SetHooks(h Hooks) {
+	hooks = h
+}
+
+func reportLoginFailed() {
+	if hooks.LoginFailed != nil {
+		hooks.LoginFailed()
+	}
+}
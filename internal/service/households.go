@@ -0,0 +1,686 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// HouseholdSettings configure household pools.
+type HouseholdSettings struct {
+	InviteTTL  time.Duration // how long an invitation can be accepted
+	MaxMembers int           // members per household, head included
+}
+
+// householdSettings are the active settings. The default matches the config defaults.
+var householdSettings = HouseholdSettings{InviteTTL: 168 * time.Hour, MaxMembers: 6}
+
+// SetHouseholdSettings replaces the household settings. It must be called before
+// serving requests.
+func SetHouseholdSettings(s HouseholdSettings) {
+	householdSettings = s
+}
+
+// HouseholdHistoryPageOptions are the sort orders offered for a household's
+// points history.
+var HouseholdHistoryPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"occurred_at": {Column: "occurred_at", Kind: pagination.KindTime},
+		"points":      {Column: "points", Kind: pagination.KindInt},
+	},
+	DefaultSort: "occurred_at",
+	DefaultDesc: true,
+	IDColumn:    "entry_key",
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// householdRole returns userID's role in householdID, or "" when they are not a
+// member. It fails with HOUSEHOLD_NOT_FOUND when the household does not exist.
+func householdRole(ctx context.Context, q queryRower, householdID, userID int) (string, error) {
+	var exists int
+	err := q.QueryRowContext(ctx, "SELECT 1 FROM households WHERE id = ?", householdID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return "", apperrors.New(apperrors.CodeHouseholdNotFound, "Household does not exist")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching household", "household_id", householdID, "err", err)
+		return "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household")
+	}
+
+	var role string
+	err = q.QueryRowContext(ctx, "SELECT role FROM household_members WHERE household_id = ? AND user_id = ?",
+		householdID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching household membership", "household_id", householdID, "err", err)
+		return "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household membership")
+	}
+	return role, nil
+}
+
+// requireHead checks that username heads householdID and returns their id.
+func requireHead(ctx context.Context, db *sql.DB, username string, householdID int, forbidden string) (int, error) {
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return 0, err
+	}
+	role, err := householdRole(ctx, db, householdID, callerID)
+	if err != nil {
+		return 0, err
+	}
+	if role != models.HouseholdRoleHead {
+		return 0, apperrors.New(apperrors.CodeHouseholdRole, forbidden)
+	}
+	return callerID, nil
+}
+
+// CreateHousehold creates a household headed by username, who must not belong to
+// one already. Points the head has earned so far stay in their own balance.
+func CreateHousehold(ctx context.Context, db *sql.DB, username string, req models.CreateHouseholdRequest) (*models.HouseholdResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", callerID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, "INSERT INTO households (name, head_user_id, created_at) VALUES (?, ?, ?)",
+		req.Name, callerID, now)
+	if err != nil {
+		logger.Error("Error creating household", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create household")
+	}
+	householdID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("Error reading household id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create household")
+	}
+	// household_members.user_id is unique, so a member cannot head a second household
+	_, err = tx.ExecContext(ctx, "INSERT INTO household_members (household_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)",
+		householdID, callerID, models.HouseholdRoleHead, now)
+	if ledger.IsDuplicate(err) {
+		return nil, apperrors.New(apperrors.CodeHouseholdMember, "You already belong to a household")
+	} else if err != nil {
+		logger.Error("Error adding household head", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create household")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	utils.LogAction(ctx, db, callerID, "Create Household", fmt.Sprintf("Created household %d (%s)", householdID, req.Name))
+
+	return loadHousehold(ctx, db, int(householdID))
+}
+
+// GetHousehold returns householdID with its pooled balance and members. The caller
+// must be a member or an admin.
+func GetHousehold(ctx context.Context, db *sql.DB, username string, householdID int) (*models.HouseholdResponse, error) {
+	if err := authorizeHouseholdAccess(ctx, db, username, householdID); err != nil {
+		return nil, err
+	}
+	return loadHousehold(ctx, db, householdID)
+}
+
+// authorizeHouseholdAccess checks that the caller belongs to householdID or is an
+// admin.
+func authorizeHouseholdAccess(ctx context.Context, db *sql.DB, username string, householdID int) error {
+	callerID, callerRole, err := caller(ctx, db, username)
+	if err != nil {
+		return err
+	}
+	role, err := householdRole(ctx, db, householdID, callerID)
+	if err != nil {
+		return err
+	}
+	if role == "" && callerRole != middleware.RoleAdmin {
+		return apperrors.New(apperrors.CodeForbidden, "You can only access your own household")
+	}
+	return nil
+}
+
+func loadHousehold(ctx context.Context, db *sql.DB, householdID int) (*models.HouseholdResponse, error) {
+	logger := logging.FromContext(ctx).With("household_id", householdID)
+	household := &models.HouseholdResponse{ID: householdID, Members: []models.HouseholdMember{}}
+	err := db.QueryRowContext(ctx, "SELECT name, head_user_id, loyalty_points, created_at FROM households WHERE id = ?",
+		householdID).Scan(&household.Name, &household.HeadUserID, &household.Balance, &household.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeHouseholdNotFound, "Household does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching household", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household")
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT m.user_id, u.username, m.role, m.joined_at
+		FROM household_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.household_id = ?
+		ORDER BY m.joined_at, m.user_id`, householdID)
+	if err != nil {
+		logger.Error("Error fetching household members", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household members")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m models.HouseholdMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt); err != nil {
+			logger.Error("Error scanning household member", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household members")
+		}
+		household.Members = append(household.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over household members", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household members")
+	}
+	return household, nil
+}
+
+// HouseholdHistory returns one page of householdID's pool history, namely points
+// earned into the pool, pool lots that expired and pool redemptions, and the
+// cursor of the next page. The caller must be a member or an admin.
+func HouseholdHistory(ctx context.Context, db *sql.DB, username string, householdID int, page pagination.Params) ([]models.PointsHistoryEntry, string, error) {
+	if err := authorizeHouseholdAccess(ctx, db, username, householdID); err != nil {
+		return nil, "", err
+	}
+	logger := logging.FromContext(ctx).With("household_id", householdID)
+
+	// Entry keys follow the personal history: id * 4 for lots, + 1 for their
+	// expiry and + 2 for transactions
+	query, args := page.Apply(`
+		SELECT entry_key, entry_type, points, occurred_at, reference, reason FROM (
+			SELECT id * 4 AS entry_key, 'Earned' AS entry_type, points, transaction_date AS occurred_at,
+				transaction_id AS reference, CONCAT('Purchase by user ', user_id) AS reason
+			FROM points WHERE household_id = ? AND transaction_type IN ('Earned', 'Expired')
+			UNION ALL
+			SELECT id * 4 + 1, 'Expired', -(points - consumed), valid_until, transaction_id, 'Points expired'
+			FROM points WHERE household_id = ? AND transaction_type = 'Expired'
+			UNION ALL
+			SELECT id * 4 + 2, 'Redeemed', points, transaction_date, transaction_id, CONCAT('Redeemed by user ', user_id)
+			FROM transactions WHERE household_id = ? AND category = 'redemption'
+		) history
+		WHERE 1 = 1`, []interface{}{householdID, householdID, householdID})
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Error fetching household history", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household history")
+	}
+	defer rows.Close()
+
+	history := []models.PointsHistoryEntry{}
+	fetched := 0
+	for rows.Next() {
+		var entry models.PointsHistoryEntry
+		if err := rows.Scan(&entry.EntryID, &entry.Type, &entry.Points, &entry.OccurredAt, &entry.Reference, &entry.Reason); err != nil {
+			logger.Error("Error scanning household history row", "err", err)
+			return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process household history")
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over rows", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process household history")
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := history[len(history)-1]
+		var value interface{} = last.OccurredAt
+		if page.Sort == "points" {
+			value = last.Points
+		}
+		nextCursor = page.Next(value, last.EntryID)
+	}
+	return history, nextCursor, nil
+}
+
+// InviteHouseholdMember invites a member who is not in a household to join
+// householdID. Only the head may invite. An earlier pending invitation of the
+// same member is revoked.
+func InviteHouseholdMember(ctx context.Context, db *sql.DB, username string, householdID int, req models.InviteMemberRequest) (*models.HouseholdInvitation, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	headID, err := requireHead(ctx, db, username, householdID, "Only the head of the household can invite members")
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("household_id", householdID, "invitee_id", req.UserID)
+
+	var inviteeHousehold sql.NullInt64
+	err = db.QueryRowContext(ctx, `
+		SELECT m.household_id FROM users u
+		LEFT JOIN household_members m ON m.user_id = u.id
+		WHERE u.id = ?`, req.UserID).Scan(&inviteeHousehold)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeUserNotFound, "Invited user does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching invitee", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user data")
+	}
+	if inviteeHousehold.Valid {
+		return nil, apperrors.New(apperrors.CodeHouseholdMember, "The invited user already belongs to a household")
+	}
+
+	var members int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM household_members WHERE household_id = ?", householdID).Scan(&members); err != nil {
+		logger.Error("Error counting household members", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household members")
+	}
+	if members >= householdSettings.MaxMembers {
+		return nil, apperrors.New(apperrors.CodeHouseholdFull,
+			fmt.Sprintf("A household has at most %d members", householdSettings.MaxMembers))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE household_invitations SET status = 'revoked', responded_at = ?
+		WHERE household_id = ? AND invitee_user_id = ? AND status = 'pending'`,
+		now, householdID, req.UserID); err != nil {
+		logger.Error("Error revoking earlier invitations", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create invitation")
+	}
+	invitation := &models.HouseholdInvitation{
+		HouseholdID:   householdID,
+		InviteeUserID: req.UserID,
+		Role:          req.Role,
+		Status:        models.InvitationPending,
+		InvitedBy:     headID,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(householdSettings.InviteTTL),
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO household_invitations (household_id, invitee_user_id, role, invited_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		householdID, req.UserID, req.Role, headID, now, invitation.ExpiresAt)
+	if err != nil {
+		logger.Error("Error creating invitation", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create invitation")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("Error reading invitation id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create invitation")
+	}
+	invitation.ID = int(id)
+	if err := tx.QueryRowContext(ctx, "SELECT name FROM households WHERE id = ?", householdID).Scan(&invitation.HouseholdName); err != nil {
+		logger.Error("Error fetching household name", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	utils.LogAction(ctx, db, headID, "Invite Household Member",
+		fmt.Sprintf("Invited user %d to household %d as %s. Invitation ID: %d", req.UserID, householdID, req.Role, invitation.ID))
+	return invitation, nil
+}
+
+// ListHouseholdInvitations returns username's pending invitations that have not
+// expired, newest first.
+func ListHouseholdInvitations(ctx context.Context, db *sql.DB, username string) ([]models.HouseholdInvitation, error) {
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", callerID)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT i.id, i.household_id, h.name, i.invitee_user_id, i.role, i.status, i.invited_by, i.created_at, i.expires_at
+		FROM household_invitations i
+		JOIN households h ON h.id = i.household_id
+		WHERE i.invitee_user_id = ? AND i.status = 'pending' AND i.expires_at > ?
+		ORDER BY i.created_at DESC, i.id DESC`, callerID, time.Now().UTC())
+	if err != nil {
+		logger.Error("Error fetching invitations", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch invitations")
+	}
+	defer rows.Close()
+
+	invitations := []models.HouseholdInvitation{}
+	for rows.Next() {
+		var i models.HouseholdInvitation
+		if err := rows.Scan(&i.ID, &i.HouseholdID, &i.HouseholdName, &i.InviteeUserID, &i.Role, &i.Status,
+			&i.InvitedBy, &i.CreatedAt, &i.ExpiresAt); err != nil {
+			logger.Error("Error scanning invitation", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch invitations")
+		}
+		invitations = append(invitations, i)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over invitations", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch invitations")
+	}
+	return invitations, nil
+}
+
+// AcceptHouseholdInvitation adds username to the inviting household with the
+// invited role and returns the household. Their later purchases earn into its
+// pool; points earned before stay in their own balance.
+func AcceptHouseholdInvitation(ctx context.Context, db *sql.DB, username string, invitationID int) (*models.HouseholdResponse, error) {
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", callerID, "invitation_id", invitationID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	invitation, err := lockInvitation(ctx, tx, callerID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The household row lock serializes acceptances, so the member count cannot go stale
+	var members int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM households WHERE id = ? FOR UPDATE", invitation.HouseholdID).Scan(new(int))
+	if err == nil {
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM household_members WHERE household_id = ?", invitation.HouseholdID).Scan(&members)
+	}
+	if err != nil {
+		logger.Error("Error locking household", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household")
+	}
+	if members >= householdSettings.MaxMembers {
+		return nil, apperrors.New(apperrors.CodeHouseholdFull,
+			fmt.Sprintf("A household has at most %d members", householdSettings.MaxMembers))
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, "INSERT INTO household_members (household_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)",
+		invitation.HouseholdID, callerID, invitation.Role, now)
+	if ledger.IsDuplicate(err) {
+		return nil, apperrors.New(apperrors.CodeHouseholdMember, "You already belong to a household")
+	} else if err != nil {
+		logger.Error("Error adding household member", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to join household")
+	}
+	if err := respondToInvitation(ctx, tx, invitationID, models.InvitationAccepted, now); err != nil {
+		logger.Error("Error updating invitation", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update invitation")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	utils.LogAction(ctx, db, callerID, "Join Household",
+		fmt.Sprintf("Joined household %d as %s. Invitation ID: %d", invitation.HouseholdID, invitation.Role, invitationID))
+
+	return loadHousehold(ctx, db, invitation.HouseholdID)
+}
+
+// DeclineHouseholdInvitation declines one of username's pending invitations.
+func DeclineHouseholdInvitation(ctx context.Context, db *sql.DB, username string, invitationID int) (*models.HouseholdInvitation, error) {
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", callerID, "invitation_id", invitationID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	invitation, err := lockInvitation(ctx, tx, callerID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := respondToInvitation(ctx, tx, invitationID, models.InvitationDeclined, time.Now().UTC()); err != nil {
+		logger.Error("Error updating invitation", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update invitation")
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	utils.LogAction(ctx, db, callerID, "Decline Household Invitation",
+		fmt.Sprintf("Declined invitation %d to household %d", invitationID, invitation.HouseholdID))
+
+	invitation.Status = models.InvitationDeclined
+	return invitation, nil
+}
+
+// lockInvitation locks invitationID for inviteeID to respond to. Invitations of
+// other members are reported as not found.
+func lockInvitation(ctx context.Context, tx *sql.Tx, inviteeID, invitationID int) (*models.HouseholdInvitation, error) {
+	var i models.HouseholdInvitation
+	err := tx.QueryRowContext(ctx, `
+		SELECT i.id, i.household_id, h.name, i.invitee_user_id, i.role, i.status, i.invited_by, i.created_at, i.expires_at
+		FROM household_invitations i
+		JOIN households h ON h.id = i.household_id
+		WHERE i.id = ? FOR UPDATE`, invitationID).Scan(&i.ID, &i.HouseholdID, &i.HouseholdName, &i.InviteeUserID,
+		&i.Role, &i.Status, &i.InvitedBy, &i.CreatedAt, &i.ExpiresAt)
+	if err == sql.ErrNoRows || (err == nil && i.InviteeUserID != inviteeID) {
+		return nil, apperrors.New(apperrors.CodeInvitationNotFound, "Invitation does not exist")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching invitation", "invitation_id", invitationID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch invitation")
+	}
+	if i.Status != models.InvitationPending {
+		return nil, apperrors.New(apperrors.CodeInvitationClosed, fmt.Sprintf("Invitation has already been %s", i.Status))
+	}
+	if !time.Now().Before(i.ExpiresAt) {
+		return nil, apperrors.New(apperrors.CodeInvitationClosed, "Invitation has expired")
+	}
+	return &i, nil
+}
+
+func respondToInvitation(ctx context.Context, tx *sql.Tx, invitationID int, status string, at time.Time) error {
+	_, err := tx.ExecContext(ctx, "UPDATE household_invitations SET status = ?, responded_at = ? WHERE id = ?",
+		status, at, invitationID)
+	return err
+}
+
+// UpdateHouseholdMemberRole changes the role of userID in householdID. Only the
+// head may change roles, and the head's own role cannot change.
+func UpdateHouseholdMemberRole(ctx context.Context, db *sql.DB, username string, householdID, userID int, req models.UpdateMemberRoleRequest) (*models.HouseholdResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	headID, err := requireHead(ctx, db, username, householdID, "Only the head of the household can change roles")
+	if err != nil {
+		return nil, err
+	}
+	role, err := householdRole(ctx, db, householdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch role {
+	case "":
+		return nil, apperrors.New(apperrors.CodeHouseholdNoMember, "User is not a member of this household")
+	case models.HouseholdRoleHead:
+		return nil, apperrors.New(apperrors.CodeHouseholdRole, "The head's role cannot change")
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE household_members SET role = ? WHERE household_id = ? AND user_id = ?",
+		req.Role, householdID, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error updating member role", "household_id", householdID, "member_id", userID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update member role")
+	}
+	utils.LogAction(ctx, db, headID, "Update Household Role",
+		fmt.Sprintf("Changed user %d in household %d from %s to %s", userID, householdID, role, req.Role))
+
+	return loadHousehold(ctx, db, householdID)
+}
+
+// RemoveHouseholdMember removes userID from householdID. The head may remove any
+// other member and members may leave; the head cannot leave. Points the member
+// earned into the pool stay there.
+func RemoveHouseholdMember(ctx context.Context, db *sql.DB, username string, householdID, userID int) (*models.HouseholdResponse, error) {
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	callerRole, err := householdRole(ctx, db, householdID, callerID)
+	if err != nil {
+		return nil, err
+	}
+	if callerID != userID && callerRole != models.HouseholdRoleHead {
+		return nil, apperrors.New(apperrors.CodeHouseholdRole, "Only the head of the household can remove other members")
+	}
+	role, err := householdRole(ctx, db, householdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch role {
+	case "":
+		return nil, apperrors.New(apperrors.CodeHouseholdNoMember, "User is not a member of this household")
+	case models.HouseholdRoleHead:
+		return nil, apperrors.New(apperrors.CodeHouseholdRole, "The head cannot leave the household")
+	}
+
+	_, err = db.ExecContext(ctx, "DELETE FROM household_members WHERE household_id = ? AND user_id = ?", householdID, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error removing household member", "household_id", householdID, "member_id", userID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to remove household member")
+	}
+	if callerID == userID {
+		utils.LogAction(ctx, db, userID, "Leave Household", fmt.Sprintf("Left household %d", householdID))
+	} else {
+		utils.LogAction(ctx, db, callerID, "Remove Household Member",
+			fmt.Sprintf("Removed user %d from household %d", userID, householdID))
+	}
+
+	return loadHousehold(ctx, db, householdID)
+}
+
+// RedeemHouseholdPoints spends points from householdID's pool. Only the head and
+// redeemers may redeem.
+func RedeemHouseholdPoints(ctx context.Context, db *sql.DB, username string, householdID int, req models.HouseholdRedeemRequest) (*models.HouseholdRedeemResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	callerID, _, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", callerID, "household_id", householdID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the pool before reading the caller's role, and hold their membership
+	// row, so a role change or removal cannot land between the check and the spend
+	var totalPoints int
+	err = tx.QueryRowContext(ctx, "SELECT loyalty_points FROM households WHERE id = ? FOR UPDATE", householdID).Scan(&totalPoints)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeHouseholdNotFound, "Household does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching household points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household points")
+	}
+	var role string
+	err = tx.QueryRowContext(ctx, "SELECT role FROM household_members WHERE household_id = ? AND user_id = ? FOR SHARE",
+		householdID, callerID).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("Error fetching household membership", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch household membership")
+	}
+	switch role {
+	case "":
+		return nil, apperrors.New(apperrors.CodeForbidden, "You can only redeem from your own household")
+	case models.HouseholdRoleMember:
+		return nil, apperrors.New(apperrors.CodeHouseholdRole, "Only the head and redeemers can redeem household points")
+	}
+
+	if req.Points > totalPoints {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "Household does not have enough points for redemption")
+	}
+
+	now := time.Now()
+	redemptionTxnID := fmt.Sprintf("RED_H%d_%d_%s", householdID, callerID, now.Format("20060102150405"))
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			transaction_id, user_id, transaction_amount, category, transaction_date, product_code, points, household_id
+		) VALUES (?, ?, ?, 'redemption', NOW(), 'REDEMPTION', ?, ?)`,
+		redemptionTxnID, callerID, 0, -req.Points, householdID)
+	if err != nil {
+		logger.Error("Error creating redemption transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create redemption transaction")
+	}
+
+	// Spent points come out of the oldest lots first
+	if _, err := ledger.DebitHouseholdLotsFIFO(ctx, tx, householdID, req.Points, now); err != nil {
+		logger.Error("Error debiting points lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to debit points")
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE households SET loyalty_points = loyalty_points - ? WHERE id = ?", req.Points, householdID)
+	if err != nil {
+		logger.Error("Error updating household points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update household points")
+	}
+
+	// Redeeming is household activity, which keeps the pool's rolling lots alive
+	if err := ledger.ExtendHouseholdRollingExpiry(ctx, tx, householdID, now); err != nil {
+		logger.Error("Error extending rolling expiry", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update points expiry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	ledger.ReportRedeemed(req.Points)
+
+	utils.LogAction(ctx, db, callerID, "Redeem Household Points",
+		fmt.Sprintf("Redeemed %d points from household %d. Transaction ID: %s", req.Points, householdID, redemptionTxnID))
+
+	return &models.HouseholdRedeemResponse{
+		HouseholdID:     householdID,
+		RemainingPoints: totalPoints - req.Points,
+		PointsRedeemed:  req.Points,
+		RedemptionID:    redemptionTxnID,
+	}, nil
+}
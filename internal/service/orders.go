@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// OrderPageOptions are the sort orders offered for order lists.
+var OrderPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"created_at": {Column: "o.created_at", Kind: pagination.KindTime},
+	},
+	DefaultSort: "created_at",
+	DefaultDesc: true,
+	IDColumn:    "o.id",
+}
+
+const orderSelect = `
+	SELECT o.id, o.reference, o.user_id, o.reward_id, r.sku, r.name, o.quantity, o.points, o.status,
+		o.created_at, o.updated_at, o.fulfilled_at, o.closed_at
+	FROM reward_orders o
+	JOIN rewards r ON r.id = o.reward_id`
+
+func scanOrder(row rowScanner) (models.Order, error) {
+	var o models.Order
+	var fulfilledAt, closedAt sql.NullTime
+	err := row.Scan(&o.ID, &o.Reference, &o.UserID, &o.RewardID, &o.RewardSKU, &o.RewardName, &o.Quantity, &o.Points,
+		&o.Status, &o.CreatedAt, &o.UpdatedAt, &fulfilledAt, &closedAt)
+	if fulfilledAt.Valid {
+		o.FulfilledAt = &fulfilledAt.Time
+	}
+	if closedAt.Valid {
+		o.ClosedAt = &closedAt.Time
+	}
+	return o, err
+}
+
+// PlaceOrder orders a reward with the points of username's own account. The
+// reward's stock and the member's points are taken in one database transaction,
+// and the points are recorded as a redemption under the order reference.
+func PlaceOrder(ctx context.Context, db *sql.DB, username string, req models.OrderRequest) (*models.OrderResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if err := requireOwner(ctx, db, username, req.UserID, "You can only order with your own points"); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", req.UserID, "reward_id", req.RewardID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// The member is locked before the reward, as cancellations do, so the two
+	// cannot deadlock
+	var balance int
+	var tier string
+	err = tx.QueryRowContext(ctx, "SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE", req.UserID).Scan(&balance, &tier)
+	if err != nil {
+		logger.Error("Error fetching user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	reward, err := scanReward(tx.QueryRowContext(ctx, "SELECT "+rewardColumns+" FROM rewards WHERE id = ? FOR UPDATE", req.RewardID))
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeRewardNotFound, "Reward does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching reward", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch reward")
+	}
+
+	now := time.Now().UTC()
+	if !availableAt(reward, now) {
+		return nil, apperrors.New(apperrors.CodeRewardUnavailable, fmt.Sprintf("Reward %s cannot be ordered now", reward.SKU))
+	}
+	if !tierAllowed(reward, tier) {
+		return nil, apperrors.New(apperrors.CodeRewardTier, fmt.Sprintf("Reward %s is not available to tier %s", reward.SKU, tier))
+	}
+	if reward.Stock != nil && *reward.Stock < req.Quantity {
+		return nil, apperrors.New(apperrors.CodeRewardOutOfStock, fmt.Sprintf("Only %d of reward %s left", *reward.Stock, reward.SKU))
+	}
+	// Points reserved by holds cannot pay for orders
+	pending, err := pendingHolds(ctx, tx, req.UserID, now)
+	if err != nil {
+		logger.Error("Error fetching pending holds", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	points := reward.PointsCost * req.Quantity
+	if points > balance-pending {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "User does not have enough points for this order")
+	}
+
+	if reward.Stock != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE rewards SET stock = stock - ? WHERE id = ?", req.Quantity, reward.ID); err != nil {
+			logger.Error("Error reserving stock", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to reserve stock")
+		}
+	}
+
+	reference := newOrderReference()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			transaction_id, user_id, transaction_amount, category, transaction_date, product_code, points
+		) VALUES (?, ?, ?, 'redemption', ?, ?, ?)`,
+		reference, req.UserID, 0, now, reward.SKU, -points)
+	if err != nil {
+		logger.Error("Error creating redemption transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create redemption transaction")
+	}
+	// Spent points come out of the oldest lots first
+	slices, err := ledger.DebitLotsFIFO(ctx, tx, req.UserID, points, now)
+	if err != nil {
+		logger.Error("Error debiting points lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to debit points")
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?", points, req.UserID); err != nil {
+		logger.Error("Error updating user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+	}
+	// Ordering is member activity, which keeps rolling lots alive
+	if err := ledger.ExtendRollingExpiry(ctx, tx, req.UserID, now); err != nil {
+		logger.Error("Error extending rolling expiry", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update points expiry")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO reward_orders (reference, user_id, reward_id, quantity, points, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 'pending', ?, ?)`,
+		reference, req.UserID, reward.ID, req.Quantity, points, now, now)
+	if err != nil {
+		logger.Error("Error recording order", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record order")
+	}
+	orderID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("Error reading order id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record order")
+	}
+	// The lots are kept so that a cancellation or refund can give them back
+	if err := ledger.SaveOrderLots(ctx, tx, orderID, slices); err != nil {
+		logger.Error("Error recording order lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record order")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	ledger.ReportRedeemed(points)
+
+	utils.LogAction(ctx, db, req.UserID, "Place Order",
+		fmt.Sprintf("Ordered %d x %s for %d points. Order ID: %s", req.Quantity, reward.SKU, points, reference))
+
+	return &models.OrderResponse{
+		Order: models.Order{
+			ID: int(orderID), Reference: reference, UserID: req.UserID,
+			RewardID: reward.ID, RewardSKU: reward.SKU, RewardName: reward.Name,
+			Quantity: req.Quantity, Points: points, Status: models.OrderPending,
+			CreatedAt: now, UpdatedAt: now,
+		},
+		RemainingPoints: balance - points,
+	}, nil
+}
+
+// ListOrders returns one page of orders, newest first, optionally only those in
+// status, and the cursor of the next page. userID 0 lists every member's orders
+// and is admin only; otherwise the caller must own the account or be an admin.
+func ListOrders(ctx context.Context, db *sql.DB, username string, userID int, status string, page pagination.Params) ([]models.Order, string, error) {
+	if userID == 0 {
+		if _, err := requireAdmin(ctx, db, username); err != nil {
+			return nil, "", err
+		}
+	} else if err := AuthorizeUserAccess(ctx, db, username, userID); err != nil {
+		return nil, "", err
+	}
+	logger := logging.FromContext(ctx).With("user_id", userID)
+
+	query := orderSelect + " WHERE 1 = 1"
+	var args []interface{}
+	if userID != 0 {
+		query += " AND o.user_id = ?"
+		args = append(args, userID)
+	}
+	if status != "" {
+		query += " AND o.status = ?"
+		args = append(args, status)
+	}
+	query, args = page.Apply(query, args)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Error fetching orders", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch orders")
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	fetched := 0
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			logger.Error("Error scanning order", "err", err)
+			return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch orders")
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over orders", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch orders")
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := orders[len(orders)-1]
+		nextCursor = page.Next(last.CreatedAt, int64(last.ID))
+	}
+	return orders, nextCursor, nil
+}
+
+// GetOrder returns one order. The caller must have placed it or be an admin.
+func GetOrder(ctx context.Context, db *sql.DB, username string, orderID int) (*models.Order, error) {
+	o, err := scanOrder(db.QueryRowContext(ctx, orderSelect+" WHERE o.id = ?", orderID))
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeOrderNotFound, "Order does not exist")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching order", "order_id", orderID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch order")
+	}
+	if err := AuthorizeUserAccess(ctx, db, username, o.UserID); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// orderTransition moves an order from one status to another.
+type orderTransition struct {
+	from, to     string
+	action       string // audit log action
+	adminOnly    bool   // otherwise the member who placed the order may too
+	restoreStock bool   // put the quantity back into the reward's stock
+	refundSuffix string // with a suffix, the points are credited back under reference+suffix
+}
+
+var (
+	fulfillOrder = orderTransition{from: models.OrderPending, to: models.OrderFulfilled, action: "Fulfill Order", adminOnly: true}
+	cancelOrder  = orderTransition{from: models.OrderPending, to: models.OrderCancelled, action: "Cancel Order",
+		restoreStock: true, refundSuffix: "_CANCEL"}
+	refundOrder = orderTransition{from: models.OrderFulfilled, to: models.OrderRefunded, action: "Refund Order",
+		adminOnly: true, refundSuffix: "_REFUND"}
+)
+
+// FulfillOrder marks a pending order as fulfilled. Admin only.
+func FulfillOrder(ctx context.Context, db *sql.DB, username string, orderID int) (*models.Order, error) {
+	return transitionOrder(ctx, db, username, orderID, fulfillOrder)
+}
+
+// CancelOrder cancels a pending order, returning its points to the member and its
+// quantity to the reward's stock. The member who placed it or an admin may cancel.
+func CancelOrder(ctx context.Context, db *sql.DB, username string, orderID int) (*models.Order, error) {
+	return transitionOrder(ctx, db, username, orderID, cancelOrder)
+}
+
+// RefundOrder refunds a fulfilled order, returning its points to the member. The
+// stock is not restored, since the item was handed out. Admin only.
+func RefundOrder(ctx context.Context, db *sql.DB, username string, orderID int) (*models.Order, error) {
+	return transitionOrder(ctx, db, username, orderID, refundOrder)
+}
+
+func transitionOrder(ctx context.Context, db *sql.DB, username string, orderID int, t orderTransition) (*models.Order, error) {
+	callerID, callerRole, err := caller(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin := callerRole == middleware.RoleAdmin
+	if t.adminOnly && !isAdmin {
+		return nil, apperrors.New(apperrors.CodeForbidden, "This operation requires the admin role")
+	}
+	logger := logging.FromContext(ctx).With("order_id", orderID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// The member is locked before the order and the reward, as new orders do,
+	// so the two cannot deadlock. An order's member never changes, so it can be
+	// read before the lock
+	var ownerID int
+	err = tx.QueryRowContext(ctx, "SELECT user_id FROM reward_orders WHERE id = ?", orderID).Scan(&ownerID)
+	if err == sql.ErrNoRows || (err == nil && !isAdmin && ownerID != callerID) {
+		return nil, apperrors.New(apperrors.CodeOrderNotFound, "Order does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching order", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch order")
+	}
+	var balance int
+	if err := tx.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE", ownerID).Scan(&balance); err != nil {
+		logger.Error("Error locking member", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	// Only the order row is locked here; the reward is locked by the stock update
+	o, err := scanOrder(tx.QueryRowContext(ctx, orderSelect+" WHERE o.id = ? FOR UPDATE OF o", orderID))
+	if err != nil {
+		logger.Error("Error fetching order", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch order")
+	}
+	if o.Status != t.from {
+		return nil, apperrors.New(apperrors.CodeOrderState,
+			fmt.Sprintf("Order %s is %s; only %s orders can be moved to %s", o.Reference, o.Status, t.from, t.to))
+	}
+
+	now := time.Now().UTC()
+	if t.refundSuffix != "" {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points + ? WHERE id = ?", o.Points, o.UserID); err != nil {
+			logger.Error("Error restoring user points", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO transactions (
+				transaction_id, user_id, transaction_amount, category, transaction_date, product_code, points
+			) VALUES (?, ?, ?, 'adjustment', ?, ?, ?)`,
+			o.Reference+t.refundSuffix, o.UserID, 0, now, o.RewardSKU, o.Points)
+		if err != nil {
+			logger.Error("Error recording points credit", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record points credit")
+		}
+		// The points go back into lots with the expiry they had when spent
+		if err := ledger.CreditRefund(ctx, tx, o.UserID, o.ID, o.Reference+t.refundSuffix, now); err != nil {
+			logger.Error("Error restoring points lots", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to restore points")
+		}
+	}
+	if t.restoreStock {
+		_, err := tx.ExecContext(ctx, "UPDATE rewards SET stock = stock + ? WHERE id = ? AND stock IS NOT NULL", o.Quantity, o.RewardID)
+		if err != nil {
+			logger.Error("Error restoring stock", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to restore stock")
+		}
+	}
+
+	o.Status, o.UpdatedAt = t.to, now
+	if t.to == models.OrderFulfilled {
+		o.FulfilledAt = &now
+	} else {
+		o.ClosedAt = &now
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE reward_orders SET status = ?, updated_at = ?, fulfilled_at = ?, closed_at = ? WHERE id = ?",
+		o.Status, now, o.FulfilledAt, o.ClosedAt, orderID)
+	if err != nil {
+		logger.Error("Error updating order", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update order")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+
+	detail := fmt.Sprintf("Order %s %s by user %d", o.Reference, o.Status, callerID)
+	if t.refundSuffix != "" {
+		detail += fmt.Sprintf("; %d points returned", o.Points)
+	}
+	utils.LogAction(ctx, db, o.UserID, t.action, detail)
+	return &o, nil
+}
+
+// newOrderReference returns a random order ID such as ORD_9f86d081884c7d65.
+func newOrderReference() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "ORD_" + hex.EncodeToString(b)
+}
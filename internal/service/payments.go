@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/internal/validation"
+)
+
+// defaultCurrency is the currency of purchases and quotes that do not name one.
+// The default matches the config default.
+var defaultCurrency = "USD"
+
+// SetDefaultCurrency replaces the currency assumed when a request names none. It
+// must be called before serving requests.
+func SetDefaultCurrency(currency string) {
+	defaultCurrency = currency
+}
+
+// RatePageOptions are the sort orders offered for conversion rates.
+var RatePageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"effective_from": {Column: "effective_from", Kind: pagination.KindTime},
+		"id":             {Column: "id", Kind: pagination.KindInt},
+	},
+	DefaultSort: "effective_from",
+	DefaultDesc: true,
+}
+
+const rateColumns = "id, currency, tier, point_value, effective_from, created_at"
+
+func scanRate(row rowScanner) (models.ConversionRate, error) {
+	var r models.ConversionRate
+	err := row.Scan(&r.ID, &r.Currency, &r.Tier, &r.PointValue, &r.EffectiveFrom, &r.CreatedAt)
+	return r, err
+}
+
+// rateFor returns the conversion rate for tier in effect at at. A rate set for
+// the tier wins over one set for every tier, whichever took effect later.
+func rateFor(ctx context.Context, q queryRower, currency, tier string, at time.Time) (models.ConversionRate, error) {
+	rate, err := scanRate(q.QueryRowContext(ctx, "SELECT "+rateColumns+` FROM conversion_rates
+		WHERE currency = ? AND tier IN (?, '') AND effective_from <= ?
+		ORDER BY tier = '', effective_from DESC
+		LIMIT 1`, currency, tier, at))
+	if err == sql.ErrNoRows {
+		return rate, apperrors.New(apperrors.CodeRateNotFound,
+			fmt.Sprintf("No %s conversion rate is in effect for tier %s", currency, tier))
+	} else if err != nil {
+		return rate, fmt.Errorf("could not look up conversion rate: %w", err)
+	}
+	return rate, nil
+}
+
+// Amounts are worked out in whole cents so that points never pay for more than
+// the amount and the cash part never picks up float noise.
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// pointsFor returns the fewest points that pay for cents at pointValue. A rate
+// that is not positive prices nothing and is an error.
+func pointsFor(cents int64, pointValue float64) (int, error) {
+	if pointValue <= 0 {
+		return 0, fmt.Errorf("conversion rate point value %v is not positive", pointValue)
+	}
+	return int(math.Ceil(float64(cents)/(pointValue*100) - 1e-9)), nil
+}
+
+// pointsValue returns what points pay for at pointValue, in cents, rounded down
+// and capped at cents.
+func pointsValue(points int, pointValue float64, cents int64) int64 {
+	value := int64(math.Floor(float64(points)*pointValue*100 + 1e-9))
+	if value > cents {
+		return cents
+	}
+	return value
+}
+
+// ListConversionRates returns one page of conversion rates, optionally filtered
+// by currency and tier, and the cursor of the next page. Past and future rates
+// are listed alongside the ones in effect.
+func ListConversionRates(ctx context.Context, db *sql.DB, currency, tier string, page pagination.Params) ([]models.ConversionRate, string, error) {
+	logger := logging.FromContext(ctx)
+
+	query := "SELECT " + rateColumns + " FROM conversion_rates WHERE 1 = 1"
+	var args []interface{}
+	if currency != "" {
+		query += " AND currency = ?"
+		args = append(args, currency)
+	}
+	if tier != "" {
+		query += " AND tier = ?"
+		args = append(args, tier)
+	}
+	query, args = page.Apply(query, args)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Error fetching conversion rates", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch conversion rates")
+	}
+	defer rows.Close()
+
+	rates := []models.ConversionRate{}
+	fetched := 0
+	for rows.Next() {
+		r, err := scanRate(rows)
+		if err != nil {
+			logger.Error("Error scanning conversion rate", "err", err)
+			return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch conversion rates")
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		rates = append(rates, r)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over conversion rates", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch conversion rates")
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := rates[len(rates)-1]
+		var value interface{} = last.EffectiveFrom
+		if page.Sort == "id" {
+			value = last.ID
+		}
+		nextCursor = page.Next(value, int64(last.ID))
+	}
+	return rates, nextCursor, nil
+}
+
+// CreateConversionRate adds a rate that takes over from effective_from, or from
+// now. Rates are never edited, so every payment can be traced to the rate it
+// used; a change of rate is a new rate. Admin only.
+func CreateConversionRate(ctx context.Context, db *sql.DB, username string, req models.ConversionRateRequest) (*models.ConversionRate, error) {
+	errs := validation.Validate(req)
+	if strings.Contains(req.Tier, ",") || (req.Tier != "" && strings.TrimSpace(req.Tier) != req.Tier) {
+		errs = append(errs, validation.FieldError{Field: "tier", Rule: "tier", Msg: "must be a tier name without commas or surrounding spaces"})
+	}
+	if len(errs) > 0 {
+		return nil, apperrors.Validation(errs)
+	}
+	adminID, err := requireAdmin(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	effectiveFrom := now.Truncate(time.Second)
+	if req.EffectiveFrom != "" {
+		effectiveFrom, _ = validation.ParseDateTime(req.EffectiveFrom)
+		effectiveFrom = effectiveFrom.UTC()
+	}
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO conversion_rates (currency, tier, point_value, effective_from, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		req.Currency, req.Tier, req.PointValue, effectiveFrom, now)
+	if ledger.IsDuplicate(err) {
+		return nil, apperrors.New(apperrors.CodeRateExists,
+			fmt.Sprintf("A %s rate for this tier already takes effect at %s", req.Currency, effectiveFrom.Format(time.RFC3339)))
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error creating conversion rate", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create conversion rate")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		logging.FromContext(ctx).Error("Error reading conversion rate id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create conversion rate")
+	}
+
+	tier := req.Tier
+	if tier == "" {
+		tier = "every tier"
+	}
+	utils.LogAction(ctx, db, adminID, "Create Conversion Rate",
+		fmt.Sprintf("One point pays %g %s for %s from %s. Rate ID: %d", req.PointValue, req.Currency, tier,
+			effectiveFrom.Format(time.RFC3339), id))
+
+	return &models.ConversionRate{
+		ID: int(id), Currency: req.Currency, Tier: req.Tier, PointValue: req.PointValue,
+		EffectiveFrom: effectiveFrom, CreatedAt: now,
+	}, nil
+}
+
+// QuotePoints prices an amount in points at the rate in effect now for the
+// member's tier, and works out how much of it their available points can pay.
+// The caller must own the account or be an admin.
+func QuotePoints(ctx context.Context, db *sql.DB, username string, req models.PointsQuoteRequest) (*models.PointsQuote, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if err := AuthorizeUserAccess(ctx, db, username, req.UserID); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", req.UserID)
+	if req.Currency == "" {
+		req.Currency = defaultCurrency
+	}
+
+	var balance int
+	var tier string
+	err := db.QueryRowContext(ctx, "SELECT loyalty_points, tier FROM users WHERE id = ?", req.UserID).Scan(&balance, &tier)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeUserNotFound, "User ID does not exist")
+	} else if err != nil {
+		logger.Error("Error fetching user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	now := time.Now().UTC()
+	pending, err := pendingHolds(ctx, db, req.UserID, now)
+	if err != nil {
+		logger.Error("Error fetching pending holds", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	rate, err := rateFor(ctx, db, req.Currency, tier, now)
+	if apperrors.Is(err, apperrors.CodeRateNotFound) {
+		return nil, err
+	} else if err != nil {
+		logger.Error("Error fetching conversion rate", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch conversion rate")
+	}
+
+	cents := toCents(req.Amount)
+	required, err := pointsFor(cents, rate.PointValue)
+	if err != nil {
+		logger.Error("Error pricing points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to price points")
+	}
+	quote := &models.PointsQuote{
+		UserID: req.UserID, Currency: req.Currency, Amount: fromCents(cents), Rate: rate,
+		PointsRequired: required,
+	}
+	if available := balance - pending; available > 0 {
+		quote.AvailablePoints = available
+	}
+	quote.MaxPoints = quote.PointsRequired
+	if quote.AvailablePoints < quote.MaxPoints {
+		quote.MaxPoints = quote.AvailablePoints
+	}
+	value := pointsValue(quote.MaxPoints, rate.PointValue, cents)
+	quote.MaxPointsValue = fromCents(value)
+	quote.CashAmount = fromCents(cents - value)
+	return quote, nil
+}
+
+// payWithPoints spends req.PointsPayment of the member's own balance towards the
+// purchase inside tx, at the rate in effect now, as /points-quote prices it. The
+// client-supplied transaction date is not used, so a purchase cannot be backdated
+// onto a better rate. It records the redemption under a PAY_ reference and the
+// split in points_payments, and returns the payment, whose cash part is what the
+// purchase earns on.
+func payWithPoints(ctx context.Context, tx *sql.Tx, req models.AddTransactionRequest) (*models.PointsPayment, error) {
+	logger := logging.FromContext(ctx).With("user_id", req.UserID)
+
+	var balance int
+	var tier string
+	err := tx.QueryRowContext(ctx, "SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE", req.UserID).Scan(&balance, &tier)
+	if err != nil {
+		logger.Error("Error fetching user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	// Points reserved by holds cannot pay for purchases
+	now := time.Now().UTC()
+	pending, err := pendingHolds(ctx, tx, req.UserID, now)
+	if err != nil {
+		logger.Error("Error fetching pending holds", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	if req.PointsPayment > balance-pending {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "User does not have enough points for this payment")
+	}
+
+	rate, err := rateFor(ctx, tx, req.Currency, tier, now)
+	if apperrors.Is(err, apperrors.CodeRateNotFound) {
+		return nil, err
+	} else if err != nil {
+		logger.Error("Error fetching conversion rate", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch conversion rate")
+	}
+	cents := toCents(req.TransactionAmount)
+	required, err := pointsFor(cents, rate.PointValue)
+	if err != nil {
+		logger.Error("Error pricing points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to price points")
+	}
+	if req.PointsPayment > required {
+		return nil, apperrors.New(apperrors.CodePaymentExceeded,
+			fmt.Sprintf("%d points pay for the whole amount; %d were offered", required, req.PointsPayment))
+	}
+	value := pointsValue(req.PointsPayment, rate.PointValue, cents)
+	payment := &models.PointsPayment{
+		Reference: newPaymentReference(), Currency: req.Currency, RateID: rate.ID, PointValue: rate.PointValue,
+		Points: req.PointsPayment, PointsValue: fromCents(value), CashAmount: fromCents(cents - value),
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO points_payments (reference, transaction_id, user_id, currency, rate_id, points, points_value, cash_amount, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		payment.Reference, req.TransactionID, req.UserID, payment.Currency, payment.RateID, payment.Points,
+		payment.PointsValue, payment.CashAmount, now)
+	if ledger.IsDuplicate(err) {
+		return nil, apperrors.Wrap(err, apperrors.CodeTransactionExists, "Transaction ID has already been recorded")
+	} else if err != nil {
+		logger.Error("Error recording points payment", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record points payment")
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			transaction_id, user_id, transaction_amount, category, transaction_date, product_code, points
+		) VALUES (?, ?, ?, 'redemption', ?, 'REDEMPTION', ?)`,
+		payment.Reference, req.UserID, 0, now, -payment.Points)
+	if err != nil {
+		logger.Error("Error creating redemption transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create redemption transaction")
+	}
+	// Spent points come out of the oldest lots first
+	if _, err := ledger.DebitLotsFIFO(ctx, tx, req.UserID, payment.Points, now); err != nil {
+		logger.Error("Error debiting points lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to debit points")
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?", payment.Points, req.UserID); err != nil {
+		logger.Error("Error updating user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+	}
+	// Paying with points is member activity, which keeps rolling lots alive
+	if err := ledger.ExtendRollingExpiry(ctx, tx, req.UserID, now); err != nil {
+		logger.Error("Error extending rolling expiry", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update points expiry")
+	}
+	return payment, nil
+}
+
+// newPaymentReference returns a random payment ID such as PAY_9f86d081884c7d65.
+func newPaymentReference() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "PAY_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/utils"
+)
+
+// PointsBalance returns userID's balance with one page of earning history, and the
+// cursor of the next page.
+func PointsBalance(ctx context.Context, db *sql.DB, userID int, page pagination.Params) (*models.PointsBalanceResponse, string, error) {
+	logger := logging.FromContext(ctx).With("user_id", userID)
+	var balance int
+	err := db.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ?", userID).Scan(&balance)
+	if err != nil {
+		logger.Error("Error retrieving points balance", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Could not retrieve points balance")
+	}
+	pending, err := pendingHolds(ctx, db, userID, time.Now().UTC())
+	if err != nil {
+		logger.Error("Error retrieving pending holds", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Could not retrieve points balance")
+	}
+
+	history := []models.PointsHistory{}
+	query, args := page.Apply(`SELECT id, transaction_date, points, category FROM transactions WHERE user_id = ?`, []interface{}{userID})
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Error retrieving points history", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Could not retrieve points history")
+	}
+	defer rows.Close()
+
+	var lastID int64
+	var lastDate time.Time
+	var lastPoints, fetched int
+	for rows.Next() {
+		var record models.PointsHistory
+		var category string
+		var id int64
+		var transactionDate time.Time
+		if err := rows.Scan(&id, &transactionDate, &record.Points, &category); err != nil {
+			logger.Error("Error scanning points history row", "err", err)
+			return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Error scanning points history")
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		record.TransactionDate = transactionDate.Format(time.RFC3339Nano)
+		record.Reason = "Transaction - " + category
+		history = append(history, record)
+		lastID, lastDate, lastPoints = id, transactionDate, record.Points
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over rows", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process points history")
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		nextCursor = page.Next(ledgerSortValue(page.Sort, lastDate, lastPoints), lastID)
+	}
+	return &models.PointsBalanceResponse{
+		Balance:         balance,
+		AvailablePoints: max(balance-pending, 0),
+		PendingPoints:   pending,
+		History:         history,
+	}, nextCursor, nil
+}
+
+// RedeemPoints spends points from username's own account and logs the action.
+func RedeemPoints(ctx context.Context, db *sql.DB, username string, req models.RedeemRequest) (*models.RedeemResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if err := requireOwner(ctx, db, username, req.UserID, "You can only redeem your own points"); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", req.UserID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// Check available points
+	var totalPoints int
+	err = tx.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE", req.UserID).Scan(&totalPoints)
+	if err != nil {
+		logger.Error("Error fetching user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	// Points reserved by holds cannot be redeemed
+	pending, err := pendingHolds(ctx, tx, req.UserID, time.Now().UTC())
+	if err != nil {
+		logger.Error("Error fetching pending holds", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	if req.Points > totalPoints-pending {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "User does not have enough points for redemption")
+	}
+
+	// Generate redemption transaction ID
+	redemptionTxnID := fmt.Sprintf("RED_%d_%s", req.UserID, time.Now().Format("20060102150405"))
+
+	// Create a transaction record for the redemption
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			transaction_id, user_id, transaction_amount, category, transaction_date, product_code, points
+		) VALUES (?, ?, ?, 'redemption', NOW(), 'REDEMPTION', ?)`,
+		redemptionTxnID, req.UserID, 0, -req.Points)
+	if err != nil {
+		logger.Error("Error creating redemption transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create redemption transaction")
+	}
+
+	// Spent points come out of the oldest lots first
+	if _, err := ledger.DebitLotsFIFO(ctx, tx, req.UserID, req.Points, time.Now()); err != nil {
+		logger.Error("Error debiting points lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to debit points")
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?", req.Points, req.UserID)
+	if err != nil {
+		logger.Error("Error updating user points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+	}
+
+	// Redeeming is member activity, which keeps rolling lots alive
+	if err := ledger.ExtendRollingExpiry(ctx, tx, req.UserID, time.Now()); err != nil {
+		logger.Error("Error extending rolling expiry", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update points expiry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	ledger.ReportRedeemed(req.Points)
+
+	// Fetch the updated points balance
+	var remainingPoints int
+	if err := db.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ?", req.UserID).Scan(&remainingPoints); err != nil {
+		logger.Error("Error fetching final balance", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch final points balance")
+	}
+
+	utils.LogAction(ctx, db, req.UserID, "Redeem Points", fmt.Sprintf("Redeemed %d points. Transaction ID: %s", req.Points, redemptionTxnID))
+
+	return &models.RedeemResponse{
+		RemainingPoints: remainingPoints,
+		PointsRedeemed:  req.Points,
+		RedemptionID:    redemptionTxnID,
+	}, nil
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/internal/validation"
+)
+
+// RewardPageOptions are the sort orders offered for the rewards catalog.
+var RewardPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"id":          {Column: "id", Kind: pagination.KindInt},
+		"points_cost": {Column: "points_cost", Kind: pagination.KindInt},
+	},
+	DefaultSort: "id",
+}
+
+const rewardColumns = `id, sku, name, description, points_cost, stock, available_from, available_until,
+	tiers, active, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReward(row rowScanner) (models.Reward, error) {
+	var r models.Reward
+	var stock sql.NullInt64
+	var from, until sql.NullTime
+	var tiers string
+	err := row.Scan(&r.ID, &r.SKU, &r.Name, &r.Description, &r.PointsCost, &stock, &from, &until,
+		&tiers, &r.Active, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return r, err
+	}
+	if stock.Valid {
+		n := int(stock.Int64)
+		r.Stock = &n
+	}
+	if from.Valid {
+		r.AvailableFrom = &from.Time
+	}
+	if until.Valid {
+		r.AvailableUntil = &until.Time
+	}
+	r.Tiers = []string{}
+	if tiers != "" {
+		r.Tiers = strings.Split(tiers, ",")
+	}
+	return r, nil
+}
+
+// availableAt reports whether the reward can be ordered at now.
+func availableAt(r models.Reward, now time.Time) bool {
+	return r.Active && (r.AvailableFrom == nil || !now.Before(*r.AvailableFrom)) &&
+		(r.AvailableUntil == nil || now.Before(*r.AvailableUntil))
+}
+
+// tierAllowed reports whether members of tier may order the reward.
+func tierAllowed(r models.Reward, tier string) bool {
+	if len(r.Tiers) == 0 {
+		return true
+	}
+	for _, t := range r.Tiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// rewardFields checks req beyond its validate tags and returns the values stored
+// for its stock, availability window and tiers.
+func rewardFields(req models.RewardRequest) (stock, from, until interface{}, tiers string, err error) {
+	errs := validation.Validate(req)
+	if req.Stock != nil {
+		if *req.Stock < 0 {
+			errs = append(errs, validation.FieldError{Field: "stock", Rule: "min", Msg: "must be at least 0"})
+		}
+		stock = *req.Stock
+	}
+	if f, err := validation.NormalizeDateTime(req.AvailableFrom); err == nil {
+		from = f
+	}
+	if u, err := validation.NormalizeDateTime(req.AvailableUntil); err == nil {
+		until = u
+	}
+	// The normalized layout sorts like the times it holds
+	if f, ok := from.(string); ok {
+		if u, ok := until.(string); ok && u <= f {
+			errs = append(errs, validation.FieldError{Field: "available_until", Rule: "after", Msg: "must be after available_from"})
+		}
+	}
+	var list []string
+	for _, tier := range req.Tiers {
+		tier = strings.TrimSpace(tier)
+		if tier == "" || strings.Contains(tier, ",") {
+			errs = append(errs, validation.FieldError{Field: "tiers", Rule: "tier", Msg: "must be non-empty tier names without commas"})
+			break
+		}
+		list = append(list, tier)
+	}
+	if len(errs) > 0 {
+		return nil, nil, nil, "", apperrors.Validation(errs)
+	}
+	return stock, from, until, strings.Join(list, ","), nil
+}
+
+// ListRewards returns one page of the rewards that can be ordered now, and the
+// cursor of the next page. With all, an admin also sees inactive rewards and
+// those outside their availability window.
+func ListRewards(ctx context.Context, db *sql.DB, username string, all bool, page pagination.Params) ([]models.Reward, string, error) {
+	if all {
+		if _, err := requireAdmin(ctx, db, username); err != nil {
+			return nil, "", err
+		}
+	}
+	logger := logging.FromContext(ctx)
+
+	query := "SELECT " + rewardColumns + " FROM rewards WHERE 1 = 1"
+	var args []interface{}
+	if !all {
+		now := time.Now().UTC()
+		query += " AND active AND (available_from IS NULL OR available_from <= ?) AND (available_until IS NULL OR available_until > ?)"
+		args = append(args, now, now)
+	}
+	query, args = page.Apply(query, args)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Error fetching rewards", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch rewards")
+	}
+	defer rows.Close()
+
+	rewards := []models.Reward{}
+	fetched := 0
+	for rows.Next() {
+		r, err := scanReward(rows)
+		if err != nil {
+			logger.Error("Error scanning reward", "err", err)
+			return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch rewards")
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		rewards = append(rewards, r)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over rewards", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch rewards")
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := rewards[len(rewards)-1]
+		var value interface{} = last.ID
+		if page.Sort == "points_cost" {
+			value = last.PointsCost
+		}
+		nextCursor = page.Next(value, int64(last.ID))
+	}
+	return rewards, nextCursor, nil
+}
+
+// GetReward returns one catalog item.
+func GetReward(ctx context.Context, db *sql.DB, rewardID int) (*models.Reward, error) {
+	r, err := scanReward(db.QueryRowContext(ctx, "SELECT "+rewardColumns+" FROM rewards WHERE id = ?", rewardID))
+	if err == sql.ErrNoRows {
+		return nil, apperrors.New(apperrors.CodeRewardNotFound, "Reward does not exist")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching reward", "reward_id", rewardID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch reward")
+	}
+	return &r, nil
+}
+
+// CreateReward adds an item to the catalog. Admin only.
+func CreateReward(ctx context.Context, db *sql.DB, username string, req models.RewardRequest) (*models.Reward, error) {
+	stock, from, until, tiers, err := rewardFields(req)
+	if err != nil {
+		return nil, err
+	}
+	adminID, err := requireAdmin(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO rewards (sku, name, description, points_cost, stock, available_from, available_until, tiers, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.SKU, req.Name, req.Description, req.PointsCost, stock, from, until, tiers, req.Active, now, now)
+	if ledger.IsDuplicate(err) {
+		return nil, apperrors.New(apperrors.CodeRewardExists, fmt.Sprintf("A reward with SKU %s already exists", req.SKU))
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error creating reward", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create reward")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		logging.FromContext(ctx).Error("Error reading reward id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to create reward")
+	}
+	utils.LogAction(ctx, db, adminID, "Create Reward", fmt.Sprintf("Created reward %d (%s) costing %d points", id, req.SKU, req.PointsCost))
+
+	return GetReward(ctx, db, int(id))
+}
+
+// UpdateReward replaces a catalog item. Orders already placed keep the cost they
+// were placed at. Admin only.
+func UpdateReward(ctx context.Context, db *sql.DB, username string, rewardID int, req models.RewardRequest) (*models.Reward, error) {
+	stock, from, until, tiers, err := rewardFields(req)
+	if err != nil {
+		return nil, err
+	}
+	adminID, err := requireAdmin(ctx, db, username)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetReward(ctx, db, rewardID); err != nil {
+		return nil, err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE rewards
+		SET sku = ?, name = ?, description = ?, points_cost = ?, stock = ?, available_from = ?, available_until = ?,
+			tiers = ?, active = ?, updated_at = ?
+		WHERE id = ?`,
+		req.SKU, req.Name, req.Description, req.PointsCost, stock, from, until, tiers, req.Active, time.Now().UTC(), rewardID)
+	if ledger.IsDuplicate(err) {
+		return nil, apperrors.New(apperrors.CodeRewardExists, fmt.Sprintf("A reward with SKU %s already exists", req.SKU))
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error updating reward", "reward_id", rewardID, "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update reward")
+	}
+	utils.LogAction(ctx, db, adminID, "Update Reward", fmt.Sprintf("Updated reward %d (%s)", rewardID, req.SKU))
+
+	return GetReward(ctx, db, rewardID)
+}
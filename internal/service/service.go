@@ -0,0 +1,79 @@
+// Package service holds the business logic shared by the REST handlers and the
+// gRPC server. Functions validate their input and report failures as apperrors
+// errors, which each transport maps to its own status codes.
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/validation"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// validate returns a VALIDATION_FAILED error when req breaks its validate tags.
+func validate(req interface{}) error {
+	if errs := validation.Validate(req); len(errs) > 0 {
+		return apperrors.Validation(errs)
+	}
+	return nil
+}
+
+// AuthorizeUserAccess checks that the caller owns userID or is an admin.
+func AuthorizeUserAccess(ctx context.Context, db *sql.DB, username string, userID int) error {
+	callerID, callerRole, err := caller(ctx, db, username)
+	if err != nil {
+		return err
+	}
+
+	if callerID != userID && callerRole != middleware.RoleAdmin {
+		return apperrors.New(apperrors.CodeForbidden, "You can only access your own account")
+	}
+	return nil
+}
+
+// caller returns the id and role of the account behind username.
+func caller(ctx context.Context, db *sql.DB, username string) (int, string, error) {
+	var id int
+	var role string
+	err := db.QueryRowContext(ctx, "SELECT id, role FROM users WHERE username = ?", username).Scan(&id, &role)
+	if err == sql.ErrNoRows {
+		return 0, "", apperrors.New(apperrors.CodeTokenInvalid, "Token user no longer exists")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching caller data", "err", err)
+		return 0, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user data")
+	}
+	return id, role, nil
+}
+
+// requireAdmin checks that username is an admin and returns their id.
+func requireAdmin(ctx context.Context, db *sql.DB, username string) (int, error) {
+	callerID, callerRole, err := caller(ctx, db, username)
+	if err != nil {
+		return 0, err
+	}
+	if callerRole != middleware.RoleAdmin {
+		return 0, apperrors.New(apperrors.CodeForbidden, "This operation requires the admin role")
+	}
+	return callerID, nil
+}
+
+// requireOwner checks that userID exists and belongs to username. forbidden is the
+// detail message returned when it belongs to someone else.
+func requireOwner(ctx context.Context, db *sql.DB, username string, userID int, forbidden string) error {
+	var dbUsername string
+	err := db.QueryRowContext(ctx, "SELECT username FROM users WHERE id = ?", userID).Scan(&dbUsername)
+	if err == sql.ErrNoRows {
+		return apperrors.New(apperrors.CodeUserNotFound, "User ID does not exist")
+	} else if err != nil {
+		logging.FromContext(ctx).Error("Error fetching user data", "user_id", userID, "err", err)
+		return apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user data")
+	}
+
+	if username != dbUsername {
+		return apperrors.New(apperrors.CodeForbidden, forbidden)
+	}
+	return nil
+}
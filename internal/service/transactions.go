@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/pagination"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/internal/validation"
+)
+
+// LedgerPageOptions are the sort orders offered for transaction and points lists.
+var LedgerPageOptions = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"transaction_date": {Column: "transaction_date", Kind: pagination.KindTime},
+		"points":           {Column: "points", Kind: pagination.KindInt},
+	},
+	DefaultSort: "transaction_date",
+	DefaultDesc: true,
+}
+
+// ledgerSortValue returns the value of the active sort column for a cursor.
+func ledgerSortValue(sort string, date interface{}, points int) interface{} {
+	if sort == "points" {
+		return points
+	}
+	return date
+}
+
+// AddTransaction records a purchase by username's own account, credits its points
+// and logs the action. Points put towards the purchase are spent in the same
+// database transaction, and only the cash remainder earns points.
+func AddTransaction(ctx context.Context, db *sql.DB, username string, req models.AddTransactionRequest) (*models.AddTransactionResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	req.TransactionDate, _ = validation.NormalizeDateTime(req.TransactionDate)
+	if req.Currency == "" {
+		req.Currency = defaultCurrency
+	}
+
+	if err := requireOwner(ctx, db, username, req.UserID, "You can only create transactions for your own account"); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", req.UserID)
+
+	// Calculate points based on category
+	pointsEarned, ok := ledger.CalculatePoints(req.Category, req.TransactionAmount)
+	if !ok {
+		return nil, apperrors.New(apperrors.CodeCategoryInvalid, "The category provided is not valid")
+	}
+	logger.Debug("Calculated points", "points", pointsEarned, "category", req.Category)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start database transaction")
+	}
+	defer tx.Rollback()
+
+	var payment *models.PointsPayment
+	if req.PointsPayment > 0 {
+		if payment, err = payWithPoints(ctx, tx, req); err != nil {
+			return nil, err
+		}
+		pointsEarned, _ = ledger.CalculatePoints(req.Category, payment.CashAmount)
+		logger.Debug("Paid with points", "points", payment.Points, "cash_amount", payment.CashAmount, "points_earned", pointsEarned)
+	}
+
+	// Record the transaction, its points and the new balance
+	if err := ledger.RecordEarn(ctx, tx, req, pointsEarned); err != nil {
+		logger.Error("Error recording transaction", "err", err)
+		if apperrors.Is(err, apperrors.CodeTransactionExists) {
+			return nil, err
+		}
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Could not record transaction")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Could not commit transaction")
+	}
+	ledger.ReportEarned(req.Category, pointsEarned)
+	if payment != nil {
+		ledger.ReportRedeemed(payment.Points)
+	}
+
+	// Get updated balance
+	var currentPoints int
+	if err := db.QueryRowContext(ctx, "SELECT loyalty_points FROM users WHERE id = ?", req.UserID).Scan(&currentPoints); err != nil {
+		logger.Error("Error fetching updated points balance", "err", err)
+	}
+
+	details := fmt.Sprintf("Transaction %s: Earned %d points. New balance: %d",
+		req.TransactionID, pointsEarned, currentPoints)
+	if payment != nil {
+		details = fmt.Sprintf("Transaction %s: Paid %.2f %s with %d points (Payment ID: %s) and earned %d points on %.2f %s. New balance: %d",
+			req.TransactionID, payment.PointsValue, payment.Currency, payment.Points, payment.Reference,
+			pointsEarned, payment.CashAmount, payment.Currency, currentPoints)
+	}
+	utils.LogAction(ctx, db, req.UserID, "Add Transaction", details)
+
+	return &models.AddTransactionResponse{
+		Message: "Transaction recorded successfully",
+		Points:  pointsEarned,
+		Payment: payment,
+	}, nil
+}
+
+// ListTransactions returns one page of userID's transactions, optionally filtered
+// by category, and the cursor of the next page. The caller must own the account
+// or be an admin.
+func ListTransactions(ctx context.Context, db *sql.DB, username string, userID int, category string, page pagination.Params) ([]models.TransactionRecord, string, error) {
+	if err := AuthorizeUserAccess(ctx, db, username, userID); err != nil {
+		return nil, "", err
+	}
+	logger := logging.FromContext(ctx).With("user_id", userID)
+
+	query := `
+		SELECT id, transaction_id, user_id, transaction_amount, category, transaction_date, COALESCE(product_code, ''), points
+		FROM transactions
+		WHERE user_id = ?`
+	args := []interface{}{userID}
+	if category != "" {
+		query += " AND category = ?"
+		args = append(args, category)
+	}
+
+	query, args = page.Apply(query, args)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Error fetching transactions", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch transactions")
+	}
+	defer rows.Close()
+
+	transactions := []models.TransactionRecord{}
+	fetched := 0
+	for rows.Next() {
+		var record models.TransactionRecord
+		if err := rows.Scan(&record.ID, &record.TransactionID, &record.UserID, &record.TransactionAmount,
+			&record.Category, &record.TransactionDate, &record.ProductCode, &record.Points); err != nil {
+			logger.Error("Error scanning transaction row", "err", err)
+			return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process transactions")
+		}
+		fetched++
+		if fetched > page.Limit {
+			break
+		}
+		transactions = append(transactions, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over transaction rows", "err", err)
+		return nil, "", apperrors.Wrap(err, apperrors.CodeInternal, "Failed to process transactions")
+	}
+
+	nextCursor := ""
+	if page.HasMore(fetched) {
+		last := transactions[len(transactions)-1]
+		nextCursor = page.Next(ledgerSortValue(page.Sort, last.TransactionDate, last.Points), int64(last.ID))
+	}
+	return transactions, nextCursor, nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/internal/validation"
+)
+
+// TransferLimits restrict transfers between members.
+type TransferLimits struct {
+	DailyMax   int      // points a member may send per UTC day, 0 for no cap
+	MinBalance int      // points the sender must keep after a transfer
+	Tiers      []string // tiers whose members may send and receive, empty for all
+}
+
+// transferLimits are the active limits. The default matches the config defaults.
+var transferLimits = TransferLimits{DailyMax: 5000}
+
+// SetTransferLimits replaces the transfer limits. It must be called before
+// serving requests.
+func SetTransferLimits(l TransferLimits) {
+	transferLimits = l
+}
+
+func (l TransferLimits) tierAllowed(tier string) bool {
+	if len(l.Tiers) == 0 {
+		return true
+	}
+	for _, t := range l.Tiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// TransferPoints moves points from username's own account to another member. The
+// sender's lots are debited oldest first and the recipient is credited in the
+// same database transaction. Both are recorded in the audit log.
+func TransferPoints(ctx context.Context, db *sql.DB, username string, req models.TransferRequest) (*models.TransferResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if req.ToUserID == req.FromUserID {
+		return nil, apperrors.Validation(validation.Errors{{Field: "to_user_id", Rule: "ne", Msg: "must differ from from_user_id"}})
+	}
+	if err := requireOwner(ctx, db, username, req.FromUserID, "You can only transfer your own points"); err != nil {
+		return nil, err
+	}
+	logger := logging.FromContext(ctx).With("user_id", req.FromUserID, "recipient_id", req.ToUserID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("Transaction start error", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to start transaction")
+	}
+	defer tx.Rollback()
+
+	// Both members are locked in id order, so opposite transfers cannot deadlock
+	type member struct {
+		balance int
+		tier    string
+	}
+	members := map[int]*member{}
+	for _, id := range []int{min(req.FromUserID, req.ToUserID), max(req.FromUserID, req.ToUserID)} {
+		m := &member{}
+		err := tx.QueryRowContext(ctx, "SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE", id).Scan(&m.balance, &m.tier)
+		if err == sql.ErrNoRows {
+			return nil, apperrors.New(apperrors.CodeUserNotFound, "Recipient does not exist")
+		} else if err != nil {
+			logger.Error("Error locking member", "member_id", id, "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+		}
+		members[id] = m
+	}
+	sender, recipient := members[req.FromUserID], members[req.ToUserID]
+
+	if !transferLimits.tierAllowed(sender.tier) {
+		return nil, apperrors.New(apperrors.CodeTransferNotAllowed, fmt.Sprintf("Members of tier %s cannot transfer points", sender.tier))
+	}
+	if !transferLimits.tierAllowed(recipient.tier) {
+		return nil, apperrors.New(apperrors.CodeTransferNotAllowed, fmt.Sprintf("Members of tier %s cannot receive points", recipient.tier))
+	}
+	// Points reserved by the sender's holds cannot be transferred
+	pending, err := pendingHolds(ctx, tx, req.FromUserID, time.Now().UTC())
+	if err != nil {
+		logger.Error("Error fetching pending holds", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to fetch user points")
+	}
+	available := sender.balance - pending
+	if req.Points > available {
+		return nil, apperrors.New(apperrors.CodePointsInsufficient, "User does not have enough points for the transfer")
+	}
+	if available-req.Points < transferLimits.MinBalance {
+		return nil, apperrors.New(apperrors.CodeTransferLimit,
+			fmt.Sprintf("Transfers must leave at least %d points in the account", transferLimits.MinBalance))
+	}
+
+	now := time.Now().UTC()
+	if transferLimits.DailyMax > 0 {
+		// The sender's row lock serializes their transfers, so the sum cannot go stale
+		var sentToday int
+		dayStart := now.Truncate(24 * time.Hour)
+		err := tx.QueryRowContext(ctx,
+			"SELECT COALESCE(SUM(points), 0) FROM point_transfers WHERE sender_id = ? AND created_at >= ?",
+			req.FromUserID, dayStart).Scan(&sentToday)
+		if err != nil {
+			logger.Error("Error summing today's transfers", "err", err)
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to check transfer limits")
+		}
+		if sentToday+req.Points > transferLimits.DailyMax {
+			return nil, apperrors.New(apperrors.CodeTransferLimit, fmt.Sprintf(
+				"Daily transfer limit is %d points; %d left today", transferLimits.DailyMax, max(transferLimits.DailyMax-sentToday, 0)))
+		}
+	}
+
+	slices, err := ledger.DebitLotsFIFO(ctx, tx, req.FromUserID, req.Points, now)
+	if err != nil {
+		logger.Error("Error debiting points lots", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to debit points")
+	}
+
+	reference := newTransferReference()
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO point_transfers (reference, sender_id, recipient_id, points, preserve_expiry, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		reference, req.FromUserID, req.ToUserID, req.Points, req.PreserveExpiry, req.Note, now)
+	if err != nil {
+		logger.Error("Error recording transfer", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record transfer")
+	}
+	transferID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("Error reading transfer id", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to record transfer")
+	}
+
+	if err := ledger.CreditTransfer(ctx, tx, req.ToUserID, transferID, reference, req.Points, slices, req.PreserveExpiry, now); err != nil {
+		logger.Error("Error crediting recipient", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to credit points")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?", req.Points, req.FromUserID); err != nil {
+		logger.Error("Error updating sender points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET loyalty_points = loyalty_points + ? WHERE id = ?", req.Points, req.ToUserID); err != nil {
+		logger.Error("Error updating recipient points", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update user points")
+	}
+	// Sending points is the sender's activity, which keeps their rolling lots
+	// alive; receiving them is not the recipient's
+	if err := ledger.ExtendRollingExpiry(ctx, tx, req.FromUserID, now); err != nil {
+		logger.Error("Error extending rolling expiry", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to update points expiry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error committing transaction", "err", err)
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to commit transaction")
+	}
+	ledger.ReportTransferred(req.Points)
+
+	utils.LogAction(ctx, db, req.FromUserID, "Transfer Points",
+		fmt.Sprintf("Transferred %d points to user %d. Transfer ID: %s", req.Points, req.ToUserID, reference))
+	utils.LogAction(ctx, db, req.ToUserID, "Receive Points",
+		fmt.Sprintf("Received %d points from user %d. Transfer ID: %s", req.Points, req.FromUserID, reference))
+
+	lots := make([]models.TransferLot, len(slices))
+	for i, s := range slices {
+		lots[i] = models.TransferLot{Points: s.Points, ValidUntil: s.ValidUntil}
+	}
+	return &models.TransferResponse{
+		TransferID:        reference,
+		FromUserID:        req.FromUserID,
+		ToUserID:          req.ToUserID,
+		PointsTransferred: req.Points,
+		RemainingPoints:   sender.balance - req.Points,
+		PreserveExpiry:    req.PreserveExpiry,
+		Lots:              lots,
+	}, nil
+}
+
+// newTransferReference returns a random transfer ID such as TRF_9f86d081884c7d65.
+func newTransferReference() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "TRF_" + hex.EncodeToString(b)
+}
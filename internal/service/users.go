@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/utils"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CreateUser registers a user and logs the action.
+func CreateUser(ctx context.Context, db *sql.DB, req models.CreateUserRequest) (*models.CreateUserResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	// Hash the password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to hash the password")
+	}
+
+	// Insert user into the database
+	result, err := db.ExecContext(ctx, "INSERT INTO users (username, password_hash) VALUES (?, ?)", req.Username, hashedPassword)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 { // Duplicate entry error
+			return nil, apperrors.New(apperrors.CodeUserExists, "Username already exists")
+		}
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to insert user into database")
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Failed to retrieve new user ID")
+	}
+
+	utils.LogAction(ctx, db, int(userID), "Create User", "New user created successfully")
+	return &models.CreateUserResponse{UserID: userID}, nil
+}
+
+// Login checks credentials, issues an access and refresh token pair and logs the
+// action.
+func Login(ctx context.Context, db *sql.DB, req models.LoginRequest) (*models.TokenResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	// Retrieve user from the database
+	var user models.User
+	err := db.QueryRowContext(ctx, "SELECT id, username, password_hash FROM users WHERE username = ?", req.Username).
+		Scan(&user.ID, &user.Username, &user.PasswordHash)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logging.FromContext(ctx).Error("Error fetching user for login", "err", err)
+		}
+		reportLoginFailed()
+		return nil, apperrors.New(apperrors.CodeInvalidCredentials, "Invalid username or password")
+	}
+
+	// Validate password
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		logging.FromContext(ctx).Info("Login rejected", "user_id", user.ID)
+		reportLoginFailed()
+		return nil, apperrors.New(apperrors.CodeInvalidCredentials, "Invalid username or password")
+	}
+
+	accessToken, err := utils.GenerateAccessToken(user.Username)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Could not generate access token")
+	}
+	refreshToken, err := utils.GenerateRefreshToken(user.Username)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Could not generate refresh token")
+	}
+
+	// Store refresh token in the database
+	if _, err := db.ExecContext(ctx, "UPDATE users SET refresh_token = ? WHERE id = ?", refreshToken, user.ID); err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Could not store refresh token")
+	}
+
+	utils.LogAction(ctx, db, user.ID, "Login", "User logged in successfully")
+	return &models.TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// RefreshToken issues a new access token for a valid refresh token.
+func RefreshToken(ctx context.Context, req models.RefreshRequest) (*models.RefreshResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+
+	claims, err := utils.ValidateToken(req.RefreshToken)
+	if err != nil {
+		return nil, apperrors.New(apperrors.CodeTokenInvalid, "Invalid or expired refresh token")
+	}
+
+	accessToken, err := utils.GenerateAccessToken(claims.Username)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "Error generating access token")
+	}
+	return &models.RefreshResponse{AccessToken: accessToken}, nil
+}
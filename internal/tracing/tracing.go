@@ -0,0 +1,158 @@
+// Package tracing sets up OpenTelemetry tracing. HTTP requests, gRPC calls,
+// database calls and background jobs are recorded as spans, and W3C trace context
+// is read from incoming requests and sent on outgoing ones.
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"loyalty-points-system-api/internal/logging"
+)
+
+// Name is the service name reported with every span and the instrumentation name
+// of the spans this service starts.
+const Name = "loyalty-points-system-api"
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"   // spans are not recorded; trace context still propagates
+	ExporterOTLP   = "otlp"   // OTLP over gRPC, e.g. to an OpenTelemetry Collector or Jaeger
+	ExporterStdout = "stdout" // indented JSON on stdout
+	ExporterFile   = "file"   // one JSON span per line, appended to a file
+)
+
+// Options configures Setup.
+type Options struct {
+	Exporter      string
+	OTLPEndpoint  string // URL of the OTLP gRPC receiver; http:// disables TLS
+	FilePath      string // file the file exporter appends to
+	SamplePercent int    // share of new traces recorded, 0 to 100
+	Environment   string // deployment.environment, e.g. prod
+	Instance      string // service.instance.id
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+// The returned function flushes buffered spans and stops the exporter.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if opts.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var export sdktrace.SpanProcessor
+	var closer io.Closer
+	switch opts.Exporter {
+	case ExporterOTLP:
+		u, err := url.Parse(opts.OTLPEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: OTLP endpoint: %w", err)
+		}
+		grpcOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(u.Host)}
+		if u.Scheme == "http" {
+			grpcOpts = append(grpcOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, grpcOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: OTLP exporter: %w", err)
+		}
+		export = sdktrace.NewBatchSpanProcessor(exporter)
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("tracing: stdout exporter: %w", err)
+		}
+		// Local debugging wants spans as soon as they end
+		export = sdktrace.NewSimpleSpanProcessor(exporter)
+	case ExporterFile:
+		f, err := os.OpenFile(opts.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: opening %s: %w", opts.FilePath, err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("tracing: file exporter: %w", err)
+		}
+		export, closer = sdktrace.NewSimpleSpanProcessor(exporter), f
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", opts.Exporter)
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName(Name), semconv.DeploymentEnvironment(opts.Environment)}
+	if opts.Instance != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(opts.Instance))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(export),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+		// A caller's sampling decision wins, so a trace is never recorded in pieces
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(opts.SamplePercent)/100))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+// Tracer returns the tracer for spans this service starts itself, e.g. jobs.
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// OpenDB opens a database whose calls are recorded as child spans of the span in
+// the call's context, with the SQL text but not the arguments. Calls made without
+// a span in their context, or through the methods without a context, are not
+// traced, so they never start traces of their own.
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(semconv.DBSystemMySQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}))
+}
+
+// WithTraceID adds the trace_id of ctx's span to ctx's logger, so log lines can be
+// matched with traces. ctx is returned unchanged when it has no span.
+func WithTraceID(ctx context.Context) context.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx
+	}
+	return logging.WithLogger(ctx, logging.FromContext(ctx).With("trace_id", sc.TraceID().String()))
+}
+
+// Correlate puts requestID in ctx for logging and, when ctx has a span, records the
+// ID on the span and the trace ID on every log line.
+func Correlate(ctx context.Context, requestID string) context.Context {
+	ctx = logging.WithRequestID(ctx, requestID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request_id", requestID))
+	return WithTraceID(ctx)
+}
@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"loyalty-points-system-api/internal/logging"
+)
+
+// AuditBreak describes the first link in the audit chain that failed verification.
+type AuditBreak struct {
+	AuditID int    `json:"audit_id"`
+	Reason  string `json:"reason"`
+}
+
+// AuditVerification summarises a walk over the audit chain.
+type AuditVerification struct {
+	Checked       int         `json:"checked"`
+	Legacy        int         `json:"legacy"`
+	Checkpoints   int         `json:"checkpoints"`
+	LastAuditID   int         `json:"last_audit_id"`
+	LastHash      string      `json:"last_hash"`
+	Break         *AuditBreak `json:"break,omitempty"`
+	CheckpointErr string      `json:"checkpoint_error,omitempty"`
+}
+
+// Valid reports whether the chain and all checkpoints verified.
+func (v *AuditVerification) Valid() bool {
+	return v.Break == nil && v.CheckpointErr == ""
+}
+
+// AuditChainVerifier checks audit entries one at a time, in id order.
+type AuditChainVerifier struct {
+	prevHash string
+	started  bool
+	Result   AuditVerification
+}
+
+// NewAuditChainVerifier returns a verifier positioned at the genesis hash.
+func NewAuditChainVerifier() *AuditChainVerifier {
+	return &AuditChainVerifier{prevHash: AuditGenesisHash}
+}
+
+// Next verifies the next entry of the chain and returns the break, if any.
+// Rows written before hash chaining was enabled carry no hash and are only
+// tolerated before the first chained row.
+func (v *AuditChainVerifier) Next(entry AuditEntry) *AuditBreak {
+	if v.Result.Break != nil {
+		return v.Result.Break
+	}
+
+	if entry.RowHash == "" && !v.started {
+		v.Result.Legacy++
+		return nil
+	}
+	v.started = true
+
+	var brk *AuditBreak
+	switch {
+	case entry.RowHash == "":
+		brk = &AuditBreak{AuditID: entry.ID, Reason: "row has no hash after chaining started"}
+	case entry.PrevHash != v.prevHash:
+		brk = &AuditBreak{AuditID: entry.ID, Reason: "prev_hash does not match the previous row (row removed, inserted or reordered)"}
+	case ComputeAuditHash(entry.PrevHash, entry.UserID, entry.Action, entry.Details, entry.CreatedAt, entry.RequestID) != entry.RowHash:
+		brk = &AuditBreak{AuditID: entry.ID, Reason: "row content does not match row_hash (row edited)"}
+	}
+	if brk != nil {
+		v.Result.Break = brk
+		return brk
+	}
+
+	v.prevHash = entry.RowHash
+	v.Result.Checked++
+	v.Result.LastAuditID = entry.ID
+	v.Result.LastHash = entry.RowHash
+	return nil
+}
+
+// VerifyAuditChain walks audit_log in id order and reports the first broken link.
+// It also checks that the chain head and every signed checkpoint agree with the rows.
+func VerifyAuditChain(db *sql.DB, signingKey []byte) (*AuditVerification, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, action, details, created_at, prev_hash, row_hash, request_id
+		FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verifier := NewAuditChainVerifier()
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Action, &entry.Details,
+			&entry.CreatedAt, &entry.PrevHash, &entry.RowHash, &entry.RequestID); err != nil {
+			return nil, err
+		}
+		if verifier.Next(entry) != nil {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result := &verifier.Result
+	if result.Break != nil {
+		return result, nil
+	}
+
+	// A truncated tail leaves the rows consistent, so compare against the head.
+	var headID int
+	var headHash string
+	err = db.QueryRow("SELECT last_audit_id, last_hash FROM audit_chain_head WHERE id = 1").Scan(&headID, &headHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+	if headID != 0 && (headID != result.LastAuditID || headHash != result.LastHash) {
+		result.Break = &AuditBreak{
+			AuditID: headID,
+			Reason:  fmt.Sprintf("chain head points at audit %d but the last verified row is %d", headID, result.LastAuditID),
+		}
+		return result, nil
+	}
+
+	if err := verifyCheckpoints(db, signingKey, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// verifyCheckpoints checks each checkpoint signature and that the row it
+// references still carries the signed hash.
+func verifyCheckpoints(db *sql.DB, signingKey []byte, result *AuditVerification) error {
+	checkpoints, err := listAuditCheckpoints(db)
+	if err != nil {
+		return err
+	}
+	for _, cp := range checkpoints {
+		if !hmac.Equal([]byte(cp.Signature), []byte(SignAuditCheckpoint(signingKey, cp.LastAuditID, cp.LastHash, cp.CreatedAt))) {
+			result.CheckpointErr = fmt.Sprintf("checkpoint %d has an invalid signature", cp.ID)
+			return nil
+		}
+		var rowHash string
+		err := db.QueryRow("SELECT row_hash FROM audit_log WHERE id = ?", cp.LastAuditID).Scan(&rowHash)
+		if err == sql.ErrNoRows {
+			result.CheckpointErr = fmt.Sprintf("checkpoint %d references missing audit %d", cp.ID, cp.LastAuditID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if rowHash != cp.LastHash {
+			result.CheckpointErr = fmt.Sprintf("checkpoint %d does not match audit %d (chain rewritten)", cp.ID, cp.LastAuditID)
+			return nil
+		}
+		result.Checkpoints++
+	}
+	return nil
+}
+
+// AuditCheckpoint is a signed snapshot of the audit chain head.
+type AuditCheckpoint struct {
+	ID          int       `json:"id"`
+	LastAuditID int       `json:"last_audit_id"`
+	LastHash    string    `json:"last_hash"`
+	Signature   string    `json:"signature"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SignAuditCheckpoint returns the HMAC-SHA256 signature of a checkpoint.
+func SignAuditCheckpoint(key []byte, lastAuditID int, lastHash string, createdAt time.Time) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d|%s|%s", lastAuditID, lastHash, createdAt.UTC().Format(time.RFC3339))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateAuditCheckpoint signs the current chain head and returns how many
+// checkpoints it wrote. Nothing is written when the chain has not advanced since
+// the previous checkpoint, or on a dry run.
+func CreateAuditCheckpoint(ctx context.Context, db *sql.DB, signingKey []byte, dryRun bool) (int64, error) {
+	var headID int
+	var headHash string
+	err := db.QueryRowContext(ctx, "SELECT last_audit_id, last_hash FROM audit_chain_head WHERE id = 1").Scan(&headID, &headHash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+	if headID == 0 {
+		return 0, nil
+	}
+
+	var lastCheckpointID int
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(last_audit_id), 0) FROM audit_checkpoints").Scan(&lastCheckpointID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read last audit checkpoint: %w", err)
+	}
+	if lastCheckpointID == headID {
+		return 0, nil
+	}
+	if dryRun {
+		return 1, nil
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	signature := SignAuditCheckpoint(signingKey, headID, headHash, createdAt)
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO audit_checkpoints (last_audit_id, last_hash, signature, created_at)
+		VALUES (?, ?, ?, ?)`, headID, headHash, signature, createdAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write audit checkpoint: %w", err)
+	}
+	logging.FromContext(ctx).Info("Audit checkpoint written", "audit_id", headID)
+	return 1, nil
+}
+
+func listAuditCheckpoints(db *sql.DB) ([]AuditCheckpoint, error) {
+	rows, err := db.Query(`
+		SELECT id, last_audit_id, last_hash, signature, created_at
+		FROM audit_checkpoints ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []AuditCheckpoint
+	for rows.Next() {
+		var cp AuditCheckpoint
+		if err := rows.Scan(&cp.ID, &cp.LastAuditID, &cp.LastHash, &cp.Signature, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
+
+// ExportAuditCheckpoints writes every checkpoint to w as JSON lines so they can be
+// kept outside the database.
+func ExportAuditCheckpoints(db *sql.DB, w io.Writer) (int, error) {
+	checkpoints, err := listAuditCheckpoints(db)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	for _, cp := range checkpoints {
+		if err := enc.Encode(cp); err != nil {
+			return 0, err
+		}
+	}
+	return len(checkpoints), nil
+}
@@ -1,16 +1,140 @@
 package utils
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
-	"log"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"loyalty-points-system-api/internal/logging"
 )
 
-func LogAction(db *sql.DB, userID int, action, details string) {
+// AuditGenesisHash is the prev_hash of the first entry in the audit chain.
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// AuditEntry is a single row of the audit_log table.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Action    string    `json:"action"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+	PrevHash  string    `json:"prev_hash"`
+	RowHash   string    `json:"row_hash"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// ComputeAuditHash returns the SHA-256 of the previous row's hash plus the entry
+// content. The request ID is appended only when set, so rows written before it
+// was recorded, and rows written outside a request, keep their original hash.
+func ComputeAuditHash(prevHash string, userID int, action, details string, createdAt time.Time, requestID string) string {
+	content := fmt.Sprintf("%s|%d|%q|%q|%s", prevHash, userID, action, details, createdAt.UTC().Format(time.RFC3339))
+	if requestID != "" {
+		content += fmt.Sprintf("|%q", requestID)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// pendingAudit counts LogAction writes that have not finished yet. idle is closed
+// whenever the count drops back to zero.
+var pendingAudit struct {
+	sync.Mutex
+	count int
+	idle  chan struct{}
+}
+
+// LogAction appends an audit entry in the background so the request does not wait
+// for the chain lock. The entry records ctx's request ID and the write is traced as
+// part of ctx's trace. FlushAuditLog waits for these writes on shutdown.
+func LogAction(ctx context.Context, db *sql.DB, userID int, action, details string) {
+	// The write outlives the request, so it must not be cancelled with it
+	ctx = context.WithoutCancel(ctx)
+
+	pendingAudit.Lock()
+	if pendingAudit.count == 0 {
+		pendingAudit.idle = make(chan struct{})
+	}
+	pendingAudit.count++
+	pendingAudit.Unlock()
+
 	go func() {
-		query := `INSERT INTO audit_log (user_id, action, details, created_at) VALUES (?, ?, ?, NOW())`
-		_, err := db.Exec(query, userID, action, details)
-		if err != nil {
-			log.Printf("Error logging audit action for user %d: %v", userID, err)
+		defer func() {
+			pendingAudit.Lock()
+			pendingAudit.count--
+			if pendingAudit.count == 0 {
+				close(pendingAudit.idle)
+			}
+			pendingAudit.Unlock()
+		}()
+		if err := appendAuditEntry(ctx, db, userID, action, details); err != nil {
+			logging.FromContext(ctx).Error("Error logging audit action", "user_id", userID, "action", action, "err", err)
 		}
 	}()
 }
+
+// PendingAuditWrites returns how many LogAction writes have not finished yet.
+func PendingAuditWrites() int {
+	pendingAudit.Lock()
+	defer pendingAudit.Unlock()
+	return pendingAudit.count
+}
+
+// FlushAuditLog waits until every pending LogAction write has finished, or returns
+// ctx's error if the deadline comes first.
+func FlushAuditLog(ctx context.Context) error {
+	pendingAudit.Lock()
+	if pendingAudit.count == 0 {
+		pendingAudit.Unlock()
+		return nil
+	}
+	idle := pendingAudit.idle
+	pendingAudit.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// appendAuditEntry inserts a row at the tip of the hash chain. The chain head row
+// is locked for the duration of the transaction so concurrent writers append in order.
+func appendAuditEntry(ctx context.Context, db *sql.DB, userID int, action, details string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var prevHash string
+	if err := tx.QueryRowContext(ctx, "SELECT last_hash FROM audit_chain_head WHERE id = 1 FOR UPDATE").Scan(&prevHash); err != nil {
+		return fmt.Errorf("failed to lock audit chain head: %w", err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	requestID := logging.RequestID(ctx)
+	rowHash := ComputeAuditHash(prevHash, userID, action, details, createdAt, requestID)
+
+	query := `INSERT INTO audit_log (user_id, action, details, created_at, prev_hash, row_hash, request_id) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, userID, action, details, createdAt, prevHash, rowHash, requestID)
+	if err != nil {
+		return err
+	}
+	auditID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE audit_chain_head SET last_audit_id = ?, last_hash = ? WHERE id = 1", auditID, rowHash)
+	if err != nil {
+		return fmt.Errorf("failed to advance audit chain head: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseRangeDate parses a date range bound. RFC 3339 values carry their own
+// offset; YYYY-MM-DD values are read in loc. When endOfRange is set a date-only
+// value is moved to the start of the following day, so it can be used as an
+// exclusive upper bound that still includes the whole day.
+func ParseRangeDate(value string, loc *time.Location, endOfRange bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", value)
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
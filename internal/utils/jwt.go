@@ -13,12 +13,22 @@ type Claims struct {
 	jwt.StandardClaims
 }
 
-// Secret key for signing JWTs (replace with a secure key)
-var jwtSecret = []byte("your_secret_key")
+// Secret key and lifetimes for JWTs; ConfigureTokens replaces these defaults at startup
+var (
+	jwtSecret       = []byte("your_secret_key")
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// ConfigureTokens sets the signing secret and token lifetimes. It must be called
+// before the servers start.
+func ConfigureTokens(secret []byte, accessTTL, refreshTTL time.Duration) {
+	jwtSecret, accessTokenTTL, refreshTokenTTL = secret, accessTTL, refreshTTL
+}
 
 // GenerateAccessToken creates a new JWT access token.
 func GenerateAccessToken(username string) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute) // Access token expires in 15 minutes
+	expirationTime := time.Now().Add(accessTokenTTL)
 	claims := &Claims{
 		Username: username,
 		StandardClaims: jwt.StandardClaims{
@@ -31,7 +41,7 @@ func GenerateAccessToken(username string) (string, error) {
 
 // GenerateRefreshToken creates a long-lived refresh token.
 func GenerateRefreshToken(username string) (string, error) {
-	expirationTime := time.Now().Add(7 * 24 * time.Hour) // Refresh token expires in 7 days
+	expirationTime := time.Now().Add(refreshTokenTTL)
 	claims := &Claims{
 		Username: username,
 		StandardClaims: jwt.StandardClaims{
@@ -0,0 +1,237 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes one invalid field, named by its JSON key.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Msg   string `json:"msg"`
+}
+
+// Errors is the list of field errors for a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Msg
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// RuleFunc checks a field against a rule parameter and returns a message when
+// the value is invalid.
+type RuleFunc func(v reflect.Value, param string) (string, bool)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"required":  required,
+		"min":       minRule,
+		"max":       maxRule,
+		"maxbytes":  maxBytes,
+		"gt":        gtRule,
+		"oneof":     oneOf,
+		"datetime":  datetime,
+		"notfuture": notFuture,
+		"currency":  currency,
+	}
+)
+
+// RegisterRule adds a named rule usable in `validate` struct tags.
+func RegisterRule(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+}
+
+// Validate checks every field of the struct s against its `validate` tag, e.g.
+// `validate:"required,max=255"`. Rules are comma separated and take an optional
+// "=param". All failing fields are reported, one error per field.
+func Validate(s interface{}) Errors {
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	var errs Errors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name := jsonName(field)
+		value := v.Field(i)
+
+		for _, rule := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(rule, "=")
+			// Optional fields skip their remaining rules when empty
+			if ruleName == "omitempty" {
+				if value.IsZero() {
+					break
+				}
+				continue
+			}
+			fn, ok := rules[ruleName]
+			if !ok {
+				panic(fmt.Sprintf("validation: unknown rule %q on %s.%s", ruleName, t.Name(), field.Name))
+			}
+			if msg, valid := fn(value, param); !valid {
+				errs = append(errs, FieldError{Field: name, Rule: ruleName, Msg: msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func required(v reflect.Value, _ string) (string, bool) {
+	if v.Kind() == reflect.String {
+		return "is required", strings.TrimSpace(v.String()) != ""
+	}
+	return "is required", !v.IsZero()
+}
+
+// number returns the numeric value of v, or the rune count for strings.
+func number(v reflect.Value) (float64, bool, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	return 0, false, false
+}
+
+func minRule(v reflect.Value, param string) (string, bool) {
+	limit, _ := strconv.ParseFloat(param, 64)
+	n, isLen, ok := number(v)
+	if isLen {
+		return fmt.Sprintf("must be at least %s characters long", param), !ok || n >= limit
+	}
+	return fmt.Sprintf("must be at least %s", param), !ok || n >= limit
+}
+
+func maxRule(v reflect.Value, param string) (string, bool) {
+	limit, _ := strconv.ParseFloat(param, 64)
+	n, isLen, ok := number(v)
+	if isLen {
+		return fmt.Sprintf("must be at most %s characters long", param), !ok || n <= limit
+	}
+	return fmt.Sprintf("must be at most %s", param), !ok || n <= limit
+}
+
+// maxBytes limits a string's length in bytes rather than characters, for values
+// such as bcrypt passwords whose limit is in bytes.
+func maxBytes(v reflect.Value, param string) (string, bool) {
+	limit, _ := strconv.Atoi(param)
+	return fmt.Sprintf("must be at most %s bytes long", param), v.Kind() != reflect.String || len(v.String()) <= limit
+}
+
+func gtRule(v reflect.Value, param string) (string, bool) {
+	limit, _ := strconv.ParseFloat(param, 64)
+	n, _, ok := number(v)
+	if param == "0" {
+		return "must be positive", !ok || n > limit
+	}
+	return fmt.Sprintf("must be greater than %s", param), !ok || n > limit
+}
+
+// oneOf takes space separated allowed values, e.g. oneof=csv jsonl.
+func oneOf(v reflect.Value, param string) (string, bool) {
+	allowed := strings.Fields(param)
+	value := fmt.Sprint(v.Interface())
+	for _, a := range allowed {
+		if value == a {
+			return "", true
+		}
+	}
+	return "must be one of " + strings.Join(allowed, ", "), false
+}
+
+// dateTimeLayouts are the accepted formats for datetime fields.
+var dateTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02"}
+
+// ParseDateTime parses a datetime field value. Values without an offset are UTC.
+func ParseDateTime(s string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("must be YYYY-MM-DD, YYYY-MM-DD HH:MM:SS or RFC 3339")
+}
+
+// NormalizeDateTime rewrites a datetime field value in the MySQL DATETIME layout, in UTC.
+func NormalizeDateTime(s string) (string, error) {
+	t, err := ParseDateTime(s)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format("2006-01-02 15:04:05"), nil
+}
+
+func datetime(v reflect.Value, _ string) (string, bool) {
+	if _, err := ParseDateTime(v.String()); err != nil {
+		return err.Error(), false
+	}
+	return "", true
+}
+
+// clockSkew is how far ahead of the server's clock a notfuture value may be.
+const clockSkew = 5 * time.Minute
+
+// notFuture rejects datetimes later than now, allowing for clock skew between
+// the caller and the server. It follows datetime, which reports bad formats.
+func notFuture(v reflect.Value, _ string) (string, bool) {
+	t, err := ParseDateTime(v.String())
+	return "must not be in the future", err != nil || !t.After(time.Now().Add(clockSkew))
+}
+
+// IsCurrency reports whether s is an ISO 4217 style code: three upper-case
+// letters, e.g. USD.
+func IsCurrency(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func currency(v reflect.Value, _ string) (string, bool) {
+	return "must be a three-letter currency code such as USD", IsCurrency(v.String())
+}
@@ -0,0 +1,22 @@
+-- Chain every audit_log row to its predecessor with a SHA-256 hash.
+ALTER TABLE audit_log
+    ADD COLUMN prev_hash CHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN row_hash CHAR(64) NOT NULL DEFAULT '';
+
+-- Single-row table holding the tip of the chain; writers lock it to append in order.
+CREATE TABLE audit_chain_head (
+    id TINYINT NOT NULL PRIMARY KEY,
+    last_audit_id INT NOT NULL DEFAULT 0,
+    last_hash CHAR(64) NOT NULL
+);
+INSERT INTO audit_chain_head (id, last_audit_id, last_hash)
+VALUES (1, 0, REPEAT('0', 64));
+
+-- Periodic HMAC-signed snapshots of the chain head.
+CREATE TABLE audit_checkpoints (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    last_audit_id INT NOT NULL,
+    last_hash CHAR(64) NOT NULL,
+    signature CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,30 @@
+-- Roles gate operational endpoints such as batch import.
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'member';
+
+-- One row per imported file, identified by its SHA-256 so interrupted imports resume.
+CREATE TABLE import_jobs (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    checksum CHAR(64) NOT NULL UNIQUE,
+    source VARCHAR(255) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    status ENUM('in_progress', 'completed') NOT NULL DEFAULT 'in_progress',
+    rows_done INT NOT NULL DEFAULT 0,
+    accepted INT NOT NULL DEFAULT 0,
+    duplicates INT NOT NULL DEFAULT 0,
+    rejected INT NOT NULL DEFAULT 0,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- Per-row outcome, committed in the same transaction as the chunk it belongs to.
+CREATE TABLE import_results (
+    job_id INT NOT NULL,
+    row_num INT NOT NULL,
+    transaction_id VARCHAR(255) NOT NULL,
+    status ENUM('accepted', 'duplicate', 'rejected') NOT NULL,
+    points INT NOT NULL DEFAULT 0,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (job_id, row_num),
+    FOREIGN KEY (job_id) REFERENCES import_jobs(id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Responses to POSTs sent with an Idempotency-Key header, replayed when a client
+-- retries the same request. Rows older than a day are purged by the server.
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(255) NOT NULL,
+    idem_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT NULL,
+    content_type VARCHAR(100) NOT NULL DEFAULT '',
+    response_body MEDIUMBLOB NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP NULL,
+    PRIMARY KEY (scope, idem_key),
+    INDEX idx_idempotency_created (created_at)
+);
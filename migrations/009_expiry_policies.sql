@@ -0,0 +1,14 @@
+-- Expiry policies: each earned lot records the policy that set its valid_until,
+-- so rolling lots can be extended on member activity. Lots that never expire
+-- have a NULL valid_until. Existing lots keep their one-year expiry.
+ALTER TABLE points
+    ADD COLUMN expiry_policy VARCHAR(32) NULL,
+    ADD COLUMN expiry_days INT NOT NULL DEFAULT 0,
+    ADD INDEX idx_points_user_policy (user_id, expiry_policy);
+
+UPDATE points SET expiry_policy = 'fixed_days', expiry_days = 365
+WHERE transaction_type = 'Earned' AND expiry_policy IS NULL;
+
+-- Member tier used to pick a tier-specific expiry policy
+ALTER TABLE users
+    ADD COLUMN tier VARCHAR(32) NOT NULL DEFAULT 'standard';
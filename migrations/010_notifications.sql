@@ -0,0 +1,21 @@
+-- Notifications sent to members. dedup_key makes each warning unique per member,
+-- horizon and expiry date, so reruns of the job do not notify twice.
+CREATE TABLE notifications (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    dedup_key VARCHAR(255) NOT NULL,
+    user_id INT NOT NULL,
+    kind VARCHAR(64) NOT NULL,
+    channel VARCHAR(32) NOT NULL,
+    horizon_days INT NOT NULL,
+    points INT NOT NULL,
+    expires_at TIMESTAMP NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    status ENUM('pending', 'sent', 'failed') NOT NULL,
+    error TEXT NULL,
+    attempts INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP NULL,
+    UNIQUE KEY uq_notifications_dedup (dedup_key),
+    INDEX idx_notifications_user (user_id, created_at)
+);
@@ -0,0 +1,19 @@
+-- History of background job runs. Scheduled runs are unique per job and scheduled
+-- time, so when several replicas fire the same slot only the first one runs it.
+-- Manual runs have no scheduled_for and are never deduplicated.
+CREATE TABLE job_runs (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    job_name VARCHAR(64) NOT NULL,
+    source ENUM('schedule', 'manual') NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    scheduled_for DATETIME NULL,
+    instance VARCHAR(255) NOT NULL,
+    triggered_by VARCHAR(255) NULL,
+    status ENUM('running', 'succeeded', 'failed') NOT NULL,
+    affected_rows BIGINT NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NULL,
+    UNIQUE KEY uq_job_runs_slot (job_name, scheduled_for),
+    INDEX idx_job_runs_job (job_name, id)
+);
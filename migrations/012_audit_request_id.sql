@@ -0,0 +1,6 @@
+-- The X-Request-ID of the request that caused each audit entry, so entries can be
+-- matched with log lines. A non-empty request_id is covered by row_hash; entries
+-- written outside a request, e.g. by a scheduled job, have '' and hash as before.
+ALTER TABLE audit_log
+    ADD COLUMN request_id VARCHAR(128) NOT NULL DEFAULT '',
+    ADD INDEX idx_audit_log_request (request_id);
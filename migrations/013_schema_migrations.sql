@@ -0,0 +1,24 @@
+-- Records which migrations have been applied, so /readyz can tell when the
+-- database is behind the code. Every later migration ends by inserting its own
+-- version here.
+CREATE TABLE schema_migrations (
+    version INT NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO schema_migrations (version, name) VALUES
+    (0, '000_create_loyalty_db'),
+    (1, '001_create_users_table'),
+    (2, '002_create_points_table'),
+    (3, '003_points'),
+    (4, '004_transactions'),
+    (5, '005_auditLog'),
+    (6, '006_audit_hash_chain'),
+    (7, '007_batch_import'),
+    (8, '008_idempotency_keys'),
+    (9, '009_expiry_policies'),
+    (10, '010_notifications'),
+    (11, '011_job_runs'),
+    (12, '012_audit_request_id'),
+    (13, '013_schema_migrations');
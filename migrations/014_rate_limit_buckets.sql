@@ -0,0 +1,11 @@
+-- Token buckets of the shared rate limit backend (RATE_LIMIT_BACKEND=mysql). One
+-- row per policy and client; idle rows are full buckets and are purged by the
+-- rate_limit_purge job.
+CREATE TABLE rate_limit_buckets (
+    bucket_key VARCHAR(512) NOT NULL PRIMARY KEY,
+    tokens DOUBLE NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    INDEX idx_rate_limit_updated (updated_at)
+);
+
+INSERT INTO schema_migrations (version, name) VALUES (14, '014_rate_limit_buckets');
@@ -0,0 +1,23 @@
+-- Points transfers between members. The sender's earned lots are debited oldest
+-- first by raising points.transferred, so each lot keeps the amount it was
+-- earned with and only what is left of it can expire. The recipient gets new
+-- lots linked to the transfer through points.transfer_id.
+CREATE TABLE point_transfers (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    reference VARCHAR(64) NOT NULL UNIQUE,
+    sender_id INT NOT NULL,
+    recipient_id INT NOT NULL,
+    points INT NOT NULL,
+    preserve_expiry BOOLEAN NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_transfers_sender (sender_id, created_at),
+    INDEX idx_transfers_recipient (recipient_id, created_at)
+);
+
+ALTER TABLE points
+    ADD COLUMN transferred INT NOT NULL DEFAULT 0,
+    ADD COLUMN transfer_id BIGINT NULL,
+    ADD INDEX idx_points_user_earned (user_id, transaction_type, transaction_date);
+
+INSERT INTO schema_migrations (version, name) VALUES (15, '015_point_transfers');
@@ -0,0 +1,42 @@
+-- Household pools. Purchases by any member earn into the household's balance
+-- instead of the member's own; the lots and transactions involved carry the
+-- household_id. A member belongs to at most one household.
+CREATE TABLE households (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    head_user_id INT NOT NULL,
+    loyalty_points INT NOT NULL DEFAULT 0,
+    created_at DATETIME(6) NOT NULL
+);
+
+CREATE TABLE household_members (
+    household_id INT NOT NULL,
+    user_id INT NOT NULL UNIQUE,
+    role ENUM('head', 'redeemer', 'member') NOT NULL,
+    joined_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (household_id, user_id)
+);
+
+CREATE TABLE household_invitations (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    household_id INT NOT NULL,
+    invitee_user_id INT NOT NULL,
+    role ENUM('redeemer', 'member') NOT NULL,
+    status ENUM('pending', 'accepted', 'declined', 'revoked') NOT NULL DEFAULT 'pending',
+    invited_by INT NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    responded_at DATETIME(6) NULL,
+    INDEX idx_invitations_invitee (invitee_user_id, status),
+    INDEX idx_invitations_household (household_id, status)
+);
+
+ALTER TABLE points
+    ADD COLUMN household_id INT NULL,
+    ADD INDEX idx_points_household (household_id, transaction_type);
+
+ALTER TABLE transactions
+    ADD COLUMN household_id INT NULL,
+    ADD INDEX idx_transactions_household (household_id);
+
+INSERT INTO schema_migrations (version, name) VALUES (16, '016_households');
@@ -0,0 +1,36 @@
+-- Rewards catalog and orders. Placing an order takes the reward's stock and the
+-- member's points in one transaction and records the redemption in transactions
+-- under the order reference. Cancelling a pending order, or refunding a fulfilled
+-- one, credits the points back as an adjustment.
+CREATE TABLE rewards (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    description VARCHAR(1000) NOT NULL DEFAULT '',
+    points_cost INT NOT NULL,
+    stock INT NULL, -- NULL for unlimited stock
+    available_from DATETIME NULL,
+    available_until DATETIME NULL,
+    tiers VARCHAR(255) NOT NULL DEFAULT '', -- comma-separated, empty for every tier
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL
+);
+
+CREATE TABLE reward_orders (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    reference VARCHAR(64) NOT NULL UNIQUE,
+    user_id INT NOT NULL,
+    reward_id INT NOT NULL,
+    quantity INT NOT NULL,
+    points INT NOT NULL,
+    status ENUM('pending', 'fulfilled', 'cancelled', 'refunded') NOT NULL DEFAULT 'pending',
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    fulfilled_at DATETIME(6) NULL,
+    closed_at DATETIME(6) NULL,
+    INDEX idx_orders_user (user_id, created_at),
+    INDEX idx_orders_status (status, created_at)
+);
+
+INSERT INTO schema_migrations (version, name) VALUES (17, '017_rewards');
@@ -0,0 +1,19 @@
+-- Two-phase points holds. An authorized hold reserves points: they stay in
+-- users.loyalty_points but are not available to spend until the hold is captured,
+-- released or expires. Capturing records a redemption in transactions under the
+-- hold reference.
+CREATE TABLE points_holds (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    reference VARCHAR(64) NOT NULL UNIQUE,
+    user_id INT NOT NULL,
+    points INT NOT NULL,
+    captured_points INT NULL,
+    status ENUM('authorized', 'captured', 'released', 'expired') NOT NULL DEFAULT 'authorized',
+    created_at DATETIME(6) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    closed_at DATETIME(6) NULL,
+    INDEX idx_holds_user (user_id, status, expires_at),
+    INDEX idx_holds_expiry (status, expires_at)
+);
+
+INSERT INTO schema_migrations (version, name) VALUES (18, '018_points_holds');
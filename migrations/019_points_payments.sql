@@ -0,0 +1,30 @@
+-- Points as payment. conversion_rates sets what one point is worth in a currency,
+-- optionally for one member tier, from effective_from until a later rate for the
+-- same currency and tier takes over. A purchase paid partly with points records
+-- the redemption in transactions under the payment reference and the split in
+-- points_payments.
+CREATE TABLE conversion_rates (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    currency CHAR(3) NOT NULL,
+    tier VARCHAR(32) NOT NULL DEFAULT '', -- empty for every tier
+    point_value DECIMAL(12, 6) NOT NULL, -- currency units one point pays for
+    effective_from DATETIME NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    UNIQUE KEY uq_rates_effective (currency, tier, effective_from)
+);
+
+CREATE TABLE points_payments (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    reference VARCHAR(64) NOT NULL UNIQUE, -- transaction_id of the redemption
+    transaction_id VARCHAR(255) NOT NULL UNIQUE, -- the purchase paid for
+    user_id INT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    rate_id INT NOT NULL,
+    points INT NOT NULL,
+    points_value DECIMAL(10, 2) NOT NULL,
+    cash_amount DECIMAL(10, 2) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_payments_user (user_id, created_at)
+);
+
+INSERT INTO schema_migrations (version, name) VALUES (19, '019_points_payments');
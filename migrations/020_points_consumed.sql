@@ -0,0 +1,7 @@
+-- Redemptions, pool redemptions, reward orders, hold captures and points
+-- payments now debit the owner's earned lots oldest first, as transfers already
+-- did. The column that counts what has been taken from a lot is renamed from
+-- transferred to consumed; only what is left of a lot can expire.
+ALTER TABLE points RENAME COLUMN transferred TO consumed;
+
+INSERT INTO schema_migrations (version, name) VALUES (20, '020_points_consumed');
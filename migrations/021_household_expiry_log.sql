@@ -0,0 +1,9 @@
+-- Expired pool lots are logged against the household that held them rather than
+-- the member who earned them, so expired_points_log rows carry either a user_id
+-- or a household_id.
+ALTER TABLE expired_points_log
+    MODIFY COLUMN user_id INT NULL,
+    ADD COLUMN household_id INT NULL,
+    ADD INDEX idx_expired_points_household (household_id);
+
+INSERT INTO schema_migrations (version, name) VALUES (21, '021_household_expiry_log');
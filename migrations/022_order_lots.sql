@@ -0,0 +1,18 @@
+-- Refunded reward orders give the member back the lots the order spent, with
+-- their original expiry. reward_order_lots keeps each slice an order took, and
+-- the replacement lots are linked to the order through points.order_id. Orders
+-- placed before this migration are refunded as unbacked points, as before.
+CREATE TABLE reward_order_lots (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    points INT NOT NULL,
+    valid_until DATETIME(6) NULL, -- NULL for points that never expire
+    expiry_policy VARCHAR(32) NULL,
+    expiry_days INT NOT NULL DEFAULT 0,
+    INDEX idx_order_lots_order (order_id)
+);
+
+ALTER TABLE points
+    ADD COLUMN order_id INT NULL;
+
+INSERT INTO schema_migrations (version, name) VALUES (22, '022_order_lots');
@@ -0,0 +1,303 @@
+// Package client is a typed Go client for the Loyalty Points System API. It logs
+// in, refreshes expired access tokens through /refresh, retries idempotent calls
+// and decodes API errors into *Error values.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader carries the key that lets the server replay a retried
+// POST. It is declared here so the client does not pull in the server packages.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Client calls the API at a base URL. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	userAgent  string
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	refreshMu    sync.Mutex // serializes token refreshes
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTokens starts the client with previously issued tokens instead of calling Login.
+func WithTokens(accessToken, refreshToken string) Option {
+	return func(c *Client) { c.accessToken, c.refreshToken = accessToken, refreshToken }
+}
+
+// WithRetries sets how many times a retryable call is repeated after a network
+// error, 5xx or 429 response, and the initial backoff, which doubles per attempt.
+// A 429 is retried after its Retry-After instead.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) { c.maxRetries, c.backoff = maxRetries, backoff }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client for the API at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 2,
+		backoff:    200 * time.Millisecond,
+		userAgent:  "loyalty-points-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tokens returns the current access and refresh tokens, e.g. to persist them.
+func (c *Client) Tokens() (accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken, c.refreshToken
+}
+
+// SetTokens replaces the tokens used for authenticated calls.
+func (c *Client) SetTokens(accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken, c.refreshToken = accessToken, refreshToken
+}
+
+// call describes one API request.
+type call struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	auth        bool      // send the access token, refreshing it once on 401
+	idempotent  bool      // POST retried under one Idempotency-Key
+	raw         io.Writer // receives the body of a non-envelope response
+	probe       bool      // the body is the data itself, also with 503; not retried
+}
+
+// envelope is the success envelope with data left undecoded.
+type envelope struct {
+	Data       json.RawMessage `json:"data"`
+	Message    string          `json:"message"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+func jsonCall(method, path string, body interface{}) (call, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return call{}, fmt.Errorf("client: encoding request: %w", err)
+	}
+	return call{method: method, path: path, body: payload, contentType: "application/json"}, nil
+}
+
+// do sends req and decodes the envelope's data into out. It returns the page's
+// next cursor, if any.
+func (c *Client) do(ctx context.Context, req call, out interface{}) (string, error) {
+	var idempotencyKey string
+	if req.idempotent {
+		idempotencyKey = newIdempotencyKey()
+	}
+	// A probe reports the service's state now, so it is not retried
+	retryable := (req.method == http.MethodGet || req.idempotent) && !req.probe
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		token := ""
+		if req.auth {
+			token, _ = c.Tokens()
+		}
+		resp, err := c.send(ctx, req, token, idempotencyKey)
+		if err != nil {
+			if retryable && attempt < c.maxRetries && ctx.Err() == nil {
+				if err := c.sleep(ctx, attempt); err != nil {
+					return "", err
+				}
+				continue
+			}
+			return "", err
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError && retryable && attempt < c.maxRetries {
+			resp.Body.Close()
+			if err := c.sleep(ctx, attempt); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		// A rate limited request was not processed; it is retried once the server's
+		// Retry-After has passed
+		if resp.StatusCode == http.StatusTooManyRequests && retryable && attempt < c.maxRetries {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				resp.Body.Close()
+				if err := wait(ctx, time.Duration(seconds)*time.Second); err != nil {
+					return "", err
+				}
+				continue
+			}
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && req.auth && !refreshed {
+			apiErr := decodeError(resp)
+			if !IsCode(apiErr, CodeTokenInvalid) || !c.canRefresh() {
+				return "", apiErr
+			}
+			if err := c.refresh(ctx, token); err != nil {
+				return "", err
+			}
+			// The request is replayed with the new token and does not count as a retry
+			refreshed = true
+			attempt--
+			continue
+		}
+
+		if req.probe {
+			return "", decodeProbe(resp, out)
+		}
+		return decodeResponse(resp, req.raw, out)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req call, token, idempotencyKey string) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("client: building request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if idempotencyKey != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("client: %s %s: %w", req.method, req.path, err)
+	}
+	return resp, nil
+}
+
+func (c *Client) sleep(ctx context.Context, attempt int) error {
+	return wait(ctx, c.backoff<<attempt)
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Client) canRefresh() bool {
+	_, refreshToken := c.Tokens()
+	return refreshToken != ""
+}
+
+// refresh replaces a stale access token. When several calls see the same expired
+// token only the first one calls /refresh.
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if current, _ := c.Tokens(); current != stale {
+		return nil
+	}
+	_, err := c.RefreshToken(ctx)
+	return err
+}
+
+func decodeResponse(resp *http.Response, raw io.Writer, out interface{}) (string, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", decodeError(resp)
+	}
+
+	if raw != nil {
+		if _, err := io.Copy(raw, resp.Body); err != nil {
+			return "", fmt.Errorf("client: reading response: %w", err)
+		}
+		return "", nil
+	}
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return "", fmt.Errorf("client: decoding response: %w", err)
+	}
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return "", fmt.Errorf("client: decoding response data: %w", err)
+		}
+	}
+	return env.NextCursor, nil
+}
+
+// decodeProbe decodes a probe body into out. A 503 body is decoded as well and
+// ErrNotReady is returned with it.
+func decodeProbe(resp *http.Response, out interface{}) error {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return decodeError(resp)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decoding response: %w", err)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return ErrNotReady
+	}
+	return nil
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to the clock
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,624 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Login exchanges credentials for tokens and keeps them for later calls.
+func (c *Client) Login(ctx context.Context, username, password string) (*TokenResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/login", LoginRequest{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	var out TokenResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	c.SetTokens(out.AccessToken, out.RefreshToken)
+	return &out, nil
+}
+
+// RefreshToken exchanges the refresh token for a new access token and keeps it.
+// Authenticated calls do this automatically when the access token has expired.
+func (c *Client) RefreshToken(ctx context.Context) (*RefreshResponse, error) {
+	_, refreshToken := c.Tokens()
+	req, err := jsonCall(http.MethodPost, "/refresh", RefreshRequest{RefreshToken: refreshToken})
+	if err != nil {
+		return nil, err
+	}
+	var out RefreshResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	c.SetTokens(out.AccessToken, refreshToken)
+	return &out, nil
+}
+
+// Health reports whether the service is up.
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	var out HealthResponse
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/health"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Livez reports whether the server process is up, without checking its
+// dependencies.
+func (c *Client) Livez(ctx context.Context) (*HealthResponse, error) {
+	var out HealthResponse
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/livez", probe: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Readyz runs the server's readiness checks. When the server is not ready, the
+// report is returned with ErrNotReady.
+func (c *Client) Readyz(ctx context.Context) (*ReadinessResponse, error) {
+	var out ReadinessResponse
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/readyz", probe: true}, &out); err != nil {
+		if errors.Is(err, ErrNotReady) {
+			return &out, err
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateUser registers a user.
+func (c *Client) CreateUser(ctx context.Context, in CreateUserRequest) (*CreateUserResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/create-user", in)
+	if err != nil {
+		return nil, err
+	}
+	var out CreateUserResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListUsers returns one page of users. Admin only.
+func (c *Client) ListUsers(ctx context.Context, opts PageOptions) (*UserPage, error) {
+	page := &UserPage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/get-all-users", query: opts.values(), auth: true}, &page.Users)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// AddTransaction records a purchase. It is retried under one Idempotency-Key, so a
+// retry after a lost response does not earn points twice.
+func (c *Client) AddTransaction(ctx context.Context, in AddTransactionRequest) (*AddTransactionResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/add-transaction", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out AddTransactionResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPointsBalance returns a user's balance and one page of earning history.
+func (c *Client) GetPointsBalance(ctx context.Context, userID int, opts PageOptions) (*PointsBalancePage, error) {
+	q := opts.values()
+	q.Set("user_id", strconv.Itoa(userID))
+	page := &PointsBalancePage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/points-balance", query: q, auth: true}, &page.PointsBalanceResponse)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// RedeemPoints redeems points. It is retried under one Idempotency-Key, so a retry
+// after a lost response does not redeem twice.
+func (c *Client) RedeemPoints(ctx context.Context, in RedeemRequest) (*RedeemResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/redeem", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out RedeemResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TransferPoints moves points to another member. It is retried under one
+// Idempotency-Key, so a retry after a lost response does not transfer twice.
+func (c *Client) TransferPoints(ctx context.Context, in TransferRequest) (*TransferResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/transfers", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out TransferResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuthorizeHold reserves points of the caller's balance. It is retried under one
+// Idempotency-Key, so a retry does not place a second hold.
+func (c *Client) AuthorizeHold(ctx context.Context, in AuthorizeHoldRequest) (*HoldResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/holds", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out HoldResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHold returns one points hold.
+func (c *Client) GetHold(ctx context.Context, holdID int) (*Hold, error) {
+	var out Hold
+	path := fmt.Sprintf("/holds/%d", holdID)
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CaptureHold redeems a hold; in.Points of zero captures all of it. It is retried
+// under one Idempotency-Key, so a retry does not fail on the captured hold.
+func (c *Client) CaptureHold(ctx context.Context, holdID int, in CaptureHoldRequest) (*HoldResponse, error) {
+	req, err := jsonCall(http.MethodPost, fmt.Sprintf("/holds/%d/capture", holdID), in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out HoldResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReleaseHold releases a hold, making its points available again. It is retried
+// under one Idempotency-Key.
+func (c *Client) ReleaseHold(ctx context.Context, holdID int) (*HoldResponse, error) {
+	var out HoldResponse
+	path := fmt.Sprintf("/holds/%d/release", holdID)
+	if _, err := c.do(ctx, call{method: http.MethodPost, path: path, auth: true, idempotent: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPointsQuote prices in.Amount in points for a member at the rate in effect
+// now. An empty in.Currency uses the server's default currency.
+func (c *Client) GetPointsQuote(ctx context.Context, in PointsQuoteRequest) (*PointsQuote, error) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(in.UserID))
+	q.Set("amount", strconv.FormatFloat(in.Amount, 'f', -1, 64))
+	setNonEmpty(q, "currency", in.Currency)
+
+	var out PointsQuote
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/points-quote", query: q, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListConversionRates returns one page of point-to-currency conversion rates.
+func (c *Client) ListConversionRates(ctx context.Context, opts ConversionRateOptions) (*ConversionRatePage, error) {
+	q := opts.values()
+	setNonEmpty(q, "currency", opts.Currency)
+	setNonEmpty(q, "tier", opts.Tier)
+
+	page := &ConversionRatePage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/conversion-rates", query: q, auth: true}, &page.Rates)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// CreateConversionRate adds a conversion rate taking effect at in.EffectiveFrom.
+// Admin only.
+func (c *Client) CreateConversionRate(ctx context.Context, in ConversionRateRequest) (*ConversionRate, error) {
+	req, err := jsonCall(http.MethodPost, "/conversion-rates", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth = true
+	var out ConversionRate
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateHousehold creates a household pool headed by the caller. It is retried
+// under one Idempotency-Key, so a retry does not create a second household.
+func (c *Client) CreateHousehold(ctx context.Context, in CreateHouseholdRequest) (*Household, error) {
+	req, err := jsonCall(http.MethodPost, "/households", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out Household
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHousehold returns a household's pooled balance and members.
+func (c *Client) GetHousehold(ctx context.Context, householdID int) (*Household, error) {
+	var out Household
+	path := fmt.Sprintf("/households/%d", householdID)
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHouseholdHistory returns one page of a household's pool history.
+func (c *Client) GetHouseholdHistory(ctx context.Context, householdID int, opts PageOptions) (*PointsHistoryPage, error) {
+	page := &PointsHistoryPage{}
+	path := fmt.Sprintf("/households/%d/history", householdID)
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: path, query: opts.values(), auth: true}, &page.Entries)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// InviteHouseholdMember invites a member to the household. Head only.
+func (c *Client) InviteHouseholdMember(ctx context.Context, householdID int, in InviteMemberRequest) (*HouseholdInvitation, error) {
+	req, err := jsonCall(http.MethodPost, fmt.Sprintf("/households/%d/invitations", householdID), in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth = true
+	var out HouseholdInvitation
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RedeemHouseholdPoints spends points from the household pool. It is retried
+// under one Idempotency-Key, so a retry after a lost response does not redeem
+// twice.
+func (c *Client) RedeemHouseholdPoints(ctx context.Context, householdID int, in HouseholdRedeemRequest) (*HouseholdRedeemResponse, error) {
+	req, err := jsonCall(http.MethodPost, fmt.Sprintf("/households/%d/redeem", householdID), in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out HouseholdRedeemResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateHouseholdMemberRole changes a member's role. Head only.
+func (c *Client) UpdateHouseholdMemberRole(ctx context.Context, householdID, userID int, in UpdateMemberRoleRequest) (*Household, error) {
+	req, err := jsonCall(http.MethodPatch, fmt.Sprintf("/households/%d/members/%d", householdID, userID), in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth = true
+	var out Household
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveHouseholdMember removes a member from the household; members may remove
+// themselves to leave it.
+func (c *Client) RemoveHouseholdMember(ctx context.Context, householdID, userID int) (*Household, error) {
+	var out Household
+	path := fmt.Sprintf("/households/%d/members/%d", householdID, userID)
+	if _, err := c.do(ctx, call{method: http.MethodDelete, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListHouseholdInvitations returns the caller's pending household invitations.
+func (c *Client) ListHouseholdInvitations(ctx context.Context) ([]HouseholdInvitation, error) {
+	var out []HouseholdInvitation
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/household-invitations", auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AcceptHouseholdInvitation joins the inviting household and returns it.
+func (c *Client) AcceptHouseholdInvitation(ctx context.Context, invitationID int) (*Household, error) {
+	var out Household
+	path := fmt.Sprintf("/household-invitations/%d/accept", invitationID)
+	if _, err := c.do(ctx, call{method: http.MethodPost, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeclineHouseholdInvitation declines a household invitation.
+func (c *Client) DeclineHouseholdInvitation(ctx context.Context, invitationID int) (*HouseholdInvitation, error) {
+	var out HouseholdInvitation
+	path := fmt.Sprintf("/household-invitations/%d/decline", invitationID)
+	if _, err := c.do(ctx, call{method: http.MethodPost, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRewards returns one page of the rewards catalog.
+func (c *Client) ListRewards(ctx context.Context, opts RewardOptions) (*RewardPage, error) {
+	q := opts.values()
+	if opts.All {
+		q.Set("all", "true")
+	}
+	page := &RewardPage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/rewards", query: q, auth: true}, &page.Rewards)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// CreateReward adds a reward to the catalog. Admin only.
+func (c *Client) CreateReward(ctx context.Context, in RewardRequest) (*Reward, error) {
+	req, err := jsonCall(http.MethodPost, "/rewards", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth = true
+	var out Reward
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetReward returns one reward.
+func (c *Client) GetReward(ctx context.Context, rewardID int) (*Reward, error) {
+	var out Reward
+	path := fmt.Sprintf("/rewards/%d", rewardID)
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateReward replaces a reward. Admin only.
+func (c *Client) UpdateReward(ctx context.Context, rewardID int, in RewardRequest) (*Reward, error) {
+	req, err := jsonCall(http.MethodPut, fmt.Sprintf("/rewards/%d", rewardID), in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth = true
+	var out Reward
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlaceOrder orders a reward with the caller's points. It is retried under one
+// Idempotency-Key, so a retry after a lost response does not order twice.
+func (c *Client) PlaceOrder(ctx context.Context, in OrderRequest) (*OrderResponse, error) {
+	req, err := jsonCall(http.MethodPost, "/orders", in)
+	if err != nil {
+		return nil, err
+	}
+	req.auth, req.idempotent = true, true
+	var out OrderResponse
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListOrders returns one page of reward orders, newest first.
+func (c *Client) ListOrders(ctx context.Context, opts OrderOptions) (*OrderPage, error) {
+	q := opts.values()
+	setPositive(q, "user_id", opts.UserID)
+	setNonEmpty(q, "status", opts.Status)
+
+	page := &OrderPage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/orders", query: q, auth: true}, &page.Orders)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// GetOrder returns one reward order.
+func (c *Client) GetOrder(ctx context.Context, orderID int) (*Order, error) {
+	return c.orderCall(ctx, http.MethodGet, fmt.Sprintf("/orders/%d", orderID))
+}
+
+// CancelOrder cancels a pending order, restoring its points and stock.
+func (c *Client) CancelOrder(ctx context.Context, orderID int) (*Order, error) {
+	return c.orderCall(ctx, http.MethodPost, fmt.Sprintf("/orders/%d/cancel", orderID))
+}
+
+// FulfillOrder marks a pending order as fulfilled. Admin only.
+func (c *Client) FulfillOrder(ctx context.Context, orderID int) (*Order, error) {
+	return c.orderCall(ctx, http.MethodPost, fmt.Sprintf("/orders/%d/fulfill", orderID))
+}
+
+// RefundOrder refunds a fulfilled order, restoring its points. Admin only.
+func (c *Client) RefundOrder(ctx context.Context, orderID int) (*Order, error) {
+	return c.orderCall(ctx, http.MethodPost, fmt.Sprintf("/orders/%d/refund", orderID))
+}
+
+func (c *Client) orderCall(ctx context.Context, method, path string) (*Order, error) {
+	var out Order
+	if _, err := c.do(ctx, call{method: method, path: path, auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPointsHistory returns one page of a user's unified points history.
+func (c *Client) GetPointsHistory(ctx context.Context, userID int, opts HistoryOptions) (*PointsHistoryPage, error) {
+	q := opts.values()
+	setNonEmpty(q, "from", opts.From)
+	setNonEmpty(q, "to", opts.To)
+	setNonEmpty(q, "tz", opts.TZ)
+	setNonEmpty(q, "type", joinTypes(opts.Types))
+	path := fmt.Sprintf("/users/%d/points/history", userID)
+
+	page := &PointsHistoryPage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: path, query: q, auth: true}, &page.Entries)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// ListTransactions returns one page of a user's transactions.
+func (c *Client) ListTransactions(ctx context.Context, userID int, opts TransactionOptions) (*TransactionPage, error) {
+	q := opts.values()
+	q.Set("user_id", strconv.Itoa(userID))
+	setNonEmpty(q, "category", opts.Category)
+
+	page := &TransactionPage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/transactions", query: q, auth: true}, &page.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// ImportTransactions uploads an end-of-day CSV or JSONL file. Admin only. The
+// server resumes interrupted imports of the same file, so re-sending is safe.
+func (c *Client) ImportTransactions(ctx context.Context, file io.Reader, opts ImportOptions) (*ImportReport, error) {
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("client: reading import file: %w", err)
+	}
+	q := url.Values{}
+	setNonEmpty(q, "format", opts.Format)
+	setPositive(q, "chunk_size", opts.ChunkSize)
+	setNonEmpty(q, "source", opts.Source)
+	contentType := "application/x-ndjson"
+	if opts.Format == "csv" {
+		contentType = "text/csv"
+	}
+
+	var out ImportReport
+	req := call{method: http.MethodPost, path: "/transactions/batch", query: q, body: body, contentType: contentType, auth: true}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportDataset streams the transactions, points or audit_log table to w. Admin only.
+func (c *Client) ExportDataset(ctx context.Context, dataset string, opts ExportOptions, w io.Writer) error {
+	q := url.Values{}
+	setNonEmpty(q, "format", opts.Format)
+	setNonEmpty(q, "month", opts.Month)
+	setNonEmpty(q, "from", opts.From)
+	setNonEmpty(q, "to", opts.To)
+	setPositive(q, "user_id", opts.UserID)
+
+	_, err := c.do(ctx, call{method: http.MethodGet, path: "/export/" + url.PathEscape(dataset), query: q, auth: true, raw: w}, nil)
+	return err
+}
+
+// ListAuditLog returns one page of audit log entries. Admin only.
+func (c *Client) ListAuditLog(ctx context.Context, opts AuditLogOptions) (*AuditLogPage, error) {
+	q := opts.values()
+	setPositive(q, "user_id", opts.UserID)
+	setNonEmpty(q, "action", opts.Action)
+	setNonEmpty(q, "request_id", opts.RequestID)
+
+	page := &AuditLogPage{}
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: "/audit-log", query: q, auth: true}, &page.Entries)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// ListJobs returns the background jobs with their schedules and latest runs. Admin only.
+func (c *Client) ListJobs(ctx context.Context) ([]JobInfo, error) {
+	var out []JobInfo
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/jobs", auth: true}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListJobRuns returns one page of a job's run history, newest first. Admin only.
+func (c *Client) ListJobRuns(ctx context.Context, name string, opts PageOptions) (*JobRunPage, error) {
+	page := &JobRunPage{}
+	path := "/jobs/" + url.PathEscape(name) + "/runs"
+	cursor, err := c.do(ctx, call{method: http.MethodGet, path: path, query: opts.values(), auth: true}, &page.Runs)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+// RunJob runs a job now and returns the recorded run; check its Status for the
+// outcome. With dryRun the job reports what it would change. Admin only.
+func (c *Client) RunJob(ctx context.Context, name string, dryRun bool) (*JobRun, error) {
+	q := url.Values{}
+	if dryRun {
+		q.Set("dry_run", "true")
+	}
+	var out JobRun
+	req := call{method: http.MethodPost, path: "/jobs/" + url.PathEscape(name) + "/run", query: q, auth: true}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMetrics returns the server's metrics in the Prometheus text format.
+func (c *Client) GetMetrics(ctx context.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/metrics", raw: &buf}, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetOpenAPI returns the API's OpenAPI document.
+func (c *Client) GetOpenAPI(ctx context.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.do(ctx, call{method: http.MethodGet, path: "/openapi.json", raw: &buf}, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
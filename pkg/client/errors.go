@@ -0,0 +1,136 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Code is a stable API error code.
+type Code string
+
+// FieldError is one invalid request field in a VALIDATION_FAILED error.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Msg   string `json:"msg"`
+}
+
+// Error codes returned by the API; see the Error Codes section of the README.
+const (
+	CodeBadRequest         Code = "REQUEST_INVALID"
+	CodeInvalidBody        Code = "REQUEST_INVALID_BODY"
+	CodeInvalidParameter   Code = "REQUEST_INVALID_PARAMETER"
+	CodeMissingParameter   Code = "REQUEST_MISSING_PARAMETER"
+	CodeValidationFailed   Code = "VALIDATION_FAILED"
+	CodeMethodNotAllowed   Code = "METHOD_NOT_ALLOWED"
+	CodeRouteNotFound      Code = "ROUTE_NOT_FOUND"
+	CodePaginationInvalid  Code = "PAGINATION_INVALID"
+	CodeFormatUnsupported  Code = "FORMAT_UNSUPPORTED"
+	CodeDatasetUnknown     Code = "EXPORT_DATASET_UNKNOWN"
+	CodeUnauthorized       Code = "AUTH_UNAUTHORIZED"
+	CodeTokenMissing       Code = "AUTH_TOKEN_MISSING"
+	CodeTokenInvalid       Code = "AUTH_TOKEN_INVALID"
+	CodeInvalidCredentials Code = "AUTH_INVALID_CREDENTIALS"
+	CodeForbidden          Code = "ACCESS_FORBIDDEN"
+	CodeUserNotFound       Code = "USER_NOT_FOUND"
+	CodeUserExists         Code = "USER_ALREADY_EXISTS"
+	CodePointsInsufficient Code = "POINTS_INSUFFICIENT"
+	CodeTransferLimit      Code = "TRANSFER_LIMIT_EXCEEDED"
+	CodeTransferNotAllowed Code = "TRANSFER_NOT_ALLOWED"
+	CodeHouseholdNotFound  Code = "HOUSEHOLD_NOT_FOUND"
+	CodeHouseholdMember    Code = "HOUSEHOLD_MEMBERSHIP_EXISTS"
+	CodeHouseholdNoMember  Code = "HOUSEHOLD_MEMBER_NOT_FOUND"
+	CodeHouseholdFull      Code = "HOUSEHOLD_FULL"
+	CodeHouseholdRole      Code = "HOUSEHOLD_PERMISSION_DENIED"
+	CodeInvitationNotFound Code = "HOUSEHOLD_INVITATION_NOT_FOUND"
+	CodeInvitationClosed   Code = "HOUSEHOLD_INVITATION_CLOSED"
+	CodeRewardNotFound     Code = "REWARD_NOT_FOUND"
+	CodeRewardExists       Code = "REWARD_SKU_DUPLICATE"
+	CodeRewardUnavailable  Code = "REWARD_UNAVAILABLE"
+	CodeRewardOutOfStock   Code = "REWARD_OUT_OF_STOCK"
+	CodeRewardTier         Code = "REWARD_TIER_RESTRICTED"
+	CodeOrderNotFound      Code = "ORDER_NOT_FOUND"
+	CodeOrderState         Code = "ORDER_STATE_INVALID"
+	CodeHoldNotFound       Code = "HOLD_NOT_FOUND"
+	CodeHoldState          Code = "HOLD_STATE_INVALID"
+	CodeHoldExpired        Code = "HOLD_EXPIRED"
+	CodeHoldExceeded       Code = "HOLD_CAPTURE_EXCEEDS_AMOUNT"
+	CodeRateNotFound       Code = "CONVERSION_RATE_NOT_FOUND"
+	CodeRateExists         Code = "CONVERSION_RATE_DUPLICATE"
+	CodePaymentExceeded    Code = "POINTS_PAYMENT_EXCEEDS_AMOUNT"
+	CodeCategoryInvalid    Code = "TRANSACTION_CATEGORY_INVALID"
+	CodeTransactionExists  Code = "TRANSACTION_DUPLICATE"
+	CodeImportInterrupted  Code = "IMPORT_INTERRUPTED"
+	CodeIdempotencyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyPending Code = "IDEMPOTENCY_REQUEST_IN_PROGRESS"
+	CodeJobUnknown         Code = "JOB_UNKNOWN"
+	CodeJobRunning         Code = "JOB_ALREADY_RUNNING"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeInternal           Code = "INTERNAL_ERROR"
+)
+
+// ErrNotReady is returned by Readyz, along with the report, when the service is
+// not ready.
+var ErrNotReady = errors.New("client: service is not ready")
+
+// Error is an error response from the API.
+type Error struct {
+	StatusCode int
+	Code       Code
+	Msg        string
+	Details    string
+	Fields     []FieldError
+	RequestID  string // the server's X-Request-ID, for support requests
+}
+
+func (e *Error) Error() string {
+	if e.Details == "" {
+		return fmt.Sprintf("api error %d %s: %s", e.StatusCode, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("api error %d %s: %s", e.StatusCode, e.Code, e.Details)
+}
+
+// IsCode reports whether err is an API error with the given code.
+func IsCode(err error, code Code) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// errorEnvelope is the body of every error response.
+type errorEnvelope struct {
+	Error struct {
+		Code      string       `json:"code"`
+		Msg       string       `json:"msg"`
+		Details   string       `json:"details"`
+		Fields    []FieldError `json:"fields"`
+		RequestID string       `json:"request_id"`
+	} `json:"error"`
+}
+
+// decodeError reads an ErrorResponse envelope. Bodies that are not envelopes, e.g.
+// from a proxy, keep the HTTP status with an empty code.
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &Error{StatusCode: resp.StatusCode, Msg: http.StatusText(resp.StatusCode),
+		RequestID: resp.Header.Get("X-Request-ID")}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return apiErr
+	}
+	var env errorEnvelope
+	if json.Unmarshal(body, &env) != nil || env.Error.Code == "" {
+		return apiErr
+	}
+	apiErr.Code = Code(env.Error.Code)
+	apiErr.Msg = env.Error.Msg
+	apiErr.Details = env.Error.Details
+	apiErr.Fields = env.Error.Fields
+	if env.Error.RequestID != "" {
+		apiErr.RequestID = env.Error.RequestID
+	}
+	return apiErr
+}
@@ -0,0 +1,574 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request and response types mirror the API's JSON. They are declared here,
+// not shared with the server, so the client depends on nothing but the wire
+// format.
+
+// LoginRequest is the body of POST /login.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// TokenResponse carries the tokens issued by /login.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshRequest is the body of POST /refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshResponse carries the access token issued by /refresh.
+type RefreshResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
+// HealthResponse is the liveness report.
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// ReadinessResponse is the readiness report with one entry per component.
+type ReadinessResponse struct {
+	Status       string            `json:"status"`
+	ShuttingDown bool              `json:"shutting_down,omitempty"`
+	Components   []ComponentHealth `json:"components"`
+}
+
+// ComponentHealth is one component of a ReadinessResponse.
+type ComponentHealth struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+// CreateUserRequest is the body of POST /create-user.
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"` // bcrypt rejects passwords over 72 bytes
+}
+
+// CreateUserResponse identifies the created user.
+type CreateUserResponse struct {
+	UserID int64 `json:"user_id"`
+}
+
+// UserSummary is a user in user lists.
+type UserSummary struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// AddTransactionRequest records a purchase, optionally paid partly with points.
+type AddTransactionRequest struct {
+	TransactionID     string  `json:"transaction_id"`
+	UserID            int     `json:"user_id"`
+	TransactionAmount float64 `json:"transaction_amount"`
+	Category          string  `json:"category"`
+	TransactionDate   string  `json:"transaction_date"`
+	ProductCode       string  `json:"product_code"`
+	Currency          string  `json:"currency,omitempty"`       // DEFAULT_CURRENCY when empty
+	PointsPayment     int     `json:"points_payment,omitempty"` // points put towards the amount
+}
+
+// AddTransactionResponse reports the points a purchase earned.
+type AddTransactionResponse struct {
+	Message string         `json:"message"`
+	Points  int            `json:"points"`
+	Payment *PointsPayment `json:"payment,omitempty"` // set when points paid part of the purchase
+}
+
+// TransactionRecord is a recorded transaction.
+type TransactionRecord struct {
+	ID                int       `json:"id"`
+	TransactionID     string    `json:"transaction_id"`
+	UserID            int       `json:"user_id"`
+	TransactionAmount float64   `json:"transaction_amount"`
+	Category          string    `json:"category"`
+	TransactionDate   time.Time `json:"transaction_date"`
+	ProductCode       string    `json:"product_code"`
+	Points            int       `json:"points"`
+}
+
+// RedeemRequest spends points from a member's balance.
+type RedeemRequest struct {
+	UserID int `json:"user_id"`
+	Points int `json:"points"`
+}
+
+// RedeemResponse reports a redemption.
+type RedeemResponse struct {
+	RemainingPoints int    `json:"remaining_points"`
+	PointsRedeemed  int    `json:"points_redeemed"`
+	RedemptionID    string `json:"redemption_id"`
+}
+
+// TransferRequest moves points between members.
+type TransferRequest struct {
+	FromUserID     int    `json:"from_user_id"`
+	ToUserID       int    `json:"to_user_id"`
+	Points         int    `json:"points"`
+	PreserveExpiry bool   `json:"preserve_expiry"`
+	Note           string `json:"note,omitempty"`
+}
+
+// TransferResponse reports a transfer and the sender's lots it debited.
+type TransferResponse struct {
+	TransferID        string        `json:"transfer_id"`
+	FromUserID        int           `json:"from_user_id"`
+	ToUserID          int           `json:"to_user_id"`
+	PointsTransferred int           `json:"points_transferred"`
+	RemainingPoints   int           `json:"remaining_points"`
+	PreserveExpiry    bool          `json:"preserve_expiry"`
+	Lots              []TransferLot `json:"lots"` // sender's lots debited, oldest first
+}
+
+// TransferLot is one debited slice of the sender's lots.
+type TransferLot struct {
+	Points     int        `json:"points"`
+	ValidUntil *time.Time `json:"valid_until"` // null for points that never expire
+}
+
+// AuthorizeHoldRequest reserves points for a later capture.
+type AuthorizeHoldRequest struct {
+	UserID int `json:"user_id"`
+	Points int `json:"points"`
+}
+
+// CaptureHoldRequest redeems all of a hold, or Points of it.
+type CaptureHoldRequest struct {
+	Points int `json:"points,omitempty"`
+}
+
+// Hold is a reservation of points.
+type Hold struct {
+	ID             int        `json:"id"`
+	Reference      string     `json:"reference"` // redemption transaction_id once captured
+	UserID         int        `json:"user_id"`
+	Points         int        `json:"points"`
+	CapturedPoints int        `json:"captured_points,omitempty"`
+	Status         string     `json:"status"` // authorized, captured, released or expired
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+}
+
+// HoldResponse is a hold with the member's balances.
+type HoldResponse struct {
+	Hold            Hold `json:"hold"`
+	Balance         int  `json:"balance"`
+	AvailablePoints int  `json:"available_points"`
+	PendingPoints   int  `json:"pending_points"`
+}
+
+// PointsQuoteRequest prices an amount in points.
+type PointsQuoteRequest struct {
+	UserID   int     `json:"user_id"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"` // DEFAULT_CURRENCY when empty
+}
+
+// PointsQuote is the price of an amount in points for a member.
+type PointsQuote struct {
+	UserID          int            `json:"user_id"`
+	Currency        string         `json:"currency"`
+	Amount          float64        `json:"amount"`
+	Rate            ConversionRate `json:"rate"`
+	PointsRequired  int            `json:"points_required"` // points that pay the whole amount
+	AvailablePoints int            `json:"available_points"`
+	MaxPoints       int            `json:"max_points"` // most points the member can put towards the amount
+	MaxPointsValue  float64        `json:"max_points_value"`
+	CashAmount      float64        `json:"cash_amount"` // left to pay in cash after MaxPoints
+}
+
+// PointsPayment is the points part of a purchase.
+type PointsPayment struct {
+	Reference   string  `json:"reference"` // transaction_id of the redemption
+	Currency    string  `json:"currency"`
+	RateID      int     `json:"rate_id"`
+	PointValue  float64 `json:"point_value"`
+	Points      int     `json:"points"`
+	PointsValue float64 `json:"points_value"`
+	CashAmount  float64 `json:"cash_amount"` // the part that earns points
+}
+
+// ConversionRateRequest adds a conversion rate.
+type ConversionRateRequest struct {
+	Currency      string  `json:"currency"`
+	Tier          string  `json:"tier,omitempty"` // empty for every tier
+	PointValue    float64 `json:"point_value"`
+	EffectiveFrom string  `json:"effective_from,omitempty"` // now when empty
+}
+
+// ConversionRate is the value of one point in a currency.
+type ConversionRate struct {
+	ID            int       `json:"id"`
+	Currency      string    `json:"currency"`
+	Tier          string    `json:"tier"`        // empty for every tier
+	PointValue    float64   `json:"point_value"` // currency units one point pays for
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CreateHouseholdRequest is the body of POST /households.
+type CreateHouseholdRequest struct {
+	Name string `json:"name"`
+}
+
+// Household is a household pool with its members.
+type Household struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	HeadUserID int               `json:"head_user_id"`
+	Balance    int               `json:"balance"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Members    []HouseholdMember `json:"members"`
+}
+
+// HouseholdMember is one member of a household.
+type HouseholdMember struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"` // head, redeemer or member
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// InviteMemberRequest invites a user into a household.
+type InviteMemberRequest struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// HouseholdInvitation is an invitation into a household.
+type HouseholdInvitation struct {
+	ID            int       `json:"id"`
+	HouseholdID   int       `json:"household_id"`
+	HouseholdName string    `json:"household_name"`
+	InviteeUserID int       `json:"invitee_user_id"`
+	Role          string    `json:"role"`
+	Status        string    `json:"status"` // pending, accepted, declined or revoked
+	InvitedBy     int       `json:"invited_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// UpdateMemberRoleRequest changes a member's role.
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// HouseholdRedeemRequest spends points from a household pool.
+type HouseholdRedeemRequest struct {
+	Points int `json:"points"`
+}
+
+// HouseholdRedeemResponse reports a pool redemption.
+type HouseholdRedeemResponse struct {
+	HouseholdID     int    `json:"household_id"`
+	RemainingPoints int    `json:"remaining_points"`
+	PointsRedeemed  int    `json:"points_redeemed"`
+	RedemptionID    string `json:"redemption_id"`
+}
+
+// RewardRequest creates or replaces a catalog reward.
+type RewardRequest struct {
+	SKU            string   `json:"sku"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description,omitempty"`
+	PointsCost     int      `json:"points_cost"`
+	Stock          *int     `json:"stock"`                     // null for unlimited stock
+	AvailableFrom  string   `json:"available_from,omitempty"`  // orderable from, inclusive
+	AvailableUntil string   `json:"available_until,omitempty"` // orderable until, exclusive
+	Tiers          []string `json:"tiers,omitempty"`           // tiers that may order it, empty for all
+	Active         bool     `json:"active"`
+}
+
+// Reward is a catalog reward.
+type Reward struct {
+	ID             int        `json:"id"`
+	SKU            string     `json:"sku"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	PointsCost     int        `json:"points_cost"`
+	Stock          *int       `json:"stock"` // null for unlimited stock
+	AvailableFrom  *time.Time `json:"available_from"`
+	AvailableUntil *time.Time `json:"available_until"`
+	Tiers          []string   `json:"tiers"` // empty for every tier
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// OrderRequest orders a reward.
+type OrderRequest struct {
+	UserID   int `json:"user_id"`
+	RewardID int `json:"reward_id"`
+	Quantity int `json:"quantity"` // with points_cost's max, keeps an order's points inside INT
+}
+
+// Order is a reward order.
+type Order struct {
+	ID          int        `json:"id"`
+	Reference   string     `json:"reference"` // transaction_id of the redemption
+	UserID      int        `json:"user_id"`
+	RewardID    int        `json:"reward_id"`
+	RewardSKU   string     `json:"reward_sku"`
+	RewardName  string     `json:"reward_name"`
+	Quantity    int        `json:"quantity"`
+	Points      int        `json:"points"`
+	Status      string     `json:"status"` // pending, fulfilled, cancelled or refunded
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FulfilledAt *time.Time `json:"fulfilled_at"`
+	ClosedAt    *time.Time `json:"closed_at"` // when it was cancelled or refunded
+}
+
+// OrderResponse is an order with the member's remaining points.
+type OrderResponse struct {
+	Order           Order `json:"order"`
+	RemainingPoints int   `json:"remaining_points"`
+}
+
+// PointsBalanceResponse is a member's balances with earning history.
+type PointsBalanceResponse struct {
+	Balance         int             `json:"balance"`
+	AvailablePoints int             `json:"available_points"`
+	PendingPoints   int             `json:"pending_points"`
+	History         []PointsHistory `json:"history"`
+}
+
+// PointsHistory is one entry of a balance's earning history.
+type PointsHistory struct {
+	TransactionDate string `json:"transaction_date"`
+	Points          int    `json:"points"`
+	Reason          string `json:"reason"`
+}
+
+// PointsHistoryEntry is one entry of the points history timeline.
+type PointsHistoryEntry struct {
+	EntryID    int64     `json:"entry_id"`
+	Type       string    `json:"type"` // Earned, Redeemed, Expired, Adjusted, TransferOut, TransferIn
+	Points     int       `json:"points"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Reference  string    `json:"reference"` // transaction_id the entry belongs to
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// ImportReport summarises a batch import.
+type ImportReport struct {
+	JobID      int64             `json:"job_id"`
+	Resumed    bool              `json:"resumed"`
+	Total      int               `json:"total"`
+	Accepted   int               `json:"accepted"`
+	Duplicates int               `json:"duplicates"`
+	Rejected   int               `json:"rejected"`
+	Rows       []ImportRowResult `json:"rows"`
+}
+
+// ImportRowResult is the outcome of one imported row.
+type ImportRowResult struct {
+	Row           int    `json:"row"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Status        string `json:"status"`
+	Points        int    `json:"points,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// AuditEntry is one row of the audit log.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Action    string    `json:"action"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+	PrevHash  string    `json:"prev_hash"`
+	RowHash   string    `json:"row_hash"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// JobInfo describes a scheduled job and its last run.
+type JobInfo struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	LastRun  *JobRun    `json:"last_run,omitempty"`
+}
+
+// JobRun is one run of a job.
+type JobRun struct {
+	ID           int64      `json:"id"`
+	Job          string     `json:"job"`
+	Source       string     `json:"source"`
+	DryRun       bool       `json:"dry_run"`
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	Instance     string     `json:"instance"`
+	TriggeredBy  string     `json:"triggered_by,omitempty"`
+	Status       string     `json:"status"`
+	AffectedRows int64      `json:"affected_rows"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// PageOptions selects one page of a list endpoint. Zero values use the server
+// defaults; Cursor is the NextCursor of the previous page.
+type PageOptions struct {
+	Limit  int
+	Sort   string // field name, prefixed with "-" for descending order
+	Cursor string
+}
+
+func (p PageOptions) values() url.Values {
+	q := url.Values{}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// HistoryOptions filters GetPointsHistory.
+type HistoryOptions struct {
+	PageOptions
+	From  string   // YYYY-MM-DD or RFC 3339
+	To    string   // YYYY-MM-DD (inclusive) or RFC 3339 (exclusive)
+	TZ    string   // IANA time zone, default UTC
+	Types []string // earned, redeemed, expired, adjusted, transfer_out, transfer_in
+}
+
+// TransactionOptions filters ListTransactions.
+type TransactionOptions struct {
+	PageOptions
+	Category string
+}
+
+// RewardOptions filters ListRewards.
+type RewardOptions struct {
+	PageOptions
+	All bool // admin only: include inactive and out-of-window rewards
+}
+
+// ConversionRateOptions filters ListConversionRates.
+type ConversionRateOptions struct {
+	PageOptions
+	Currency string
+	Tier     string
+}
+
+// OrderOptions filters ListOrders. Without UserID every member's orders are
+// listed, which is admin only.
+type OrderOptions struct {
+	PageOptions
+	UserID int
+	Status string
+}
+
+// AuditLogOptions filters ListAuditLog.
+type AuditLogOptions struct {
+	PageOptions
+	UserID    int
+	Action    string
+	RequestID string
+}
+
+// ImportOptions configures ImportTransactions.
+type ImportOptions struct {
+	Format    string // csv or jsonl
+	ChunkSize int
+	Source    string
+}
+
+// ExportOptions filters ExportDataset.
+type ExportOptions struct {
+	Format string // csv, jsonl or columnar
+	Month  string // YYYY-MM, alternative to From/To
+	From   string
+	To     string // exclusive
+	UserID int
+}
+
+// UserPage is one page of ListUsers.
+type UserPage struct {
+	Users      []UserSummary
+	NextCursor string
+}
+
+// TransactionPage is one page of ListTransactions.
+type TransactionPage struct {
+	Transactions []TransactionRecord
+	NextCursor   string
+}
+
+// PointsBalancePage is the balance with one page of earning history.
+type PointsBalancePage struct {
+	PointsBalanceResponse
+	NextCursor string
+}
+
+// PointsHistoryPage is one page of GetPointsHistory.
+type PointsHistoryPage struct {
+	Entries    []PointsHistoryEntry
+	NextCursor string
+}
+
+// RewardPage is one page of ListRewards.
+type RewardPage struct {
+	Rewards    []Reward
+	NextCursor string
+}
+
+// ConversionRatePage is one page of ListConversionRates.
+type ConversionRatePage struct {
+	Rates      []ConversionRate
+	NextCursor string
+}
+
+// OrderPage is one page of ListOrders.
+type OrderPage struct {
+	Orders     []Order
+	NextCursor string
+}
+
+// AuditLogPage is one page of ListAuditLog.
+type AuditLogPage struct {
+	Entries    []AuditEntry
+	NextCursor string
+}
+
+// JobRunPage is one page of ListJobRuns.
+type JobRunPage struct {
+	Runs       []JobRun
+	NextCursor string
+}
+
+func setNonEmpty(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+func setPositive(q url.Values, key string, value int) {
+	if value > 0 {
+		q.Set(key, strconv.Itoa(value))
+	}
+}
+
+func joinTypes(types []string) string {
+	return strings.Join(types, ",")
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"loyalty-points-system-api/internal/apperrors"
 	response "loyalty-points-system-api/internal/reponse"
 	"loyalty-points-system-api/internal/utils"
 )
@@ -19,44 +20,28 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Get the Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			response.WriteErrorResponse(w, http.StatusUnauthorized, response.APIError{
-				Code:    "401",
-				Msg:     "Unauthorized",
-				Details: "Missing Authorization header",
-			})
+			response.WriteError(w, r, apperrors.New(apperrors.CodeTokenMissing, "Missing Authorization header"))
 			return
 		}
 
 		// Check if the token is in the format "Bearer <token>"
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			response.WriteErrorResponse(w, http.StatusUnauthorized, response.APIError{
-				Code:    "401",
-				Msg:     "Unauthorized",
-				Details: "Invalid Authorization header format",
-			})
+			response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Invalid Authorization header format"))
 			return
 		}
 
 		// Validate the token
 		claims, err := utils.ValidateToken(tokenParts[1])
 		if err != nil {
-			response.WriteErrorResponse(w, http.StatusUnauthorized, response.APIError{
-				Code:    "401",
-				Msg:     "Unauthorized",
-				Details: "Invalid or expired token",
-			})
+			response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Invalid or expired token"))
 			return
 		}
 
 		// Extract the user_id (or username) from the token claims
 		username := claims.Username
 		if username == "" {
-			response.WriteErrorResponse(w, http.StatusUnauthorized, response.APIError{
-				Code:    "401",
-				Msg:     "Unauthorized",
-				Details: "Invalid token payload",
-			})
+			response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Invalid token payload"))
 			return
 		}
 
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"loyalty-points-system-api/config"
+)
+
+func init() {
+	// Rate limit settings are reported with the rest of an invalid configuration
+	config.RegisterCheck(func(c *config.Config) error {
+		_, err := RateLimitOptionsFromConfig(c)
+		return err
+	})
+}
+
+// RateLimitOptionsFromConfig parses the rate limit policies, API keys and trusted
+// proxies.
+func RateLimitOptionsFromConfig(cfg *config.Config) (RateLimitOptions, error) {
+	var opts RateLimitOptions
+	var err error
+	if opts.Policies, err = ParseRatePolicies(cfg.RateLimitPolicies); err != nil {
+		return opts, fmt.Errorf("RATE_LIMIT_POLICIES: %w", err)
+	}
+
+	opts.APIKeys = map[string]string{}
+	for _, item := range strings.Split(cfg.RateLimitAPIKeys, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, hash, ok := strings.Cut(item, "=")
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if _, err := hex.DecodeString(hash); !ok || name == "" || err != nil || len(hash) != 64 {
+			return opts, fmt.Errorf("RATE_LIMIT_API_KEYS: %q must be name=<sha256 hex of the key>", name)
+		}
+		opts.APIKeys[hash] = strings.TrimSpace(name)
+	}
+
+	for _, item := range strings.Split(cfg.RateLimitTrustedProxies, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return opts, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES: %q is not an IP address or CIDR", item)
+		}
+		opts.TrustedProxies = append(opts.TrustedProxies, network)
+	}
+	return opts, nil
+}
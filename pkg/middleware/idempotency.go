@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	response "loyalty-points-system-api/internal/reponse"
+)
+
+// IdempotencyKeyHeader names the client-chosen key that makes a POST safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotentBody bounds the request body hashed and held in memory.
+const maxIdempotentBody = 1 << 20
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key. Keys are scoped to the authenticated user, so it must run after
+// AuthMiddleware. Reads, and requests without the header, pass straight through:
+// a GET must always see the current state, not a stored one.
+//
+// Server errors are not stored, so a retry after a 5xx runs the request again.
+func Idempotency(db *sql.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > 255 {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidParameter, "Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		scope, ok := r.Context().Value(UserIDKey).(string)
+		if !ok {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Failed to extract user information from token"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil || len(body) > maxIdempotentBody {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInvalidBody, "Failed to read request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		// Claim the key; the primary key makes concurrent retries race safely
+		result, err := db.ExecContext(r.Context(), `INSERT IGNORE INTO idempotency_keys (scope, idem_key, request_hash) VALUES (?, ?, ?)`,
+			scope, key, requestHash)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error claiming idempotency key", "scope", scope, "err", err)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to record idempotency key"))
+			return
+		}
+		if claimed, _ := result.RowsAffected(); claimed == 0 {
+			replayIdempotent(w, r, db, scope, key, requestHash)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Record the outcome even if the client has gone away
+		ctx := context.WithoutCancel(r.Context())
+		if rec.status >= http.StatusInternalServerError {
+			if _, err := db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE scope = ? AND idem_key = ?", scope, key); err != nil {
+				logging.FromContext(r.Context()).Error("Error releasing idempotency key", "scope", scope, "err", err)
+			}
+			return
+		}
+		_, err = db.ExecContext(ctx, `
+			UPDATE idempotency_keys
+			SET status_code = ?, content_type = ?, response_body = ?, completed_at = ?
+			WHERE scope = ? AND idem_key = ?`,
+			rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes(), time.Now().UTC(), scope, key)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error storing idempotent response", "scope", scope, "err", err)
+		}
+	})
+}
+
+func replayIdempotent(w http.ResponseWriter, r *http.Request, db *sql.DB, scope, key, requestHash string) {
+	var storedHash, contentType string
+	var status sql.NullInt64
+	var body []byte
+	err := db.QueryRowContext(r.Context(), `
+		SELECT request_hash, status_code, content_type, response_body
+		FROM idempotency_keys WHERE scope = ? AND idem_key = ?`, scope, key).
+		Scan(&storedHash, &status, &contentType, &body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error loading idempotent response", "scope", scope, "err", err)
+		response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to load idempotent response"))
+		return
+	}
+
+	if storedHash != requestHash {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeIdempotencyReused, "Idempotency-Key was already used for a different request"))
+		return
+	}
+	if !status.Valid {
+		response.WriteError(w, r, apperrors.New(apperrors.CodeIdempotencyPending, "A request with this Idempotency-Key is still being processed"))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(status.Int64))
+	w.Write(body)
+}
+
+// PurgeIdempotencyKeys deletes stored responses older than maxAge and returns how
+// many were deleted. A dry run only counts them.
+func PurgeIdempotencyKeys(ctx context.Context, db *sql.DB, maxAge time.Duration, dryRun bool) (int64, error) {
+	cutoff := time.Now().UTC().Add(-maxAge)
+	if dryRun {
+		var count int64
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM idempotency_keys WHERE created_at < ?", cutoff).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("counting expired idempotency keys: %w", err)
+		}
+		return count, nil
+	}
+	result, err := db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("purging idempotency keys: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+	logging.FromContext(ctx).Info("Purged idempotency keys", "purged", purged)
+	return purged, nil
+}
+
+// recordingWriter passes the response through while keeping a copy to store.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
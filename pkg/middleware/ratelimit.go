@@ -0,0 +1,400 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	response "loyalty-points-system-api/internal/reponse"
+	"loyalty-points-system-api/internal/utils"
+)
+
+// APIKeyHeader identifies a registered API client for rate limiting.
+const APIKeyHeader = "X-API-Key"
+
+// DefaultRatePolicy names the policy for routes without one of their own.
+const DefaultRatePolicy = "default"
+
+// RatePolicy is a token bucket holding at most Burst requests and refilled with
+// Rate requests per Period. Buckets are kept per policy and client, so routes
+// sharing a policy share a budget. An Off policy is not limited.
+type RatePolicy struct {
+	Name   string // "POST /login", "/livez" or DefaultRatePolicy
+	Rate   int
+	Period time.Duration
+	Burst  int
+	Off    bool
+}
+
+// String formats p for the RateLimit-Policy header, e.g. 5;w=60;burst=10.
+func (p RatePolicy) String() string {
+	return fmt.Sprintf("%d;w=%d;burst=%d", p.Rate, int(math.Ceil(p.Period.Seconds())), p.Burst)
+}
+
+// fillTime is how long an empty bucket takes to fill up.
+func (p RatePolicy) fillTime() time.Duration {
+	return time.Duration(float64(p.Period) * float64(p.Burst) / float64(p.Rate))
+}
+
+// take refills a bucket holding tokens at updated, up to now, and removes one
+// token if there is one. It returns the tokens left and the decision.
+func (p RatePolicy) take(tokens float64, updated, now time.Time) (float64, RateDecision) {
+	perSecond := float64(p.Rate) / p.Period.Seconds()
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(p.Burst), tokens+elapsed*perSecond)
+	}
+	d := RateDecision{Policy: p}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+	d.Remaining = int(tokens)
+	d.Reset = time.Duration((float64(p.Burst) - tokens) / perSecond * float64(time.Second))
+	return tokens, d
+}
+
+// RateDecision is the outcome of taking a token.
+type RateDecision struct {
+	Policy     RatePolicy
+	Allowed    bool
+	Remaining  int           // whole requests left in the bucket
+	RetryAfter time.Duration // until the next request is allowed, when refused
+	Reset      time.Duration // until the bucket is full again
+}
+
+// RatePolicies maps "METHOD /path", "/path" and DefaultRatePolicy to policies.
+// Paths are API path templates such as /users/{id}/points/history.
+type RatePolicies map[string]RatePolicy
+
+// ParseRatePolicies parses a comma-separated list of name=rate/period[:burst]
+// entries, e.g. "default=20/1s:40,POST /login=5/1m,/livez=off". Burst defaults
+// to rate.
+func ParseRatePolicies(s string) (RatePolicies, error) {
+	policies := RatePolicies{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		eq := strings.LastIndex(item, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("rate limit %q: want name=rate/period[:burst]", item)
+		}
+		name, spec := strings.Join(strings.Fields(item[:eq]), " "), strings.TrimSpace(item[eq+1:])
+		if name != DefaultRatePolicy && !strings.HasPrefix(name, "/") && !strings.Contains(name, " /") {
+			return nil, fmt.Errorf("rate limit %q: name must be default, /path or METHOD /path", item)
+		}
+		if _, dup := policies[name]; dup {
+			return nil, fmt.Errorf("rate limit for %s is set twice", name)
+		}
+		policy, err := parseRatePolicy(name, spec)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %q: %w", item, err)
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+func parseRatePolicy(name, spec string) (RatePolicy, error) {
+	policy := RatePolicy{Name: name}
+	if spec == "off" {
+		policy.Off = true
+		return policy, nil
+	}
+	rate, rest, ok := strings.Cut(spec, "/")
+	if !ok {
+		return policy, fmt.Errorf("want rate/period[:burst] or off")
+	}
+	period, burst, hasBurst := strings.Cut(rest, ":")
+	var err error
+	if policy.Rate, err = strconv.Atoi(rate); err != nil || policy.Rate < 1 {
+		return policy, fmt.Errorf("rate must be a positive integer")
+	}
+	// "1m" and "m" both mean one minute
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	if policy.Period, err = time.ParseDuration(period); err != nil || policy.Period <= 0 {
+		return policy, fmt.Errorf("period must be a positive duration such as 1s or 1m")
+	}
+	policy.Burst = policy.Rate
+	if hasBurst {
+		if policy.Burst, err = strconv.Atoi(burst); err != nil || policy.Burst < 1 {
+			return policy, fmt.Errorf("burst must be a positive integer")
+		}
+	}
+	return policy, nil
+}
+
+// For returns the policy for a request to route: the one for its method and
+// route, else for its route, else the default. ok is false when none applies.
+func (p RatePolicies) For(method, route string) (policy RatePolicy, ok bool) {
+	for _, name := range []string{method + " " + route, route, DefaultRatePolicy} {
+		if policy, ok = p[name]; ok {
+			return policy, !policy.Off
+		}
+	}
+	return policy, false
+}
+
+// MaxFillTime is the longest time any bucket takes to fill up. A bucket idle for
+// longer is full and can be forgotten.
+func (p RatePolicies) MaxFillTime() time.Duration {
+	var longest time.Duration
+	for _, policy := range p {
+		if !policy.Off && policy.fillTime() > longest {
+			longest = policy.fillTime()
+		}
+	}
+	return longest
+}
+
+// RateStore keeps token buckets by key.
+type RateStore interface {
+	// Take removes a token from key's bucket under policy, if it has one.
+	Take(ctx context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error)
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	fill    time.Duration
+}
+
+// MemoryRateStore keeps buckets in this process, so each replica limits on its
+// own. Full buckets are dropped once a minute to bound memory.
+type MemoryRateStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// NewMemoryRateStore returns an empty in-process store.
+func NewMemoryRateStore() *MemoryRateStore {
+	return &MemoryRateStore{buckets: map[string]*memoryBucket{}}
+}
+
+// Take implements RateStore.
+func (s *MemoryRateStore) Take(_ context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, b := range s.buckets {
+			if now.Sub(b.updated) > b.fill {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(policy.Burst), updated: now, fill: policy.fillTime()}
+		s.buckets[key] = b
+	}
+	var d RateDecision
+	b.tokens, d = policy.take(b.tokens, b.updated, now)
+	b.updated = now
+	return d, nil
+}
+
+// MySQLRateStore keeps buckets in the rate_limit_buckets table, so every replica
+// draws from the same budget. Each Take locks the bucket's row for one short
+// transaction.
+type MySQLRateStore struct {
+	db *sql.DB
+}
+
+// NewMySQLRateStore returns a store using db.
+func NewMySQLRateStore(db *sql.DB) *MySQLRateStore {
+	return &MySQLRateStore{db: db}
+}
+
+// Take implements RateStore.
+func (s *MySQLRateStore) Take(ctx context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RateDecision{}, err
+	}
+	defer tx.Rollback()
+
+	now = now.UTC()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT IGNORE INTO rate_limit_buckets (bucket_key, tokens, updated_at) VALUES (?, ?, ?)",
+		key, policy.Burst, now); err != nil {
+		return RateDecision{}, fmt.Errorf("creating rate limit bucket: %w", err)
+	}
+	var tokens float64
+	var updated time.Time
+	if err := tx.QueryRowContext(ctx,
+		"SELECT tokens, updated_at FROM rate_limit_buckets WHERE bucket_key = ? FOR UPDATE", key).Scan(&tokens, &updated); err != nil {
+		return RateDecision{}, fmt.Errorf("locking rate limit bucket: %w", err)
+	}
+	// Replica clocks may disagree slightly; time never runs backwards for a bucket
+	if now.Before(updated) {
+		now = updated
+	}
+	tokens, d := policy.take(tokens, updated, now)
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE rate_limit_buckets SET tokens = ?, updated_at = ? WHERE bucket_key = ?", tokens, now, key); err != nil {
+		return RateDecision{}, fmt.Errorf("updating rate limit bucket: %w", err)
+	}
+	return d, tx.Commit()
+}
+
+// PurgeRateLimitBuckets deletes buckets idle for longer than maxAge, which are
+// full and would be recreated as they were, and returns how many were deleted. A
+// dry run only counts them.
+func PurgeRateLimitBuckets(ctx context.Context, db *sql.DB, maxAge time.Duration, dryRun bool) (int64, error) {
+	cutoff := time.Now().UTC().Add(-maxAge)
+	if dryRun {
+		var count int64
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rate_limit_buckets WHERE updated_at < ?", cutoff).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("counting idle rate limit buckets: %w", err)
+		}
+		return count, nil
+	}
+	result, err := db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("purging rate limit buckets: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+	logging.FromContext(ctx).Info("Purged rate limit buckets", "purged", purged)
+	return purged, nil
+}
+
+// RateLimitOptions configures a RateLimiter.
+type RateLimitOptions struct {
+	Policies RatePolicies
+	// APIKeys maps the hex SHA-256 of each registered API key to its client name.
+	// Unregistered keys are ignored, so made-up keys cannot buy fresh buckets.
+	APIKeys map[string]string
+	// TrustedProxies are the networks whose X-Forwarded-For header is believed.
+	TrustedProxies []*net.IPNet
+}
+
+// RateLimiter throttles requests per route policy and client.
+type RateLimiter struct {
+	store RateStore
+	opts  RateLimitOptions
+	now   func() time.Time
+}
+
+// NewRateLimiter returns a limiter keeping its buckets in store.
+func NewRateLimiter(store RateStore, opts RateLimitOptions) *RateLimiter {
+	return &RateLimiter{store: store, opts: opts, now: time.Now}
+}
+
+// Limit refuses requests over their route's policy with 429 RATE_LIMITED and a
+// Retry-After header. Limited routes answer with RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers. If the store
+// fails the request is let through. A nil limiter limits nothing.
+func (l *RateLimiter) Limit(route func(r *http.Request) string, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy, ok := l.opts.Policies.For(r.Method, route(r))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := l.client(r)
+		d, err := l.store.Take(r.Context(), policy.Name+"|"+client, policy, l.now())
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("Rate limit store failed, request let through", "policy", policy.Name, "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(policy.Burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+		h.Set("RateLimit-Policy", policy.String())
+		if !d.Allowed {
+			retryAfter := ceilSeconds(d.RetryAfter)
+			h.Set("Retry-After", strconv.Itoa(retryAfter))
+			logging.FromContext(r.Context()).Info("Rate limit exceeded", "policy", policy.Name, "client", client)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeRateLimited,
+				fmt.Sprintf("Too many requests; retry in %d seconds", retryAfter)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// client names the caller: the user of a valid access token, else a registered
+// API client, else the client IP.
+func (l *RateLimiter) client(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if claims, err := utils.ValidateToken(token); err == nil && claims.Username != "" {
+			return "user:" + claims.Username
+		}
+	}
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		if name, ok := l.opts.APIKeys[hex.EncodeToString(sum[:])]; ok {
+			return "key:" + name
+		}
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP is the peer address, or, when the peer is a trusted proxy, the last
+// X-Forwarded-For address that is not one.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !l.trusted(host) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !l.trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+func (l *RateLimiter) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.opts.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/tracing"
+)
+
+// RequestIDHeader carries the ID that correlates a request's log lines, audit
+// entries and error response.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID takes the client's X-Request-ID, or generates one when it is missing or
+// malformed, echoes it in the response and puts it in the request context and on
+// the request's span. Each request is logged once when it completes.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := tracing.Correlate(r.Context(), id)
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		logging.FromContext(ctx).Info("Request completed",
+			"method", r.Method, "path", r.URL.Path, "status", sw.status, "duration_ms", time.Since(start).Milliseconds())
+	})
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/logging"
+	response "loyalty-points-system-api/internal/reponse"
+)
+
+// Roles stored in users.role
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
+// RequireRole only lets through users whose role matches. It must run after AuthMiddleware.
+func RequireRole(db *sql.DB, role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, ok := r.Context().Value(UserIDKey).(string)
+		if !ok {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeTokenInvalid, "Failed to extract user information from token"))
+			return
+		}
+
+		var userRole string
+		err := db.QueryRowContext(r.Context(), "SELECT role FROM users WHERE username = ?", username).Scan(&userRole)
+		if err != nil && err != sql.ErrNoRows {
+			logging.FromContext(r.Context()).Error("Error fetching user role", "username", username, "err", err)
+			response.WriteError(w, r, apperrors.New(apperrors.CodeInternal, "Failed to fetch user role"))
+			return
+		}
+
+		if userRole != role {
+			response.WriteError(w, r, apperrors.New(apperrors.CodeForbidden, "This operation requires the "+role+" role"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for each request, continuing the caller's W3C trace
+// context. Spans are named by method and the route returned by route, e.g.
+// POST /redeem, so names stay bounded like the metrics labels.
+func Tracing(route func(r *http.Request) string, next http.Handler) http.Handler {
+	tagged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(route(r)))
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(tagged, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + route(r)
+		}))
+}
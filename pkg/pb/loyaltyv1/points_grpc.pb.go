@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: loyalty/v1/points.proto
+
+package loyaltyv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PointsServiceClient is the client API for PointsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PointsServiceClient interface {
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*GetBalanceResponse, error)
+	RedeemPoints(ctx context.Context, in *RedeemPointsRequest, opts ...grpc.CallOption) (*RedeemPointsResponse, error)
+}
+
+type pointsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPointsServiceClient(cc grpc.ClientConnInterface) PointsServiceClient {
+	return &pointsServiceClient{cc}
+}
+
+func (c *pointsServiceClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*GetBalanceResponse, error) {
+	out := new(GetBalanceResponse)
+	err := c.cc.Invoke(ctx, "/loyalty.v1.PointsService/GetBalance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pointsServiceClient) RedeemPoints(ctx context.Context, in *RedeemPointsRequest, opts ...grpc.CallOption) (*RedeemPointsResponse, error) {
+	out := new(RedeemPointsResponse)
+	err := c.cc.Invoke(ctx, "/loyalty.v1.PointsService/RedeemPoints", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PointsServiceServer is the server API for PointsService service.
+// All implementations must embed UnimplementedPointsServiceServer
+// for forward compatibility
+type PointsServiceServer interface {
+	GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error)
+	RedeemPoints(context.Context, *RedeemPointsRequest) (*RedeemPointsResponse, error)
+	mustEmbedUnimplementedPointsServiceServer()
+}
+
+// UnimplementedPointsServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPointsServiceServer struct {
+}
+
+func (UnimplementedPointsServiceServer) GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedPointsServiceServer) RedeemPoints(context.Context, *RedeemPointsRequest) (*RedeemPointsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeemPoints not implemented")
+}
+func (UnimplementedPointsServiceServer) mustEmbedUnimplementedPointsServiceServer() {}
+
+// UnsafePointsServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PointsServiceServer will
+// result in compilation errors.
+type UnsafePointsServiceServer interface {
+	mustEmbedUnimplementedPointsServiceServer()
+}
+
+func RegisterPointsServiceServer(s grpc.ServiceRegistrar, srv PointsServiceServer) {
+	s.RegisterService(&PointsService_ServiceDesc, srv)
+}
+
+func _PointsService_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PointsServiceServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/loyalty.v1.PointsService/GetBalance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PointsServiceServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PointsService_RedeemPoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RedeemPointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PointsServiceServer).RedeemPoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/loyalty.v1.PointsService/RedeemPoints",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PointsServiceServer).RedeemPoints(ctx, req.(*RedeemPointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PointsService_ServiceDesc is the grpc.ServiceDesc for PointsService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PointsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "loyalty.v1.PointsService",
+	HandlerType: (*PointsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBalance",
+			Handler:    _PointsService_GetBalance_Handler,
+		},
+		{
+			MethodName: "RedeemPoints",
+			Handler:    _PointsService_RedeemPoints_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "loyalty/v1/points.proto",
+}
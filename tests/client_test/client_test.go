@@ -0,0 +1,433 @@
+package client_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/health"
+	"loyalty-points-system-api/internal/openapi"
+	"loyalty-points-system-api/internal/routes"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/client"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// apiServer runs the real route table against a mocked database and records
+// every request it receives.
+type apiServer struct {
+	*httptest.Server
+	mock    sqlmock.Sqlmock
+	checker *health.Checker
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newAPIServer(t *testing.T) *apiServer {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	// Audit entries are written from a goroutine, so statements may interleave
+	mock.MatchExpectationsInOrder(false)
+
+	s := &apiServer{mock: mock, checker: health.New(time.Second)}
+	s.checker.Add("database", health.Database(db))
+	mux := http.NewServeMux()
+	routes.Register(mux, routes.Table(db, &config.Config{}, nil, s.checker), nil)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Clone(context.Background()))
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return s
+}
+
+// paths returns "METHOD path" for each request received.
+func (s *apiServer) paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for _, r := range s.requests {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+	}
+	return paths
+}
+
+// waitForDB waits until every expected statement, including asynchronous audit
+// writes, has run.
+func (s *apiServer) waitForDB(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := s.mock.ExpectationsWereMet()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Unmet database expectations: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (s *apiServer) expectAudit() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+}
+
+// capture is a sqlmock argument matcher that remembers the value it was given.
+type capture struct {
+	mu    sync.Mutex
+	value driver.Value
+}
+
+func (c *capture) Match(v driver.Value) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value = v
+	return true
+}
+
+func (c *capture) get() driver.Value {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// dropFirstResponse delivers the first request to path and then reports a network
+// error, as if the connection dropped before the response arrived.
+type dropFirstResponse struct {
+	path    string
+	mu      sync.Mutex
+	dropped bool
+}
+
+func (d *dropFirstResponse) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if req.URL.Path == d.path && !d.dropped {
+		d.dropped = true
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, errors.New("connection reset by peer")
+	}
+	return resp, nil
+}
+
+func accessToken(t *testing.T, username string) string {
+	t.Helper()
+	token, err := utils.GenerateAccessToken(username)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return token
+}
+
+func TestLoginAndAutomaticTokenRefresh(t *testing.T) {
+	srv := newAPIServer(t)
+	ctx := context.Background()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, password_hash FROM users WHERE username = ?")).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash"}).AddRow(1, "alice", string(hash)))
+	srv.mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET refresh_token = ? WHERE id = ?")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	srv.expectAudit()
+
+	c := client.New(srv.URL, client.WithRetries(2, time.Millisecond))
+	tokens, err := c.Login(ctx, "alice", "secret123")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("Expected both tokens, got %+v", tokens)
+	}
+	srv.waitForDB(t)
+
+	// An expired access token is refreshed once and the call replayed
+	c.SetTokens("expired-access-token", tokens.RefreshToken)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(250))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(40))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, transaction_date, points, category FROM transactions")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_date", "points", "category"}).
+			AddRow(7, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), 100, "groceries"))
+
+	page, err := c.GetPointsBalance(ctx, 1, client.PageOptions{})
+	if err != nil {
+		t.Fatalf("GetPointsBalance failed: %v", err)
+	}
+	if page.Balance != 250 || page.AvailablePoints != 210 || page.PendingPoints != 40 ||
+		len(page.History) != 1 || page.History[0].Points != 100 {
+		t.Errorf("Unexpected balance page: %+v", page)
+	}
+	if access, _ := c.Tokens(); access == "expired-access-token" || access == "" {
+		t.Errorf("Expected a refreshed access token, got %q", access)
+	}
+
+	want := []string{"POST /login", "GET /points-balance", "POST /refresh", "GET /points-balance"}
+	if got := srv.paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected requests %v, got %v", want, got)
+	}
+	srv.waitForDB(t)
+}
+
+func TestAddTransactionRetryReplaysIdempotentResponse(t *testing.T) {
+	srv := newAPIServer(t)
+	ctx := context.Background()
+
+	in := client.AddTransactionRequest{
+		TransactionID:     "POS-1001",
+		UserID:            1,
+		TransactionAmount: 100,
+		Category:          "groceries",
+		TransactionDate:   "2024-01-15 10:00:00",
+	}
+	body, _ := json.Marshal(in)
+	sum := sha256.Sum256(append([]byte("POST /add-transaction\n"), body...))
+	requestHash := hex.EncodeToString(sum[:])
+
+	// First attempt runs the handler once and stores its response
+	srv.mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO idempotency_keys")).
+		WithArgs("alice", sqlmock.AnyArg(), requestHash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	srv.mock.ExpectBegin()
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT household_id FROM household_members")).
+		WillReturnRows(sqlmock.NewRows([]string{"household_id"}))
+	srv.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).WillReturnResult(sqlmock.NewResult(1, 1))
+	srv.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).WillReturnResult(sqlmock.NewResult(1, 1))
+	srv.mock.ExpectExec(regexp.QuoteMeta("SET loyalty_points = loyalty_points + ?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	srv.mock.ExpectCommit()
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(200))
+	srv.expectAudit()
+	stored := &capture{}
+	srv.mock.ExpectExec(regexp.QuoteMeta("UPDATE idempotency_keys")).
+		WithArgs(200, "application/json", stored, sqlmock.AnyArg(), "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// The retry finds the key taken and gets the stored response back
+	replayed := `{"success":true,"data":{"message":"Transaction recorded successfully","points":200},"message":"Transaction recorded successfully"}` + "\n"
+	srv.mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO idempotency_keys")).
+		WithArgs("alice", sqlmock.AnyArg(), requestHash).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT request_hash, status_code, content_type, response_body")).
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status_code", "content_type", "response_body"}).
+			AddRow(requestHash, 200, "application/json", []byte(replayed)))
+
+	transport := &dropFirstResponse{path: "/add-transaction"}
+	c := client.New(srv.URL,
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithTokens(accessToken(t, "alice"), ""),
+		client.WithRetries(2, time.Millisecond))
+
+	out, err := c.AddTransaction(ctx, in)
+	if err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+	if out.Points != 200 {
+		t.Errorf("Expected 200 points, got %+v", out)
+	}
+	srv.waitForDB(t)
+
+	if got, _ := stored.get().([]byte); string(got) != replayed {
+		t.Errorf("Stored response %q differs from the replayed one %q", got, replayed)
+	}
+	if got := srv.paths(); len(got) != 2 {
+		t.Fatalf("Expected the request to be sent twice, got %v", got)
+	}
+	first, second := srv.requests[0].Header.Get("Idempotency-Key"), srv.requests[1].Header.Get("Idempotency-Key")
+	if first == "" || first != second {
+		t.Errorf("Expected the retry to reuse the Idempotency-Key, got %q and %q", first, second)
+	}
+}
+
+func TestIdempotencyKeyHeaderMatchesServer(t *testing.T) {
+	if client.IdempotencyKeyHeader != middleware.IdempotencyKeyHeader {
+		t.Errorf("Client sends %q but the server reads %q", client.IdempotencyKeyHeader, middleware.IdempotencyKeyHeader)
+	}
+}
+
+func TestRedeemDecodesAPIError(t *testing.T) {
+	srv := newAPIServer(t)
+
+	srv.mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO idempotency_keys")).WillReturnResult(sqlmock.NewResult(0, 1))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	srv.mock.ExpectBegin()
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE")).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(50))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(0))
+	srv.mock.ExpectRollback()
+	srv.mock.ExpectExec(regexp.QuoteMeta("UPDATE idempotency_keys")).
+		WithArgs(400, "application/json", sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	c := client.New(srv.URL, client.WithTokens(accessToken(t, "alice"), ""), client.WithRetries(2, time.Millisecond))
+	_, err := c.RedeemPoints(context.Background(), client.RedeemRequest{UserID: 1, Points: 100})
+
+	if !client.IsCode(err, client.CodePointsInsufficient) {
+		t.Fatalf("Expected POINTS_INSUFFICIENT, got %v", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Details == "" {
+		t.Errorf("Unexpected error: %+v", apiErr)
+	}
+	if apiErr != nil && len(apiErr.RequestID) != 32 {
+		t.Errorf("Expected the server's request ID on the error, got %q", apiErr.RequestID)
+	}
+	if got := srv.paths(); len(got) != 1 {
+		t.Errorf("Client errors must not be retried, got %v", got)
+	}
+	srv.waitForDB(t)
+}
+
+func TestListUsersRequiresAdmin(t *testing.T) {
+	srv := newAPIServer(t)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT role FROM users WHERE username = ?")).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(middleware.RoleMember))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT role FROM users WHERE username = ?")).WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(middleware.RoleAdmin))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username FROM users")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(1, "alice").AddRow(2, "admin"))
+
+	_, err := client.New(srv.URL).ListUsers(context.Background(), client.PageOptions{})
+	if !client.IsCode(err, client.CodeTokenMissing) {
+		t.Errorf("Expected AUTH_TOKEN_MISSING without a token, got %v", err)
+	}
+	_, err = client.New(srv.URL, client.WithTokens(accessToken(t, "alice"), "")).ListUsers(context.Background(), client.PageOptions{})
+	if !client.IsCode(err, client.CodeForbidden) {
+		t.Errorf("Expected FORBIDDEN for a member, got %v", err)
+	}
+	page, err := client.New(srv.URL, client.WithTokens(accessToken(t, "admin"), "")).ListUsers(context.Background(), client.PageOptions{})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if len(page.Users) != 2 {
+		t.Errorf("Expected 2 users, got %+v", page.Users)
+	}
+	srv.waitForDB(t)
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	srv := newAPIServer(t)
+	c := client.New(srv.URL)
+
+	_, err := c.CreateUser(context.Background(), client.CreateUserRequest{Username: "bob", Password: "abc"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != client.CodeValidationFailed || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected VALIDATION_FAILED, got %v", err)
+	}
+	if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "password" || apiErr.Fields[0].Rule != "min" {
+		t.Errorf("Unexpected field errors: %+v", apiErr.Fields)
+	}
+}
+
+func TestRateLimitedCallsWaitForRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.Method+" "+r.URL.Path]++
+		n := calls[r.Method+" "+r.URL.Path]
+		mu.Unlock()
+		if r.Method == http.MethodGet && n > 1 {
+			w.Write([]byte(`{"success": true, "data": {"status": "UP"}}`))
+			return
+		}
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"success": false, "error": {"code": "RATE_LIMITED", "status": 429, "msg": "Too Many Requests"}}`))
+	}))
+	defer srv.Close()
+	c := client.New(srv.URL, client.WithRetries(2, time.Millisecond))
+
+	if health, err := c.Health(context.Background()); err != nil || health.Status != "UP" || calls["GET /health"] != 2 {
+		t.Fatalf("Expected the GET to be retried once, got %+v, %v after %d calls", health, err, calls["GET /health"])
+	}
+	_, err := c.CreateUser(context.Background(), client.CreateUserRequest{Username: "bob", Password: "secret123"})
+	if !client.IsCode(err, client.CodeRateLimited) || calls["POST /create-user"] != 1 {
+		t.Errorf("Expected RATE_LIMITED without a retry, got %v after %d calls", err, calls["POST /create-user"])
+	}
+}
+
+func TestHealthAndOpenAPI(t *testing.T) {
+	srv := newAPIServer(t)
+	c := client.New(srv.URL)
+
+	health, err := c.Health(context.Background())
+	if err != nil || health.Status != "UP" {
+		t.Fatalf("Unexpected health: %+v, %v", health, err)
+	}
+	live, err := c.Livez(context.Background())
+	if err != nil || live.Status != "UP" {
+		t.Fatalf("Unexpected liveness: %+v, %v", live, err)
+	}
+	ready, err := c.Readyz(context.Background())
+	if err != nil || ready.Status != "UP" || len(ready.Components) != 1 || ready.Components[0].Name != "database" {
+		t.Fatalf("Unexpected readiness: %+v, %v", ready, err)
+	}
+	srv.checker.SetShuttingDown()
+	ready, err = c.Readyz(context.Background())
+	if !errors.Is(err, client.ErrNotReady) || ready == nil || !ready.ShuttingDown {
+		t.Fatalf("Expected ErrNotReady with the report, got %+v, %v", ready, err)
+	}
+	doc, err := c.GetOpenAPI(context.Background())
+	if err != nil || !strings.Contains(string(doc), `"openapi": "3.0.3"`) {
+		t.Fatalf("Unexpected OpenAPI document: %v", err)
+	}
+}
+
+func TestClientCoversEveryOperation(t *testing.T) {
+	clientType := reflect.TypeOf(&client.Client{})
+	for path, item := range openapi.Spec().Paths {
+		for method, op := range item {
+			// The docs page is for browsers
+			if op.OperationID == "getDocs" {
+				continue
+			}
+			name := strings.ToUpper(op.OperationID[:1]) + op.OperationID[1:]
+			if _, ok := clientType.MethodByName(name); !ok {
+				t.Errorf("No client method %s for %s %s", name, strings.ToUpper(method), path)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package client_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ingest"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/client"
+)
+
+// TestClientHasNoServerDependencies keeps pkg/client importable without the
+// server's database drivers, tracing exporters and business code.
+func TestClientHasNoServerDependencies(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not available")
+	}
+	out, err := exec.Command("go", "list", "-deps", "loyalty-points-system-api/pkg/client").Output()
+	if err != nil {
+		t.Fatalf("go list failed: %v", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if strings.HasPrefix(dep, "loyalty-points-system-api/internal/") {
+			t.Errorf("pkg/client depends on %s", dep)
+		}
+	}
+}
+
+// jsonFields returns the JSON names of t's fields.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestClientTypesMatchServer keeps the client's copies of the wire types in step
+// with the server's.
+func TestClientTypesMatchServer(t *testing.T) {
+	pairs := []struct{ client, server interface{} }{
+		{client.LoginRequest{}, models.LoginRequest{}},
+		{client.TokenResponse{}, models.TokenResponse{}},
+		{client.RefreshRequest{}, models.RefreshRequest{}},
+		{client.RefreshResponse{}, models.RefreshResponse{}},
+		{client.HealthResponse{}, models.HealthResponse{}},
+		{client.ReadinessResponse{}, models.ReadinessResponse{}},
+		{client.ComponentHealth{}, models.ComponentHealth{}},
+		{client.CreateUserRequest{}, models.CreateUserRequest{}},
+		{client.CreateUserResponse{}, models.CreateUserResponse{}},
+		{client.UserSummary{}, models.UserSummary{}},
+		{client.AddTransactionRequest{}, models.AddTransactionRequest{}},
+		{client.AddTransactionResponse{}, models.AddTransactionResponse{}},
+		{client.TransactionRecord{}, models.TransactionRecord{}},
+		{client.RedeemRequest{}, models.RedeemRequest{}},
+		{client.RedeemResponse{}, models.RedeemResponse{}},
+		{client.TransferRequest{}, models.TransferRequest{}},
+		{client.TransferResponse{}, models.TransferResponse{}},
+		{client.TransferLot{}, models.TransferLot{}},
+		{client.AuthorizeHoldRequest{}, models.AuthorizeHoldRequest{}},
+		{client.CaptureHoldRequest{}, models.CaptureHoldRequest{}},
+		{client.Hold{}, models.Hold{}},
+		{client.HoldResponse{}, models.HoldResponse{}},
+		{client.PointsQuoteRequest{}, models.PointsQuoteRequest{}},
+		{client.PointsQuote{}, models.PointsQuote{}},
+		{client.PointsPayment{}, models.PointsPayment{}},
+		{client.ConversionRateRequest{}, models.ConversionRateRequest{}},
+		{client.ConversionRate{}, models.ConversionRate{}},
+		{client.CreateHouseholdRequest{}, models.CreateHouseholdRequest{}},
+		{client.Household{}, models.HouseholdResponse{}},
+		{client.HouseholdMember{}, models.HouseholdMember{}},
+		{client.InviteMemberRequest{}, models.InviteMemberRequest{}},
+		{client.HouseholdInvitation{}, models.HouseholdInvitation{}},
+		{client.UpdateMemberRoleRequest{}, models.UpdateMemberRoleRequest{}},
+		{client.HouseholdRedeemRequest{}, models.HouseholdRedeemRequest{}},
+		{client.HouseholdRedeemResponse{}, models.HouseholdRedeemResponse{}},
+		{client.RewardRequest{}, models.RewardRequest{}},
+		{client.Reward{}, models.Reward{}},
+		{client.OrderRequest{}, models.OrderRequest{}},
+		{client.Order{}, models.Order{}},
+		{client.OrderResponse{}, models.OrderResponse{}},
+		{client.PointsBalanceResponse{}, models.PointsBalanceResponse{}},
+		{client.PointsHistory{}, models.PointsHistory{}},
+		{client.PointsHistoryEntry{}, models.PointsHistoryEntry{}},
+		{client.ImportReport{}, ingest.Report{}},
+		{client.ImportRowResult{}, ingest.RowResult{}},
+		{client.AuditEntry{}, utils.AuditEntry{}},
+		{client.JobInfo{}, jobs.Info{}},
+		{client.JobRun{}, jobs.RunRecord{}},
+	}
+	for _, p := range pairs {
+		c, s := reflect.TypeOf(p.client), reflect.TypeOf(p.server)
+		if got, want := jsonFields(c), jsonFields(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("client.%s has fields %v, server %s has %v", c.Name(), got, s, want)
+		}
+	}
+}
+
+// TestClientDeclaresEveryErrorCode checks the client's Code constants against the
+// server's catalog.
+func TestClientDeclaresEveryErrorCode(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../../pkg/client/errors.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse pkg/client/errors.go: %v", err)
+	}
+	declared := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.ValueSpec); ok && len(spec.Values) == 1 {
+			if lit, ok := spec.Values[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				value, _ := strconv.Unquote(lit.Value)
+				declared[value] = true
+			}
+		}
+		return true
+	})
+	for _, code := range apperrors.Codes() {
+		if !declared[string(code)] {
+			t.Errorf("pkg/client declares no constant for %s", code)
+		}
+	}
+}
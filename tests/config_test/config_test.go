@@ -0,0 +1,239 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// load parses args with a fresh flag set reading env files from dir.
+func load(t *testing.T, dir string, args ...string) (*config.Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	loader := config.RegisterFlags(fs)
+	if err := fs.Parse(append([]string{"--config-dir", dir}, args...)); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	return loader.Load()
+}
+
+func writeEnvFile(t *testing.T, dir, env, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, env+".env"), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+}
+
+func TestLayersOverrideInOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeEnvFile(t, dir, "dev", "JWT_SECRET=file_secret\nAPP_PORT=7000\nDB_NAME=file_db\nDB_MAX_OPEN_CONNS=20\n")
+	t.Setenv("APP_ENV", "")
+	t.Setenv("DB_NAME", "env_db")
+	t.Setenv("DB_MAX_OPEN_CONNS", "30")
+
+	cfg, err := load(t, dir, "--db-max-open-conns", "40")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Env != "dev" {
+		t.Errorf("Expected env dev, got %q", cfg.Env)
+	}
+	if cfg.GRPCPort != "9090" || cfg.AccessTokenTTL != 15*time.Minute {
+		t.Errorf("Expected defaults for unset keys, got %q and %s", cfg.GRPCPort, cfg.AccessTokenTTL)
+	}
+	if cfg.AppPort != "7000" {
+		t.Errorf("Expected the file to override the default port, got %q", cfg.AppPort)
+	}
+	if cfg.DBName != "env_db" {
+		t.Errorf("Expected the environment to override the file, got %q", cfg.DBName)
+	}
+	if cfg.DBMaxOpenConns != 40 {
+		t.Errorf("Expected the flag to override the environment, got %d", cfg.DBMaxOpenConns)
+	}
+	if cfg.AuditSigningKey != "file_secret" {
+		t.Errorf("Expected the audit key to default to JWT_SECRET, got %q", cfg.AuditSigningKey)
+	}
+}
+
+func TestEnvironmentSelection(t *testing.T) {
+	dir := t.TempDir()
+	writeEnvFile(t, dir, "dev", "JWT_SECRET=dev_secret\n")
+	writeEnvFile(t, dir, "test", "JWT_SECRET=test_secret\n")
+
+	t.Setenv("APP_ENV", "test")
+	cfg, err := load(t, dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Env != "test" || cfg.JWTSecret != "test_secret" {
+		t.Errorf("Expected APP_ENV to select test.env, got %q/%q", cfg.Env, cfg.JWTSecret)
+	}
+
+	cfg, err = load(t, dir, "--env", "dev")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Env != "dev" || cfg.JWTSecret != "dev_secret" {
+		t.Errorf("Expected --env to win over APP_ENV, got %q/%q", cfg.Env, cfg.JWTSecret)
+	}
+}
+
+func TestMissingEnvFileUsesEnvironment(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	t.Setenv("JWT_SECRET", "from_env")
+
+	cfg, err := load(t, t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected a missing env file to be optional, got %v", err)
+	}
+	if cfg.JWTSecret != "from_env" {
+		t.Errorf("Expected JWT_SECRET from the environment, got %q", cfg.JWTSecret)
+	}
+}
+
+func TestInvalidValuesAreReported(t *testing.T) {
+	dir := t.TempDir()
+	writeEnvFile(t, dir, "dev", "JWT_SECRET=dev_secret\nPOINTS_EXPIRATION_DAYS=forever\n")
+	t.Setenv("APP_ENV", "")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "60")
+
+	_, err := load(t, dir)
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"POINTS_EXPIRATION_DAYS=\"forever\"", "dev.env", "must be an integer",
+		"HTTP_WRITE_TIMEOUT=\"60\" (from environment)"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %q in error %q", want, msg)
+		}
+	}
+}
+
+// The expiry and rate limit cases are checked by ledger and middleware, which
+// register their checks when imported, as they are in the binaries.
+func TestValidateRules(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APP_ENV", "")
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"missing secret", nil, "JWT_SECRET is required"},
+		{"same ports", []string{"--jwt-secret", "s", "--grpc-port", "8080"}, "APP_PORT and GRPC_PORT must differ"},
+		{"idle above open", []string{"--jwt-secret", "s", "--db-max-idle-conns", "50"}, "DB_MAX_IDLE_CONNS"},
+		{"token ttls", []string{"--jwt-secret", "s", "--access-token-ttl", "200h"}, "ACCESS_TOKEN_TTL must be shorter"},
+		{"bad schedule", []string{"--jwt-secret", "s", "--expiration-schedule", "sometimes"}, "EXPIRATION_SCHEDULE is not a valid cron schedule"},
+		{"short prod secret", []string{"--env", "prod", "--jwt-secret", "short", "--db-password", "pw"}, "at least 32 bytes"},
+		{"bad expiry policy", []string{"--jwt-secret", "s", "--expiry-policy", "monthly"}, "EXPIRY_POLICY: expiry policy \"monthly\""},
+		{"expiry for unknown rule", []string{"--jwt-secret", "s", "--expiry-policy-rules", "toys=never"}, "no earning rule for toys"},
+		{"bad warning horizon", []string{"--jwt-secret", "s", "--expiry-warning-horizons", "30,soon"}, "EXPIRY_WARNING_HORIZONS must be positive"},
+		{"unknown notify channel", []string{"--jwt-secret", "s", "--notify-channel", "sms"}, "NOTIFY_CHANNEL must be log, file, smtp or webhook"},
+		{"webhook without url", []string{"--jwt-secret", "s", "--notify-channel", "webhook"}, "NOTIFY_WEBHOOK_URL must be an http(s) URL"},
+		{"unknown env", []string{"--env", "staging", "--jwt-secret", "s"}, "APP_ENV must be dev, test or prod"},
+		{"unknown log level", []string{"--jwt-secret", "s", "--log-level", "trace"}, "LOG_LEVEL must be debug, info, warn or error"},
+		{"unknown log format", []string{"--jwt-secret", "s", "--log-format", "xml"}, "LOG_FORMAT must be json or text"},
+		{"unknown trace exporter", []string{"--jwt-secret", "s", "--trace-exporter", "zipkin"}, "TRACE_EXPORTER must be none, otlp, stdout or file"},
+		{"otlp without scheme", []string{"--jwt-secret", "s", "--trace-exporter", "otlp", "--otlp-endpoint", "collector:4317"}, "OTLP_ENDPOINT must be an http(s) URL"},
+		{"sample above 100", []string{"--jwt-secret", "s", "--trace-sample-percent", "150"}, "TRACE_SAMPLE_PERCENT must be between 0 and 100"},
+		{"negative drain delay", []string{"--jwt-secret", "s", "--shutdown-drain-delay", "-5s"}, "SHUTDOWN_DRAIN_DELAY must not be negative"},
+		{"zero outbox max", []string{"--jwt-secret", "s", "--ready-outbox-max", "0"}, "READY_OUTBOX_MAX must be positive"},
+		{"negative transfer cap", []string{"--jwt-secret", "s", "--transfer-daily-max", "-1"}, "TRANSFER_DAILY_MAX must not be negative"},
+		{"household of one", []string{"--jwt-secret", "s", "--household-max-members", "1"}, "HOUSEHOLD_MAX_MEMBERS must be at least 2"},
+		{"zero hold ttl", []string{"--jwt-secret", "s", "--hold-ttl", "0s"}, "HOLD_TTL must be positive"},
+		{"lower-case currency", []string{"--jwt-secret", "s", "--default-currency", "usd"}, "DEFAULT_CURRENCY must be a three-letter currency code"},
+		{"unknown rate limit backend", []string{"--jwt-secret", "s", "--rate-limit-backend", "redis"}, "RATE_LIMIT_BACKEND must be off, memory or mysql"},
+		{"bad rate limit policy", []string{"--jwt-secret", "s", "--rate-limit-policies", "POST /login=fast"}, "RATE_LIMIT_POLICIES: rate limit \"POST /login=fast\""},
+		{"plain api key", []string{"--jwt-secret", "s", "--rate-limit-api-keys", "partner=secret"}, "RATE_LIMIT_API_KEYS: \"partner\" must be name=<sha256 hex of the key>"},
+		{"bad trusted proxy", []string{"--jwt-secret", "s", "--rate-limit-trusted-proxies", "10.0.0.0/33"}, "RATE_LIMIT_TRUSTED_PROXIES: \"10.0.0.0/33\" is not an IP address or CIDR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, dir, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRepositoryEnvFilesAreValid(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	for _, env := range []string{"dev", "test"} {
+		if _, err := load(t, filepath.Join("..", "..", "config", "env"), "--env", env); err != nil {
+			t.Errorf("config/env/%s.env is invalid: %v", env, err)
+		}
+	}
+}
+
+func TestDisabledSchedule(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	cfg, err := load(t, t.TempDir(), "--jwt-secret", "s", "--audit-checkpoint-schedule", "off")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.AuditCheckpointSchedule != "off" {
+		t.Errorf("Expected the schedule to be off, got %q", cfg.AuditCheckpointSchedule)
+	}
+}
+
+// TestConfigImportsNoConsumers keeps config at the bottom of the import graph:
+// the packages it configures build their settings from it, not the other way.
+func TestConfigImportsNoConsumers(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not available")
+	}
+	out, err := exec.Command("go", "list", "-deps", "loyalty-points-system-api/config").Output()
+	if err != nil {
+		t.Fatalf("go list failed: %v", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		for _, consumer := range []string{"/internal/jobs", "/internal/ledger", "/internal/service", "/pkg/middleware"} {
+			if dep == "loyalty-points-system-api"+consumer {
+				t.Errorf("config depends on %s", dep)
+			}
+		}
+	}
+	if jobs.Disabled != config.ScheduleOff {
+		t.Errorf("jobs.Disabled is %q, config.ScheduleOff %q", jobs.Disabled, config.ScheduleOff)
+	}
+}
+
+func TestSettingsFromConfig(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	cfg, err := load(t, t.TempDir(), "--jwt-secret", "s", "--expiry-policy", "rolling", "--expiry-policy-tiers", "vip=never",
+		"--transfer-tiers", "gold, vip", "--rate-limit-trusted-proxies", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	rules, err := ledger.ExpiryRulesFromConfig(cfg)
+	if err != nil || rules.Default != (ledger.ExpiryPolicy{Kind: ledger.ExpiryRolling, Days: 365}) || rules.Tiers["vip"].Kind != ledger.ExpiryNever {
+		t.Errorf("Unexpected expiry rules %+v (%v)", rules, err)
+	}
+	if limits := service.TransferLimitsFromConfig(cfg); limits.DailyMax != 5000 || len(limits.Tiers) != 2 || limits.Tiers[1] != "vip" {
+		t.Errorf("Unexpected transfer limits %+v", limits)
+	}
+	if settings := service.HouseholdSettingsFromConfig(cfg); settings.MaxMembers != 6 || settings.InviteTTL != 168*time.Hour {
+		t.Errorf("Unexpected household settings %+v", settings)
+	}
+	opts, err := middleware.RateLimitOptionsFromConfig(cfg)
+	if err != nil || len(opts.TrustedProxies) != 1 || opts.TrustedProxies[0].String() != "10.0.0.1/32" {
+		t.Errorf("Unexpected rate limit options %+v (%v)", opts, err)
+	}
+}
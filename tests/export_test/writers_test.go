@@ -0,0 +1,73 @@
+package export_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"loyalty-points-system-api/internal/export"
+)
+
+var columns = []export.Column{{Name: "id", Kind: export.KindInt}, {Name: "reason", Kind: export.KindString}}
+
+func write(t *testing.T, format export.Format, rows [][]sql.NullString) string {
+	var buf bytes.Buffer
+	w := export.NewWriter(format, &buf)
+	if err := w.WriteHeader(columns); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.String()
+}
+
+var sampleRows = [][]sql.NullString{
+	{{String: "1", Valid: true}, {String: "Purchase, \"in store\"", Valid: true}},
+	{{String: "2", Valid: true}, {}},
+}
+
+func TestCSVWriter(t *testing.T) {
+	got := write(t, export.FormatCSV, sampleRows)
+	want := "id,reason\n1,\"Purchase, \"\"in store\"\"\"\n2,\n"
+	if got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	got := write(t, export.FormatJSONL, sampleRows)
+	want := `{"id":1,"reason":"Purchase, \"in store\""}` + "\n" + `{"id":2,"reason":null}` + "\n"
+	if got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestColumnarWriter(t *testing.T) {
+	var rows [][]sql.NullString
+	for i := 0; i < export.ColumnarRowGroupSize+1; i++ {
+		rows = append(rows, sampleRows[i%2])
+	}
+	lines := strings.Split(strings.TrimSpace(write(t, export.FormatColumnar, rows)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected schema line and 2 row groups, got %d lines", len(lines))
+	}
+
+	var group struct {
+		Rows    int                          `json:"rows"`
+		Columns map[string][]json.RawMessage `json:"columns"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &group); err != nil {
+		t.Fatalf("Row group is not valid JSON: %v", err)
+	}
+	if group.Rows != 1 || len(group.Columns["id"]) != 1 {
+		t.Errorf("Expected last row group to hold 1 row, got %+v", group)
+	}
+}
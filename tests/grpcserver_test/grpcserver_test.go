@@ -0,0 +1,279 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/grpcserver"
+	"loyalty-points-system-api/internal/utils"
+	pb "loyalty-points-system-api/pkg/pb/loyaltyv1"
+)
+
+// testServer runs the gRPC server in memory against a mocked database.
+type testServer struct {
+	conn *grpc.ClientConn
+	mock sqlmock.Sqlmock
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	// Audit entries are written from a goroutine, so statements may interleave
+	mock.MatchExpectationsInOrder(false)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := grpcserver.New(db)
+	go srv.Serve(listener)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial gRPC server: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		db.Close()
+	})
+	return &testServer{conn: conn, mock: mock}
+}
+
+// waitForDB waits until every expected statement, including asynchronous audit
+// writes, has run.
+func (s *testServer) waitForDB(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := s.mock.ExpectationsWereMet()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Unmet database expectations: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (s *testServer) expectAudit() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+}
+
+func authContext(t *testing.T, username string) context.Context {
+	t.Helper()
+	token, err := utils.GenerateAccessToken(username)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// assertStatus checks the gRPC code and the apperrors code carried in ErrorInfo.
+func assertStatus(t *testing.T, err error, wantCode codes.Code, wantReason apperrors.Code) *status.Status {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("Expected a gRPC status, got %v", err)
+	}
+	if st.Code() != wantCode {
+		t.Errorf("Expected code %s, got %s (%s)", wantCode, st.Code(), st.Message())
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if info.Reason != string(wantReason) || info.Domain != grpcserver.ErrorDomain {
+				t.Errorf("Expected reason %s, got %s/%s", wantReason, info.Domain, info.Reason)
+			}
+			return st
+		}
+	}
+	t.Errorf("Expected an ErrorInfo detail, got %v", st.Details())
+	return st
+}
+
+func TestLoginIsPublicAndIssuesUsableTokens(t *testing.T) {
+	srv := newTestServer(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, password_hash FROM users WHERE username = ?")).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash"}).AddRow(1, "alice", string(hash)))
+	srv.mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET refresh_token = ? WHERE id = ?")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	srv.expectAudit()
+
+	resp, err := pb.NewUserServiceClient(srv.conn).Login(context.Background(),
+		&pb.LoginRequest{Username: "alice", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	claims, err := utils.ValidateToken(resp.AccessToken)
+	if err != nil || claims.Username != "alice" {
+		t.Errorf("Expected an access token for alice, got %v (%v)", claims, err)
+	}
+	srv.waitForDB(t)
+}
+
+func TestLoginRejectsBadCredentials(t *testing.T) {
+	srv := newTestServer(t)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, password_hash FROM users WHERE username = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash"}))
+
+	_, err := pb.NewUserServiceClient(srv.conn).Login(context.Background(),
+		&pb.LoginRequest{Username: "alice", Password: "wrong"})
+	assertStatus(t, err, codes.Unauthenticated, apperrors.CodeInvalidCredentials)
+}
+
+func TestProtectedMethodsRequireToken(t *testing.T) {
+	srv := newTestServer(t)
+	points := pb.NewPointsServiceClient(srv.conn)
+
+	_, err := points.GetBalance(context.Background(), &pb.GetBalanceRequest{UserId: 1})
+	assertStatus(t, err, codes.Unauthenticated, apperrors.CodeTokenMissing)
+
+	badToken := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-jwt")
+	_, err = points.GetBalance(badToken, &pb.GetBalanceRequest{UserId: 1})
+	assertStatus(t, err, codes.Unauthenticated, apperrors.CodeTokenInvalid)
+}
+
+func TestRequestIDIsEchoedAndAttachedToErrors(t *testing.T) {
+	srv := newTestServer(t)
+	points := pb.NewPointsServiceClient(srv.conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcserver.RequestIDMetadata, "client-req-42")
+	var header metadata.MD
+	_, err := points.GetBalance(ctx, &pb.GetBalanceRequest{UserId: 1}, grpc.Header(&header))
+	st := assertStatus(t, err, codes.Unauthenticated, apperrors.CodeTokenMissing)
+
+	if got := header.Get(grpcserver.RequestIDMetadata); len(got) != 1 || got[0] != "client-req-42" {
+		t.Errorf("Expected the caller's request ID in the response header, got %v", got)
+	}
+	found := false
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RequestInfo); ok {
+			found = true
+			if info.RequestId != "client-req-42" {
+				t.Errorf("Expected RequestInfo client-req-42, got %q", info.RequestId)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected a RequestInfo detail, got %v", st.Details())
+	}
+
+	// Without one, the server generates an ID
+	header = nil
+	_, err = points.GetBalance(context.Background(), &pb.GetBalanceRequest{UserId: 1}, grpc.Header(&header))
+	assertStatus(t, err, codes.Unauthenticated, apperrors.CodeTokenMissing)
+	if got := header.Get(grpcserver.RequestIDMetadata); len(got) != 1 || len(got[0]) != 32 {
+		t.Errorf("Expected a generated request ID, got %v", got)
+	}
+}
+
+func TestRedeemPointsSharesBusinessRules(t *testing.T) {
+	srv := newTestServer(t)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	srv.mock.ExpectBegin()
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(50))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(0))
+	srv.mock.ExpectRollback()
+
+	_, err := pb.NewPointsServiceClient(srv.conn).RedeemPoints(authContext(t, "alice"),
+		&pb.RedeemPointsRequest{UserId: 1, Points: 100})
+	assertStatus(t, err, codes.InvalidArgument, apperrors.CodePointsInsufficient)
+	srv.waitForDB(t)
+}
+
+func TestAddTransactionReportsFieldViolations(t *testing.T) {
+	srv := newTestServer(t)
+
+	_, err := pb.NewTransactionServiceClient(srv.conn).AddTransaction(authContext(t, "alice"),
+		&pb.AddTransactionRequest{UserId: 1, TransactionAmount: 10, Category: "toys", TransactionDate: "2024-01-15"})
+	st := assertStatus(t, err, codes.InvalidArgument, apperrors.CodeValidationFailed)
+
+	fields := map[string]bool{}
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields[violation.Field] = true
+			}
+		}
+	}
+	if !fields["transaction_id"] || !fields["category"] {
+		t.Errorf("Expected transaction_id and category violations, got %v", fields)
+	}
+}
+
+func TestListTransactionsChecksOwnership(t *testing.T) {
+	srv := newTestServer(t)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(2, "user"))
+
+	_, err := pb.NewTransactionServiceClient(srv.conn).ListTransactions(authContext(t, "bob"),
+		&pb.ListTransactionsRequest{UserId: 1})
+	assertStatus(t, err, codes.PermissionDenied, apperrors.CodeForbidden)
+	srv.waitForDB(t)
+}
+
+func TestListTransactionsReturnsPage(t *testing.T) {
+	srv := newTestServer(t)
+	date := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	srv.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(1, "user"))
+	srv.mock.ExpectQuery(regexp.QuoteMeta("FROM transactions")).
+		WithArgs(1, "groceries").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "user_id", "transaction_amount", "category",
+			"transaction_date", "product_code", "points"}).
+			AddRow(7, "TXN-7", 1, 42.5, "groceries", date, "P1", 85).
+			AddRow(6, "TXN-6", 1, 10.0, "groceries", date.Add(-time.Hour), "", 20))
+
+	resp, err := pb.NewTransactionServiceClient(srv.conn).ListTransactions(authContext(t, "alice"),
+		&pb.ListTransactionsRequest{UserId: 1, Category: "groceries", Page: &pb.PageRequest{Limit: 1}})
+	if err != nil {
+		t.Fatalf("ListTransactions failed: %v", err)
+	}
+	if len(resp.Transactions) != 1 || resp.NextCursor == "" {
+		t.Fatalf("Expected one transaction and a next cursor, got %+v", resp)
+	}
+	got := resp.Transactions[0]
+	if got.TransactionId != "TXN-7" || got.Points != 85 || !got.TransactionDate.AsTime().Equal(date) {
+		t.Errorf("Unexpected transaction: %+v", got)
+	}
+	srv.waitForDB(t)
+}
+
+func TestInvalidPageIsRejected(t *testing.T) {
+	srv := newTestServer(t)
+
+	_, err := pb.NewPointsServiceClient(srv.conn).GetBalance(authContext(t, "alice"),
+		&pb.GetBalanceRequest{UserId: 1, Page: &pb.PageRequest{Sort: "amount"}})
+	assertStatus(t, err, codes.InvalidArgument, apperrors.CodePaginationInvalid)
+}
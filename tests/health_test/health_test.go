@@ -0,0 +1,226 @@
+package health_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/handlers"
+	"loyalty-points-system-api/internal/health"
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/models"
+)
+
+// newChecker returns a checker with the database, schema and outbox checks on a
+// mock database.
+func newChecker(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *health.Checker) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	// Checks run concurrently
+	mock.MatchExpectationsInOrder(false)
+	t.Cleanup(func() { db.Close() })
+
+	checker := health.New(time.Second)
+	checker.Add("database", health.Database(db))
+	checker.Add("schema", health.Schema(db))
+	checker.Add("outbox", health.Outbox(db, 10))
+	return db, mock, checker
+}
+
+func expectHealthy(mock sqlmock.Sqlmock, version, pending int) {
+	mock.ExpectPing()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(version) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM notifications WHERE status = 'pending'")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(pending))
+}
+
+func component(t *testing.T, report models.ReadinessResponse, name string) models.ComponentHealth {
+	t.Helper()
+	for _, c := range report.Components {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("No component %s in %+v", name, report)
+	return models.ComponentHealth{}
+}
+
+func TestReadyWhenEveryCheckPasses(t *testing.T) {
+	_, mock, checker := newChecker(t)
+	expectHealthy(mock, health.SchemaVersion, 3)
+
+	report := checker.Ready(context.Background())
+	if report.Status != health.StatusUp || len(report.Components) != 3 {
+		t.Fatalf("Expected UP with 3 components, got %+v", report)
+	}
+	if names := []string{report.Components[0].Name, report.Components[1].Name, report.Components[2].Name}; strings.Join(names, ",") != "database,schema,outbox" {
+		t.Errorf("Expected components in the order added, got %v", names)
+	}
+	if c := component(t, report, "outbox"); c.Detail != "0 pending audit writes, 3 pending notifications" {
+		t.Errorf("Unexpected outbox detail %q", c.Detail)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestReadyFailsPerComponent(t *testing.T) {
+	_, mock, checker := newChecker(t)
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(version) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(health.SchemaVersion - 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM notifications")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+
+	report := checker.Ready(context.Background())
+	if report.Status != health.StatusDown {
+		t.Fatalf("Expected DOWN, got %+v", report)
+	}
+	for name, want := range map[string]string{
+		"database": "ping failed: connection refused",
+		"schema":   "schema version " + strconv.Itoa(health.SchemaVersion-1) + " is behind " + strconv.Itoa(health.SchemaVersion),
+		"outbox":   "0 pending audit writes, 11 pending notifications, more than 10",
+	} {
+		if c := component(t, report, name); c.Status != health.StatusDown || c.Detail != want {
+			t.Errorf("Expected %s DOWN with %q, got %+v", name, want, c)
+		}
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := health.New(20 * time.Millisecond)
+	checker.Add("slow", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	c := checker.Ready(context.Background()).Components[0]
+	if c.Status != health.StatusDown || c.Detail != "no answer within 20ms" {
+		t.Errorf("Expected a timeout, got %+v", c)
+	}
+	if c.LatencyMS < 20 {
+		t.Errorf("Expected the latency to include the wait, got %vms", c.LatencyMS)
+	}
+}
+
+func TestJobFreshness(t *testing.T) {
+	db, mock, _ := newChecker(t)
+	s := jobs.New(db, "replica-1")
+	columns := []string{"id", "job_name", "source", "dry_run", "scheduled_for", "instance", "triggered_by",
+		"status", "affected_rows", "error", "started_at", "finished_at"}
+	lastRun := func(finished time.Time) {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM job_runs WHERE job_name = ? AND status = ? AND dry_run = FALSE")).
+			WithArgs("expire_points", jobs.StatusSucceeded).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "expire_points", jobs.SourceSchedule, false, nil,
+				"replica-1", "", jobs.StatusSucceeded, 4, "", finished, finished))
+	}
+
+	check := health.JobFreshness(s, "expire_points", 48*time.Hour)
+	lastRun(time.Now().Add(-time.Hour))
+	if detail, err := check(context.Background()); err != nil || !strings.HasPrefix(detail, "last succeeded at ") {
+		t.Errorf("Expected a recent run to pass, got %q, %v", detail, err)
+	}
+
+	lastRun(time.Now().Add(-72 * time.Hour))
+	if _, err := check(context.Background()); err == nil || !strings.Contains(err.Error(), "more than 48h0m0s ago") {
+		t.Errorf("Expected a stale run to fail, got %v", err)
+	}
+
+	// A job that has never succeeded is given maxAge from the check's creation
+	mock.ExpectQuery(regexp.QuoteMeta("FROM job_runs WHERE job_name = ?")).WillReturnRows(sqlmock.NewRows(columns))
+	if detail, err := check(context.Background()); err != nil || detail != "no successful run yet" {
+		t.Errorf("Expected a new deployment to pass, got %q, %v", detail, err)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM job_runs WHERE job_name = ?")).WillReturnRows(sqlmock.NewRows(columns))
+	if _, err := health.JobFreshness(s, "expire_points", time.Nanosecond)(context.Background()); err == nil {
+		t.Error("Expected a job that never succeeded within maxAge to fail")
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	_, mock, checker := newChecker(t)
+	expectHealthy(mock, health.SchemaVersion+1, 0)
+
+	rr := httptest.NewRecorder()
+	handlers.ReadyzHandler(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil), checker)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report models.ReadinessResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Status != health.StatusUp || component(t, report, "schema").Detail != "version "+strconv.Itoa(health.SchemaVersion+1) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	// Shutdown fails readiness without running the checks
+	checker.SetShuttingDown()
+	rr = httptest.NewRecorder()
+	handlers.ReadyzHandler(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil), checker)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 while shutting down, got %d", rr.Code)
+	}
+	report = models.ReadinessResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if report.Status != health.StatusDown || !report.ShuttingDown || len(report.Components) != 0 {
+		t.Errorf("Unexpected report while shutting down: %+v", report)
+	}
+}
+
+func TestLivezHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.LivezHandler(rr, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"status":"UP"}` {
+		t.Errorf("Unexpected liveness response %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+// TestSchemaVersionMatchesMigrations keeps SchemaVersion at the latest migration,
+// and checks that every migration since 013 records itself in schema_migrations.
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No migrations found: %v", err)
+	}
+	latest := -1
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".sql")
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			t.Fatalf("Migration %s has no version prefix", name)
+		}
+		if version > latest {
+			latest = version
+		}
+		if version < 13 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if !strings.Contains(string(data), "("+strconv.Itoa(version)+", '"+name+"')") {
+			t.Errorf("%s does not insert (%d, '%s') into schema_migrations", file, version, name)
+		}
+	}
+	if latest != health.SchemaVersion {
+		t.Errorf("Latest migration is %d but health.SchemaVersion is %d", latest, health.SchemaVersion)
+	}
+}
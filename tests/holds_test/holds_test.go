@@ -0,0 +1,206 @@
+package holds_test
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/internal/utils"
+)
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectPending(mock sqlmock.Sqlmock, pending int) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ? AND status = 'authorized' AND expires_at > ?")).
+		WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(pending))
+}
+
+// expectLockedHold expects the caller lookup and the locks CaptureHold and
+// ReleaseHold take: the member's row, then the hold's.
+func expectLockedHold(mock sqlmock.Sqlmock, callerID, balance int, status string, expiresAt time.Time) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(callerID, "user"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id FROM points_holds WHERE id = ?")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+	if callerID != 1 {
+		return
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(balance))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE id = ? FOR UPDATE")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reference", "user_id", "points", "captured_points", "status",
+			"created_at", "expires_at", "closed_at"}).
+			AddRow(4, "HOLD_1", 1, 300, nil, status, expiresAt.Add(-15*time.Minute), expiresAt, nil))
+}
+
+func expectCode(t *testing.T, err error, code apperrors.Code) {
+	t.Helper()
+	if !apperrors.Is(err, code) {
+		t.Errorf("Expected %s, got %v", code, err)
+	}
+}
+
+func TestAuthorizeHoldReservesAvailablePoints(t *testing.T) {
+	service.SetHoldTTL(10 * time.Minute)
+	defer service.SetHoldTTL(15 * time.Minute)
+
+	db, mock := newMock(t)
+	// The audit entry is written concurrently after the commit
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(1000))
+	expectPending(mock, 200)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points_holds")).
+		WithArgs(sqlmock.AnyArg(), 1, 300, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+
+	resp, err := service.AuthorizeHold(context.Background(), db, "alice", models.AuthorizeHoldRequest{UserID: 1, Points: 300})
+	if err != nil {
+		t.Fatalf("AuthorizeHold failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if resp.Hold.ID != 4 || resp.Hold.Status != models.HoldAuthorized || resp.Hold.ExpiresAt.Sub(resp.Hold.CreatedAt) != 10*time.Minute {
+		t.Errorf("Unexpected hold: %+v", resp.Hold)
+	}
+	if resp.Balance != 1000 || resp.PendingPoints != 500 || resp.AvailablePoints != 500 {
+		t.Errorf("Expected balance 1000 with 500 pending and 500 available, got %+v", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestAuthorizeHoldCannotExceedAvailablePoints(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(1000))
+	expectPending(mock, 800)
+	mock.ExpectRollback()
+
+	_, err := service.AuthorizeHold(context.Background(), db, "alice", models.AuthorizeHoldRequest{UserID: 1, Points: 300})
+	expectCode(t, err, apperrors.CodePointsInsufficient)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPartialCaptureRedeemsAndReleasesTheRest(t *testing.T) {
+	db, mock := newMock(t)
+	mock.MatchExpectationsInOrder(false)
+
+	expectLockedHold(mock, 1, 1000, models.HoldAuthorized, time.Now().Add(time.Minute))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).
+		WithArgs("HOLD_1", 1, 0, sqlmock.AnyArg(), -120).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE user_id = ? AND household_id IS NULL AND transaction_type = 'Earned' AND points > consumed")).WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "available", "valid_until", "expiry_policy", "expiry_days"}).
+			AddRow(10, 1000, time.Now().AddDate(0, 1, 0), "fixed_days", 365))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET consumed = consumed + ? WHERE id = ?")).WithArgs(120, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?")).WithArgs(120, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points_holds SET status = ?, captured_points = ?, closed_at = ? WHERE id = ?")).
+		WithArgs(models.HoldCaptured, 120, sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPending(mock, 0)
+	mock.ExpectCommit()
+	expectAudit(mock)
+
+	resp, err := service.CaptureHold(context.Background(), db, "alice", 4, models.CaptureHoldRequest{Points: 120})
+	if err != nil {
+		t.Fatalf("CaptureHold failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if resp.Hold.Status != models.HoldCaptured || resp.Hold.CapturedPoints != 120 || resp.Balance != 880 || resp.AvailablePoints != 880 {
+		t.Errorf("Unexpected capture: %+v", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCaptureAndReleaseRejections(t *testing.T) {
+	future, past := time.Now().Add(time.Minute), time.Now().Add(-time.Minute)
+	tests := []struct {
+		name      string
+		callerID  int
+		balance   int
+		status    string
+		expiresAt time.Time
+		capture   int
+		release   bool
+		code      apperrors.Code
+	}{
+		{"more than held", 1, 1000, models.HoldAuthorized, future, 301, false, apperrors.CodeHoldExceeded},
+		{"points expired since", 1, 100, models.HoldAuthorized, future, 0, false, apperrors.CodePointsInsufficient},
+		{"lapsed hold", 1, 1000, models.HoldAuthorized, past, 0, false, apperrors.CodeHoldExpired},
+		{"captured twice", 1, 1000, models.HoldCaptured, future, 0, false, apperrors.CodeHoldState},
+		{"release after capture", 1, 1000, models.HoldCaptured, future, 0, true, apperrors.CodeHoldState},
+		{"release lapsed hold", 1, 1000, models.HoldAuthorized, past, 0, true, apperrors.CodeHoldExpired},
+		{"another member's hold", 2, 1000, models.HoldAuthorized, future, 0, true, apperrors.CodeHoldNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectLockedHold(mock, tt.callerID, tt.balance, tt.status, tt.expiresAt)
+			mock.ExpectRollback()
+
+			var err error
+			if tt.release {
+				_, err = service.ReleaseHold(context.Background(), db, "alice", 4)
+			} else {
+				_, err = service.CaptureHold(context.Background(), db, "alice", 4, models.CaptureHoldRequest{Points: tt.capture})
+			}
+			expectCode(t, err, tt.code)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpireHoldsMarksLapsedHolds(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points_holds SET status = 'expired', closed_at = expires_at WHERE status = 'authorized' AND expires_at <= ?")).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	expired, err := service.ExpireHolds(context.Background(), db, false)
+	if err != nil || expired != 3 {
+		t.Errorf("Expected 3 expired holds, got %d, %v", expired, err)
+	}
+}
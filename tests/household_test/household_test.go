@@ -0,0 +1,379 @@
+package household_test
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/handlers"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/internal/utils"
+)
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func expectCaller(mock sqlmock.Sqlmock, id int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(id, "user"))
+}
+
+// expectLockedPool expects a pool redemption to lock the household with balance
+// points and then read userID's role under a shared lock.
+func expectLockedPool(mock sqlmock.Sqlmock, householdID, userID, balance int, role string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM households WHERE id = ? FOR UPDATE")).WithArgs(householdID).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(balance))
+	rows := sqlmock.NewRows([]string{"role"})
+	if role != "" {
+		rows.AddRow(role)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT role FROM household_members WHERE household_id = ? AND user_id = ? FOR SHARE")).
+		WithArgs(householdID, userID).WillReturnRows(rows)
+}
+
+// expectRole expects the household lookup and userID's membership; an empty role
+// means they are not a member.
+func expectRole(mock sqlmock.Sqlmock, householdID, userID int, role string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM households WHERE id = ?")).WithArgs(householdID).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	rows := sqlmock.NewRows([]string{"role"})
+	if role != "" {
+		rows.AddRow(role)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT role FROM household_members WHERE household_id = ? AND user_id = ?")).
+		WithArgs(householdID, userID).WillReturnRows(rows)
+}
+
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func invitationRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "household_id", "name", "invitee_user_id", "role", "status", "invited_by", "created_at", "expires_at"})
+}
+
+func expectCode(t *testing.T, err error, code apperrors.Code) {
+	t.Helper()
+	if !apperrors.Is(err, code) {
+		t.Errorf("Expected %s, got %v", code, err)
+	}
+}
+
+func TestPoolMemberEarnsIntoHousehold(t *testing.T) {
+	ledger.SetExpiryRules(ledger.ExpiryRules{
+		Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryRolling, Days: 90},
+	})
+	defer ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+
+	db, mock := newMock(t)
+	earned := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	req := models.AddTransactionRequest{
+		TransactionID: "TXN-9", UserID: 4, TransactionAmount: 20,
+		Category: "groceries", TransactionDate: "2024-05-01 12:00:00",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT household_id FROM household_members WHERE user_id = ?")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"household_id"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).
+		WithArgs("TXN-9", 4, 20.0, "groceries", req.TransactionDate, "", 40, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(4, "TXN-9", 40, req.TransactionDate, earned.AddDate(0, 0, 90), "Purchase", "rolling", 90, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The member and household rows are locked before any lots, as the expiry job
+	// locks them
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE id = ? FOR UPDATE")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE households SET loyalty_points = loyalty_points + ? WHERE id = ?")).
+		WithArgs(40, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	// The member's own rolling lots, then the pool's, are kept alive
+	mock.ExpectExec(regexp.QuoteMeta("WHERE user_id = ? AND household_id IS NULL")).
+		WithArgs(earned, 4, "rolling").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("WHERE household_id = ?")).
+		WithArgs(earned, 3, "rolling").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	if err := ledger.RecordEarn(context.Background(), tx, req, 40); err != nil {
+		t.Fatalf("RecordEarn failed: %v", err)
+	}
+	tx.Commit()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRedeemDebitsHouseholdPool(t *testing.T) {
+	db, mock := newMock(t)
+	// The audit entry is written concurrently after the commit
+	mock.MatchExpectationsInOrder(false)
+
+	expectCaller(mock, 4)
+	mock.ExpectBegin()
+	expectLockedPool(mock, 3, 4, 500, models.HouseholdRoleRedeemer)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).
+		WithArgs(sqlmock.AnyArg(), 4, 0, -200, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE household_id = ? AND transaction_type = 'Earned' AND points > consumed")).WithArgs(3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "available", "valid_until", "expiry_policy", "expiry_days"}).
+			AddRow(12, 500, time.Now().AddDate(0, 1, 0), "fixed_days", 365))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET consumed = consumed + ? WHERE id = ?")).WithArgs(200, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE households SET loyalty_points = loyalty_points - ? WHERE id = ?")).
+		WithArgs(200, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+
+	resp, err := service.RedeemHouseholdPoints(context.Background(), db, "bob", 3, models.HouseholdRedeemRequest{Points: 200})
+	if err != nil {
+		t.Fatalf("RedeemHouseholdPoints failed: %v", err)
+	}
+	if resp.RemainingPoints != 300 || resp.PointsRedeemed != 200 || resp.HouseholdID != 3 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRedeemRequiresPoolPermission(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		code apperrors.Code
+	}{
+		{"plain member", models.HouseholdRoleMember, apperrors.CodeHouseholdRole},
+		{"outsider", "", apperrors.CodeForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectCaller(mock, 4)
+			mock.ExpectBegin()
+			expectLockedPool(mock, 3, 4, 500, tt.role)
+			mock.ExpectRollback()
+
+			_, err := service.RedeemHouseholdPoints(context.Background(), db, "bob", 3, models.HouseholdRedeemRequest{Points: 10})
+			expectCode(t, err, tt.code)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRedeemChecksPoolBalance(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 1)
+	mock.ExpectBegin()
+	expectLockedPool(mock, 3, 1, 100, models.HouseholdRoleHead)
+	mock.ExpectRollback()
+
+	_, err := service.RedeemHouseholdPoints(context.Background(), db, "alice", 3, models.HouseholdRedeemRequest{Points: 150})
+	expectCode(t, err, apperrors.CodePointsInsufficient)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestInviteRequiresHead(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 4)
+	expectRole(mock, 3, 4, models.HouseholdRoleRedeemer)
+
+	_, err := service.InviteHouseholdMember(context.Background(), db, "bob", 3,
+		models.InviteMemberRequest{UserID: 9, Role: models.HouseholdRoleMember})
+	expectCode(t, err, apperrors.CodeHouseholdRole)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestInviteRejectsMembersOfAnotherHousehold(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 1)
+	expectRole(mock, 3, 1, models.HouseholdRoleHead)
+	mock.ExpectQuery(regexp.QuoteMeta("LEFT JOIN household_members m ON m.user_id = u.id")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"household_id"}).AddRow(5))
+
+	_, err := service.InviteHouseholdMember(context.Background(), db, "alice", 3,
+		models.InviteMemberRequest{UserID: 9, Role: models.HouseholdRoleMember})
+	expectCode(t, err, apperrors.CodeHouseholdMember)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestInviteValidatesRole(t *testing.T) {
+	db, _ := newMock(t)
+	_, err := service.InviteHouseholdMember(context.Background(), db, "alice", 3,
+		models.InviteMemberRequest{UserID: 9, Role: models.HouseholdRoleHead})
+	expectCode(t, err, apperrors.CodeValidationFailed)
+}
+
+func TestAcceptInvitationChecks(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name    string
+		invitee int
+		status  string
+		expires time.Time
+		code    apperrors.Code
+	}{
+		{"someone else's", 8, models.InvitationPending, now.Add(time.Hour), apperrors.CodeInvitationNotFound},
+		{"already declined", 4, models.InvitationDeclined, now.Add(time.Hour), apperrors.CodeInvitationClosed},
+		{"expired", 4, models.InvitationPending, now.Add(-time.Hour), apperrors.CodeInvitationClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectCaller(mock, 4)
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("FROM household_invitations i")).WithArgs(12).
+				WillReturnRows(invitationRows().AddRow(12, 3, "Smiths", tt.invitee, "member", tt.status, 1, now, tt.expires))
+			mock.ExpectRollback()
+
+			_, err := service.AcceptHouseholdInvitation(context.Background(), db, "bob", 12)
+			expectCode(t, err, tt.code)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAcceptRespectsMemberLimit(t *testing.T) {
+	service.SetHouseholdSettings(service.HouseholdSettings{InviteTTL: time.Hour, MaxMembers: 2})
+	t.Cleanup(func() {
+		service.SetHouseholdSettings(service.HouseholdSettings{InviteTTL: 168 * time.Hour, MaxMembers: 6})
+	})
+
+	db, mock := newMock(t)
+	now := time.Now().UTC()
+	expectCaller(mock, 4)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM household_invitations i")).
+		WillReturnRows(invitationRows().AddRow(12, 3, "Smiths", 4, "member", "pending", 1, now, now.Add(time.Hour)))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM households WHERE id = ? FOR UPDATE")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM household_members WHERE household_id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	_, err := service.AcceptHouseholdInvitation(context.Background(), db, "bob", 12)
+	expectCode(t, err, apperrors.CodeHouseholdFull)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRemoveMemberPermissions(t *testing.T) {
+	tests := []struct {
+		name       string
+		callerID   int
+		callerRole string
+		userID     int
+		targetRole string
+		code       apperrors.Code
+	}{
+		{"member removes another", 4, models.HouseholdRoleRedeemer, 5, "", apperrors.CodeHouseholdRole},
+		{"head leaves", 1, models.HouseholdRoleHead, 1, models.HouseholdRoleHead, apperrors.CodeHouseholdRole},
+		{"head removes outsider", 1, models.HouseholdRoleHead, 9, "", apperrors.CodeHouseholdNoMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectCaller(mock, tt.callerID)
+			expectRole(mock, 3, tt.callerID, tt.callerRole)
+			if tt.code != apperrors.CodeHouseholdRole || tt.callerID == tt.userID {
+				expectRole(mock, 3, tt.userID, tt.targetRole)
+			}
+
+			_, err := service.RemoveHouseholdMember(context.Background(), db, "alice", 3, tt.userID)
+			expectCode(t, err, tt.code)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUnknownHousehold(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 1)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM households WHERE id = ?")).WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}))
+
+	_, err := service.GetHousehold(context.Background(), db, "alice", 99)
+	expectCode(t, err, apperrors.CodeHouseholdNotFound)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestExpirePointsDebitsMemberAndPool(t *testing.T) {
+	db, mock := newMock(t)
+	// The audit entries are written concurrently after the commit
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, head_user_id FROM households")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "head_user_id"}).AddRow(3, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, household_id, points - consumed FROM points")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "household_id", "available"}).
+			AddRow(20, 4, 3, 150).AddRow(21, 4, nil, 40))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET transaction_type = 'Expired'")).WithArgs(20).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE households SET loyalty_points = GREATEST(loyalty_points - ?, 0) WHERE id = ?")).WithArgs(150, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO expired_points_log (household_id, expired_points)")).WithArgs(3, 150).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The member's own lot comes off their balance and is logged against them
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET transaction_type = 'Expired'")).WithArgs(21).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = GREATEST(loyalty_points - ?, 0) WHERE id = ?")).WithArgs(40, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO expired_points_log (user_id, expired_points)")).WithArgs(4, 40).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+	expectAudit(mock)
+
+	expired, err := handlers.ExpirePoints(context.Background(), db, false)
+	if err != nil {
+		t.Fatalf("ExpirePoints failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if expired != 2 {
+		t.Errorf("Expected 2 lots expired, got %d", expired)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
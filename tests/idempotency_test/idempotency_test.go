@@ -0,0 +1,75 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// idempotent wraps a handler that counts its calls in the Idempotency middleware.
+func idempotent(t *testing.T) (http.Handler, sqlmock.Sqlmock, *int) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	})
+	return middleware.Idempotency(db, next), mock, &calls
+}
+
+func request(method string) *http.Request {
+	req := httptest.NewRequest(method, "/orders", nil)
+	req.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "alice"))
+}
+
+func TestIdempotencyPassesReadsThrough(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		t.Run(method, func(t *testing.T) {
+			h, mock, calls := idempotent(t)
+			// A retried read with the same key must run again, not replay
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, request(method))
+				if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+					t.Errorf("Expected a fresh 200, got %d %v", rec.Code, rec.Header())
+				}
+			}
+			if *calls != 2 {
+				t.Errorf("Expected the handler to run twice, ran %d times", *calls)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestIdempotencyClaimsKeyForPost(t *testing.T) {
+	h, mock, calls := idempotent(t)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO idempotency_keys")).
+		WithArgs("alice", "key-1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE idempotency_keys")).
+		WithArgs(http.StatusOK, "", sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", "key-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, request(http.MethodPost))
+	if rec.Code != http.StatusOK || *calls != 1 {
+		t.Errorf("Expected the handler to run once with 200, got %d after %d calls", rec.Code, *calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
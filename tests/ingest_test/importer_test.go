@@ -0,0 +1,48 @@
+package ingest_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/ingest"
+)
+
+func TestImportStoresOverlongTransactionIDTruncated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// Multi-byte characters make sure the cut lands on a character boundary
+	longID := strings.Repeat("é", 300)
+	input := `{"transaction_id":"` + longID + `","user_id":1,"transaction_amount":20,"category":"groceries","transaction_date":"2024-01-15"}` + "\n"
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, status, rows_done FROM import_jobs WHERE checksum = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "rows_done"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO import_jobs")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM import_results WHERE job_id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"row_num", "transaction_id", "status", "points", "reason"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO import_results")).
+		WithArgs(1, 1, strings.Repeat("é", 255), ingest.StatusRejected, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE import_jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE import_jobs SET status = 'completed'")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	report, err := ingest.Import(context.Background(), db, strings.NewReader(input), ingest.FormatJSONL, ingest.Options{Source: "test.jsonl"})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report.Rejected != 1 {
+		t.Errorf("Expected the row to be rejected, got %+v", report)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package ingest_test
+
+import (
+	"strings"
+	"testing"
+
+	"loyalty-points-system-api/internal/ingest"
+)
+
+func collect(t *testing.T, input string, format ingest.Format) []ingest.Record {
+	var records []ingest.Record
+	err := ingest.ReadRecords(strings.NewReader(input), format, func(rec ingest.Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRecords returned error: %v", err)
+	}
+	return records
+}
+
+func TestReadRecordsCSV(t *testing.T) {
+	input := "transaction_id,user_id,transaction_amount,category,transaction_date,product_code\n" +
+		"TXN1,1,100.50,groceries,2024-01-15,P1\n" +
+		"TXN2,abc,10,clothing,2024-01-15,P2\n"
+
+	records := collect(t, input, ingest.FormatCSV)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Err != nil || records[0].Req.TransactionID != "TXN1" || records[0].Req.TransactionAmount != 100.50 {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].Err == nil || records[1].Row != 2 {
+		t.Errorf("Expected row 2 to fail decoding, got %+v", records[1])
+	}
+}
+
+func TestReadRecordsJSONL(t *testing.T) {
+	input := `{"transaction_id":"TXN1","user_id":1,"transaction_amount":20,"category":"electronics","transaction_date":"2024-01-15"}
+
+not json
+`
+	records := collect(t, input, ingest.FormatJSONL)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Err != nil || records[0].Req.UserID != 1 {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].Err == nil {
+		t.Errorf("Expected malformed line to fail decoding")
+	}
+}
+
+func TestPrepareRecord(t *testing.T) {
+	records := collect(t, `{"transaction_id":"TXN1","user_id":1,"transaction_amount":20,"category":"groceries","transaction_date":"2024-01-15T10:30:00+02:00"}
+{"transaction_id":"TXN2","user_id":1,"transaction_amount":-5,"category":"groceries","transaction_date":"2024-01-15"}
+{"transaction_id":"TXN3","user_id":1,"transaction_amount":5,"category":"toys","transaction_date":"2024-01-15"}
+{"transaction_id":"TXN4","user_id":1,"transaction_amount":5,"category":"groceries","transaction_date":"2024-01-15","points_payment":100}
+`, ingest.FormatJSONL)
+
+	req := records[0].Req
+	if err := ingest.PrepareRecord(&req); err != nil {
+		t.Fatalf("Expected valid record, got %v", err)
+	}
+	if req.TransactionDate != "2024-01-15 08:30:00" {
+		t.Errorf("Expected date normalised to UTC, got %s", req.TransactionDate)
+	}
+	for _, rec := range records[1:] {
+		if err := ingest.PrepareRecord(&rec.Req); err == nil {
+			t.Errorf("Expected %s to be rejected", rec.Req.TransactionID)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/jobs"
+)
+
+// fakeJob records how it was called and returns affected rows or err.
+type fakeJob struct {
+	calls    int
+	dryRun   bool
+	affected int64
+	err      error
+}
+
+func (f *fakeJob) run(_ context.Context, dryRun bool) (int64, error) {
+	f.calls++
+	f.dryRun = dryRun
+	return f.affected, f.err
+}
+
+func newScheduler(t *testing.T, job *fakeJob) (*jobs.Scheduler, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := jobs.New(db, "replica-1")
+	if err := s.Add(jobs.Job{Name: "expire_points", Schedule: "@daily", Run: job.run}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	return s, mock
+}
+
+func expectLock(mock sqlmock.Sqlmock, got int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, 0)")).
+		WithArgs("loyalty_job:expire_points").
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(got))
+}
+
+func expectRelease(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("DO RELEASE_LOCK(?)")).
+		WithArgs("loyalty_job:expire_points").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+var slot = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestScheduledRunIsRecorded(t *testing.T) {
+	job := &fakeJob{affected: 12}
+	s, mock := newScheduler(t, job)
+
+	expectLock(mock, 1)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO job_runs")).
+		WithArgs("expire_points", jobs.SourceSchedule, false, slot, "replica-1", nil, jobs.StatusRunning, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE job_runs SET status = ?")).
+		WithArgs(jobs.StatusSucceeded, int64(12), nil, sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(mock)
+
+	s.RunScheduled("expire_points", slot.Add(300*time.Millisecond))
+	if job.calls != 1 || job.dryRun {
+		t.Errorf("Expected one real run, got %d calls (dry run %v)", job.calls, job.dryRun)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestScheduledSlotRunsOnce(t *testing.T) {
+	job := &fakeJob{}
+	s, mock := newScheduler(t, job)
+
+	// Another replica already recorded this slot
+	expectLock(mock, 1)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO job_runs")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRelease(mock)
+
+	s.RunScheduled("expire_points", slot)
+	if job.calls != 0 {
+		t.Errorf("Expected the job to be skipped, got %d calls", job.calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestScheduledRunSkipsWhileLocked(t *testing.T) {
+	job := &fakeJob{}
+	s, mock := newScheduler(t, job)
+
+	expectLock(mock, 0)
+
+	s.RunScheduled("expire_points", slot)
+	if job.calls != 0 {
+		t.Errorf("Expected the job to be skipped, got %d calls", job.calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestTriggerDryRunSkipsLock(t *testing.T) {
+	job := &fakeJob{affected: 3}
+	s, mock := newScheduler(t, job)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO job_runs")).
+		WithArgs("expire_points", jobs.SourceManual, true, nil, "replica-1", "admin", jobs.StatusRunning, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE job_runs SET status = ?")).
+		WithArgs(jobs.StatusSucceeded, int64(3), nil, sqlmock.AnyArg(), int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	run, err := s.Trigger(context.Background(), "expire_points", "admin", true)
+	if err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	if !job.dryRun || run.ID != 8 || !run.DryRun || run.AffectedRows != 3 || run.Status != jobs.StatusSucceeded {
+		t.Errorf("Unexpected run %+v (dry run %v)", run, job.dryRun)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestTriggerRecordsFailure(t *testing.T) {
+	job := &fakeJob{err: errors.New("deadlock found")}
+	s, mock := newScheduler(t, job)
+
+	expectLock(mock, 1)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO job_runs")).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE job_runs SET status = ?")).
+		WithArgs(jobs.StatusFailed, int64(0), "deadlock found", sqlmock.AnyArg(), int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(mock)
+
+	run, err := s.Trigger(context.Background(), "expire_points", "admin", false)
+	if err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	if run.Status != jobs.StatusFailed || run.Error != "deadlock found" || run.FinishedAt == nil {
+		t.Errorf("Unexpected run %+v", run)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestTriggerErrors(t *testing.T) {
+	job := &fakeJob{}
+	s, mock := newScheduler(t, job)
+
+	_, err := s.Trigger(context.Background(), "reindex", "admin", false)
+	if appErr := apperrors.From(err); appErr.Code != apperrors.CodeJobUnknown {
+		t.Errorf("Expected JOB_UNKNOWN, got %v", err)
+	}
+
+	expectLock(mock, 0)
+	_, err = s.Trigger(context.Background(), "expire_points", "admin", false)
+	if appErr := apperrors.From(err); appErr.Code != apperrors.CodeJobRunning {
+		t.Errorf("Expected JOB_ALREADY_RUNNING, got %v", err)
+	}
+	if job.calls != 0 {
+		t.Errorf("Expected the job not to run, got %d calls", job.calls)
+	}
+}
+
+func TestJobsListsSchedulesAndLastRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := jobs.New(db, "replica-1")
+	noop := func(context.Context, bool) (int64, error) { return 0, nil }
+	s.Add(jobs.Job{Name: "expire_points", Schedule: "@daily", Run: noop})
+	s.Add(jobs.Job{Name: "audit_checkpoint", Schedule: jobs.Disabled, Run: noop})
+	if err := s.Add(jobs.Job{Name: "audit_checkpoint", Schedule: "@hourly", Run: noop}); err == nil {
+		t.Error("Expected an error for a duplicate job name")
+	}
+	if err := s.Add(jobs.Job{Name: "bad", Schedule: "sometimes", Run: noop}); err == nil {
+		t.Error("Expected an error for an invalid schedule")
+	}
+
+	columns := []string{"id", "job_name", "source", "dry_run", "scheduled_for", "instance", "triggered_by",
+		"status", "affected_rows", "error", "started_at", "finished_at"}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM job_runs WHERE job_name = ?")).
+		WithArgs("audit_checkpoint").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM job_runs WHERE job_name = ?")).
+		WithArgs("expire_points").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(4, "expire_points", "schedule", false, slot, "replica-2", "", "succeeded", 12, "", slot, slot))
+
+	infos, err := s.Jobs(context.Background())
+	if err != nil {
+		t.Fatalf("Jobs failed: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != "audit_checkpoint" || infos[1].Name != "expire_points" {
+		t.Fatalf("Unexpected jobs %+v", infos)
+	}
+	if infos[0].NextRun != nil || infos[0].LastRun != nil {
+		t.Errorf("Expected a disabled job with no runs, got %+v", infos[0])
+	}
+	if infos[1].LastRun == nil || infos[1].LastRun.Instance != "replica-2" || infos[1].LastRun.AffectedRows != 12 {
+		t.Errorf("Unexpected last run %+v", infos[1].LastRun)
+	}
+}
@@ -0,0 +1,238 @@
+package ledger_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/models"
+)
+
+func TestExpiryPolicyValidUntil(t *testing.T) {
+	earned := time.Date(2024, 5, 20, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		policy  ledger.ExpiryPolicy
+		want    time.Time
+		expires bool
+	}{
+		{ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 30}, time.Date(2024, 6, 19, 15, 30, 0, 0, time.UTC), true},
+		{ledger.ExpiryPolicy{Kind: ledger.ExpiryRolling, Days: 10}, time.Date(2024, 5, 30, 15, 30, 0, 0, time.UTC), true},
+		{ledger.ExpiryPolicy{Kind: ledger.ExpiryEndOfQuarter}, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		{ledger.ExpiryPolicy{Kind: ledger.ExpiryEndOfYear}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{ledger.ExpiryPolicy{Kind: ledger.ExpiryNever}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, expires := tt.policy.ValidUntil(earned)
+		if expires != tt.expires || !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v/%v, got %v/%v", tt.policy, tt.want, tt.expires, got, expires)
+		}
+	}
+
+	// The last quarter rolls over into the next year
+	q4, _ := ledger.ExpiryPolicy{Kind: ledger.ExpiryEndOfQuarter}.ValidUntil(time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC))
+	if want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !q4.Equal(want) {
+		t.Errorf("Expected Q4 lots to expire at %v, got %v", want, q4)
+	}
+}
+
+func TestParseExpiryPolicy(t *testing.T) {
+	p, err := ledger.ParseExpiryPolicy("fixed_days", 365)
+	if err != nil || p.Days != 365 {
+		t.Errorf("Expected fixed_days to default to 365 days, got %+v (%v)", p, err)
+	}
+	p, err = ledger.ParseExpiryPolicy("rolling:180", 365)
+	if err != nil || p.Kind != ledger.ExpiryRolling || p.Days != 180 {
+		t.Errorf("Expected rolling:180, got %+v (%v)", p, err)
+	}
+	for _, spec := range []string{"monthly", "never:5", "fixed_days:soon", "rolling:0"} {
+		if _, err := ledger.ParseExpiryPolicy(spec, 365); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestExpiryRulesPrecedence(t *testing.T) {
+	categories, err := ledger.ParseExpiryAssignments("groceries=end_of_quarter, electronics=never", 365)
+	if err != nil {
+		t.Fatalf("ParseExpiryAssignments failed: %v", err)
+	}
+	tiers, err := ledger.ParseExpiryAssignments("gold=rolling:90", 365)
+	if err != nil {
+		t.Fatalf("ParseExpiryAssignments failed: %v", err)
+	}
+	rules := ledger.ExpiryRules{
+		Default:    ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365},
+		Categories: categories,
+		Tiers:      tiers,
+	}
+
+	if got := rules.For("electronics", "gold"); got.Kind != ledger.ExpiryNever {
+		t.Errorf("Expected the earning rule to win over the tier, got %s", got)
+	}
+	if got := rules.For("clothing", "gold"); got.Kind != ledger.ExpiryRolling || got.Days != 90 {
+		t.Errorf("Expected the tier policy, got %s", got)
+	}
+	if got := rules.For("clothing", "standard"); got != rules.Default {
+		t.Errorf("Expected the default policy, got %s", got)
+	}
+
+	if _, err := ledger.ParseExpiryAssignments("gold=never,gold=rolling", 365); err == nil {
+		t.Error("Expected a duplicate assignment to be rejected")
+	}
+	bad := ledger.ExpiryRules{Categories: map[string]ledger.ExpiryPolicy{"toys": {Kind: ledger.ExpiryNever}}}
+	if err := bad.Validate(); err == nil || !strings.Contains(err.Error(), "toys") {
+		t.Errorf("Expected an unknown earning rule error, got %v", err)
+	}
+}
+
+func TestRecordEarnAppliesPolicyFromTransactionDate(t *testing.T) {
+	ledger.SetExpiryRules(ledger.ExpiryRules{
+		Default:    ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365},
+		Categories: map[string]ledger.ExpiryPolicy{"groceries": {Kind: ledger.ExpiryEndOfQuarter}},
+		Tiers:      map[string]ledger.ExpiryPolicy{"gold": {Kind: ledger.ExpiryRolling, Days: 90}},
+	})
+	defer ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	earned := time.Date(2023, 2, 10, 9, 0, 0, 0, time.UTC)
+	req := models.AddTransactionRequest{
+		TransactionID: "TXN-1", UserID: 7, TransactionAmount: 50,
+		Category: "clothing", TransactionDate: "2023-02-10 09:00:00",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT household_id FROM household_members WHERE user_id = ?")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"household_id"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tier FROM users WHERE id = ?")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"tier"}).AddRow("gold"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(7, "TXN-1", 75, req.TransactionDate, earned.AddDate(0, 0, 90), "Purchase", "rolling", 90, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points")).
+		WithArgs(earned, 7, "rolling").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := ledger.RecordEarn(context.Background(), tx, req, 75); err != nil {
+		t.Fatalf("RecordEarn failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRecordEarnNeverExpiringLot(t *testing.T) {
+	ledger.SetExpiryRules(ledger.ExpiryRules{
+		Default:    ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365},
+		Categories: map[string]ledger.ExpiryPolicy{"electronics": {Kind: ledger.ExpiryNever}},
+	})
+	defer ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	req := models.AddTransactionRequest{
+		TransactionID: "TXN-2", UserID: 7, TransactionAmount: 10,
+		Category: "electronics", TransactionDate: "2024-03-01 00:00:00",
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT household_id FROM household_members WHERE user_id = ?")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"household_id"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).WillReturnResult(sqlmock.NewResult(1, 1))
+	// No tier policies, so no tier lookup; no rolling policies, so no extension
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(7, "TXN-2", 10, req.TransactionDate, nil, "Purchase", "never", 0, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	if err := ledger.RecordEarn(context.Background(), tx, req, 10); err != nil {
+		t.Fatalf("RecordEarn failed: %v", err)
+	}
+	tx.Commit()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+// notAfter matches a time argument no later than limit.
+type notAfter time.Time
+
+func (n notAfter) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && !t.After(time.Time(n))
+}
+
+func TestExtendRollingExpiry(t *testing.T) {
+	ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryRolling, Days: 30}})
+	defer ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+
+	backdated := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		activity time.Time
+		from     interface{} // the date lots are extended from
+	}{
+		// A backdated purchase extends from its own date, but lots that lapsed
+		// since then stay lapsed: the bound is the server's clock
+		{"backdated", backdated, backdated},
+		// A future date extends no further than activity now would
+		{"future", time.Now().AddDate(5, 0, 0), notAfter(time.Now().Add(time.Minute))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("AND transaction_type = 'Earned' AND expiry_policy = ? AND valid_until > NOW()")).
+				WithArgs(tt.from, 7, "rolling").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+			if err := ledger.ExtendRollingExpiry(context.Background(), tx, 7, tt.activity); err != nil {
+				t.Fatalf("ExtendRollingExpiry failed: %v", err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/metrics"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/routes"
+	"loyalty-points-system-api/internal/service"
+)
+
+// scrape returns the current /metrics output.
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func expectLine(t *testing.T, out, line string) {
+	t.Helper()
+	if !strings.Contains(out, line+"\n") {
+		t.Errorf("Expected %q in metrics output", line)
+	}
+}
+
+func TestRequestsAreLabelledByRouteTemplate(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	mux := http.NewServeMux()
+	routes.Register(mux, []routes.Route{
+		{Method: http.MethodGet, Path: "/widgets/{id}/runs", Pattern: "/widgets/", Handler: ok},
+		{Method: http.MethodPost, Path: "/widgets/{id}/run", Pattern: "/widgets/", Handler: ok},
+	}, nil)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/widgets/7/runs", nil),
+		httptest.NewRequest(http.MethodGet, "/widgets/8/runs", nil),
+		httptest.NewRequest(http.MethodPost, "/widgets/7/run", nil),
+		httptest.NewRequest(http.MethodDelete, "/widgets/7", nil),
+		httptest.NewRequest(http.MethodGet, "/no-such-route", nil),
+	} {
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	out := scrape(t)
+	expectLine(t, out, `loyalty_http_requests_total{method="GET",route="/widgets/{id}/runs",status="418"} 2`)
+	expectLine(t, out, `loyalty_http_requests_total{method="POST",route="/widgets/{id}/run",status="418"} 1`)
+	expectLine(t, out, `loyalty_http_requests_total{method="DELETE",route="unmatched",status="418"} 1`)
+	expectLine(t, out, `loyalty_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	expectLine(t, out, `loyalty_http_request_duration_seconds_count{method="GET",route="/widgets/{id}/runs"} 2`)
+}
+
+func TestLedgerHooksFeedBusinessCounters(t *testing.T) {
+	ledger.SetHooks(ledger.Hooks{
+		PointsEarned:   metrics.PointsEarned,
+		PointsRedeemed: metrics.PointsRedeemed,
+		PointsExpired:  metrics.PointsExpired,
+	})
+	defer ledger.SetHooks(ledger.Hooks{})
+
+	ledger.ReportEarned("electronics", 30)
+	ledger.ReportEarned("electronics", 12)
+	ledger.ReportRedeemed(25)
+	ledger.ReportExpired(5)
+	metrics.ObserveJob("expire_points", "succeeded", 1500*time.Millisecond)
+
+	out := scrape(t)
+	expectLine(t, out, `loyalty_transactions_total{category="electronics"} 2`)
+	expectLine(t, out, `loyalty_points_earned_total{category="electronics"} 42`)
+	expectLine(t, out, `loyalty_points_redeemed_total 25`)
+	expectLine(t, out, `loyalty_points_expired_total 5`)
+	expectLine(t, out, `loyalty_job_duration_seconds_count{job="expire_points",status="succeeded"} 1`)
+}
+
+func TestFailedLoginHook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	failed := 0
+	service.SetHooks(service.Hooks{LoginFailed: func() { failed++ }})
+	defer service.SetHooks(service.Hooks{})
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, password_hash FROM users")).
+		WithArgs("mallory").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash"}))
+
+	if _, err := service.Login(context.Background(), db, models.LoginRequest{Username: "mallory", Password: "password123"}); err == nil {
+		t.Fatal("Expected login to fail")
+	}
+	if failed != 1 {
+		t.Errorf("Expected one failed login, got %d", failed)
+	}
+}
+
+func TestDBPoolStats(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	metrics.RegisterDB(db, "pool_test")
+	expectLine(t, scrape(t), `go_sql_max_open_connections{db_name="pool_test"} 7`)
+}
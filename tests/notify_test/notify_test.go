@@ -0,0 +1,296 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/notify"
+)
+
+var sample = notify.Notification{
+	UserID: 1, Username: "alice", Kind: notify.KindExpiryWarning, HorizonDays: 7, Points: 120,
+	ExpiresAt: time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC), Subject: "120 loyalty points expire within 7 days",
+	Body: "Hi alice,\nRedeem them soon.\n",
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	subject, body, err := notify.DefaultTemplates().Render(notify.ExpiryWarningData{
+		Username: "alice", Points: 120, ExpiresAt: sample.ExpiresAt, HorizonDays: 1,
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if subject != "120 loyalty points expire within 1 day" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	if !strings.Contains(body, "Hi alice") || !strings.Contains(body, "June 5, 2024") {
+		t.Errorf("Unexpected body %q", body)
+	}
+}
+
+func TestLoadTemplatesFromDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "expiry_warning_subject.tmpl"), []byte("Hurry {{.Username}}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "expiry_warning_body.tmpl"), []byte("{{.Points}} points"), 0o644)
+
+	templates, err := notify.LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	subject, body, err := templates.Render(notify.ExpiryWarningData{Username: "bob", Points: 5})
+	if err != nil || subject != "Hurry bob" || body != "5 points" {
+		t.Errorf("Unexpected render %q/%q (%v)", subject, body, err)
+	}
+
+	if _, err := notify.LoadTemplates(t.TempDir()); err == nil {
+		t.Error("Expected an error for a directory without templates")
+	}
+}
+
+func TestFileNotifierAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	n := &notify.FileNotifier{Path: path}
+	for i := 0; i < 2; i++ {
+		if err := n.Send(context.Background(), sample); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var got notify.Notification
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil || got.Username != "alice" || got.Points != 120 {
+		t.Errorf("Unexpected line %q (%v)", lines[0], err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received notify.Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	if err := (&notify.WebhookNotifier{URL: srv.URL}).Send(context.Background(), sample); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if received.UserID != 1 || received.Subject != sample.Subject {
+		t.Errorf("Unexpected payload %+v", received)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := (&notify.WebhookNotifier{URL: failing.URL}).Send(context.Background(), sample); err == nil {
+		t.Error("Expected an error for a 502 response")
+	}
+}
+
+// fakeSMTP accepts one message and keeps its envelope and data.
+type fakeSMTP struct {
+	net.Listener
+	mu   sync.Mutex
+	rcpt string
+	data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := &fakeSMTP{Listener: l}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	conn, err := s.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = strings.TrimSpace(line[len("RCPT TO:"):])
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	srv := newFakeSMTP(t)
+	n := &notify.SMTPNotifier{Addr: srv.Addr().String(), From: "loyalty@localhost", Domain: "example.test"}
+	if err := n.Send(context.Background(), sample); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.rcpt != "<alice@example.test>" {
+		t.Errorf("Unexpected recipient %q", srv.rcpt)
+	}
+	if !strings.Contains(srv.data, "Subject: "+sample.Subject) || !strings.Contains(srv.data, "Redeem them soon.") {
+		t.Errorf("Unexpected message %q", srv.data)
+	}
+}
+
+// recorder is a Notifier that keeps what it was asked to send.
+type recorder struct {
+	mu   sync.Mutex
+	sent []notify.Notification
+	fail bool
+}
+
+func (r *recorder) Channel() string { return "test" }
+
+func (r *recorder) Send(_ context.Context, n notify.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("channel down")
+	}
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func TestExpiryWarnerPicksShortestHorizonAndDeduplicates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points p")).
+		WithArgs(now, now.AddDate(0, 0, 30)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "loyalty_points", "points", "valid_until"}).
+			// alice: one lot in 3 days, one in 20; her balance caps the 7-day total
+			AddRow(1, "alice", 80, 100, now.AddDate(0, 0, 3)).
+			AddRow(1, "alice", 80, 50, now.AddDate(0, 0, 20)).
+			// bob: already notified for this horizon and date
+			AddRow(2, "bob", 500, 40, now.AddDate(0, 0, 25)))
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO notifications")).
+		WithArgs("points_expiring:1:7:2024-06-04", 1, "points_expiring", "test", 7, 80,
+			now.AddDate(0, 0, 3), "80 loyalty points expire within 7 days", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notifications SET status = ?")).
+		WithArgs("sent", nil, sqlmock.AnyArg(), "points_expiring:1:7:2024-06-04").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO notifications")).
+		WithArgs("points_expiring:2:30:2024-06-26", 2, "points_expiring", "test", 30, 40,
+			now.AddDate(0, 0, 25), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("WHERE dedup_key = ? AND status = 'failed'")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rec := &recorder{}
+	warner := &notify.ExpiryWarner{
+		DB: db, Notifier: rec, Templates: notify.DefaultTemplates(),
+		Horizons: []int{30, 7, 1}, Now: func() time.Time { return now },
+	}
+	report, err := warner.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Members != 2 || report.Sent != 1 || report.Skipped != 1 || report.Failed != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(rec.sent) != 1 || rec.sent[0].Username != "alice" || rec.sent[0].HorizonDays != 7 {
+		t.Errorf("Unexpected notifications %+v", rec.sent)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestExpiryWarnerRecordsFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points p")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "loyalty_points", "points", "valid_until"}).
+			AddRow(1, "alice", 100, 100, now.Add(12*time.Hour)))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO notifications")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notifications SET status = ?")).
+		WithArgs("failed", "channel down", nil, "points_expiring:1:1:2024-06-02").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	warner := &notify.ExpiryWarner{
+		DB: db, Notifier: &recorder{fail: true}, Templates: notify.DefaultTemplates(),
+		Horizons: []int{1}, Now: func() time.Time { return now },
+	}
+	report, err := warner.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Failed != 1 || report.Sent != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
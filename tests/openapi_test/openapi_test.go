@@ -0,0 +1,110 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"loyalty-points-system-api/config"
+	"loyalty-points-system-api/internal/openapi"
+	"loyalty-points-system-api/internal/routes"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	spec := openapi.Spec()
+	for _, route := range routes.Table(nil, &config.Config{}, nil, nil) {
+		if !spec.Has(route.Method, route.Path) {
+			t.Errorf("Route %s %s has no OpenAPI operation", route.Method, route.Path)
+		}
+	}
+}
+
+func TestEveryOperationHasRoute(t *testing.T) {
+	served := map[string]bool{}
+	for _, route := range routes.Table(nil, &config.Config{}, nil, nil) {
+		served[route.Method+" "+route.Path] = true
+	}
+	for _, op := range openapi.Spec().Operations() {
+		if !served[op] {
+			t.Errorf("OpenAPI operation %s has no route", op)
+		}
+	}
+}
+
+func TestSpecHandlerServesSchemasFromModels(t *testing.T) {
+	rr := httptest.NewRecorder()
+	openapi.SpecHandler(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string `json:"required"`
+				Properties map[string]struct {
+					Enum             []string `json:"enum"`
+					MaxLength        *int     `json:"maxLength"`
+					ExclusiveMinimum bool     `json:"exclusiveMinimum"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	for _, name := range []string{"SuccessResponse", "ErrorResponse", "WarningResponse", "APIError", "ProblemDetails"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Missing envelope schema %s", name)
+		}
+	}
+
+	req, ok := doc.Components.Schemas["AddTransactionRequest"]
+	if !ok {
+		t.Fatal("Missing AddTransactionRequest schema")
+	}
+	required := map[string]bool{}
+	for _, field := range req.Required {
+		required[field] = true
+	}
+	for _, field := range []string{"transaction_id", "user_id", "transaction_amount", "category", "transaction_date"} {
+		if !required[field] {
+			t.Errorf("Expected %s to be required, got %v", field, req.Required)
+		}
+	}
+	if required["product_code"] {
+		t.Error("product_code should be optional")
+	}
+	if got := req.Properties["category"].Enum; len(got) != 3 || got[0] != "clothing" {
+		t.Errorf("Expected category enum from the earning rules, got %v", got)
+	}
+	if max := req.Properties["transaction_id"].MaxLength; max == nil || *max != 255 {
+		t.Errorf("Expected transaction_id maxLength 255, got %v", max)
+	}
+	if !req.Properties["transaction_amount"].ExclusiveMinimum {
+		t.Error("Expected transaction_amount to have an exclusive minimum")
+	}
+}
+
+func TestDocsPageIsSelfHosted(t *testing.T) {
+	rr := httptest.NewRecorder()
+	openapi.DocsHandler(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("Unexpected docs response: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `fetch("/openapi.json")`) {
+		t.Error("Docs page should render /openapi.json")
+	}
+	if strings.Contains(body, "https://") || strings.Contains(body, "http://") {
+		t.Error("Docs page should not load assets from other hosts")
+	}
+}
@@ -0,0 +1,78 @@
+package pagination_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"loyalty-points-system-api/internal/pagination"
+)
+
+var opts = pagination.Options{
+	Sorts: map[string]pagination.SortField{
+		"transaction_date": {Column: "transaction_date", Kind: pagination.KindTime},
+		"points":           {Column: "points", Kind: pagination.KindInt},
+	},
+	DefaultSort: "transaction_date",
+	DefaultDesc: true,
+}
+
+func TestParseDefaultsAndLimits(t *testing.T) {
+	p, err := pagination.Parse(url.Values{}, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Limit != pagination.DefaultLimit || p.Sort != "transaction_date" || !p.Desc {
+		t.Errorf("Unexpected defaults: %+v", p)
+	}
+
+	if _, err := pagination.Parse(url.Values{"limit": {"1000"}}, opts); err == nil {
+		t.Error("Expected limit above the maximum to be rejected")
+	}
+	if _, err := pagination.Parse(url.Values{"sort": {"password_hash"}}, opts); err == nil {
+		t.Error("Expected unknown sort to be rejected")
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	p, _ := pagination.Parse(url.Values{"sort": {"-points"}, "limit": {"10"}}, opts)
+	next := p.Next(150, 42)
+
+	p2, err := pagination.Parse(url.Values{"sort": {"-points"}, "cursor": {next}}, opts)
+	if err != nil {
+		t.Fatalf("Expected cursor to be accepted: %v", err)
+	}
+	cond, args := p2.Where()
+	if !strings.Contains(cond, "points < ?") || len(args) != 3 || args[0] != int64(150) || args[2] != int64(42) {
+		t.Errorf("Unexpected keyset condition %q %v", cond, args)
+	}
+	if got := p2.OrderLimit(); got != " ORDER BY points DESC, id DESC LIMIT 21" {
+		t.Errorf("Unexpected order clause %q", got)
+	}
+}
+
+func TestCursorTimeValue(t *testing.T) {
+	p, _ := pagination.Parse(url.Values{}, opts)
+	when := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	p2, err := pagination.Parse(url.Values{"cursor": {p.Next(when, 7)}}, opts)
+	if err != nil {
+		t.Fatalf("Expected cursor to be accepted: %v", err)
+	}
+	_, args := p2.Where()
+	if got, ok := args[0].(time.Time); !ok || !got.Equal(when) {
+		t.Errorf("Expected time cursor value, got %v", args[0])
+	}
+}
+
+func TestCursorRejectsTamperingAndSortChange(t *testing.T) {
+	p, _ := pagination.Parse(url.Values{}, opts)
+	next := p.Next(time.Now(), 1)
+
+	if _, err := pagination.Parse(url.Values{"cursor": {"x" + next}}, opts); err != pagination.ErrInvalidCursor {
+		t.Errorf("Expected tampered cursor to be rejected, got %v", err)
+	}
+	if _, err := pagination.Parse(url.Values{"cursor": {next}, "sort": {"points"}}, opts); err != pagination.ErrInvalidCursor {
+		t.Errorf("Expected cursor for another sort to be rejected, got %v", err)
+	}
+}
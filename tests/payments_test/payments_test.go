@@ -0,0 +1,254 @@
+package payments_test
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/internal/utils"
+)
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectPending(mock sqlmock.Sqlmock, pending int) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ? AND status = 'authorized' AND expires_at > ?")).
+		WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(pending))
+}
+
+// expectRate answers the conversion rate lookup for gold members in USD, with no
+// row when pointValue is 0.
+func expectRate(mock sqlmock.Sqlmock, pointValue float64) {
+	rows := sqlmock.NewRows([]string{"id", "currency", "tier", "point_value", "effective_from", "created_at"})
+	if pointValue > 0 {
+		effective := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		rows.AddRow(3, "USD", "gold", pointValue, effective, effective)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM conversion_rates")).
+		WithArgs("USD", "gold", sqlmock.AnyArg()).WillReturnRows(rows)
+}
+
+// expectPaymentChecks expects the owner check and the locks and lookups made
+// before a points payment is recorded.
+func expectPaymentChecks(mock sqlmock.Sqlmock, balance, pending int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points", "tier"}).AddRow(balance, "gold"))
+	expectPending(mock, pending)
+}
+
+func purchase(points int) models.AddTransactionRequest {
+	return models.AddTransactionRequest{
+		TransactionID: "TXN1", UserID: 1, TransactionAmount: 42.50, Category: "groceries",
+		TransactionDate: "2024-01-15 10:30:00", PointsPayment: points,
+	}
+}
+
+func TestAddTransactionPaysPartWithPoints(t *testing.T) {
+	ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryRolling, Days: 90}})
+	defer ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+
+	db, mock := newMock(t)
+	// The audit entry is written concurrently after the commit
+	mock.MatchExpectationsInOrder(false)
+
+	expectPaymentChecks(mock, 5000, 1000)
+	expectRate(mock, 0.01)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points_payments")).
+		WithArgs(sqlmock.AnyArg(), "TXN1", 1, "USD", 3, 2000, 20.0, 22.5, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("VALUES (?, ?, ?, 'redemption', ?, 'REDEMPTION', ?)")).
+		WithArgs(sqlmock.AnyArg(), 1, 0, sqlmock.AnyArg(), -2000).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE user_id = ? AND household_id IS NULL AND transaction_type = 'Earned' AND points > consumed")).WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "available", "valid_until", "expiry_policy", "expiry_days"}).
+			AddRow(10, 5000, time.Now().AddDate(0, 1, 0), "fixed_days", 365))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET consumed = consumed + ? WHERE id = ?")).WithArgs(2000, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?")).WithArgs(2000, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Paying with points keeps the member's rolling lots alive, and so does earning
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(regexp.QuoteMeta("SET valid_until = GREATEST(valid_until")).WithArgs(sqlmock.AnyArg(), 1, "rolling").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	// The cash part, 22.50 of groceries, earns 45 points
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT household_id FROM household_members WHERE user_id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"household_id"}))
+	mock.ExpectExec(regexp.QuoteMeta("category, transaction_date, product_code, points, household_id")).
+		WithArgs("TXN1", 1, 42.50, "groceries", "2024-01-15 10:30:00", "", 45, nil).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`loyalty_points \+ \?`).WithArgs(45, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(3045))
+	expectAudit(mock)
+
+	resp, err := service.AddTransaction(context.Background(), db, "alice", purchase(2000))
+	if err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if resp.Points != 45 || resp.Payment == nil {
+		t.Fatalf("Expected 45 points earned with a payment, got %+v", resp)
+	}
+	if p := resp.Payment; p.Points != 2000 || p.PointsValue != 20 || p.CashAmount != 22.5 || p.Currency != "USD" || p.RateID != 3 {
+		t.Errorf("Unexpected payment: %+v", p)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestAddTransactionPaymentRejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		balance    int
+		pending    int
+		points     int
+		pointValue float64
+		code       apperrors.Code
+	}{
+		// 4250 points pay for the whole 42.50
+		{"more than the amount", 10000, 0, 4251, 0.01, apperrors.CodePaymentExceeded},
+		{"points on hold", 3000, 1500, 2000, 0.01, apperrors.CodePointsInsufficient},
+		{"no rate for the currency", 5000, 0, 2000, 0, apperrors.CodeRateNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectPaymentChecks(mock, tt.balance, tt.pending)
+			if tt.code != apperrors.CodePointsInsufficient {
+				expectRate(mock, tt.pointValue)
+			}
+			mock.ExpectRollback()
+
+			_, err := service.AddTransaction(context.Background(), db, "alice", purchase(tt.points))
+			if !apperrors.Is(err, tt.code) {
+				t.Errorf("Expected %s, got %v", tt.code, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestQuotePoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		balance   int
+		pending   int
+		maxPoints int
+		value     float64
+		cash      float64
+	}{
+		// 10.00 at 0.03 a point needs 334 points, which pay for 10.02
+		{"enough points", 1000, 0, 334, 10, 0},
+		{"part of the amount", 300, 100, 200, 6, 4},
+		{"everything on hold", 300, 400, 0, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(1, "user"))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points, tier FROM users WHERE id = ?")).WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"loyalty_points", "tier"}).AddRow(tt.balance, "gold"))
+			expectPending(mock, tt.pending)
+			expectRate(mock, 0.03)
+
+			quote, err := service.QuotePoints(context.Background(), db, "alice", models.PointsQuoteRequest{UserID: 1, Amount: 10})
+			if err != nil {
+				t.Fatalf("QuotePoints failed: %v", err)
+			}
+			if quote.Currency != "USD" || quote.PointsRequired != 334 {
+				t.Errorf("Expected 334 USD points required, got %+v", quote)
+			}
+			if quote.MaxPoints != tt.maxPoints || quote.MaxPointsValue != tt.value || quote.CashAmount != tt.cash {
+				t.Errorf("Expected %d points worth %.2f leaving %.2f, got %+v", tt.maxPoints, tt.value, tt.cash, quote)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestQuotePointsRejectsZeroRate(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(1, "user"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points, tier FROM users WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points", "tier"}).AddRow(1000, "gold"))
+	expectPending(mock, 0)
+	// A rate stored before point values had a floor
+	effective := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM conversion_rates")).WithArgs("USD", "gold", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency", "tier", "point_value", "effective_from", "created_at"}).
+			AddRow(3, "USD", "gold", 0.0, effective, effective))
+
+	_, err := service.QuotePoints(context.Background(), db, "alice", models.PointsQuoteRequest{UserID: 1, Amount: 10})
+	if !apperrors.Is(err, apperrors.CodeInternal) {
+		t.Errorf("Expected INTERNAL_ERROR, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCreateConversionRateRequiresAdmin(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(1, "user"))
+
+	_, err := service.CreateConversionRate(context.Background(), db, "alice",
+		models.ConversionRateRequest{Currency: "USD", PointValue: 0.01})
+	if !apperrors.Is(err, apperrors.CodeForbidden) {
+		t.Errorf("Expected FORBIDDEN, got %v", err)
+	}
+}
+
+func TestCreateConversionRateValidation(t *testing.T) {
+	db, _ := newMock(t)
+	_, err := service.CreateConversionRate(context.Background(), db, "admin",
+		models.ConversionRateRequest{Currency: "usd", Tier: "gold,vip", PointValue: 0, EffectiveFrom: "tomorrow"})
+	if e := apperrors.From(err); e.Code != apperrors.CodeValidationFailed || len(e.Fields) != 4 {
+		t.Errorf("Expected 4 field errors, got %v", err)
+	}
+
+	// DECIMAL(12,6) would store this as 0
+	_, err = service.CreateConversionRate(context.Background(), db, "admin",
+		models.ConversionRateRequest{Currency: "USD", PointValue: 0.0000004})
+	if e := apperrors.From(err); e.Code != apperrors.CodeValidationFailed || len(e.Fields) != 1 || e.Fields[0].Field != "point_value" {
+		t.Errorf("Expected a point_value error, got %v", err)
+	}
+}
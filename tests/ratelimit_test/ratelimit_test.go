@@ -0,0 +1,329 @@
+package ratelimit_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/utils"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+func mustPolicies(t *testing.T, s string) middleware.RatePolicies {
+	t.Helper()
+	policies, err := middleware.ParseRatePolicies(s)
+	if err != nil {
+		t.Fatalf("ParseRatePolicies(%q) failed: %v", s, err)
+	}
+	return policies
+}
+
+// limited serves route behind a limiter with opts and a fresh memory store.
+func limited(opts middleware.RateLimitOptions, route string) http.Handler {
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryRateStore(), opts)
+	return limiter.Limit(func(*http.Request) string { return route }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func serve(h http.Handler, method, path string, headers map[string]string, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestParseRatePolicies(t *testing.T) {
+	policies := mustPolicies(t, "default=20/1s:40, POST  /login=5/m:10,/users/{id}/points/history=3/1h,/livez=off")
+	want := map[string]middleware.RatePolicy{
+		"default":                    {Name: "default", Rate: 20, Period: time.Second, Burst: 40},
+		"POST /login":                {Name: "POST /login", Rate: 5, Period: time.Minute, Burst: 10},
+		"/users/{id}/points/history": {Name: "/users/{id}/points/history", Rate: 3, Period: time.Hour, Burst: 3},
+		"/livez":                     {Name: "/livez", Off: true},
+	}
+	if len(policies) != len(want) {
+		t.Fatalf("Expected %d policies, got %+v", len(want), policies)
+	}
+	for name, p := range want {
+		if policies[name] != p {
+			t.Errorf("Expected %s to be %+v, got %+v", name, p, policies[name])
+		}
+	}
+	if got := policies["POST /login"].String(); got != "5;w=60;burst=10" {
+		t.Errorf("Expected policy header 5;w=60;burst=10, got %s", got)
+	}
+
+	for input, want := range map[string]string{
+		"login=5/1m":                "name must be default, /path or METHOD /path",
+		"/login=5":                  "want rate/period[:burst] or off",
+		"/login=0/1m":               "rate must be a positive integer",
+		"/login=5/soon":             "period must be a positive duration",
+		"/login=5/1m:-1":            "burst must be a positive integer",
+		"/login=5/1m,/login=6/1m":   "rate limit for /login is set twice",
+		"default=20/1s,default=off": "rate limit for default is set twice",
+	} {
+		if _, err := middleware.ParseRatePolicies(input); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseRatePolicies(%q): expected error containing %q, got %v", input, want, err)
+		}
+	}
+}
+
+func TestPolicyPrecedence(t *testing.T) {
+	policies := mustPolicies(t, "default=20/1s,POST /login=5/1m,/login=50/1m,/livez=off")
+	for _, tt := range []struct {
+		method, route, want string
+		ok                  bool
+	}{
+		{"POST", "/login", "POST /login", true},
+		{"GET", "/login", "/login", true},
+		{"GET", "/points-balance", "default", true},
+		{"GET", "/livez", "/livez", false},
+	} {
+		p, ok := policies.For(tt.method, tt.route)
+		if p.Name != tt.want || ok != tt.ok {
+			t.Errorf("For(%s, %s) = %s, %v; expected %s, %v", tt.method, tt.route, p.Name, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := mustPolicies(t, "/login=5/1m").For("GET", "/redeem"); ok {
+		t.Error("Expected no policy without a default")
+	}
+	if got := policies.MaxFillTime(); got != time.Minute {
+		t.Errorf("Expected the longest fill time to be 1m, got %s", got)
+	}
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+	policy := mustPolicies(t, "default=2/1s:4")["default"]
+	store := middleware.NewMemoryRateStore()
+	ctx, start := context.Background(), time.Now()
+
+	for i := 0; i < 4; i++ {
+		d, _ := store.Take(ctx, "k", policy, start)
+		if !d.Allowed || d.Remaining != 3-i {
+			t.Fatalf("Request %d: expected allowed with %d left, got %+v", i+1, 3-i, d)
+		}
+	}
+	d, _ := store.Take(ctx, "k", policy, start)
+	if d.Allowed || d.RetryAfter != 500*time.Millisecond || d.Reset != 2*time.Second {
+		t.Fatalf("Expected a refusal retrying in 500ms, got %+v", d)
+	}
+	if d, _ := store.Take(ctx, "other", policy, start); !d.Allowed {
+		t.Error("Expected another key to have its own bucket")
+	}
+	// Half a second refills one token, and a long pause never fills beyond the burst
+	if d, _ := store.Take(ctx, "k", policy, start.Add(500*time.Millisecond)); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Expected one refilled token, got %+v", d)
+	}
+	if d, _ := store.Take(ctx, "k", policy, start.Add(time.Hour)); !d.Allowed || d.Remaining != 3 {
+		t.Errorf("Expected a full bucket after an hour, got %+v", d)
+	}
+}
+
+func TestLimitRefusesWithHeaders(t *testing.T) {
+	h := limited(middleware.RateLimitOptions{Policies: mustPolicies(t, "POST /login=1/1h:2")}, "/login")
+
+	for i := 0; i < 2; i++ {
+		rr := serve(h, http.MethodPost, "/login", nil, "192.0.2.1:4000")
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("Request %d: expected it to pass, got %d", i+1, rr.Code)
+		}
+	}
+	rr := serve(h, http.MethodPost, "/login", nil, "192.0.2.1:4000")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rr.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "7200",
+		"RateLimit-Policy":    "1;w=3600;burst=2",
+		"Retry-After":         "3600",
+	} {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("Expected %s: %s, got %q", header, want, got)
+		}
+	}
+	var body struct {
+		Success bool
+		Error   struct{ Code string }
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Success || body.Error.Code != "RATE_LIMITED" {
+		t.Errorf("Expected a RATE_LIMITED error, got %s", rr.Body.String())
+	}
+
+	// Another address has its own budget
+	if rr := serve(h, http.MethodPost, "/login", nil, "192.0.2.2:4000"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected another client to pass, got %d", rr.Code)
+	}
+}
+
+func TestUnlimitedRoutes(t *testing.T) {
+	policies := mustPolicies(t, "default=1/1h,/livez=off")
+	h := limited(middleware.RateLimitOptions{Policies: policies}, "/livez")
+	for i := 0; i < 3; i++ {
+		rr := serve(h, http.MethodGet, "/livez", nil, "")
+		if rr.Code != http.StatusNoContent || rr.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("Expected an unlimited route without headers, got %d %v", rr.Code, rr.Header())
+		}
+	}
+
+	var nilLimiter *middleware.RateLimiter
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	if got := nilLimiter.Limit(func(*http.Request) string { return "/" }, next); got == nil {
+		t.Error("Expected a nil limiter to return the next handler")
+	}
+}
+
+func TestClientIdentity(t *testing.T) {
+	utils.ConfigureTokens([]byte("test-secret"), 15*time.Minute, time.Hour)
+	alice, _ := utils.GenerateAccessToken("alice")
+	sum := sha256.Sum256([]byte("partner-key"))
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	opts := middleware.RateLimitOptions{
+		Policies:       mustPolicies(t, "default=1/1h"),
+		APIKeys:        map[string]string{hex.EncodeToString(sum[:]): "partner"},
+		TrustedProxies: []*net.IPNet{proxies},
+	}
+
+	bearer := map[string]string{"Authorization": "Bearer " + alice}
+	peer, otherPeer, proxy := "192.0.2.1:4000", "192.0.2.99:4000", "10.0.0.5:4000"
+	tests := []struct {
+		name          string
+		first, second map[string]string
+		firstAddr     string
+		secondAddr    string
+		sameBucket    bool
+	}{
+		{"same user from two addresses", bearer, bearer, peer, otherPeer, true},
+		{"user and anonymous caller", bearer, nil, peer, peer, false},
+		{"invalid token falls back to the address", map[string]string{"Authorization": "Bearer forged"}, nil, peer, peer, true},
+		{"registered key", map[string]string{middleware.APIKeyHeader: "partner-key"}, nil, peer, peer, false},
+		{"unknown key falls back to the address", map[string]string{middleware.APIKeyHeader: "made-up"}, nil, peer, peer, true},
+		{"forwarded for ignored from untrusted peer", map[string]string{"X-Forwarded-For": "198.51.100.7"}, map[string]string{"X-Forwarded-For": "198.51.100.8"}, peer, peer, true},
+		{"forwarded for used behind trusted proxy", map[string]string{"X-Forwarded-For": "198.51.100.7, 10.1.1.1"}, map[string]string{"X-Forwarded-For": "198.51.100.8"}, proxy, proxy, false},
+		{"spoofed first hop ignored", map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.7"}, map[string]string{"X-Forwarded-For": "198.51.100.7"}, proxy, proxy, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := limited(opts, "/points-balance")
+			if rr := serve(h, http.MethodGet, "/points-balance", tt.first, tt.firstAddr); rr.Code != http.StatusNoContent {
+				t.Fatalf("Expected the first request to pass, got %d", rr.Code)
+			}
+			rr := serve(h, http.MethodGet, "/points-balance", tt.second, tt.secondAddr)
+			if refused := rr.Code == http.StatusTooManyRequests; refused != tt.sameBucket {
+				t.Errorf("Expected same bucket %v, got status %d", tt.sameBucket, rr.Code)
+			}
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, middleware.RatePolicy, time.Time) (middleware.RateDecision, error) {
+	return middleware.RateDecision{}, errors.New("too many connections")
+}
+
+func TestStoreFailureLetsRequestsThrough(t *testing.T) {
+	limiter := middleware.NewRateLimiter(failingStore{}, middleware.RateLimitOptions{Policies: mustPolicies(t, "default=1/1h")})
+	h := limiter.Limit(func(*http.Request) string { return "/redeem" }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for i := 0; i < 3; i++ {
+		if rr := serve(h, http.MethodPost, "/redeem", nil, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected the request through despite the store failing, got %d", rr.Code)
+		}
+	}
+}
+
+func TestMySQLStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	policy := mustPolicies(t, "POST /login=5/1m:10")["POST /login"]
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO rate_limit_buckets (bucket_key, tokens, updated_at) VALUES (?, ?, ?)")).
+		WithArgs("POST /login|ip:192.0.2.1", 10, now).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tokens, updated_at FROM rate_limit_buckets WHERE bucket_key = ? FOR UPDATE")).
+		WithArgs("POST /login|ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(0.5, now.Add(-3*time.Second)))
+	// 3s at 5/min refills 0.25 tokens, which is still short of one
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE rate_limit_buckets SET tokens = ?, updated_at = ? WHERE bucket_key = ?")).
+		WithArgs(0.75, now, "POST /login|ip:192.0.2.1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	d, err := middleware.NewMySQLRateStore(db).Take(context.Background(), "POST /login|ip:192.0.2.1", policy, now)
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	if d.Allowed || d.RetryAfter != 3*time.Second {
+		t.Errorf("Expected a refusal retrying in 3s, got %+v", d)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestMySQLStoreRollsBackOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	policy := mustPolicies(t, "default=1/1s")["default"]
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO rate_limit_buckets")).WillReturnError(errors.New("table doesn't exist"))
+	mock.ExpectRollback()
+
+	_, err = middleware.NewMySQLRateStore(db).Take(context.Background(), "k", policy, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "creating rate limit bucket") {
+		t.Errorf("Expected the insert error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeRateLimitBuckets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM rate_limit_buckets WHERE updated_at < ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	if n, err := middleware.PurgeRateLimitBuckets(context.Background(), db, time.Hour, true); err != nil || n != 7 {
+		t.Errorf("Expected a dry run to count 7, got %d, %v", n, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM rate_limit_buckets WHERE updated_at < ?")).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+	if n, err := middleware.PurgeRateLimitBuckets(context.Background(), db, time.Hour, false); err != nil || n != 7 {
+		t.Errorf("Expected 7 purged, got %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
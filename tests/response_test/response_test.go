@@ -0,0 +1,65 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"loyalty-points-system-api/internal/apperrors"
+	response "loyalty-points-system-api/internal/reponse"
+)
+
+func TestWriteErrorEnvelope(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/redeem", nil)
+	response.WriteError(rr, req, apperrors.New(apperrors.CodePointsInsufficient, "User does not have enough points for redemption"))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+	var body response.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if body.Success || body.Error.Code != "POINTS_INSUFFICIENT" || body.Error.Status != 400 {
+		t.Errorf("Unexpected error body: %+v", body)
+	}
+}
+
+func TestWriteErrorProblemJSON(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/2/points/history", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	response.WriteError(rr, req, apperrors.New(apperrors.CodeForbidden, "You can only access your own account"))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json content type, got %q", ct)
+	}
+	var problem response.ProblemDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if problem.Status != 403 || problem.Code != "ACCESS_FORBIDDEN" || problem.Instance != "/users/2/points/history" {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+}
+
+func TestWriteErrorUnwrapsAndDefaults(t *testing.T) {
+	dup := apperrors.Wrap(errors.New("Error 1062"), apperrors.CodeTransactionExists, "Transaction ID has already been recorded")
+	wrapped := fmt.Errorf("could not save: %w", dup)
+
+	rr := httptest.NewRecorder()
+	response.WriteError(rr, httptest.NewRequest(http.MethodPost, "/add-transaction", nil), wrapped)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected wrapped domain error to map to 409, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	response.WriteError(rr, httptest.NewRequest(http.MethodGet, "/", nil), errors.New("boom"))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected plain error to map to 500, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,325 @@
+package rewards_test
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/internal/utils"
+)
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func expectCaller(mock sqlmock.Sqlmock, id int, role string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, role FROM users WHERE username = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(id, role))
+}
+
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectOrderStart expects the owner check and the locked member and reward rows
+// of PlaceOrder.
+func expectOrderStart(mock sqlmock.Sqlmock, balance int, tier string, reward *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points", "tier"}).AddRow(balance, tier))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM rewards WHERE id = ? FOR UPDATE")).WithArgs(3).WillReturnRows(reward)
+}
+
+// expectLockedOrder expects transitionOrder's reads and locks: the order's
+// member, then the member's row, then the order. The member is only locked when
+// the caller may see the order.
+func expectLockedOrder(mock sqlmock.Sqlmock, ownerVisible bool, status string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id FROM reward_orders WHERE id = ?")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+	if !ownerVisible {
+		return
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points FROM users WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"loyalty_points"}).AddRow(500))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE o.id = ? FOR UPDATE OF o")).WithArgs(9).WillReturnRows(orderRows(status))
+}
+
+func expectPending(mock sqlmock.Sqlmock, pending int) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ?")).WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(pending))
+}
+
+type rewardRow struct {
+	stock       interface{}
+	from, until interface{}
+	tiers       string
+	active      bool
+}
+
+func rewardRows(r rewardRow) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows([]string{"id", "sku", "name", "description", "points_cost", "stock",
+		"available_from", "available_until", "tiers", "active", "created_at", "updated_at"}).
+		AddRow(3, "MUG-01", "Coffee mug", "", 100, r.stock, r.from, r.until, r.tiers, r.active, now, now)
+}
+
+func orderRows(status string) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows([]string{"id", "reference", "user_id", "reward_id", "sku", "name", "quantity", "points", "status",
+		"created_at", "updated_at", "fulfilled_at", "closed_at"}).
+		AddRow(9, "ORD_1", 1, 3, "MUG-01", "Coffee mug", 2, 200, status, now, now, nil, nil)
+}
+
+func expectCode(t *testing.T, err error, code apperrors.Code) {
+	t.Helper()
+	if !apperrors.Is(err, code) {
+		t.Errorf("Expected %s, got %v", code, err)
+	}
+}
+
+func TestPlaceOrderReservesStockAndPoints(t *testing.T) {
+	db, mock := newMock(t)
+	// The audit entry is written concurrently after the commit
+	mock.MatchExpectationsInOrder(false)
+
+	expectOrderStart(mock, 500, "gold", rewardRows(rewardRow{stock: 5, tiers: "gold,platinum", active: true}))
+	expectPending(mock, 0)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE rewards SET stock = stock - ? WHERE id = ?")).WithArgs(2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).
+		WithArgs(sqlmock.AnyArg(), 1, 0, sqlmock.AnyArg(), "MUG-01", -200).WillReturnResult(sqlmock.NewResult(1, 1))
+	validUntil := time.Now().AddDate(0, 1, 0).UTC()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE user_id = ? AND household_id IS NULL AND transaction_type = 'Earned' AND points > consumed")).WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "available", "valid_until", "expiry_policy", "expiry_days"}).
+			AddRow(10, 500, validUntil, "fixed_days", 365))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET consumed = consumed + ? WHERE id = ?")).WithArgs(200, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?")).WithArgs(200, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO reward_orders")).
+		WithArgs(sqlmock.AnyArg(), 1, 3, 2, 200, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(9, 1))
+	// The slice the order took is kept for a refund
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO reward_order_lots")).
+		WithArgs(int64(9), 200, validUntil, "fixed_days", 365).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+
+	resp, err := service.PlaceOrder(context.Background(), db, "alice", models.OrderRequest{UserID: 1, RewardID: 3, Quantity: 2})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if resp.Order.ID != 9 || resp.Order.Status != models.OrderPending || resp.Order.Points != 200 || resp.RemainingPoints != 300 {
+		t.Errorf("Unexpected order: %+v", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPlaceOrderRejections(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		balance int
+		reward  rewardRow
+		code    apperrors.Code
+	}{
+		{"inactive", 500, rewardRow{active: false}, apperrors.CodeRewardUnavailable},
+		{"not yet available", 500, rewardRow{from: future, active: true}, apperrors.CodeRewardUnavailable},
+		{"window closed", 500, rewardRow{until: past, active: true}, apperrors.CodeRewardUnavailable},
+		{"other tier", 500, rewardRow{tiers: "platinum", active: true}, apperrors.CodeRewardTier},
+		{"out of stock", 500, rewardRow{stock: 1, active: true}, apperrors.CodeRewardOutOfStock},
+		{"insufficient points", 150, rewardRow{active: true}, apperrors.CodePointsInsufficient},
+		{"points on hold", 250, rewardRow{active: true}, apperrors.CodePointsInsufficient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectOrderStart(mock, tt.balance, "gold", rewardRows(tt.reward))
+			if tt.code == apperrors.CodePointsInsufficient {
+				expectPending(mock, 100)
+			}
+			mock.ExpectRollback()
+
+			_, err := service.PlaceOrder(context.Background(), db, "alice", models.OrderRequest{UserID: 1, RewardID: 3, Quantity: 2})
+			expectCode(t, err, tt.code)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCancelOrderRestoresPointsAndStock(t *testing.T) {
+	db, mock := newMock(t)
+	mock.MatchExpectationsInOrder(false)
+
+	expectCaller(mock, 1, "user")
+	mock.ExpectBegin()
+	expectLockedOrder(mock, true, models.OrderPending)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points + ? WHERE id = ?")).WithArgs(200, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).
+		WithArgs("ORD_1_CANCEL", 1, 0, sqlmock.AnyArg(), "MUG-01", 200).WillReturnResult(sqlmock.NewResult(1, 1))
+	// The order took 150 points from a lot that has since lapsed and 50 that never
+	// expire; both come back with the expiry they had
+	lapsed := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM reward_order_lots WHERE order_id = ?")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"points", "valid_until", "expiry_policy", "expiry_days"}).
+			AddRow(150, lapsed, "fixed_days", 365).
+			AddRow(50, nil, "never", 0))
+	mock.ExpectExec(regexp.QuoteMeta("VALUES (?, ?, ?, 'Earned', ?, ?, 'Refund', ?, ?, ?)")).
+		WithArgs(1, "ORD_1_CANCEL", 150, sqlmock.AnyArg(), lapsed, "fixed_days", 365, 9).WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec(regexp.QuoteMeta("VALUES (?, ?, ?, 'Earned', ?, ?, 'Refund', ?, ?, ?)")).
+		WithArgs(1, "ORD_1_CANCEL", 50, sqlmock.AnyArg(), nil, "never", 0, 9).WillReturnResult(sqlmock.NewResult(21, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE rewards SET stock = stock + ? WHERE id = ? AND stock IS NOT NULL")).WithArgs(2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE reward_orders SET status = ?")).
+		WithArgs(models.OrderCancelled, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+
+	order, err := service.CancelOrder(context.Background(), db, "alice", 9)
+	if err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if order.Status != models.OrderCancelled || order.ClosedAt == nil {
+		t.Errorf("Unexpected order: %+v", order)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestRefundOrderPlacedBeforeLotsWereKept(t *testing.T) {
+	db, mock := newMock(t)
+	mock.MatchExpectationsInOrder(false)
+
+	expectCaller(mock, 7, "admin")
+	mock.ExpectBegin()
+	expectLockedOrder(mock, true, models.OrderFulfilled)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points + ? WHERE id = ?")).WithArgs(200, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO transactions")).
+		WithArgs("ORD_1_REFUND", 1, 0, sqlmock.AnyArg(), "MUG-01", 200).WillReturnResult(sqlmock.NewResult(1, 1))
+	// No lots were recorded, so the points come back without one
+	mock.ExpectQuery(regexp.QuoteMeta("FROM reward_order_lots WHERE order_id = ?")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"points", "valid_until", "expiry_policy", "expiry_days"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE reward_orders SET status = ?")).
+		WithArgs(models.OrderRefunded, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+
+	order, err := service.RefundOrder(context.Background(), db, "admin", 9)
+	if err != nil {
+		t.Fatalf("RefundOrder failed: %v", err)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if order.Status != models.OrderRefunded {
+		t.Errorf("Unexpected order: %+v", order)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCancelOrderOfAnotherMemberIsNotFound(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 2, "user")
+	mock.ExpectBegin()
+	expectLockedOrder(mock, false, models.OrderPending)
+	mock.ExpectRollback()
+
+	_, err := service.CancelOrder(context.Background(), db, "bob", 9)
+	expectCode(t, err, apperrors.CodeOrderNotFound)
+}
+
+func TestOrderStateTransitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		do     func(context.Context, *sql.DB, string, int) (*models.Order, error)
+	}{
+		{"fulfill fulfilled", models.OrderFulfilled, service.FulfillOrder},
+		{"refund pending", models.OrderPending, service.RefundOrder},
+		{"cancel fulfilled", models.OrderFulfilled, service.CancelOrder},
+		{"cancel refunded", models.OrderRefunded, service.CancelOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			expectCaller(mock, 7, "admin")
+			mock.ExpectBegin()
+			expectLockedOrder(mock, true, tt.status)
+			mock.ExpectRollback()
+
+			_, err := tt.do(context.Background(), db, "admin", 9)
+			expectCode(t, err, apperrors.CodeOrderState)
+		})
+	}
+}
+
+func TestFulfillOrderRequiresAdmin(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 1, "user")
+
+	_, err := service.FulfillOrder(context.Background(), db, "alice", 9)
+	expectCode(t, err, apperrors.CodeForbidden)
+}
+
+func TestCreateRewardValidation(t *testing.T) {
+	db, _ := newMock(t)
+	stock := -1
+	req := models.RewardRequest{
+		SKU: "MUG-01", Name: "Coffee mug", PointsCost: 10000001, Stock: &stock,
+		AvailableFrom: "2024-09-01T00:00:00Z", AvailableUntil: "2024-06-01T00:00:00Z",
+	}
+	_, err := service.CreateReward(context.Background(), db, "admin", req)
+	if !apperrors.Is(err, apperrors.CodeValidationFailed) {
+		t.Fatalf("Expected VALIDATION_FAILED, got %v", err)
+	}
+	appErr := apperrors.From(err)
+	fields := map[string]bool{}
+	for _, f := range appErr.Fields {
+		fields[f.Field] = true
+	}
+	if !fields["points_cost"] || !fields["stock"] || !fields["available_until"] {
+		t.Errorf("Expected points_cost, stock and available_until errors, got %+v", appErr.Fields)
+	}
+}
+
+func TestCreateRewardRequiresAdmin(t *testing.T) {
+	db, mock := newMock(t)
+	expectCaller(mock, 1, "user")
+
+	_, err := service.CreateReward(context.Background(), db, "alice", models.RewardRequest{SKU: "MUG-01", Name: "Coffee mug", PointsCost: 100})
+	expectCode(t, err, apperrors.CodeForbidden)
+}
@@ -0,0 +1,230 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"loyalty-points-system-api/internal/jobs"
+	"loyalty-points-system-api/internal/logging"
+	"loyalty-points-system-api/internal/tracing"
+	"loyalty-points-system-api/pkg/middleware"
+)
+
+// record installs a tracer provider that keeps every ended span in memory, and
+// the W3C propagator, for the rest of the test.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func spanNamed(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, s := range spans {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), tracing.Options{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected an error for exporter zipkin")
+	}
+}
+
+func TestFileExporterWritesSpans(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	flush, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter: tracing.ExporterFile, FilePath: path, SamplePercent: 100, Environment: "test", Instance: "replica-1",
+	})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	_, span := tracing.Tracer().Start(context.Background(), "job expire_points")
+	span.End()
+	if err := flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	var line struct{ Name string }
+	if err := json.Unmarshal(bytes.TrimSpace(data), &line); err != nil {
+		t.Fatalf("Expected one JSON span, got %q: %v", data, err)
+	}
+	if line.Name != "job expire_points" {
+		t.Errorf("Expected span job expire_points, got %q", line.Name)
+	}
+	for _, want := range []string{"service.name", "deployment.environment", "service.instance.id"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected resource attribute %s in %s", want, data)
+		}
+	}
+}
+
+func TestZeroSamplingRecordsNothing(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	flush, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterFile, FilePath: path})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	_, span := tracing.Tracer().Start(context.Background(), "unsampled")
+	span.End()
+	flush(context.Background())
+
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("Expected no spans at 0%% sampling, got %s", data)
+	}
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := record(t)
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "info", "json")
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	route := func(*http.Request) string { return "/points-balance" }
+	h := middleware.Tracing(route, middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/points-balance?user_id=1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set(middleware.RequestIDHeader, "req-trace")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := spanNamed(recorder.Ended(), "GET /points-balance")
+	if span == nil {
+		t.Fatalf("Expected a span named GET /points-balance, got %d spans", len(recorder.Ended()))
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("Expected the caller's trace %s, got %s", traceID, got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("Expected the caller's span as parent, got %s", got)
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["http.route"] != "/points-balance" || attrs["request_id"] != "req-trace" {
+		t.Errorf("Expected http.route and request_id attributes, got %v", attrs)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one access log line, got %q: %v", buf.String(), err)
+	}
+	if line["trace_id"] != traceID || line["request_id"] != "req-trace" {
+		t.Errorf("Expected trace_id and request_id in the access log line, got %v", line)
+	}
+}
+
+func TestTriggeredJobIsChildOfRequestSpan(t *testing.T) {
+	recorder := record(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	s := jobs.New(db, "replica-1")
+	failing := func(context.Context, bool) (int64, error) { return 0, errors.New("deadlock found") }
+	if err := s.Add(jobs.Job{Name: "expire_points", Schedule: "@daily", Run: failing}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO job_runs")).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE job_runs SET status = ?")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "POST /admin/jobs/{name}/run")
+	if _, err := s.Trigger(ctx, "expire_points", "admin", true); err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	parent.End()
+
+	span := spanNamed(recorder.Ended(), "job expire_points")
+	if span == nil {
+		t.Fatal("Expected a span named job expire_points")
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected the job span to be a child of the request span")
+	}
+	if span.Status().Code != codes.Error || span.Status().Description != "deadlock found" {
+		t.Errorf("Expected an error status, got %+v", span.Status())
+	}
+}
+
+func TestOpenDBTracesOnlyWithinSpans(t *testing.T) {
+	recorder := record(t)
+	_, mock, err := sqlmock.NewWithDSN("sqlmock_tracing")
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	db, err := tracing.OpenDB("sqlmock", "sqlmock_tracing")
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM idempotency_keys")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM idempotency_keys")).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM idempotency_keys"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if n := len(recorder.Ended()); n != 0 {
+		t.Fatalf("Expected no spans outside a trace, got %d", n)
+	}
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "job purge_idempotency_keys")
+	if _, err := db.ExecContext(ctx, "DELETE FROM idempotency_keys"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	parent.End()
+
+	var found bool
+	for _, span := range recorder.Ended() {
+		if span.Parent().SpanID() == parent.SpanContext().SpanID() {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected a database span under the job span")
+	}
+}
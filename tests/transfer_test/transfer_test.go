@@ -0,0 +1,258 @@
+package transfer_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"loyalty-points-system-api/internal/apperrors"
+	"loyalty-points-system-api/internal/ledger"
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/service"
+	"loyalty-points-system-api/internal/utils"
+)
+
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func limits(t *testing.T, l service.TransferLimits) {
+	t.Helper()
+	service.SetTransferLimits(l)
+	t.Cleanup(func() { service.SetTransferLimits(service.TransferLimits{DailyMax: 5000}) })
+}
+
+// expectLocked expects the sender's ownership check and both members' row locks,
+// in id order.
+func expectLocked(mock sqlmock.Sqlmock, sender, recipient, senderBalance int, senderTier, recipientTier string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).WithArgs(sender).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	lock := func(id, balance int, tier string) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE")).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"loyalty_points", "tier"}).AddRow(balance, tier))
+	}
+	if sender < recipient {
+		lock(sender, senderBalance, senderTier)
+		lock(recipient, 0, recipientTier)
+	} else {
+		lock(recipient, 0, recipientTier)
+		lock(sender, senderBalance, senderTier)
+	}
+}
+
+// expectPending expects the sum of the sender's pending holds.
+func expectPending(mock sqlmock.Sqlmock, sender, pending int) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM points_holds WHERE user_id = ?")).WithArgs(sender, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(pending))
+}
+
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_hash FROM audit_chain_head")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_hash"}).AddRow(utils.AuditGenesisHash))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE audit_chain_head")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func lotRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "available", "valid_until", "expiry_policy", "expiry_days"})
+}
+
+func TestTransferDebitsOldestLotsFirst(t *testing.T) {
+	db, mock := newMock(t)
+	// The audit entries are written concurrently after the commit
+	mock.MatchExpectationsInOrder(false)
+	limits(t, service.TransferLimits{DailyMax: 1000})
+	ledger.SetExpiryRules(ledger.ExpiryRules{
+		Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365},
+		Tiers:   map[string]ledger.ExpiryPolicy{"gold": {Kind: ledger.ExpiryRolling, Days: 90}},
+	})
+	defer ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+
+	soon := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	later := soon.AddDate(0, 6, 0)
+	expectLocked(mock, 1, 2, 500, "standard", "standard")
+	expectPending(mock, 1, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(points), 0) FROM point_transfers WHERE sender_id = ? AND created_at >= ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(600))
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY transaction_date, id")).
+		WillReturnRows(lotRows().AddRow(10, 100, soon, "fixed_days", 365).AddRow(11, 300, later, "rolling", 90))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET consumed = consumed + ? WHERE id = ?")).
+		WithArgs(100, 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE points SET consumed = consumed + ? WHERE id = ?")).
+		WithArgs(150, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO point_transfers")).
+		WithArgs(sqlmock.AnyArg(), 1, 2, 250, true, "groceries", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	// Preserved expiry: one lot per debited slice, linked to the transfer
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(2, sqlmock.AnyArg(), 100, sqlmock.AnyArg(), soon, "fixed_days", 365, int64(7)).
+		WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(2, sqlmock.AnyArg(), 150, sqlmock.AnyArg(), later, "rolling", 90, int64(7)).
+		WillReturnResult(sqlmock.NewResult(21, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points - ? WHERE id = ?")).
+		WithArgs(250, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET loyalty_points = loyalty_points + ? WHERE id = ?")).
+		WithArgs(250, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Only the sender's rolling lots are kept alive
+	mock.ExpectExec(regexp.QuoteMeta("SET valid_until = GREATEST(valid_until")).
+		WithArgs(sqlmock.AnyArg(), 1, "rolling").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectAudit(mock)
+	expectAudit(mock)
+
+	resp, err := service.TransferPoints(context.Background(), db, "alice",
+		models.TransferRequest{FromUserID: 1, ToUserID: 2, Points: 250, PreserveExpiry: true, Note: "groceries"})
+	if err != nil {
+		t.Fatalf("TransferPoints failed: %v", err)
+	}
+	if !strings.HasPrefix(resp.TransferID, "TRF_") || resp.RemainingPoints != 250 || resp.PointsTransferred != 250 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if len(resp.Lots) != 2 || resp.Lots[0].Points != 100 || !resp.Lots[0].ValidUntil.Equal(soon) || resp.Lots[1].Points != 150 {
+		t.Errorf("Expected 100 then 150 points from the oldest lots, got %+v", resp.Lots)
+	}
+	if err := utils.FlushAuditLog(context.Background()); err != nil {
+		t.Fatalf("FlushAuditLog failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestLocksMembersInIDOrder(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT username FROM users WHERE id = ?")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	// Sending from 5 to 2 still locks 2 first
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT loyalty_points, tier FROM users WHERE id = ? FOR UPDATE")).WithArgs(2).
+		WillReturnError(errors.New("lock wait timeout"))
+	mock.ExpectRollback()
+
+	_, err := service.TransferPoints(context.Background(), db, "alice", models.TransferRequest{FromUserID: 5, ToUserID: 2, Points: 10})
+	if err == nil || !strings.Contains(err.Error(), "lock wait timeout") {
+		t.Errorf("Expected the lock on member 2 to be taken first, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestTransferRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  service.TransferLimits
+		balance int
+		tiers   [2]string
+		sent    int
+		pending int
+		points  int
+		code    apperrors.Code
+		detail  string
+	}{
+		{"insufficient", service.TransferLimits{}, 50, [2]string{"gold", "gold"}, 0, 0, 100, apperrors.CodePointsInsufficient, "enough points"},
+		{"held points", service.TransferLimits{}, 150, [2]string{"gold", "gold"}, 0, 100, 100, apperrors.CodePointsInsufficient, "enough points"},
+		{"minimum balance", service.TransferLimits{MinBalance: 100}, 150, [2]string{"gold", "gold"}, 0, 0, 100, apperrors.CodeTransferLimit, "at least 100 points"},
+		{"sender tier", service.TransferLimits{Tiers: []string{"gold"}}, 500, [2]string{"standard", "gold"}, 0, 0, 100, apperrors.CodeTransferNotAllowed, "tier standard cannot transfer"},
+		{"recipient tier", service.TransferLimits{Tiers: []string{"gold"}}, 500, [2]string{"gold", "standard"}, 0, 0, 100, apperrors.CodeTransferNotAllowed, "tier standard cannot receive"},
+		{"daily cap", service.TransferLimits{DailyMax: 300}, 500, [2]string{"gold", "gold"}, 250, 0, 100, apperrors.CodeTransferLimit, "300 points; 50 left today"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			limits(t, tt.limits)
+			expectLocked(mock, 1, 2, tt.balance, tt.tiers[0], tt.tiers[1])
+			if tt.code != apperrors.CodeTransferNotAllowed {
+				expectPending(mock, 1, tt.pending)
+			}
+			if tt.limits.DailyMax > 0 {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM point_transfers WHERE sender_id = ?")).
+					WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(tt.sent))
+			}
+			mock.ExpectRollback()
+
+			_, err := service.TransferPoints(context.Background(), db, "alice", models.TransferRequest{FromUserID: 1, ToUserID: 2, Points: tt.points})
+			var appErr *apperrors.Error
+			if !errors.As(err, &appErr) || appErr.Code != tt.code || !strings.Contains(appErr.Details, tt.detail) {
+				t.Fatalf("Expected %s containing %q, got %v", tt.code, tt.detail, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestTransferToSelfIsInvalid(t *testing.T) {
+	db, _ := newMock(t)
+	_, err := service.TransferPoints(context.Background(), db, "alice", models.TransferRequest{FromUserID: 1, ToUserID: 1, Points: 10})
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperrors.CodeValidationFailed || appErr.Fields[0].Field != "to_user_id" {
+		t.Errorf("Expected a to_user_id validation error, got %v", err)
+	}
+}
+
+func TestCreditWithoutPreservedExpiry(t *testing.T) {
+	db, mock := newMock(t)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 30}})
+	t.Cleanup(func() {
+		ledger.SetExpiryRules(ledger.ExpiryRules{Default: ledger.ExpiryPolicy{Kind: ledger.ExpiryFixedDays, Days: 365}})
+	})
+
+	mock.ExpectBegin()
+	// The recipient's policy applies from the transfer, whatever the slices held
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(2, "TRF_1", 80, at, at.AddDate(0, 0, 30), "fixed_days", 30, int64(3)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	never := []ledger.LotSlice{{Points: 50, Policy: ledger.ExpiryPolicy{Kind: ledger.ExpiryNever}}}
+	if err := ledger.CreditTransfer(context.Background(), tx, 2, 3, "TRF_1", 80, never, false, at); err != nil {
+		t.Fatalf("CreditTransfer failed: %v", err)
+	}
+	tx.Commit()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestPreservedExpiryCoversUnbackedPoints(t *testing.T) {
+	db, mock := newMock(t)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(2, "TRF_1", 50, at, nil, "never", 0, int64(3)).WillReturnResult(sqlmock.NewResult(1, 1))
+	// 30 of the 80 points had no lot behind them and get the default policy
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO points")).
+		WithArgs(2, "TRF_1", 30, at, at.AddDate(0, 0, 365), "fixed_days", 365, int64(3)).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	never := []ledger.LotSlice{{Points: 50, Policy: ledger.ExpiryPolicy{Kind: ledger.ExpiryNever}}}
+	if err := ledger.CreditTransfer(context.Background(), tx, 2, 3, "TRF_1", 80, never, true, at); err != nil {
+		t.Fatalf("CreditTransfer failed: %v", err)
+	}
+	tx.Commit()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
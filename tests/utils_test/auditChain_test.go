@@ -0,0 +1,90 @@
+package utils_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"loyalty-points-system-api/internal/utils"
+)
+
+func buildChain(n int) []utils.AuditEntry {
+	prev := utils.AuditGenesisHash
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var entries []utils.AuditEntry
+	for i := 1; i <= n; i++ {
+		entry := utils.AuditEntry{
+			ID:        i,
+			UserID:    i,
+			Action:    "Login",
+			Details:   "User logged in successfully",
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Minute),
+			PrevHash:  prev,
+		}
+		if i%2 == 0 {
+			entry.RequestID = fmt.Sprintf("req-%d", i)
+		}
+		entry.RowHash = utils.ComputeAuditHash(prev, entry.UserID, entry.Action, entry.Details, entry.CreatedAt, entry.RequestID)
+		prev = entry.RowHash
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func verify(entries []utils.AuditEntry) *utils.AuditBreak {
+	verifier := utils.NewAuditChainVerifier()
+	for _, entry := range entries {
+		if brk := verifier.Next(entry); brk != nil {
+			return brk
+		}
+	}
+	return nil
+}
+
+func TestAuditChainIntact(t *testing.T) {
+	legacy := utils.AuditEntry{ID: 0, UserID: 1, Action: "Login"}
+	entries := append([]utils.AuditEntry{legacy}, buildChain(5)...)
+	if brk := verify(entries); brk != nil {
+		t.Fatalf("Expected intact chain, got break at %d: %s", brk.AuditID, brk.Reason)
+	}
+}
+
+func TestAuditChainDetectsEditedRow(t *testing.T) {
+	entries := buildChain(5)
+	entries[2].Details = "Redeemed 1 points"
+	brk := verify(entries)
+	if brk == nil || brk.AuditID != 3 {
+		t.Fatalf("Expected break at audit 3, got %+v", brk)
+	}
+}
+
+func TestAuditChainDetectsEditedRequestID(t *testing.T) {
+	for _, id := range []int{1, 2} {
+		entries := buildChain(5)
+		entries[id].RequestID = "req-forged"
+		brk := verify(entries)
+		if brk == nil || brk.AuditID != id+1 {
+			t.Errorf("Expected break at audit %d, got %+v", id+1, brk)
+		}
+	}
+}
+
+func TestAuditChainDetectsDeletedRow(t *testing.T) {
+	entries := buildChain(5)
+	entries = append(entries[:1], entries[2:]...)
+	brk := verify(entries)
+	if brk == nil || brk.AuditID != 3 {
+		t.Fatalf("Expected break at audit 3, got %+v", brk)
+	}
+}
+
+func TestAuditCheckpointSignature(t *testing.T) {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sig := utils.SignAuditCheckpoint([]byte("key"), 10, "abc", createdAt)
+	if sig != utils.SignAuditCheckpoint([]byte("key"), 10, "abc", createdAt) {
+		t.Fatal("Expected signature to be deterministic")
+	}
+	if sig == utils.SignAuditCheckpoint([]byte("other"), 10, "abc", createdAt) {
+		t.Fatal("Expected signature to depend on the key")
+	}
+}
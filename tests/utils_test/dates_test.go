@@ -0,0 +1,43 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"loyalty-points-system-api/internal/utils"
+)
+
+func TestParseRangeDate(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	from, err := utils.ParseRangeDate("2024-01-01", kolkata, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := time.Date(2023, 12, 31, 18, 30, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("from = %v, want %v", from.UTC(), want)
+	}
+
+	to, err := utils.ParseRangeDate("2024-01-31", kolkata, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := time.Date(2024, 1, 31, 18, 30, 0, 0, time.UTC); !to.Equal(want) {
+		t.Errorf("to = %v, want %v", to.UTC(), want)
+	}
+
+	exact, err := utils.ParseRangeDate("2024-01-31T10:00:00-05:00", kolkata, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := time.Date(2024, 1, 31, 15, 0, 0, 0, time.UTC); !exact.Equal(want) {
+		t.Errorf("exact = %v, want %v", exact.UTC(), want)
+	}
+
+	if _, err := utils.ParseRangeDate("31/01/2024", time.UTC, false); err == nil {
+		t.Error("Expected invalid date to be rejected")
+	}
+}
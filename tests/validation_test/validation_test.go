@@ -0,0 +1,112 @@
+package validation_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "loyalty-points-system-api/internal/ledger" // registers the category rule
+	"loyalty-points-system-api/internal/models"
+	"loyalty-points-system-api/internal/validation"
+)
+
+func fields(errs validation.Errors) map[string]string {
+	out := map[string]string{}
+	for _, fe := range errs {
+		out[fe.Field] = fe.Rule
+	}
+	return out
+}
+
+func TestAddTransactionRequestValidation(t *testing.T) {
+	valid := models.AddTransactionRequest{
+		TransactionID:     "TXN1",
+		UserID:            1,
+		TransactionAmount: 49.99,
+		Category:          "groceries",
+		TransactionDate:   "2024-01-15 10:30:00",
+	}
+	if errs := validation.Validate(valid); len(errs) != 0 {
+		t.Fatalf("Expected valid request, got %v", errs)
+	}
+
+	invalid := models.AddTransactionRequest{
+		TransactionAmount: -10,
+		Category:          "toys",
+		TransactionDate:   "15/01/2024",
+		Currency:          "usd",
+		PointsPayment:     -100,
+	}
+	got := fields(validation.Validate(invalid))
+	want := map[string]string{
+		"transaction_id":     "required",
+		"user_id":            "gt",
+		"transaction_amount": "gt",
+		"category":           "category",
+		"transaction_date":   "datetime",
+		"currency":           "currency",
+		"points_payment":     "min",
+	}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("Expected %s to fail %q, got %q", field, rule, got[field])
+		}
+	}
+}
+
+func TestAddTransactionRequestRejectsFutureDates(t *testing.T) {
+	req := models.AddTransactionRequest{TransactionID: "TXN1", UserID: 1, TransactionAmount: 10, Category: "groceries"}
+	tests := []struct {
+		date  time.Time
+		valid bool
+	}{
+		{time.Now().AddDate(-1, 0, 0), true},
+		// A caller's clock may run a little ahead of the server's
+		{time.Now().Add(time.Minute), true},
+		{time.Now().Add(time.Hour), false},
+		{time.Now().AddDate(3, 0, 0), false},
+	}
+	for _, tt := range tests {
+		req.TransactionDate = tt.date.UTC().Format(time.RFC3339)
+		errs := validation.Validate(req)
+		if tt.valid && len(errs) != 0 {
+			t.Errorf("Expected %s to be valid, got %v", req.TransactionDate, errs)
+		}
+		if !tt.valid && fields(errs)["transaction_date"] != "notfuture" {
+			t.Errorf("Expected %s to be rejected as future, got %v", req.TransactionDate, errs)
+		}
+	}
+}
+
+func TestRedeemRequestRejectsNonPositivePoints(t *testing.T) {
+	for _, points := range []int{0, -50} {
+		errs := validation.Validate(models.RedeemRequest{UserID: 1, Points: points})
+		if fields(errs)["points"] != "gt" {
+			t.Errorf("Expected points=%d to be rejected, got %v", points, errs)
+		}
+	}
+}
+
+func TestCreateUserRequestValidation(t *testing.T) {
+	errs := validation.Validate(models.CreateUserRequest{Username: "  ", Password: "abc"})
+	got := fields(errs)
+	if got["username"] != "required" || got["password"] != "min" {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	// 40 two-byte characters are under 72 characters but over bcrypt's 72 bytes
+	errs = validation.Validate(models.CreateUserRequest{Username: "alice", Password: strings.Repeat("é", 40)})
+	if fields(errs)["password"] != "maxbytes" {
+		t.Errorf("Expected an 80-byte password to be rejected, got %v", errs)
+	}
+	if errs := validation.Validate(models.CreateUserRequest{Username: "alice", Password: strings.Repeat("é", 36)}); len(errs) != 0 {
+		t.Errorf("Expected a 72-byte password to be valid, got %v", errs)
+	}
+}
+
+func TestNormalizeDateTime(t *testing.T) {
+	got, err := validation.NormalizeDateTime("2024-01-15T10:30:00+05:30")
+	if err != nil || got != "2024-01-15 05:00:00" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}